@@ -38,8 +38,60 @@ type Spec struct {
 	// SSHAuthRef stores ssh key and if it is empty ,then use sshpass.
 	// +optional
 	SSHAuthRef *apis.SecretRef `json:"sshAuthRef"`
+	// SSHAuthProvider fetches the ssh credentials when the jobs start, instead of SSHAuthRef.
+	// +optional
+	SSHAuthProvider *SSHAuthProvider `json:"sshAuthProvider,omitempty"`
 	// +optional
 	PreCheckRef *apis.ConfigMapRef `json:"preCheckRef"`
+	// MaintenanceWindow restricts when the jobs of ClusterOperations may start, and they always start if it is empty.
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+	// ReconcileNodes creates the ClusterOperations of scale.yml and remove-node.yml when the hosts in HostsConfRef
+	// differ from the inventory applied by the last succeeded ClusterOperation. The nodes are removed only after
+	// they are listed in the annotation kubean.io/approve-node-removal, and they are taken out of the annotation
+	// once their removal is created.
+	// +optional
+	ReconcileNodes bool `json:"reconcileNodes,omitempty"`
+	// Tenant owns the cluster. The refs of the tenant cluster and its ClusterOperations must be in the tenant namespace,
+	// where the jobs run as the tenant ServiceAccount. Only the users allowed to `operate` the cluster in the tenant
+	// namespace can change the cluster and its ClusterOperations.
+	// +optional
+	Tenant *Tenant `json:"tenant,omitempty"`
+}
+
+// Tenant is the namespace and the ServiceAccount of the team which owns the cluster.
+type Tenant struct {
+	// Namespace stores the configs and runs the jobs of the cluster.
+	// +required
+	Namespace string `json:"namespace"`
+	// ServiceAccountName is the ServiceAccount in the namespace which the jobs run as.
+	// +required
+	ServiceAccountName string `json:"serviceAccountName"`
+}
+
+// SSHAuthProvider is the credential provider registered in kubean-operator, e.g. `file`. The credentials are
+// stored in a Secret owned by the ClusterOperation while its job runs, and deleted once it finishes.
+type SSHAuthProvider struct {
+	// Name is the registered name of the provider.
+	// +required
+	Name string `json:"name"`
+	// Parameters are passed to the provider, e.g. the role or the path of a vault.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// MaintenanceWindow opens by the cron schedule and stays open for the duration.
+type MaintenanceWindow struct {
+	// Schedule is the cron expression when the window opens, e.g. `0 22 * * 6` for 22:00 on Saturday.
+	// +required
+	Schedule string `json:"schedule"`
+	// DurationSeconds is how long the window stays open.
+	// +kubebuilder:validation:Minimum=1
+	// +required
+	DurationSeconds int64 `json:"durationSeconds"`
+	// TimeZone is the IANA time zone name of the schedule, e.g. Asia/Shanghai, and UTC is used if it is empty.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 func (spec *Spec) ConfigDataList() []*apis.ConfigMapRef {
@@ -50,18 +102,47 @@ func (spec *Spec) SecretDataList() []*apis.SecretRef {
 	return []*apis.SecretRef{spec.SSHAuthRef}
 }
 
+// ClusterConditionType is the status of the ClusterOperation in the operation history.
 type ClusterConditionType string
 
 const (
-	ClusterConditionCreating ClusterConditionType = "Running"
+	ClusterOpsPending   ClusterConditionType = "Pending"
+	ClusterOpsRunning   ClusterConditionType = "Running"
+	ClusterOpsSucceeded ClusterConditionType = "Succeeded"
+	ClusterOpsFailed    ClusterConditionType = "Failed"
+	ClusterOpsCancelled ClusterConditionType = "Cancelled"
 
+	// Deprecated: use ClusterOpsRunning.
+	ClusterConditionCreating ClusterConditionType = "Running"
+	// Deprecated: use ClusterOpsSucceeded.
 	ClusterConditionRunning ClusterConditionType = "Succeeded"
-
+	// Deprecated: use ClusterOpsFailed.
 	ClusterConditionUpdating ClusterConditionType = "Failed"
+	// Deprecated: use ClusterOpsCancelled.
+	ClusterConditionCancelled ClusterConditionType = "Cancelled"
 
 	BlockedStatus ClusterConditionType = "Blocked"
 )
 
+// The types of the standard conditions of Cluster.
+const (
+	// ProvisionedCondition is True after cluster.yml succeeded and until reset.yml succeeds.
+	ProvisionedCondition = "Provisioned"
+	// ReachableCondition is whether the apiserver of the cluster can be connected with the kubeconfig in KubeConfRef.
+	ReachableCondition = "Reachable"
+	// APIServerHealthyCondition is whether the apiserver of the cluster reports ready.
+	APIServerHealthyCondition = "APIServerHealthy"
+	// CertificatesValidCondition is whether the certificates in the kubeconfig of the cluster have not expired.
+	CertificatesValidCondition = "CertificatesValid"
+	// UpgradeInProgressCondition is True while a ClusterOperation of upgrade-cluster.yml is running.
+	UpgradeInProgressCondition = "UpgradeInProgress"
+	// InventoryValidCondition is whether the ansible inventory in HostsConfRef is parsed and valid.
+	InventoryValidCondition = "InventoryValid"
+	// NodesReconciledCondition is whether the hosts in HostsConfRef have been applied, and it is only set when ReconcileNodes is enabled.
+	NodesReconciledCondition = "NodesReconciled"
+)
+
+// ClusterCondition is one ClusterOperation in the operation history of the cluster.
 type ClusterCondition struct {
 	// ClusterOps refers to the name of ClusterOperation.
 	// +required
@@ -77,7 +158,72 @@ type ClusterCondition struct {
 // Status contains information about the current status of a
 // cluster updated periodically by cluster controller.
 type Status struct {
-	Conditions []ClusterCondition `json:"conditions"`
+	// Conditions describe the actual state of the cluster.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// OperationHistory records the ClusterOperations of the cluster, the latest first.
+	// +optional
+	OperationHistory []ClusterCondition `json:"operationHistory,omitempty"`
+	// Health is observed by connecting to the cluster with the kubeconfig in KubeConfRef.
+	// +optional
+	Health *ClusterHealth `json:"health,omitempty"`
+	// Nodes are parsed from the ansible inventory in HostsConfRef.
+	// +optional
+	Nodes []NodeInfo `json:"nodes,omitempty"`
+}
+
+// NodeInfo is one host of the ansible inventory.
+type NodeInfo struct {
+	// Name is the inventory hostname.
+	// +required
+	Name string `json:"name"`
+	// AnsibleHost is the address ansible connects to.
+	// +optional
+	AnsibleHost string `json:"ansibleHost,omitempty"`
+	// IP is the address kubernetes binds to.
+	// +optional
+	IP string `json:"ip,omitempty"`
+	// Roles are the groups of kube_control_plane, kube_node and etcd which the host belongs to.
+	// +optional
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ClusterHealth is the state of the cluster reported by its apiserver.
+type ClusterHealth struct {
+	// KubernetesVersion is the git version of the apiserver.
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// Nodes is the number of the nodes.
+	// +optional
+	Nodes int32 `json:"nodes,omitempty"`
+	// ReadyNodes is the number of the nodes whose Ready condition is True.
+	// +optional
+	ReadyNodes int32 `json:"readyNodes,omitempty"`
+	// Components are the checks listed by `/readyz?verbose` of the apiserver.
+	// +optional
+	Components []ComponentHealth `json:"components,omitempty"`
+	// LastProbeTime is when the cluster was probed last time.
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+	// LastSeenTime is when the cluster was connected successfully last time.
+	// +optional
+	LastSeenTime *metav1.Time `json:"lastSeenTime,omitempty"`
+	// ProbeError is the error of the last probe, and it is empty if the last probe succeeded.
+	// +optional
+	ProbeError string `json:"probeError,omitempty"`
+}
+
+// ComponentHealth is one check of the apiserver readiness, e.g. etcd or informer-sync.
+type ComponentHealth struct {
+	// +required
+	Name string `json:"name"`
+	// +required
+	Healthy bool `json:"healthy"`
+	// Message is the reason of the failed check.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
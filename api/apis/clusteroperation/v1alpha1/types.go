@@ -68,7 +68,10 @@ type Spec struct {
 	ActionSourceRef *apis.ConfigMapRef `json:"actionSourceRef,omitempty"`
 	// +optional
 	ExtraArgs string `json:"extraArgs"`
-	// +required
+	// Image is the spray-job image. It is defaulted by kubean-admission to the image of the Manifest
+	// whose sprayRelease matches the cluster, and the tag is added if missing, which is the configured
+	// sprayJob.image.tag if any.
+	// +optional
 	Image string `json:"image"`
 	// +optional
 	PreHook []HookAction `json:"preHook"`
@@ -78,6 +81,41 @@ type Spec struct {
 	Resources corev1.ResourceRequirements `json:"resources"`
 	// +optional
 	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// Cancel stops the clusterOps. The running job is suspended and ansible receives SIGTERM.
+	// +optional
+	Cancel bool `json:"cancel,omitempty"`
+	// RetryPolicy retries the failed job. The job runs only once if not set.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+	// Priority orders the Pending clusterOps of the same cluster, the higher runs first.
+	// A Pending clusterOps also preempts the running one with lower priority unless
+	// its action is configured as non-preemptible.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// DryRun runs the playbooks with `--check --diff` and skips the shell hooks which are not dryRunSafe.
+	// The dry-run clusterOps does not block other clusterOps of the same cluster.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RetryPolicy describes when and how a failed job is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of jobs, including the first one.
+	// +kubebuilder:validation:Minimum=1
+	// +required
+	MaxAttempts int32 `json:"maxAttempts"`
+	// BackoffSeconds is the delay between a failed job and the next attempt.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffSeconds int32 `json:"backoffSeconds,omitempty"`
+	// RetryableStages are the names of stages such as prehook-0, action or posthook-1
+	// which are retried when failed. All stages are retryable if empty.
+	// +optional
+	RetryableStages []string `json:"retryableStages,omitempty"`
+	// OnlyFailedHosts limits the playbooks of the next attempt to the hosts which failed
+	// or were unreachable, the same as `--limit @retry_file`.
+	// +optional
+	OnlyFailedHosts bool `json:"onlyFailedHosts,omitempty"`
 }
 
 func (spec *Spec) ConfigDataList() []*apis.ConfigMapRef {
@@ -107,16 +145,128 @@ type HookAction struct {
 	ActionSourceRef *apis.ConfigMapRef `json:"actionSourceRef,omitempty"`
 	// +optional
 	ExtraArgs string `json:"extraArgs"`
+	// DryRunSafe marks the shell hook which changes nothing, so that it still runs in dry run.
+	// +optional
+	DryRunSafe bool `json:"dryRunSafe,omitempty"`
 }
 
 type OpsStatus string
 
 const (
+	PendingStatus   OpsStatus = "Pending"
 	RunningStatus   OpsStatus = "Running"
 	SucceededStatus OpsStatus = "Succeeded"
 	FailedStatus    OpsStatus = "Failed"
+	CancelledStatus OpsStatus = "Cancelled"
+	// PendingApprovalStatus holds the clusterOps whose action requires approval until another user approves it.
+	PendingApprovalStatus OpsStatus = "PendingApproval"
+	// PreemptedStatus is only used by Attempt, the clusterOps itself goes back to Pending.
+	PreemptedStatus OpsStatus = "Preempted"
+)
+
+// Stage describes the progress of one step (preHook, action or postHook) in the spray job.
+type Stage struct {
+	// Name is the stage identifier such as prehook-0, action or posthook-1.
+	// +required
+	Name string `json:"name"`
+	// +optional
+	ActionType ActionType `json:"actionType,omitempty"`
+	// Action is the playbook name or the first line of the shell action.
+	// +optional
+	Action string `json:"action,omitempty"`
+	// +optional
+	Status OpsStatus `json:"status,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+}
+
+// FailureReason summarizes why the spray job failed.
+type FailureReason struct {
+	// Stage is the name of the first failed stage.
+	// +optional
+	Stage string `json:"stage,omitempty"`
+	// Task is the name of the last failed ansible task.
+	// +optional
+	Task string `json:"task,omitempty"`
+	// Hosts are the hosts on which the task failed.
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+	// Message is the truncated error message of the failed task.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type LogBackend string
+
+const (
+	ConfigMapLogBackend LogBackend = "ConfigMap"
+	PVCLogBackend       LogBackend = "PVC"
 )
 
+// LogRef points to the archived and gzip compressed log of the spray job.
+type LogRef struct {
+	// +required
+	Backend LogBackend `json:"backend"`
+	// +optional
+	NameSpace string `json:"namespace,omitempty"`
+	// Name is the name prefix of configmaps or the name of the persistentVolumeClaim.
+	// +required
+	Name string `json:"name"`
+	// Chunks is the number of configmaps named {name}-{index} which store the log in order.
+	// +optional
+	Chunks int32 `json:"chunks,omitempty"`
+	// Path is the file path of the log in the persistentVolumeClaim.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// HostSummary is the task counts of one host summed from the PLAY RECAP of every playbook.
+type HostSummary struct {
+	// +required
+	Host string `json:"host"`
+	// +optional
+	Ok int32 `json:"ok"`
+	// +optional
+	Changed int32 `json:"changed"`
+	// +optional
+	Unreachable int32 `json:"unreachable"`
+	// +optional
+	Failed int32 `json:"failed"`
+	// +optional
+	Skipped int32 `json:"skipped"`
+}
+
+// Attempt records one job of the clusterOps.
+type Attempt struct {
+	// Index starts from 1.
+	// +required
+	Index int32 `json:"index"`
+	// +optional
+	JobRef *apis.JobRef `json:"jobRef,omitempty"`
+	// +optional
+	Status OpsStatus `json:"status,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+	// ResumeStage is the stage the attempt starts from, the stages before it are skipped.
+	// +optional
+	ResumeStage string `json:"resumeStage,omitempty"`
+	// LimitHosts are the hosts which the playbooks of the resumed stage are limited to.
+	// +optional
+	LimitHosts []string `json:"limitHosts,omitempty"`
+	// FailureReason will be filled by operator when the job of the attempt failed.
+	// +optional
+	FailureReason *FailureReason `json:"failureReason,omitempty"`
+	// PreemptedBy is the name of the clusterOps which preempted the attempt.
+	// +optional
+	PreemptedBy string `json:"preemptedBy,omitempty"`
+}
+
 // Status contains information about the current status of a
 // cluster operation job updated periodically by cluster controller.
 type Status struct {
@@ -130,12 +280,50 @@ type Status struct {
 	StartTime *metav1.Time `json:"startTime,omitempty"`
 	// +optional
 	EndTime *metav1.Time `json:"endTime,omitempty"`
-	// Digest is used to avoid the change of clusterOps by others. it will be filled by operator. Do Not change this value.
+	// Digest is the sha256 of the entrypoint and the configs referenced by the clusterOps, in the format of sha256:<hex>.
+	// it will be filled by operator after the entrypoint is rendered. Do Not change this value.
 	// +optional
 	Digest string `json:"digest,omitempty"`
 	// HasModified indicates the spec has been modified by others after created.
+	// Deprecated: the spec is immutable and enforced by kubean-admission, this field is no longer set.
 	// +optional
 	HasModified bool `json:"hasModified,omitempty"`
+	// Stages records the progress of preHooks, the main action and postHooks in execution order.
+	// +optional
+	Stages []Stage `json:"stages,omitempty"`
+	// FailureReason will be filled by operator when the job failed.
+	// +optional
+	FailureReason *FailureReason `json:"failureReason,omitempty"`
+	// LogRef will be filled by operator after the job log is archived.
+	// +optional
+	LogRef *LogRef `json:"logRef,omitempty"`
+	// CancelledBy is the user who set spec.cancel.
+	// +optional
+	CancelledBy string `json:"cancelledBy,omitempty"`
+	// CancelledTime is the time when spec.cancel was set.
+	// +optional
+	CancelledTime *metav1.Time `json:"cancelledTime,omitempty"`
+	// ApprovedBy is the user who approved the clusterOps whose action requires approval.
+	// +optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+	// ApprovalRequestedTime is the time when the clusterOps entered PendingApproval, from which the approval timeout is measured.
+	// +optional
+	ApprovalRequestedTime *metav1.Time `json:"approvalRequestedTime,omitempty"`
+	// Attempts records every job of the clusterOps in order, including the ones retried by RetryPolicy and the ones resumed after preemption.
+	// +optional
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// QueuePosition is the number of clusterOps of the same cluster which run before this Pending clusterOps.
+	// +optional
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+	// BlockedBy is the name of the clusterOps which this Pending clusterOps is waiting for.
+	// +optional
+	BlockedBy string `json:"blockedBy,omitempty"`
+	// Message explains why the job has not been created yet, e.g. waiting for the maintenance window of the cluster.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// HostSummaries will be filled by operator when the dry-run job completed.
+	// +optional
+	HostSummaries []HostSummary `json:"hostSummaries,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
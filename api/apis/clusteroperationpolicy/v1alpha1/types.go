@@ -0,0 +1,64 @@
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:printcolumn:JSONPath=`.spec.clusters`,name="Clusters",type=string
+// +kubebuilder:printcolumn:JSONPath=`.metadata.creationTimestamp`,name="Age",type=date
+
+// ClusterOperationPolicy allows the subjects to create the ClusterOperations of the clusters which run the listed actions.
+// Once a cluster is selected by any policy, the ClusterOperation of the cluster is admitted only if one of the policies
+// binds the user and allows all of its actions.
+type ClusterOperationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +required
+	Spec Spec `json:"spec"`
+}
+
+// Spec defines who may run which actions on which clusters.
+// The empty list of the actionTypes, playbooks, actionSources and imageRegistries does not limit that field, but the extraArgs
+// of the playbooks never runs other playbooks or contains the shell metacharacters.
+type Spec struct {
+	// Clusters are the names of the clusters which the policy applies to, and it applies to all the clusters if it is empty.
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+	// Subjects are the users, the groups and the ServiceAccounts which the policy binds. The ClusterOperations created by
+	// a ClusterOperationSchedule are checked as the user who created or last updated the schedule, and kubean-operator
+	// can not be bound.
+	// +required
+	Subjects []rbacv1.Subject `json:"subjects"`
+	// ActionTypes are the allowed actionType of the action and the hooks, e.g. playbook.
+	// +optional
+	ActionTypes []clusteroperationv1alpha1.ActionType `json:"actionTypes,omitempty"`
+	// Playbooks are the allowed builtin playbooks of the action and the hooks, e.g. scale.yml. If it is set, the shell
+	// and the playbooks of the configmap are not allowed either.
+	// +optional
+	Playbooks []string `json:"playbooks,omitempty"`
+	// ActionSources are the allowed actionSource of the action and the hooks, e.g. builtin.
+	// +optional
+	ActionSources []clusteroperationv1alpha1.ActionSource `json:"actionSources,omitempty"`
+	// ImageRegistries are the allowed registries of the spray job image, e.g. ghcr.m.daocloud.io.
+	// +optional
+	ImageRegistries []string `json:"imageRegistries,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterOperationPolicyList contains a list of ClusterOperationPolicy.
+type ClusterOperationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items holds a list of ClusterOperationPolicy.
+	Items []ClusterOperationPolicy `json:"items"`
+}
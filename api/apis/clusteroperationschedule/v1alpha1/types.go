@@ -0,0 +1,120 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:JSONPath=`.spec.schedule`,name="Schedule",type=string
+// +kubebuilder:printcolumn:JSONPath=`.status.lastScheduleTime`,name="LastSchedule",type=date
+// +kubebuilder:printcolumn:JSONPath=`.metadata.creationTimestamp`,name="Age",type=date
+
+// ClusterOperationSchedule creates ClusterOperations from the template by the cron schedule.
+type ClusterOperationSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +required
+	Spec Spec `json:"spec"`
+
+	// +optional
+	Status Status `json:"status,omitempty"`
+}
+
+// ConcurrencyPolicy describes how to treat the ClusterOperation created by the last schedule which is still running.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent creates the ClusterOperation anyway, and it is rejected or queued by the admission webhook.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the schedule if the last ClusterOperation has not finished.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent cancels the running ClusterOperation and creates the new one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// Spec defines when and how to create ClusterOperations.
+type Spec struct {
+	// Schedule is the cron expression in five fields, e.g. `0 3 * * 0` for 03:00 on Sunday.
+	// +required
+	Schedule string `json:"schedule"`
+	// TimeZone is the IANA time zone name of the schedule, and UTC is used if it is empty.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// StartingDeadlineSeconds is how late a missed schedule may still be started, and the missed schedule is always started if it is empty.
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// ConcurrencyPolicy is one of Allow, Forbid and Replace.
+	// +optional
+	// +kubebuilder:default="Forbid"
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// Suspend stops creating ClusterOperations and does not affect the created ones.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// SuccessfulHistoryLimit is how many Succeeded ClusterOperations to keep.
+	// +optional
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=0
+	SuccessfulHistoryLimit *int32 `json:"successfulHistoryLimit,omitempty"`
+	// FailedHistoryLimit is how many Failed or Cancelled ClusterOperations to keep.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	FailedHistoryLimit *int32 `json:"failedHistoryLimit,omitempty"`
+	// Template is the ClusterOperation to create.
+	// +required
+	Template ClusterOperationTemplate `json:"template"`
+}
+
+// ClusterOperationTemplate describes the ClusterOperation created by the schedule.
+type ClusterOperationTemplate struct {
+	// +optional
+	Metadata TemplateMeta `json:"metadata,omitempty"`
+	// +required
+	Spec clusteroperationv1alpha1.Spec `json:"spec"`
+}
+
+// TemplateMeta is the labels and annotations copied to the created ClusterOperation.
+type TemplateMeta struct {
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Status records the ClusterOperations created by the schedule.
+type Status struct {
+	// Active is the names of the created ClusterOperations which have not finished.
+	// +optional
+	Active []string `json:"active,omitempty"`
+	// LastScheduleTime is the time of the last schedule which created a ClusterOperation.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastSuccessfulTime is the time when the last Succeeded ClusterOperation finished.
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// LastOperation is the name of the ClusterOperation created most recently.
+	// +optional
+	LastOperation string `json:"lastOperation,omitempty"`
+	// LastOperationStatus is the status of the LastOperation.
+	// +optional
+	LastOperationStatus clusteroperationv1alpha1.OpsStatus `json:"lastOperationStatus,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterOperationScheduleList contains a list of ClusterOperationSchedule.
+type ClusterOperationScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items holds a list of ClusterOperationSchedule.
+	Items []ClusterOperationSchedule `json:"items"`
+}
@@ -1,14 +1,142 @@
 package cluster
 
 import (
+	"fmt"
 	"github.com/kubean-io/kubean-api/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 	"strconv"
+	"strings"
 )
 
 type ConfigProperty struct {
 	ClusterOperationsBackEndLimit string `json:"CLUSTER_OPERATIONS_BACKEND_LIMIT"`
 	SprayJobImageRegistry         string `json:"SPRAY_JOB_IMAGE_REGISTRY"`
+	LogArchiveBackend             string `json:"LOG_ARCHIVE_BACKEND"`
+	LogArchivePVC                 string `json:"LOG_ARCHIVE_PVC"`
+	ClusterOperationQueueMode     string `json:"CLUSTER_OPERATION_QUEUE_MODE"`
+	NonPreemptibleActions         string `json:"NON_PREEMPTIBLE_ACTIONS"`
+	SprayJobImageRepository       string `json:"SPRAY_JOB_IMAGE_REPOSITORY"`
+	SprayJobImageTag              string `json:"SPRAY_JOB_IMAGE_TAG"`
+	ActiveDeadlineSeconds         string `json:"CLUSTER_OPERATION_ACTIVE_DEADLINE_SECONDS"`
+	SprayJobCPURequest            string `json:"SPRAY_JOB_CPU_REQUEST"`
+	SprayJobMemoryRequest         string `json:"SPRAY_JOB_MEMORY_REQUEST"`
+	ApprovalRequiredActions       string `json:"APPROVAL_REQUIRED_ACTIONS"`
+	ApprovalTimeoutSeconds        string `json:"APPROVAL_TIMEOUT_SECONDS"`
+	AuditRetentionDays            string `json:"AUDIT_RETENTION_DAYS"`
+}
+
+// GetSprayJobImage returns the spray-job image without tag, which is defaulted to the ClusterOperation.
+func (config *ConfigProperty) GetSprayJobImage() string {
+	registry, repository := strings.TrimSpace(config.SprayJobImageRegistry), strings.TrimSpace(config.SprayJobImageRepository)
+	if registry == "" {
+		registry = constants.DefaultSprayJobImageRegistry
+	}
+	if repository == "" {
+		repository = constants.DefaultSprayJobImageRepository
+	}
+	return fmt.Sprintf("%s/%s", registry, repository)
+}
+
+// GetSprayJobImageTag returns the configured tag of the spray-job image, and empty if not set.
+func (config *ConfigProperty) GetSprayJobImageTag() string {
+	return strings.TrimSpace(config.SprayJobImageTag)
+}
+
+// GetActiveDeadlineSeconds returns the default activeDeadlineSeconds of the ClusterOperation, and nil if not set.
+func (config *ConfigProperty) GetActiveDeadlineSeconds() *int64 {
+	value, err := strconv.ParseInt(strings.TrimSpace(config.ActiveDeadlineSeconds), 10, 64)
+	if err != nil || value <= 0 {
+		return nil
+	}
+	return &value
+}
+
+// GetSprayJobResourceRequests returns the default resource requests of the spray job, and ignores the invalid quantities.
+func (config *ConfigProperty) GetSprayJobResourceRequests() corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    config.SprayJobCPURequest,
+		corev1.ResourceMemory: config.SprayJobMemoryRequest,
+	} {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(strings.TrimSpace(value))
+		if err != nil {
+			klog.Warningf("GetSprayJobResourceRequests but %s request %q is invalid, %v", name, value, err)
+			continue
+		}
+		requests[name] = quantity
+	}
+	return requests
+}
+
+// GetNonPreemptibleActions returns the actions which are never preempted by a higher priority ClusterOperation.
+func (config *ConfigProperty) GetNonPreemptibleActions() []string {
+	value := config.NonPreemptibleActions
+	if strings.TrimSpace(value) == "" {
+		value = constants.DefaultNonPreemptibleActions
+	}
+	actions := make([]string, 0)
+	for _, action := range strings.Split(value, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// GetApprovalRequiredActions returns the actions which run only after a user other than the creator approves them.
+func (config *ConfigProperty) GetApprovalRequiredActions() []string {
+	actions := make([]string, 0)
+	for _, action := range strings.Split(config.ApprovalRequiredActions, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// GetApprovalTimeoutSeconds returns how long the ClusterOperation waits for the approval after it entered PendingApproval.
+func (config *ConfigProperty) GetApprovalTimeoutSeconds() int64 {
+	value, err := strconv.ParseInt(strings.TrimSpace(config.ApprovalTimeoutSeconds), 10, 64)
+	if err != nil || value <= 0 {
+		return constants.DefaultApprovalTimeoutSeconds
+	}
+	return value
+}
+
+// GetAuditRetentionDays returns how long the audit records of the ClusterOperations are kept.
+func (config *ConfigProperty) GetAuditRetentionDays() int {
+	value, err := strconv.Atoi(strings.TrimSpace(config.AuditRetentionDays))
+	if err != nil || value <= 0 {
+		return constants.DefaultAuditRetentionDays
+	}
+	return value
+}
+
+// IsClusterOperationQueueMode returns whether the webhook admits concurrent ClusterOperations and queues them.
+func (config *ConfigProperty) IsClusterOperationQueueMode() bool {
+	value, _ := strconv.ParseBool(strings.TrimSpace(config.ClusterOperationQueueMode))
+	return value
+}
+
+// GetLogArchiveBackend returns configmap, pvc or none.
+func (config *ConfigProperty) GetLogArchiveBackend() string {
+	switch strings.ToLower(strings.TrimSpace(config.LogArchiveBackend)) {
+	case constants.LogArchiveBackendNone:
+		return constants.LogArchiveBackendNone
+	case constants.LogArchiveBackendPVC:
+		if config.LogArchivePVC == "" {
+			klog.Warningf("GetLogArchiveBackend but %s is empty and use %s", "LOG_ARCHIVE_PVC", constants.LogArchiveBackendConfigMap)
+			return constants.LogArchiveBackendConfigMap
+		}
+		return constants.LogArchiveBackendPVC
+	default:
+		return constants.LogArchiveBackendConfigMap
+	}
 }
 
 func (config *ConfigProperty) GetClusterOperationsBackEndLimit() int {
@@ -5,6 +5,11 @@ const (
 
 	KubeanClusterLabelKey = "clusterName"
 
+	// KubeanConfigTypeLabelKey labels the ConfigMaps which are validated by kubean-admission as the inventory or the vars.
+	KubeanConfigTypeLabelKey = "kubean.io/config-type"
+	ConfigTypeInventory      = "inventory"
+	ConfigTypeVars           = "vars"
+
 	Hosts_yml = "hosts.yml"
 
 	Group_vars_yml = "group_vars.yml"
@@ -19,4 +24,17 @@ const (
 	KubeanConfigMapName                  = "kubean-config"
 	DefaultClusterOperationsBackEndLimit = 30
 	MaxClusterOperationsBackEndLimit     = 200
+
+	LogArchiveBackendConfigMap = "configmap"
+	LogArchiveBackendPVC       = "pvc"
+	LogArchiveBackendNone      = "none"
+
+	DefaultNonPreemptibleActions = "upgrade-cluster.yml,reset.yml"
+
+	DefaultApprovalTimeoutSeconds = 24 * 60 * 60
+
+	DefaultAuditRetentionDays = 365
+
+	DefaultSprayJobImageRegistry   = "ghcr.io"
+	DefaultSprayJobImageRepository = "kubean-io/spray-job"
 )
@@ -10,6 +10,8 @@ bash "$API_REPO_ROOT/hack/update-codegen.sh" cluster
 bash "$API_REPO_ROOT/hack/update-codegen.sh" clusteroperation
 bash "$API_REPO_ROOT/hack/update-codegen.sh" manifest
 bash "$API_REPO_ROOT/hack/update-codegen.sh" localartifactset
+bash "$API_REPO_ROOT/hack/update-codegen.sh" clusteroperationschedule
+bash "$API_REPO_ROOT/hack/update-codegen.sh" clusteroperationpolicy
 bash "$API_REPO_ROOT/hack/update-crdgen.sh"
 
 go mod tidy
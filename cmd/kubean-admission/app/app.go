@@ -8,7 +8,10 @@ import (
 	"flag"
 	"fmt"
 
+	kubeanClusterClientSet "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned"
 	kubeanClusterOperationClientSet "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned"
+	kubeanClusterOperationPolicyClientSet "github.com/kubean-io/kubean-api/generated/clusteroperationpolicy/clientset/versioned"
+	kubeanManifestClientSet "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned"
 	"github.com/kubean-io/kubean/pkg/version"
 	clusteropswebhook "github.com/kubean-io/kubean/pkg/webhooks/clusterops"
 
@@ -59,10 +62,22 @@ func Run(ctx context.Context, opt *Options) error {
 	if err != nil {
 		return err
 	}
+	clusterClientSet, err := kubeanClusterClientSet.NewForConfig(resetConfig)
+	if err != nil {
+		return err
+	}
 	clusterClientOperationSet, err := kubeanClusterOperationClientSet.NewForConfig(resetConfig)
 	if err != nil {
 		return err
 	}
+	manifestClientSet, err := kubeanManifestClientSet.NewForConfig(resetConfig)
+	if err != nil {
+		return err
+	}
+	clusterOperationPolicyClientSet, err := kubeanClusterOperationPolicyClientSet.NewForConfig(resetConfig)
+	if err != nil {
+		return err
+	}
 	go func() {
 		clusteropswebhook.CreateHTTPSCASecretWithLock(ctx, ClientSet)
 	}()
@@ -70,6 +85,6 @@ func Run(ctx context.Context, opt *Options) error {
 	if err := clusteropswebhook.CreateHTTPSCAFilesFromSecret(CASecret); err != nil {
 		return err
 	}
-	clusteropswebhook.StartWebHookHTTPSServer(clusteropswebhook.PrepareWebHookHTTPSServer(clusterClientOperationSet))
+	clusteropswebhook.StartWebHookHTTPSServer(clusteropswebhook.PrepareWebHookHTTPSServer(ClientSet, clusterClientSet, clusterClientOperationSet, manifestClientSet, clusterOperationPolicyClientSet))
 	return fmt.Errorf("admission has exited")
 }
@@ -12,10 +12,12 @@ import (
 	manifestv1alpha1 "github.com/kubean-io/kubean-api/apis/manifest/v1alpha1"
 	kubeanClusterClientSet "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned"
 	kubeanClusterOperationClientSet "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned"
+	kubeanClusterOperationScheduleClientSet "github.com/kubean-io/kubean-api/generated/clusteroperationschedule/clientset/versioned"
 	kubeanLocalArtifactSetClientSet "github.com/kubean-io/kubean-api/generated/localartifactset/clientset/versioned"
 	kubeaninfomanifestClientSet "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned"
 	"github.com/kubean-io/kubean/pkg/controllers/cluster"
 	"github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/controllers/clusteropsschedule"
 	"github.com/kubean-io/kubean/pkg/controllers/infomanifest"
 	"github.com/kubean-io/kubean/pkg/controllers/offlineversion"
 	"github.com/kubean-io/kubean/pkg/util"
@@ -30,6 +32,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
+// EventSourceName is the component of the events recorded by the operator.
+const EventSourceName = "kubean-operator"
+
 func NewCommand(ctx context.Context) *cobra.Command {
 	opts := NewOptions()
 	cmd := &cobra.Command{
@@ -78,6 +83,7 @@ func StartManager(ctx context.Context, opt *Options) error {
 		LeaderElectionResourceLock: opt.LeaderElection.ResourceLock,
 		HealthProbeBindAddress:     net.JoinHostPort(opt.BindAddress, strconv.Itoa(opt.SecurePort)),
 		LivenessEndpointName:       "/healthz",
+		MetricsBindAddress:         opt.MetricsBindAddress,
 		Namespace:                  util.GetCurrentNSOrDefault(),
 	})
 	if err != nil {
@@ -120,6 +126,10 @@ func setupManager(mgr controllerruntime.Manager, opt *Options, stopChan <-chan s
 	if err != nil {
 		return err
 	}
+	clusterOperationScheduleClientSet, err := kubeanClusterOperationScheduleClientSet.NewForConfig(resetConfig)
+	if err != nil {
+		return err
+	}
 	infomanifestClientSet, err := kubeaninfomanifestClientSet.NewForConfig(resetConfig)
 	if err != nil {
 		return err
@@ -128,11 +138,14 @@ func setupManager(mgr controllerruntime.Manager, opt *Options, stopChan <-chan s
 	if err != nil {
 		return err
 	}
+	// the events of the cluster-scoped Cluster and ClusterOperation are recorded in the default namespace.
+	eventRecorder := mgr.GetEventRecorderFor(EventSourceName)
 	clusterController := &cluster.Controller{
 		Client:              mgr.GetClient(),
 		ClientSet:           ClientSet,
 		KubeanClusterSet:    clusterClientSet,
 		KubeanClusterOpsSet: clusterClientOperationSet,
+		EventRecorder:       eventRecorder,
 	}
 	// the message type
 	if err := clusterController.SetupWithManager(mgr); err != nil {
@@ -145,11 +158,20 @@ func setupManager(mgr controllerruntime.Manager, opt *Options, stopChan <-chan s
 		KubeanClusterSet:      clusterClientSet,
 		KubeanClusterOpsSet:   clusterClientOperationSet,
 		InfoManifestClientSet: infomanifestClientSet,
+		EventRecorder:         eventRecorder,
 	}
 	if err := clusterOpsController.SetupWithManager(mgr); err != nil {
 		klog.Errorf("ControllerManager ClusterOps but %s", err)
 		return err
 	}
+	clusterOpsScheduleController := &clusteropsschedule.Controller{
+		KubeanClusterOpsSet:         clusterClientOperationSet,
+		KubeanClusterOpsScheduleSet: clusterOperationScheduleClientSet,
+	}
+	if err := clusterOpsScheduleController.SetupWithManager(mgr); err != nil {
+		klog.Errorf("ControllerManager ClusterOpsSchedule but %s", err)
+		return err
+	}
 
 	offlineVersionController := &offlineversion.Controller{
 		Client:                    mgr.GetClient(),
@@ -13,8 +13,9 @@ import (
 )
 
 const (
-	defaultBindAddress = "0.0.0.0"
-	defaultPort        = 20358
+	defaultBindAddress        = "0.0.0.0"
+	defaultPort               = 20358
+	defaultMetricsBindAddress = ":8080"
 )
 
 type Options struct {
@@ -23,6 +24,8 @@ type Options struct {
 	BindAddress string
 	// SecurePort is the port that the server serves at.
 	SecurePort int
+	// MetricsBindAddress is the address that the prometheus metrics are served at.
+	MetricsBindAddress string
 
 	KubeAPIQPS float32
 	// KubeAPIBurst is the burst to allow while talking with kubean-apiserver.
@@ -47,6 +50,8 @@ func (o *Options) AddFlags(flags *pflag.FlagSet) {
 		"The IP address on which to listen for the --secure-port port.")
 	flags.IntVar(&o.SecurePort, "secure-port", defaultPort,
 		"The secure port on which to serve HTTPS.")
+	flags.StringVar(&o.MetricsBindAddress, "metrics-bind-address", defaultMetricsBindAddress,
+		"The TCP address on which to serve the prometheus metrics, \"0\" disables it.")
 	flags.BoolVar(&o.LeaderElection.LeaderElect, "leader-elect", true, "Start a leader election client and gain leadership before executing the main loop. Enable this when running replicated components for high availability.")
 	flags.Float32Var(&o.KubeAPIQPS, "kube-api-qps", 100.0, "QPS to use while talking with kubean-apiserver. Doesn't cover events and node heartbeat apis which rate limiting is controlled by a different set of flags.")
 	flags.IntVar(&o.KubeAPIBurst, "kube-api-burst", 100, "Burst to use while talking with kubean-apiserver. Doesn't cover events and node heartbeat apis which rate limiting is controlled by a different set of flags.")
@@ -0,0 +1,249 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// KubeConfigDataKey is the key of the kubeconfig in the ConfigMap of KubeConfRef.
+	KubeConfigDataKey = "config"
+	// CertificateExpiringThreshold is how long before the expiration the certificates are reported as ExpiringSoon.
+	CertificateExpiringThreshold = 30 * 24 * time.Hour
+)
+
+// The reasons of the standard conditions of Cluster.
+const (
+	ClusterCreatedReason        = "ClusterCreated"
+	ClusterResetReason          = "ClusterReset"
+	ProvisioningReason          = "Provisioning"
+	NotProvisionedReason        = "NotProvisioned"
+	UpgradingReason             = "Upgrading"
+	NoUpgradeReason             = "NoUpgrade"
+	KubeConfRefNotSetReason     = "KubeConfRefNotSet"
+	KubeConfigUnavailableReason = "KubeConfigUnavailable"
+	NoCertificatesReason        = "NoCertificates"
+	CertificatesExpiredReason   = "Expired"
+	CertificatesExpiringReason  = "ExpiringSoon"
+	CertificatesValidReason     = "Valid"
+	NotProbedReason             = "NotProbed"
+	ConnectedReason             = "Connected"
+	UnreachableReason           = "Unreachable"
+	APIServerReadyReason        = "Ready"
+	ComponentsUnhealthyReason   = "ComponentsUnhealthy"
+)
+
+var knownConditionTypes = map[string]struct{}{
+	clusterv1alpha1.ProvisionedCondition:       {},
+	clusterv1alpha1.ReachableCondition:         {},
+	clusterv1alpha1.APIServerHealthyCondition:  {},
+	clusterv1alpha1.CertificatesValidCondition: {},
+	clusterv1alpha1.UpgradeInProgressCondition: {},
+	clusterv1alpha1.InventoryValidCondition:    {},
+	clusterv1alpha1.NodesReconciledCondition:   {},
+}
+
+// knownConditions copies the conditions of the known types, which drops the op history entries
+// left in status.conditions by the older versions.
+func knownConditions(conditions []metav1.Condition) []metav1.Condition {
+	result := make([]metav1.Condition, 0, len(knownConditionTypes))
+	for _, condition := range conditions {
+		if _, ok := knownConditionTypes[condition.Type]; ok {
+			result = append(result, *condition.DeepCopy())
+		}
+	}
+	return result
+}
+
+func isPlaybook(ops *clusteroperationv1alpha1.ClusterOperation, playbook string) bool {
+	return ops.Spec.ActionType == clusteroperationv1alpha1.PlaybookActionType && ops.Spec.Action == playbook
+}
+
+// ComputeConditions returns the standard conditions of the cluster from its ClusterOperations and kubeconfig,
+// together with the conditions observed by the other steps of UpdateStatus.
+func (c *Controller) ComputeConditions(cluster *clusterv1alpha1.Cluster, operations []clusteroperationv1alpha1.ClusterOperation, observed ...metav1.Condition) []metav1.Condition {
+	conditions := knownConditions(cluster.Status.Conditions)
+	for _, condition := range append([]metav1.Condition{
+		provisionedCondition(conditions, operations),
+		upgradeInProgressCondition(operations),
+		c.certificatesValidCondition(cluster, time.Now()),
+	}, observed...) {
+		condition.ObservedGeneration = cluster.Generation
+		meta.SetStatusCondition(&conditions, condition)
+	}
+	for _, condition := range healthConditions(cluster) {
+		condition.ObservedGeneration = cluster.Generation
+		meta.SetStatusCondition(&conditions, condition)
+	}
+	return conditions
+}
+
+// provisionedCondition follows the latest succeeded cluster.yml or reset.yml, and keeps the current one
+// when both of them have been cleaned up.
+func provisionedCondition(conditions []metav1.Condition, operations []clusteroperationv1alpha1.ClusterOperation) metav1.Condition {
+	var latest, provisioning *clusteroperationv1alpha1.ClusterOperation
+	for i := range operations {
+		ops := &operations[i]
+		if ops.Spec.DryRun || !(isPlaybook(ops, entrypoint.ClusterPB) || isPlaybook(ops, entrypoint.ResetPB)) {
+			continue
+		}
+		if ops.Status.Status == clusteroperationv1alpha1.RunningStatus && isPlaybook(ops, entrypoint.ClusterPB) {
+			provisioning = ops
+		}
+		if ops.Status.Status != clusteroperationv1alpha1.SucceededStatus {
+			continue
+		}
+		if latest == nil || latest.CreationTimestamp.Before(&ops.CreationTimestamp) {
+			latest = ops
+		}
+	}
+	condition := metav1.Condition{Type: clusterv1alpha1.ProvisionedCondition}
+	switch {
+	case latest != nil && isPlaybook(latest, entrypoint.ClusterPB):
+		condition.Status, condition.Reason = metav1.ConditionTrue, ClusterCreatedReason
+		condition.Message = fmt.Sprintf("clusterOps %s succeeded", latest.Name)
+	case latest != nil:
+		condition.Status, condition.Reason = metav1.ConditionFalse, ClusterResetReason
+		condition.Message = fmt.Sprintf("clusterOps %s succeeded", latest.Name)
+	case meta.FindStatusCondition(conditions, clusterv1alpha1.ProvisionedCondition) != nil:
+		return *meta.FindStatusCondition(conditions, clusterv1alpha1.ProvisionedCondition)
+	case provisioning != nil:
+		condition.Status, condition.Reason = metav1.ConditionFalse, ProvisioningReason
+		condition.Message = fmt.Sprintf("clusterOps %s is running", provisioning.Name)
+	default:
+		condition.Status, condition.Reason = metav1.ConditionFalse, NotProvisionedReason
+		condition.Message = "no clusterOps of cluster.yml has succeeded"
+	}
+	return condition
+}
+
+// healthConditions returns the Reachable and APIServerHealthy conditions from the last probe of the cluster.
+func healthConditions(cluster *clusterv1alpha1.Cluster) []metav1.Condition {
+	reachable := metav1.Condition{Type: clusterv1alpha1.ReachableCondition, Status: metav1.ConditionUnknown}
+	apiServerHealthy := metav1.Condition{Type: clusterv1alpha1.APIServerHealthyCondition, Status: metav1.ConditionUnknown}
+	health := cluster.Status.Health
+	switch {
+	case cluster.Spec.KubeConfRef.IsEmpty():
+		reachable.Reason, reachable.Message = KubeConfRefNotSetReason, "kubeconfRef is not set"
+		apiServerHealthy.Reason, apiServerHealthy.Message = KubeConfRefNotSetReason, "kubeconfRef is not set"
+	case health == nil || health.LastProbeTime == nil:
+		reachable.Reason, reachable.Message = NotProbedReason, "the cluster has not been probed yet"
+		apiServerHealthy.Reason, apiServerHealthy.Message = NotProbedReason, "the cluster has not been probed yet"
+	case health.ProbeError != "":
+		reachable.Status, reachable.Reason, reachable.Message = metav1.ConditionFalse, UnreachableReason, health.ProbeError
+		apiServerHealthy.Reason, apiServerHealthy.Message = UnreachableReason, "the cluster is unreachable"
+	default:
+		reachable.Status, reachable.Reason = metav1.ConditionTrue, ConnectedReason
+		reachable.Message = fmt.Sprintf("kubernetes %s with %d/%d nodes ready", health.KubernetesVersion, health.ReadyNodes, health.Nodes)
+		if unhealthy := UnhealthyComponents(health); len(unhealthy) > 0 {
+			apiServerHealthy.Status, apiServerHealthy.Reason = metav1.ConditionFalse, ComponentsUnhealthyReason
+			apiServerHealthy.Message = fmt.Sprintf("unhealthy components: %s", strings.Join(unhealthy, ", "))
+		} else {
+			apiServerHealthy.Status, apiServerHealthy.Reason, apiServerHealthy.Message = metav1.ConditionTrue, APIServerReadyReason, "all checks of readyz passed"
+		}
+	}
+	return []metav1.Condition{reachable, apiServerHealthy}
+}
+
+func upgradeInProgressCondition(operations []clusteroperationv1alpha1.ClusterOperation) metav1.Condition {
+	for i := range operations {
+		ops := &operations[i]
+		if !ops.Spec.DryRun && isPlaybook(ops, entrypoint.UpgradeClusterPB) && ops.Status.Status == clusteroperationv1alpha1.RunningStatus {
+			return metav1.Condition{
+				Type:    clusterv1alpha1.UpgradeInProgressCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  UpgradingReason,
+				Message: fmt.Sprintf("clusterOps %s is running", ops.Name),
+			}
+		}
+	}
+	return metav1.Condition{
+		Type:    clusterv1alpha1.UpgradeInProgressCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  NoUpgradeReason,
+		Message: "no clusterOps of upgrade-cluster.yml is running",
+	}
+}
+
+// certificatesValidCondition checks the earliest expiration of the client and CA certificates in the kubeconfig.
+func (c *Controller) certificatesValidCondition(cluster *clusterv1alpha1.Cluster, now time.Time) metav1.Condition {
+	condition := metav1.Condition{Type: clusterv1alpha1.CertificatesValidCondition, Status: metav1.ConditionUnknown}
+	if cluster.Spec.KubeConfRef.IsEmpty() {
+		condition.Reason, condition.Message = KubeConfRefNotSetReason, "kubeconfRef is not set"
+		return condition
+	}
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(cluster.Spec.KubeConfRef.NameSpace).Get(context.Background(), cluster.Spec.KubeConfRef.Name, metav1.GetOptions{})
+	if err != nil {
+		condition.Reason, condition.Message = KubeConfigUnavailableReason, err.Error()
+		return condition
+	}
+	certificate, err := EarliestExpiringCertificate([]byte(configMap.Data[KubeConfigDataKey]))
+	if err != nil {
+		condition.Reason, condition.Message = KubeConfigUnavailableReason, err.Error()
+		return condition
+	}
+	if certificate == nil {
+		condition.Reason, condition.Message = NoCertificatesReason, "no certificate is found in the kubeconfig"
+		return condition
+	}
+	notAfter := certificate.NotAfter.UTC().Format(time.RFC3339)
+	switch {
+	case now.After(certificate.NotAfter):
+		condition.Status, condition.Reason = metav1.ConditionFalse, CertificatesExpiredReason
+		condition.Message = fmt.Sprintf("certificate %s expired at %s", certificate.Subject.CommonName, notAfter)
+	case now.Add(CertificateExpiringThreshold).After(certificate.NotAfter):
+		condition.Status, condition.Reason = metav1.ConditionTrue, CertificatesExpiringReason
+		condition.Message = fmt.Sprintf("certificate %s expires at %s", certificate.Subject.CommonName, notAfter)
+	default:
+		condition.Status, condition.Reason = metav1.ConditionTrue, CertificatesValidReason
+		condition.Message = fmt.Sprintf("certificate %s expires at %s", certificate.Subject.CommonName, notAfter)
+	}
+	return condition
+}
+
+// EarliestExpiringCertificate returns the certificate which expires first among the client certificates
+// and the certificate authorities embedded in the kubeconfig, and nil if there is none.
+func EarliestExpiringCertificate(kubeconfig []byte) (*x509.Certificate, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	pemDataList := make([][]byte, 0)
+	for _, authInfo := range config.AuthInfos {
+		pemDataList = append(pemDataList, authInfo.ClientCertificateData)
+	}
+	for _, cluster := range config.Clusters {
+		pemDataList = append(pemDataList, cluster.CertificateAuthorityData)
+	}
+	var earliest *x509.Certificate
+	for _, pemData := range pemDataList {
+		for block, rest := pem.Decode(pemData); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if earliest == nil || certificate.NotAfter.Before(earliest.NotAfter) {
+				earliest = certificate
+			}
+		}
+	}
+	return earliest, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newCertificatePEM(commonName string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newKubeconfig(caPEM, clientPEM []byte) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: cluster1
+  cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority-data: %s
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+contexts:
+- name: admin@cluster1
+  context:
+    cluster: cluster1
+    user: admin
+current-context: admin@cluster1
+`, base64.StdEncoding.EncodeToString(caPEM), base64.StdEncoding.EncodeToString(clientPEM))
+}
+
+func newOps(name, action string, status clusteroperationv1alpha1.OpsStatus, creation int64) clusteroperationv1alpha1.ClusterOperation {
+	return clusteroperationv1alpha1.ClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.Unix(creation, 0)},
+		Spec:       clusteroperationv1alpha1.Spec{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: action},
+		Status:     clusteroperationv1alpha1.Status{Status: status},
+	}
+}
+
+func TestProvisionedCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []metav1.Condition
+		operations []clusteroperationv1alpha1.ClusterOperation
+		want       metav1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:       "nothing",
+			want:       metav1.ConditionFalse,
+			wantReason: NotProvisionedReason,
+		},
+		{
+			name:       "provisioning",
+			operations: []clusteroperationv1alpha1.ClusterOperation{newOps("ops1", "cluster.yml", clusteroperationv1alpha1.RunningStatus, 1)},
+			want:       metav1.ConditionFalse,
+			wantReason: ProvisioningReason,
+		},
+		{
+			name: "created",
+			operations: []clusteroperationv1alpha1.ClusterOperation{
+				newOps("ops1", "cluster.yml", clusteroperationv1alpha1.FailedStatus, 1),
+				newOps("ops2", "cluster.yml", clusteroperationv1alpha1.SucceededStatus, 2),
+				newOps("ops3", "reset.yml", clusteroperationv1alpha1.FailedStatus, 3),
+			},
+			want:       metav1.ConditionTrue,
+			wantReason: ClusterCreatedReason,
+		},
+		{
+			name: "reset",
+			operations: []clusteroperationv1alpha1.ClusterOperation{
+				newOps("ops2", "reset.yml", clusteroperationv1alpha1.SucceededStatus, 2),
+				newOps("ops1", "cluster.yml", clusteroperationv1alpha1.SucceededStatus, 1),
+			},
+			want:       metav1.ConditionFalse,
+			wantReason: ClusterResetReason,
+		},
+		{
+			name:       "keep the current one after the ops are cleaned up",
+			conditions: []metav1.Condition{{Type: clusterv1alpha1.ProvisionedCondition, Status: metav1.ConditionTrue, Reason: ClusterCreatedReason}},
+			operations: []clusteroperationv1alpha1.ClusterOperation{newOps("ops9", "scale.yml", clusteroperationv1alpha1.SucceededStatus, 9)},
+			want:       metav1.ConditionTrue,
+			wantReason: ClusterCreatedReason,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			condition := provisionedCondition(test.conditions, test.operations)
+			if condition.Status != test.want || condition.Reason != test.wantReason {
+				t.Fatalf("got %s %s", condition.Status, condition.Reason)
+			}
+		})
+	}
+}
+
+func TestUpgradeInProgressCondition(t *testing.T) {
+	dryRun := newOps("ops2", "upgrade-cluster.yml", clusteroperationv1alpha1.RunningStatus, 2)
+	dryRun.Spec.DryRun = true
+	tests := []struct {
+		name       string
+		operations []clusteroperationv1alpha1.ClusterOperation
+		want       metav1.ConditionStatus
+	}{
+		{
+			name:       "upgraded",
+			operations: []clusteroperationv1alpha1.ClusterOperation{newOps("ops1", "upgrade-cluster.yml", clusteroperationv1alpha1.SucceededStatus, 1)},
+			want:       metav1.ConditionFalse,
+		},
+		{
+			name:       "dry run",
+			operations: []clusteroperationv1alpha1.ClusterOperation{dryRun},
+			want:       metav1.ConditionFalse,
+		},
+		{
+			name:       "upgrading",
+			operations: []clusteroperationv1alpha1.ClusterOperation{newOps("ops3", "upgrade-cluster.yml", clusteroperationv1alpha1.RunningStatus, 3)},
+			want:       metav1.ConditionTrue,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if condition := upgradeInProgressCondition(test.operations); condition.Status != test.want {
+				t.Fatalf("got %s", condition.Status)
+			}
+		})
+	}
+}
+
+func TestCertificatesValidCondition(t *testing.T) {
+	now := time.Now()
+	kubeconfRef := &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "cluster1-kubeconf"}
+	tests := []struct {
+		name       string
+		kubeconfig *string
+		want       metav1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:       "kubeconfRef not set",
+			want:       metav1.ConditionUnknown,
+			wantReason: KubeConfRefNotSetReason,
+		},
+		{
+			name:       "invalid kubeconfig",
+			kubeconfig: func() *string { s := "{"; return &s }(),
+			want:       metav1.ConditionUnknown,
+			wantReason: KubeConfigUnavailableReason,
+		},
+		{
+			name:       "no certificates",
+			kubeconfig: func() *string { s := "apiVersion: v1\nkind: Config\n"; return &s }(),
+			want:       metav1.ConditionUnknown,
+			wantReason: NoCertificatesReason,
+		},
+		{
+			name: "valid",
+			kubeconfig: func() *string {
+				s := newKubeconfig(newCertificatePEM("ca", now.Add(3650*24*time.Hour)), newCertificatePEM("admin", now.Add(365*24*time.Hour)))
+				return &s
+			}(),
+			want:       metav1.ConditionTrue,
+			wantReason: CertificatesValidReason,
+		},
+		{
+			name: "expiring soon",
+			kubeconfig: func() *string {
+				s := newKubeconfig(newCertificatePEM("ca", now.Add(10*24*time.Hour)), newCertificatePEM("admin", now.Add(365*24*time.Hour)))
+				return &s
+			}(),
+			want:       metav1.ConditionTrue,
+			wantReason: CertificatesExpiringReason,
+		},
+		{
+			name: "expired",
+			kubeconfig: func() *string {
+				s := newKubeconfig(newCertificatePEM("ca", now.Add(3650*24*time.Hour)), newCertificatePEM("admin", now.Add(-time.Hour)))
+				return &s
+			}(),
+			want:       metav1.ConditionFalse,
+			wantReason: CertificatesExpiredReason,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+			cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+			if test.kubeconfig != nil {
+				cluster.Spec.KubeConfRef = kubeconfRef
+				controller.ClientSet.CoreV1().ConfigMaps(kubeconfRef.NameSpace).Create(context.Background(), &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: kubeconfRef.Name, Namespace: kubeconfRef.NameSpace},
+					Data:       map[string]string{KubeConfigDataKey: *test.kubeconfig},
+				}, metav1.CreateOptions{})
+			}
+			condition := controller.certificatesValidCondition(cluster, now)
+			if condition.Status != test.want || condition.Reason != test.wantReason {
+				t.Fatalf("got %s %s: %s", condition.Status, condition.Reason, condition.Message)
+			}
+		})
+	}
+}
+
+func TestComputeConditions(t *testing.T) {
+	controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+	cluster := &clusterv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Generation: 3},
+		Status: clusterv1alpha1.Status{
+			Conditions: []metav1.Condition{
+				{Type: "cluster1-ops", Status: "Succeeded"},
+				{Type: clusterv1alpha1.ReachableCondition, Status: metav1.ConditionTrue, Reason: "Probed"},
+			},
+		},
+	}
+	conditions := controller.ComputeConditions(cluster, []clusteroperationv1alpha1.ClusterOperation{
+		newOps("ops1", "cluster.yml", clusteroperationv1alpha1.SucceededStatus, 1),
+	})
+	if len(conditions) != 5 || meta.FindStatusCondition(conditions, "cluster1-ops") != nil {
+		t.Fatalf("unexpected conditions %v", conditions)
+	}
+	for _, condition := range conditions {
+		if condition.ObservedGeneration != 3 {
+			t.Fatalf("condition %s observed generation %d", condition.Type, condition.ObservedGeneration)
+		}
+	}
+	if !meta.IsStatusConditionTrue(conditions, clusterv1alpha1.ProvisionedCondition) {
+		t.Fatal("cluster should be provisioned")
+	}
+	if reachable := meta.FindStatusCondition(conditions, clusterv1alpha1.ReachableCondition); reachable.Reason != KubeConfRefNotSetReason {
+		t.Fatalf("got reachable reason %s", reachable.Reason)
+	}
+}
@@ -6,20 +6,29 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"time"
 
 	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
 	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
 	clusterClientSet "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned"
 	clusterOperationClientSet "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned"
+	"github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/metrics"
 	"github.com/kubean-io/kubean/pkg/util"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,6 +45,7 @@ type Controller struct {
 	ClientSet           kubernetes.Interface
 	KubeanClusterSet    clusterClientSet.Interface
 	KubeanClusterOpsSet clusterOperationClientSet.Interface
+	EventRecorder       record.EventRecorder
 }
 
 func (c *Controller) Start(ctx context.Context) error {
@@ -44,6 +54,33 @@ func (c *Controller) Start(ctx context.Context) error {
 	return nil
 }
 
+// recordEvent is a no-op when the controller has no EventRecorder.
+func (c *Controller) recordEvent(cluster *clusterv1alpha1.Cluster, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.EventRecorder == nil {
+		return
+	}
+	c.EventRecorder.Eventf(cluster, eventType, reason, messageFmt, args...)
+}
+
+// recordFinishedOps records the event on the cluster for each clusterOps which has just succeeded or failed.
+func (c *Controller) recordFinishedOps(cluster *clusterv1alpha1.Cluster, oldConditions, newConditions []clusterv1alpha1.ClusterCondition) {
+	oldStatus := map[string]clusterv1alpha1.ClusterConditionType{}
+	for _, condition := range oldConditions {
+		oldStatus[condition.ClusterOps] = condition.Status
+	}
+	for _, condition := range newConditions {
+		if oldStatus[condition.ClusterOps] == condition.Status {
+			continue
+		}
+		switch clusteroperationv1alpha1.OpsStatus(condition.Status) {
+		case clusteroperationv1alpha1.SucceededStatus:
+			c.recordEvent(cluster, corev1.EventTypeNormal, clusterops.SucceededReason, "clusterOps %s succeeded", condition.ClusterOps)
+		case clusteroperationv1alpha1.FailedStatus:
+			c.recordEvent(cluster, corev1.EventTypeWarning, clusterops.FailedReason, "clusterOps %s failed", condition.ClusterOps)
+		}
+	}
+}
+
 func CompareClusterCondition(conditionA, conditionB clusterv1alpha1.ClusterCondition) bool {
 	unixMilli := func(t *metav1.Time) int64 {
 		if t == nil {
@@ -84,24 +121,40 @@ func (c *Controller) UpdateStatus(cluster *clusterv1alpha1.Cluster) error {
 	if err != nil {
 		return err
 	}
+	metrics.SetClusterOperations(cluster.Name, clusterOpsList.Items)
 	// clusterOps list sort by creation timestamp
 	c.SortClusterOperationsByCreation(clusterOpsList.Items)
-	newConditions := make([]clusterv1alpha1.ClusterCondition, 0)
+	newHistory := make([]clusterv1alpha1.ClusterCondition, 0)
 	for _, item := range clusterOpsList.Items {
-		newConditions = append(newConditions, clusterv1alpha1.ClusterCondition{
+		newHistory = append(newHistory, clusterv1alpha1.ClusterCondition{
 			ClusterOps: item.Name,
 			Status:     clusterv1alpha1.ClusterConditionType(item.Status.Status),
 			StartTime:  item.Status.StartTime,
 			EndTime:    item.Status.EndTime,
 		})
 	}
-	if !CompareClusterConditions(cluster.Status.Conditions, newConditions) {
-		// need update for newCondition
-		cluster.Status.Conditions = newConditions
-		klog.Warningf("update cluster %s status.condition", cluster.Name)
-		return c.Client.Status().Update(context.Background(), cluster)
+	oldStatus := cluster.Status.DeepCopy()
+	c.ProbeHealth(cluster)
+	observed := []metav1.Condition{c.SyncNodes(cluster)}
+	if cluster.Spec.ReconcileNodes {
+		observed = append(observed, c.ReconcileNodes(cluster, clusterOpsList.Items))
 	}
-	return nil
+	newConditions := c.ComputeConditions(cluster, clusterOpsList.Items, observed...)
+	if !cluster.Spec.ReconcileNodes {
+		meta.RemoveStatusCondition(&newConditions, clusterv1alpha1.NodesReconciledCondition)
+	}
+	historyChanged := !CompareClusterConditions(cluster.Status.OperationHistory, newHistory)
+	if !historyChanged && equality.Semantic.DeepEqual(cluster.Status.Conditions, newConditions) &&
+		equality.Semantic.DeepEqual(oldStatus.Health, cluster.Status.Health) && equality.Semantic.DeepEqual(oldStatus.Nodes, cluster.Status.Nodes) {
+		return nil
+	}
+	if historyChanged {
+		c.recordFinishedOps(cluster, cluster.Status.OperationHistory, newHistory)
+		cluster.Status.OperationHistory = newHistory
+	}
+	cluster.Status.Conditions = newConditions
+	klog.Warningf("update cluster %s status", cluster.Name)
+	return c.Client.Status().Update(context.Background(), cluster)
 }
 
 func (c *Controller) GetEliminateScoreValue(operation clusteroperationv1alpha1.ClusterOperation) int {
@@ -137,19 +190,96 @@ func (c *Controller) CleanExcessClusterOps(cluster *clusterv1alpha1.Cluster, Ops
 
 	excessClusterOpsList := clusterOpsList.Items[OpsBackupNum:]
 	for _, item := range excessClusterOpsList {
-		if item.Status.Status == clusteroperationv1alpha1.RunningStatus { // keep running job
+		if item.Status.Status == clusteroperationv1alpha1.RunningStatus || item.Status.Status == clusteroperationv1alpha1.PendingStatus ||
+			item.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus { // keep running or queued job
+			continue
+		}
+		if clusterops.IsFinished(&item) && item.Annotations[clusterops.AuditRecordAnnoKey] == "" { // keep until audited
 			continue
 		}
 		klog.Warningf("Delete ClusterOperation: name: %s, createTime: %s, status: %s", item.Name, item.CreationTimestamp.String(), item.Status.Status)
-		c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Delete(context.Background(), item.Name, metav1.DeleteOptions{})
+		if err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Delete(context.Background(), item.Name, metav1.DeleteOptions{}); err == nil {
+			c.recordEvent(cluster, corev1.EventTypeNormal, clusterops.OpsPrunedReason, "pruned clusterOps %s with status %s, keeping the latest %d", item.Name, item.Status.Status, OpsBackupNum)
+		}
 	}
 	return true, nil
 }
 
+// CleanExcessLogArchives keeps the job log archives of the latest OpsBackupNum ClusterOperations.
+func (c *Controller) CleanExcessLogArchives(cluster *clusterv1alpha1.Cluster, OpsBackupNum int) error {
+	listOpt := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s,%s", constants.KubeanClusterLabelKey, cluster.Name, clusterops.LogArchiveLabelKey)}
+	configMaps, err := c.ClientSet.CoreV1().ConfigMaps(util.TenantNamespace(cluster)).List(context.Background(), listOpt)
+	if err != nil {
+		return err
+	}
+	// the chunks of one archive share the same clusterOps name.
+	archiveTime := map[string]metav1.Time{}
+	for _, configMap := range configMaps.Items {
+		name := configMap.Labels[clusterops.LogArchiveLabelKey]
+		if creation, ok := archiveTime[name]; !ok || configMap.CreationTimestamp.After(creation.Time) {
+			archiveTime[name] = configMap.CreationTimestamp
+		}
+	}
+	archives := make([]string, 0, len(archiveTime))
+	for name := range archiveTime {
+		archives = append(archives, name)
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archiveTime[archives[i]].After(archiveTime[archives[j]].Time)
+	})
+	excessArchives := map[string]struct{}{}
+	if len(archives) > OpsBackupNum {
+		for _, name := range archives[OpsBackupNum:] {
+			excessArchives[name] = struct{}{}
+		}
+	}
+	for _, configMap := range configMaps.Items {
+		if _, ok := excessArchives[configMap.Labels[clusterops.LogArchiveLabelKey]]; !ok {
+			continue
+		}
+		klog.Warningf("Delete log archive: name: %s, createTime: %s", configMap.Name, configMap.CreationTimestamp.String())
+		if err := c.ClientSet.CoreV1().ConfigMaps(configMap.Namespace).Delete(context.Background(), configMap.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return c.cleanExcessLogArchiveFiles(cluster, OpsBackupNum)
+}
+
+func (c *Controller) cleanExcessLogArchiveFiles(cluster *clusterv1alpha1.Cluster, OpsBackupNum int) error {
+	clusterDir := filepath.Join(clusterops.LogArchiveDir, cluster.Name)
+	entries, err := os.ReadDir(clusterDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !info.IsDir() {
+			files = append(files, info)
+		}
+	}
+	if len(files) <= OpsBackupNum {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	for _, file := range files[OpsBackupNum:] {
+		klog.Warningf("Delete log archive: file: %s, modTime: %s", file.Name(), file.ModTime().String())
+		if err := os.Remove(filepath.Join(clusterDir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Request) (controllerruntime.Result, error) {
 	cluster := &clusterv1alpha1.Cluster{}
 	if err := c.Client.Get(ctx, req.NamespacedName, cluster); err != nil {
 		if apierrors.IsNotFound(err) {
+			metrics.DeleteClusterOperations(req.Name)
 			return controllerruntime.Result{}, nil
 		}
 		klog.ErrorS(err, "failed to get cluster", "cluster", req.String())
@@ -164,6 +294,9 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 	if needRequeue {
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
+	if err := c.CleanExcessLogArchives(cluster, OpsBackupNum); err != nil {
+		klog.ErrorS(err, "failed to clean excess log archives", "cluster", cluster.Name)
+	}
 
 	if err := c.UpdateStatus(cluster); err != nil {
 		klog.ErrorS(err, "failed to update cluster status", "cluster", cluster.Name)
@@ -7,7 +7,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,8 +35,11 @@ import (
 	"github.com/kubean-io/kubean-api/constants"
 	clusterv1alpha1fake "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned/fake"
 	clusteroperationv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned/fake"
+	"github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/util"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientsetfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -241,11 +247,13 @@ func TestSortClusterOperationsByCreation(t *testing.T) {
 
 func Test_CleanExcessClusterOps(t *testing.T) {
 	OpsBackupNum := 5
+	recorder := record.NewFakeRecorder(100)
 	controller := &Controller{
 		Client:              newFakeClient(),
 		ClientSet:           clientsetfake.NewSimpleClientset(),
 		KubeanClusterSet:    clusterv1alpha1fake.NewSimpleClientset(),
 		KubeanClusterOpsSet: clusteroperationv1alpha1fake.NewSimpleClientset(),
+		EventRecorder:       recorder,
 	}
 	exampleCluster := &clusterv1alpha1.Cluster{
 		TypeMeta: metav1.TypeMeta{
@@ -329,7 +337,7 @@ func Test_CleanExcessClusterOps(t *testing.T) {
 				}
 				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().UpdateStatus(context.Background(), clusterOperationRunning, metav1.UpdateOptions{})
 				result, _ := controller.CleanExcessClusterOps(exampleCluster, OpsBackupNum)
-				return result
+				return result && len(recorder.Events) > 0 && strings.HasPrefix(<-recorder.Events, "Normal OpsPruned")
 			},
 			want: true,
 		},
@@ -355,6 +363,136 @@ func Test_CleanExcessClusterOps(t *testing.T) {
 	}
 }
 
+func TestCleanExcessClusterOpsKeepUnaudited(t *testing.T) {
+	controller := &Controller{
+		Client:              newFakeClient(),
+		ClientSet:           clientsetfake.NewSimpleClientset(),
+		KubeanClusterSet:    clusterv1alpha1fake.NewSimpleClientset(),
+		KubeanClusterOpsSet: clusteroperationv1alpha1fake.NewSimpleClientset(),
+	}
+	exampleCluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	for i, name := range []string{"audited-old", "unaudited", "audited-new"} {
+		annotations := map[string]string{}
+		if strings.HasPrefix(name, "audited") {
+			annotations[clusterops.AuditRecordAnnoKey] = "cluster1-audit-2026-10-0"
+		}
+		controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Labels:            map[string]string{constants.KubeanClusterLabelKey: "cluster1"},
+				Annotations:       annotations,
+				CreationTimestamp: metav1.Unix(int64(i), 0),
+			},
+			Status: clusteroperationv1alpha1.Status{Status: clusteroperationv1alpha1.SucceededStatus},
+		}, metav1.CreateOptions{})
+	}
+	if _, err := controller.CleanExcessClusterOps(exampleCluster, 1); err != nil {
+		t.Fatal(err)
+	}
+	opsList, _ := controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().List(context.Background(), metav1.ListOptions{})
+	names := make([]string, 0)
+	for _, ops := range opsList.Items {
+		names = append(names, ops.Name)
+	}
+	if strings.Join(names, ",") != "audited-new,unaudited" {
+		t.Fatalf("got %v", names)
+	}
+}
+
+func TestRecordFinishedOps(t *testing.T) {
+	cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	tests := []struct {
+		name          string
+		oldConditions []clusterv1alpha1.ClusterCondition
+		newConditions []clusterv1alpha1.ClusterCondition
+		want          []string
+	}{
+		{
+			name:          "still running",
+			oldConditions: []clusterv1alpha1.ClusterCondition{{ClusterOps: "ops1", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.RunningStatus)}},
+			newConditions: []clusterv1alpha1.ClusterCondition{{ClusterOps: "ops1", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.RunningStatus)}},
+			want:          []string{},
+		},
+		{
+			name: "just finished",
+			oldConditions: []clusterv1alpha1.ClusterCondition{
+				{ClusterOps: "ops1", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.RunningStatus)},
+				{ClusterOps: "ops2", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.SucceededStatus)},
+			},
+			newConditions: []clusterv1alpha1.ClusterCondition{
+				{ClusterOps: "ops1", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.FailedStatus)},
+				{ClusterOps: "ops2", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.SucceededStatus)},
+				{ClusterOps: "ops3", Status: clusterv1alpha1.ClusterConditionType(clusteroperationv1alpha1.SucceededStatus)},
+			},
+			want: []string{"Warning Failed clusterOps ops1 failed", "Normal Succeeded clusterOps ops3 succeeded"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			controller := &Controller{EventRecorder: recorder}
+			controller.recordFinishedOps(cluster, test.oldConditions, test.newConditions)
+			close(recorder.Events)
+			got := []string{}
+			for event := range recorder.Events {
+				got = append(got, event)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_CleanExcessLogArchives(t *testing.T) {
+	controller := &Controller{
+		Client:    newFakeClient(),
+		ClientSet: clientsetfake.NewSimpleClientset(),
+	}
+	exampleCluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	namespace := util.GetCurrentNSOrDefault()
+	for i := 0; i < 4; i++ {
+		// every archive has two chunks.
+		for chunk := 0; chunk < 2; chunk++ {
+			controller.ClientSet.CoreV1().ConfigMaps(namespace).Create(context.Background(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("ops%d-log-%d", i, chunk),
+					Namespace: namespace,
+					Labels: map[string]string{
+						constants.KubeanClusterLabelKey: "cluster1",
+						clusterops.LogArchiveLabelKey:   fmt.Sprintf("ops%d", i),
+					},
+					CreationTimestamp: metav1.Unix(int64(i), 0),
+				},
+			}, metav1.CreateOptions{})
+		}
+	}
+	clusterops.LogArchiveDir = t.TempDir()
+	clusterDir := filepath.Join(clusterops.LogArchiveDir, "cluster1")
+	os.MkdirAll(clusterDir, 0o755)
+	for i := 0; i < 4; i++ {
+		logFile := filepath.Join(clusterDir, fmt.Sprintf("ops%d.log.gz", i))
+		os.WriteFile(logFile, []byte("log"), 0o644)
+		os.Chtimes(logFile, time.Unix(int64(i), 0), time.Unix(int64(i), 0))
+	}
+	if err := controller.CleanExcessLogArchives(exampleCluster, 2); err != nil {
+		t.Fatal(err)
+	}
+	configMaps, _ := controller.ClientSet.CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{})
+	if len(configMaps.Items) != 4 {
+		t.Fatalf("expect 4 configmaps but got %d", len(configMaps.Items))
+	}
+	for _, configMap := range configMaps.Items {
+		if name := configMap.Labels[clusterops.LogArchiveLabelKey]; name != "ops2" && name != "ops3" {
+			t.Fatalf("unexpected archive %s", configMap.Name)
+		}
+	}
+	entries, _ := os.ReadDir(clusterDir)
+	if len(entries) != 2 || entries[0].Name() != "ops2.log.gz" || entries[1].Name() != "ops3.log.gz" {
+		t.Fatalf("unexpected archive files %v", entries)
+	}
+}
+
 func Test_UpdateStatus(t *testing.T) {
 	controller := &Controller{
 		Client:              newFakeClient(),
@@ -376,14 +514,19 @@ func Test_UpdateStatus(t *testing.T) {
 						APIVersion: "kubean.io/v1alpha1",
 					},
 					ObjectMeta: metav1.ObjectMeta{
-						Name: "cluster1",
+						Name: "cluster0",
 					},
 					Spec: clusterv1alpha1.Spec{
 						HostsConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-a"},
 						VarsConfRef:  &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "vars-a"},
 					},
 				}
-				return controller.UpdateStatus(exampleCluster) == nil
+				controller.Client.Create(context.Background(), exampleCluster)
+				if controller.UpdateStatus(exampleCluster) != nil {
+					return false
+				}
+				condition := meta.FindStatusCondition(exampleCluster.Status.Conditions, clusterv1alpha1.ProvisionedCondition)
+				return condition != nil && condition.Reason == NotProvisionedReason && len(exampleCluster.Status.Conditions) == 6
 			},
 			want: true,
 		},
@@ -417,7 +560,7 @@ func Test_UpdateStatus(t *testing.T) {
 				controller.Client.Create(context.Background(), exampleCluster)
 				controller.KubeanClusterSet.KubeanV1alpha1().Clusters().Create(context.Background(), exampleCluster, metav1.CreateOptions{})
 				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps, metav1.CreateOptions{})
-				return controller.UpdateStatus(exampleCluster) == nil
+				return controller.UpdateStatus(exampleCluster) == nil && len(exampleCluster.Status.OperationHistory) == 1
 			},
 			want: true,
 		},
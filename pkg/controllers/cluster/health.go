@@ -0,0 +1,148 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// ProbeInterval is the least interval between two probes of one cluster.
+	ProbeInterval = time.Minute
+	// ProbeTimeout bounds each request to the apiserver of the cluster.
+	ProbeTimeout = 10 * time.Second
+)
+
+// ProbeClusterHealth connects to the cluster with the kubeconfig and reads its version, nodes and `/readyz?verbose`.
+func ProbeClusterHealth(ctx context.Context, kubeconfig []byte) (*clusterv1alpha1.ClusterHealth, error) {
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Timeout = ProbeTimeout
+	clientSet, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	version, err := clientSet.Discovery().ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	health := &clusterv1alpha1.ClusterHealth{KubernetesVersion: version.GitVersion}
+	nodes, err := clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes.Items {
+		health.Nodes++
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				health.ReadyNodes++
+			}
+		}
+	}
+	// the apiserver answers 500 with the same verbose body when some check fails.
+	body, err := clientSet.CoreV1().RESTClient().Get().AbsPath("/readyz").Param("verbose", "true").DoRaw(ctx)
+	health.Components = ParseReadyz(body)
+	if err != nil && len(health.Components) == 0 {
+		return nil, err
+	}
+	return health, nil
+}
+
+// ParseReadyz parses the checks of `/readyz?verbose`, e.g. `[+]ping ok` and `[-]etcd failed: reason withheld`.
+func ParseReadyz(body []byte) []clusterv1alpha1.ComponentHealth {
+	components := make([]clusterv1alpha1.ComponentHealth, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var healthy bool
+		switch {
+		case strings.HasPrefix(line, "[+]"):
+			healthy = true
+		case strings.HasPrefix(line, "[-]"):
+			healthy = false
+		default:
+			continue
+		}
+		name, message, _ := strings.Cut(line[len("[+]"):], " ")
+		component := clusterv1alpha1.ComponentHealth{Name: name, Healthy: healthy}
+		if !healthy {
+			component.Message = strings.TrimPrefix(message, "failed: ")
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// UnhealthyComponents returns the names of the failed checks.
+func UnhealthyComponents(health *clusterv1alpha1.ClusterHealth) []string {
+	names := make([]string, 0)
+	for _, component := range health.Components {
+		if !component.Healthy {
+			names = append(names, component.Name)
+		}
+	}
+	return names
+}
+
+func probeDue(cluster *clusterv1alpha1.Cluster, now time.Time) bool {
+	health := cluster.Status.Health
+	return health == nil || health.LastProbeTime == nil || now.Sub(health.LastProbeTime.Time) >= ProbeInterval
+}
+
+// ProbeHealth refreshes cluster.Status.Health when it is due, and keeps the last observed state when the cluster is unreachable.
+func (c *Controller) ProbeHealth(cluster *clusterv1alpha1.Cluster) {
+	if cluster.Spec.KubeConfRef.IsEmpty() {
+		cluster.Status.Health = nil
+		return
+	}
+	now := time.Now()
+	if !probeDue(cluster, now) {
+		return
+	}
+	health := cluster.Status.Health.DeepCopy()
+	if health == nil {
+		health = &clusterv1alpha1.ClusterHealth{}
+	}
+	probeTime := metav1.NewTime(now)
+	health.LastProbeTime = &probeTime
+	observed, err := c.probeClusterHealth(cluster)
+	if err != nil {
+		klog.Warningf("failed to probe cluster %s: %v", cluster.Name, err)
+		health.ProbeError = err.Error()
+		cluster.Status.Health = health
+		return
+	}
+	observed.LastProbeTime = &probeTime
+	observed.LastSeenTime = &probeTime
+	cluster.Status.Health = observed
+}
+
+func (c *Controller) probeClusterHealth(cluster *clusterv1alpha1.Cluster) (*clusterv1alpha1.ClusterHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*ProbeTimeout)
+	defer cancel()
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(cluster.Spec.KubeConfRef.NameSpace).Get(ctx, cluster.Spec.KubeConfRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig, ok := configMap.Data[KubeConfigDataKey]
+	if !ok {
+		return nil, fmt.Errorf("configmap %s/%s has no %s", configMap.Namespace, configMap.Name, KubeConfigDataKey)
+	}
+	return ProbeClusterHealth(ctx, []byte(kubeconfig))
+}
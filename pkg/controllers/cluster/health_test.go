@@ -0,0 +1,245 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+const healthyReadyz = `[+]ping ok
+[+]log ok
+[+]etcd ok
+readyz check passed
+`
+
+const unhealthyReadyz = `[+]ping ok
+[-]etcd failed: reason withheld
+[+]informer-sync ok
+readyz check failed
+`
+
+func newFakeAPIServer(readyz string, readyzCode int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(version.Info{GitVersion: "v1.27.5"})
+	})
+	mux.HandleFunc("/api/v1/nodes", func(w http.ResponseWriter, r *http.Request) {
+		ready := func(status corev1.ConditionStatus) corev1.Node {
+			return corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(corev1.NodeList{
+			TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"},
+			Items:    []corev1.Node{ready(corev1.ConditionTrue), ready(corev1.ConditionTrue), ready(corev1.ConditionFalse)},
+		})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(readyzCode)
+		fmt.Fprint(w, readyz)
+	})
+	return httptest.NewServer(mux)
+}
+
+func newServerKubeconfig(server string) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: cluster1
+  cluster:
+    server: %s
+users:
+- name: admin
+  user:
+    token: token
+contexts:
+- name: admin@cluster1
+  context:
+    cluster: cluster1
+    user: admin
+current-context: admin@cluster1
+`, server)
+}
+
+func TestParseReadyz(t *testing.T) {
+	want := []clusterv1alpha1.ComponentHealth{
+		{Name: "ping", Healthy: true},
+		{Name: "etcd", Healthy: false, Message: "reason withheld"},
+		{Name: "informer-sync", Healthy: true},
+	}
+	if got := ParseReadyz([]byte(unhealthyReadyz)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	if got := ParseReadyz([]byte("ok")); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestProbeClusterHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		readyz     string
+		readyzCode int
+		want       []string
+	}{
+		{
+			name:       "healthy",
+			readyz:     healthyReadyz,
+			readyzCode: http.StatusOK,
+			want:       []string{},
+		},
+		{
+			name:       "etcd failed",
+			readyz:     unhealthyReadyz,
+			readyzCode: http.StatusInternalServerError,
+			want:       []string{"etcd"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeAPIServer(test.readyz, test.readyzCode)
+			defer server.Close()
+			health, err := ProbeClusterHealth(context.Background(), []byte(newServerKubeconfig(server.URL)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if health.KubernetesVersion != "v1.27.5" || health.Nodes != 3 || health.ReadyNodes != 2 {
+				t.Fatalf("got %+v", health)
+			}
+			if unhealthy := UnhealthyComponents(health); !reflect.DeepEqual(unhealthy, test.want) {
+				t.Fatalf("got unhealthy %v", unhealthy)
+			}
+		})
+	}
+}
+
+func TestProbeHealth(t *testing.T) {
+	server := newFakeAPIServer(healthyReadyz, http.StatusOK)
+	defer server.Close()
+	kubeconfRef := &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "cluster1-kubeconf"}
+	controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: kubeconfRef.Name, Namespace: kubeconfRef.NameSpace},
+		Data:       map[string]string{KubeConfigDataKey: newServerKubeconfig(server.URL)},
+	})}
+	lastMinute := metav1.NewTime(time.Now().Add(-2 * ProbeInterval))
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "kubeconfRef not set",
+			args: func() bool {
+				cluster := &clusterv1alpha1.Cluster{Status: clusterv1alpha1.Status{Health: &clusterv1alpha1.ClusterHealth{}}}
+				controller.ProbeHealth(cluster)
+				return cluster.Status.Health == nil
+			},
+			want: true,
+		},
+		{
+			name: "not due",
+			args: func() bool {
+				now := metav1.Now()
+				cluster := &clusterv1alpha1.Cluster{Spec: clusterv1alpha1.Spec{KubeConfRef: kubeconfRef}}
+				cluster.Status.Health = &clusterv1alpha1.ClusterHealth{KubernetesVersion: "v1.26.0", LastProbeTime: &now}
+				controller.ProbeHealth(cluster)
+				return cluster.Status.Health.KubernetesVersion == "v1.26.0"
+			},
+			want: true,
+		},
+		{
+			name: "probed",
+			args: func() bool {
+				cluster := &clusterv1alpha1.Cluster{Spec: clusterv1alpha1.Spec{KubeConfRef: kubeconfRef}}
+				cluster.Status.Health = &clusterv1alpha1.ClusterHealth{ProbeError: "timeout", LastProbeTime: &lastMinute}
+				controller.ProbeHealth(cluster)
+				health := cluster.Status.Health
+				return health.KubernetesVersion == "v1.27.5" && health.ProbeError == "" && health.LastSeenTime != nil && len(health.Components) == 3
+			},
+			want: true,
+		},
+		{
+			name: "unreachable keeps the last observed state",
+			args: func() bool {
+				cluster := &clusterv1alpha1.Cluster{Spec: clusterv1alpha1.Spec{KubeConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "not-exist"}}}
+				cluster.Status.Health = &clusterv1alpha1.ClusterHealth{KubernetesVersion: "v1.26.0", LastProbeTime: &lastMinute, LastSeenTime: &lastMinute}
+				controller.ProbeHealth(cluster)
+				health := cluster.Status.Health
+				return health.KubernetesVersion == "v1.26.0" && health.ProbeError != "" && health.LastSeenTime.Equal(&lastMinute) && health.LastProbeTime.After(lastMinute.Time)
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestHealthConditions(t *testing.T) {
+	now := metav1.Now()
+	kubeconfRef := &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "cluster1-kubeconf"}
+	tests := []struct {
+		name   string
+		spec   clusterv1alpha1.Spec
+		health *clusterv1alpha1.ClusterHealth
+		want   []string
+	}{
+		{
+			name: "kubeconfRef not set",
+			want: []string{KubeConfRefNotSetReason, KubeConfRefNotSetReason},
+		},
+		{
+			name: "not probed",
+			spec: clusterv1alpha1.Spec{KubeConfRef: kubeconfRef},
+			want: []string{NotProbedReason, NotProbedReason},
+		},
+		{
+			name:   "unreachable",
+			spec:   clusterv1alpha1.Spec{KubeConfRef: kubeconfRef},
+			health: &clusterv1alpha1.ClusterHealth{LastProbeTime: &now, ProbeError: "connection refused"},
+			want:   []string{UnreachableReason, UnreachableReason},
+		},
+		{
+			name: "etcd failed",
+			spec: clusterv1alpha1.Spec{KubeConfRef: kubeconfRef},
+			health: &clusterv1alpha1.ClusterHealth{LastProbeTime: &now, Components: []clusterv1alpha1.ComponentHealth{
+				{Name: "ping", Healthy: true}, {Name: "etcd", Healthy: false},
+			}},
+			want: []string{ConnectedReason, ComponentsUnhealthyReason},
+		},
+		{
+			name:   "healthy",
+			spec:   clusterv1alpha1.Spec{KubeConfRef: kubeconfRef},
+			health: &clusterv1alpha1.ClusterHealth{LastProbeTime: &now, Components: []clusterv1alpha1.ComponentHealth{{Name: "ping", Healthy: true}}},
+			want:   []string{ConnectedReason, APIServerReadyReason},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &clusterv1alpha1.Cluster{Spec: test.spec, Status: clusterv1alpha1.Status{Health: test.health}}
+			conditions := healthConditions(cluster)
+			if got := []string{conditions[0].Reason, conditions[1].Reason}; !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
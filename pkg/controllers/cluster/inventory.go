@@ -0,0 +1,77 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// The reasons of the InventoryValid condition.
+const (
+	InventoryValidReason       = "Valid"
+	InventoryInvalidReason     = "Invalid"
+	InventoryParseErrorReason  = "ParseError"
+	InventoryUnavailableReason = "InventoryUnavailable"
+)
+
+// NodesFromInventory lists the hosts of the inventory with their addresses and role groups.
+func NodesFromInventory(inventory *ansible.Inventory) []clusterv1alpha1.NodeInfo {
+	roles := map[string][]string{}
+	for _, group := range ansible.RoleGroups {
+		for _, host := range inventory.GroupHosts(group) {
+			roles[host] = append(roles[host], group)
+		}
+	}
+	nodes := make([]clusterv1alpha1.NodeInfo, 0)
+	for _, host := range inventory.Hosts() {
+		nodes = append(nodes, clusterv1alpha1.NodeInfo{
+			Name:        host,
+			AnsibleHost: inventory.HostVar(host, "ansible_host"),
+			IP:          inventory.HostVar(host, "ip"),
+			Roles:       roles[host],
+		})
+	}
+	return nodes
+}
+
+// SyncNodes refreshes cluster.Status.Nodes from the inventory in HostsConfRef and returns the InventoryValid condition.
+// The nodes are kept when the inventory can not be read or parsed.
+func (c *Controller) SyncNodes(cluster *clusterv1alpha1.Cluster) metav1.Condition {
+	condition := metav1.Condition{Type: clusterv1alpha1.InventoryValidCondition, Status: metav1.ConditionUnknown}
+	if cluster.Spec.HostsConfRef.IsEmpty() {
+		condition.Reason, condition.Message = InventoryUnavailableReason, "hostsConfRef is not set"
+		return condition
+	}
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(cluster.Spec.HostsConfRef.NameSpace).Get(context.Background(), cluster.Spec.HostsConfRef.Name, metav1.GetOptions{})
+	if err != nil {
+		condition.Reason, condition.Message = InventoryUnavailableReason, err.Error()
+		return condition
+	}
+	data, ok := configMap.Data[constants.Hosts_yml]
+	if !ok {
+		condition.Reason, condition.Message = InventoryUnavailableReason, fmt.Sprintf("configmap %s/%s has no %s", configMap.Namespace, configMap.Name, constants.Hosts_yml)
+		return condition
+	}
+	inventory, err := ansible.ParseInventory([]byte(data))
+	if err != nil {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, InventoryParseErrorReason, err.Error()
+		return condition
+	}
+	cluster.Status.Nodes = NodesFromInventory(inventory)
+	if errs := inventory.Validate(); len(errs) > 0 {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, InventoryInvalidReason, utilerrors.NewAggregate(errs).Error()
+		return condition
+	}
+	condition.Status, condition.Reason = metav1.ConditionTrue, InventoryValidReason
+	condition.Message = fmt.Sprintf("%d nodes", len(cluster.Status.Nodes))
+	return condition
+}
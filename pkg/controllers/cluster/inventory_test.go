@@ -0,0 +1,115 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSyncNodes(t *testing.T) {
+	validHosts := `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 10.6.0.10
+    node2:
+      ip: 172.30.41.11
+      ansible_host: 10.6.0.11
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+        node2:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`
+	invalidHosts := `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+`
+	previousNodes := []clusterv1alpha1.NodeInfo{{Name: "node0"}}
+	tests := []struct {
+		name       string
+		hosts      *string
+		wantStatus metav1.ConditionStatus
+		wantReason string
+		wantNodes  []clusterv1alpha1.NodeInfo
+	}{
+		{
+			name:       "configmap not found",
+			wantStatus: metav1.ConditionUnknown,
+			wantReason: InventoryUnavailableReason,
+			wantNodes:  previousNodes,
+		},
+		{
+			name:       "parse error",
+			hosts:      func() *string { s := "all: ["; return &s }(),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: InventoryParseErrorReason,
+			wantNodes:  previousNodes,
+		},
+		{
+			name:       "invalid",
+			hosts:      &invalidHosts,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: InventoryInvalidReason,
+			wantNodes:  []clusterv1alpha1.NodeInfo{{Name: "node1", IP: "172.30.41.10", Roles: []string{"kube_control_plane"}}},
+		},
+		{
+			name:       "valid",
+			hosts:      &validHosts,
+			wantStatus: metav1.ConditionTrue,
+			wantReason: InventoryValidReason,
+			wantNodes: []clusterv1alpha1.NodeInfo{
+				{Name: "node1", AnsibleHost: "10.6.0.10", IP: "172.30.41.10", Roles: []string{"kube_control_plane", "kube_node", "etcd"}},
+				{Name: "node2", AnsibleHost: "10.6.0.11", IP: "172.30.41.11", Roles: []string{"kube_node"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+			cluster := &clusterv1alpha1.Cluster{
+				Spec:   clusterv1alpha1.Spec{HostsConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-a"}},
+				Status: clusterv1alpha1.Status{Nodes: previousNodes},
+			}
+			if test.hosts != nil {
+				controller.ClientSet.CoreV1().ConfigMaps("kubean-system").Create(context.Background(), &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "hosts-a", Namespace: "kubean-system"},
+					Data:       map[string]string{constants.Hosts_yml: *test.hosts},
+				}, metav1.CreateOptions{})
+			}
+			condition := controller.SyncNodes(cluster)
+			if condition.Status != test.wantStatus || condition.Reason != test.wantReason {
+				t.Fatalf("got %s %s: %s", condition.Status, condition.Reason, condition.Message)
+			}
+			if !reflect.DeepEqual(cluster.Status.Nodes, test.wantNodes) {
+				t.Fatalf("got nodes %v", cluster.Status.Nodes)
+			}
+		})
+	}
+}
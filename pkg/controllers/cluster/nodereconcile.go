@@ -0,0 +1,292 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+	"github.com/kubean-io/kubean/pkg/util"
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ApproveNodeRemovalAnno lists the comma separated nodes which ReconcileNodes is allowed to remove.
+	ApproveNodeRemovalAnno = "kubean.io/approve-node-removal"
+	// NodeReconcileLabelKey marks the ClusterOperations created by ReconcileNodes with their playbooks.
+	NodeReconcileLabelKey = "kubean.io/node-reconcile"
+)
+
+// The reasons of the NodesReconciled condition.
+const (
+	NodesInSyncReason         = "InSync"
+	OperationInProgressReason = "OperationInProgress"
+	NoAppliedInventoryReason  = "NoAppliedInventory"
+	RemovalNotApprovedReason  = "RemovalNotApproved"
+	ScalingNodesReason        = "ScalingNodes"
+	RemovingNodesReason       = "RemovingNodes"
+	NodeReconcileFailedReason = "ReconcileFailed"
+	NodeReconcileErrorReason  = "ReconcileError"
+)
+
+var removedNodesPattern = regexp.MustCompile(`(?:^|[\s"'])node=["']?([^\s"']+)`)
+
+// RemovedNodes returns the nodes passed to remove-node.yml by `-e node=`.
+func RemovedNodes(extraArgs string) []string {
+	nodes := make([]string, 0)
+	for _, match := range removedNodesPattern.FindAllStringSubmatch(extraArgs, -1) {
+		for _, node := range strings.Split(match[1], ",") {
+			if node = strings.TrimSpace(node); node != "" {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+// ApprovedNodeRemovals returns the nodes listed in the annotation kubean.io/approve-node-removal.
+func ApprovedNodeRemovals(cluster *clusterv1alpha1.Cluster) map[string]struct{} {
+	approved := map[string]struct{}{}
+	for _, node := range strings.Split(cluster.Annotations[ApproveNodeRemovalAnno], ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			approved[node] = struct{}{}
+		}
+	}
+	return approved
+}
+
+// consumeNodeRemovalApprovals removes the nodes from the annotation kubean.io/approve-node-removal once their removal
+// has been created, so that the node added back later with the same name needs the approval again.
+func (c *Controller) consumeNodeRemovalApprovals(cluster *clusterv1alpha1.Cluster, removed []string) error {
+	consumed := map[string]struct{}{}
+	for _, node := range removed {
+		consumed[node] = struct{}{}
+	}
+	remaining := make([]string, 0)
+	for _, node := range strings.Split(cluster.Annotations[ApproveNodeRemovalAnno], ",") {
+		if node = strings.TrimSpace(node); node != "" {
+			if _, ok := consumed[node]; !ok {
+				remaining = append(remaining, node)
+			}
+		}
+	}
+	// the status of the cluster in memory is not updated yet, so the annotation is patched with a copy.
+	updated := cluster.DeepCopy()
+	if len(remaining) == 0 {
+		delete(updated.Annotations, ApproveNodeRemovalAnno)
+	} else {
+		updated.Annotations[ApproveNodeRemovalAnno] = strings.Join(remaining, ",")
+	}
+	if err := c.Client.Patch(context.Background(), updated, client.MergeFrom(cluster)); err != nil {
+		return err
+	}
+	cluster.Annotations = updated.Annotations
+	cluster.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+func isInventoryPlaybook(ops *clusteroperationv1alpha1.ClusterOperation) bool {
+	return isPlaybook(ops, entrypoint.ClusterPB) || isPlaybook(ops, entrypoint.ScalePB) || isPlaybook(ops, entrypoint.RemoveNodePB)
+}
+
+func latestOps(operations []clusteroperationv1alpha1.ClusterOperation, filter func(*clusteroperationv1alpha1.ClusterOperation) bool) *clusteroperationv1alpha1.ClusterOperation {
+	var latest *clusteroperationv1alpha1.ClusterOperation
+	for i := range operations {
+		if ops := &operations[i]; !ops.Spec.DryRun && filter(ops) && (latest == nil || latest.CreationTimestamp.Before(&ops.CreationTimestamp)) {
+			latest = ops
+		}
+	}
+	return latest
+}
+
+func (c *Controller) readInventory(ref *apis.ConfigMapRef) (string, *ansible.Inventory, error) {
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(ref.NameSpace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	data, ok := configMap.Data[constants.Hosts_yml]
+	if !ok {
+		return "", nil, fmt.Errorf("configmap %s/%s has no %s", ref.NameSpace, ref.Name, constants.Hosts_yml)
+	}
+	inventory, err := ansible.ParseInventory([]byte(data))
+	return data, inventory, err
+}
+
+// difference returns the hosts of a which are not in b.
+func difference(a, b []string) []string {
+	set := map[string]struct{}{}
+	for _, host := range b {
+		set[host] = struct{}{}
+	}
+	result := make([]string, 0)
+	for _, host := range a {
+		if _, ok := set[host]; !ok {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// ReconcileNodes compares the hosts in HostsConfRef with the inventory applied by the last succeeded cluster.yml,
+// scale.yml or remove-node.yml, and creates the ClusterOperation which removes or adds the different nodes.
+// The removal goes first, because remove-node.yml needs the removed nodes in its inventory while scale.yml does not.
+func (c *Controller) ReconcileNodes(cluster *clusterv1alpha1.Cluster, operations []clusteroperationv1alpha1.ClusterOperation) metav1.Condition {
+	condition := metav1.Condition{Type: clusterv1alpha1.NodesReconciledCondition, Status: metav1.ConditionFalse}
+	inProgress := latestOps(operations, func(ops *clusteroperationv1alpha1.ClusterOperation) bool {
+		return ops.Status.Status == "" || ops.Status.Status == clusteroperationv1alpha1.PendingStatus ||
+			ops.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus || ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+	})
+	if inProgress != nil {
+		condition.Reason, condition.Message = OperationInProgressReason, fmt.Sprintf("waiting for clusterOps %s", inProgress.Name)
+		return condition
+	}
+	applied := latestOps(operations, func(ops *clusteroperationv1alpha1.ClusterOperation) bool {
+		return (isInventoryPlaybook(ops) || isPlaybook(ops, entrypoint.ResetPB)) && ops.Status.Status == clusteroperationv1alpha1.SucceededStatus
+	})
+	if applied == nil || isPlaybook(applied, entrypoint.ResetPB) || applied.Spec.HostsConfRef.IsEmpty() {
+		condition.Status, condition.Reason = metav1.ConditionUnknown, NoAppliedInventoryReason
+		condition.Message = "no clusterOps of cluster.yml, scale.yml or remove-node.yml has succeeded"
+		return condition
+	}
+	// the failed clusterOps is not created again and again.
+	if generated := latestOps(operations, func(ops *clusteroperationv1alpha1.ClusterOperation) bool {
+		return ops.Labels[NodeReconcileLabelKey] != ""
+	}); generated != nil && generated.Status.Status != clusteroperationv1alpha1.SucceededStatus && applied.CreationTimestamp.Before(&generated.CreationTimestamp) {
+		condition.Reason = NodeReconcileFailedReason
+		condition.Message = fmt.Sprintf("clusterOps %s is %s, delete it to reconcile the nodes again", generated.Name, generated.Status.Status)
+		return condition
+	}
+	_, appliedInventory, err := c.readInventory(applied.Spec.HostsConfRef)
+	if err != nil {
+		condition.Status, condition.Reason = metav1.ConditionUnknown, NoAppliedInventoryReason
+		condition.Message = fmt.Sprintf("failed to read the inventory of clusterOps %s: %v", applied.Name, err)
+		return condition
+	}
+	appliedHosts := appliedInventory.Hosts()
+	if isPlaybook(applied, entrypoint.RemoveNodePB) {
+		appliedHosts = difference(appliedHosts, RemovedNodes(applied.Spec.ExtraArgs))
+	}
+	if cluster.Spec.HostsConfRef.IsEmpty() {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionUnknown, InventoryUnavailableReason, "hostsConfRef is not set"
+		return condition
+	}
+	desiredData, desiredInventory, err := c.readInventory(cluster.Spec.HostsConfRef)
+	if err != nil {
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionUnknown, InventoryUnavailableReason, err.Error()
+		return condition
+	}
+	if errs := desiredInventory.Validate(); len(errs) > 0 {
+		condition.Reason, condition.Message = InventoryInvalidReason, "the inventory in hostsConfRef is invalid"
+		return condition
+	}
+	added := difference(desiredInventory.Hosts(), appliedHosts)
+	removed := difference(appliedHosts, desiredInventory.Hosts())
+	switch {
+	case len(removed) > 0:
+		approved := ApprovedNodeRemovals(cluster)
+		for _, node := range removed {
+			if _, ok := approved[node]; !ok {
+				condition.Reason = RemovalNotApprovedReason
+				condition.Message = fmt.Sprintf("removing nodes %s requires the annotation %s=%s", strings.Join(removed, ","), ApproveNodeRemovalAnno, strings.Join(removed, ","))
+				return condition
+			}
+		}
+		ops, err := c.createRemoveNodeOps(cluster, applied, appliedInventory, desiredData, removed)
+		if err != nil {
+			klog.ErrorS(err, "failed to create clusterOps to remove nodes", "cluster", cluster.Name, "nodes", removed)
+			condition.Reason, condition.Message = NodeReconcileErrorReason, err.Error()
+			return condition
+		}
+		if err := c.consumeNodeRemovalApprovals(cluster, removed); err != nil {
+			klog.ErrorS(err, "failed to clear the approved node removals", "cluster", cluster.Name, "nodes", removed)
+		}
+		c.recordEvent(cluster, corev1.EventTypeNormal, RemovingNodesReason, "created clusterOps %s to remove nodes %s", ops.Name, strings.Join(removed, ","))
+		condition.Reason, condition.Message = RemovingNodesReason, fmt.Sprintf("clusterOps %s removes nodes %s", ops.Name, strings.Join(removed, ","))
+	case len(added) > 0:
+		ops, err := c.createNodeOps(cluster, applied, entrypoint.ScalePB, fmt.Sprintf("--limit=%s", strings.Join(added, ",")), nil)
+		if err != nil {
+			klog.ErrorS(err, "failed to create clusterOps to add nodes", "cluster", cluster.Name, "nodes", added)
+			condition.Reason, condition.Message = NodeReconcileErrorReason, err.Error()
+			return condition
+		}
+		c.recordEvent(cluster, corev1.EventTypeNormal, ScalingNodesReason, "created clusterOps %s to add nodes %s", ops.Name, strings.Join(added, ","))
+		condition.Reason, condition.Message = ScalingNodesReason, fmt.Sprintf("clusterOps %s adds nodes %s", ops.Name, strings.Join(added, ","))
+	default:
+		condition.Status, condition.Reason = metav1.ConditionTrue, NodesInSyncReason
+		condition.Message = fmt.Sprintf("the nodes are applied by clusterOps %s", applied.Name)
+	}
+	return condition
+}
+
+// createRemoveNodeOps runs remove-node.yml with the desired inventory plus the removed nodes, instead of the backup of
+// HostsConfRef where the removed nodes are absent.
+func (c *Controller) createRemoveNodeOps(cluster *clusterv1alpha1.Cluster, applied *clusteroperationv1alpha1.ClusterOperation,
+	appliedInventory *ansible.Inventory, desiredData string, removed []string,
+) (*clusteroperationv1alpha1.ClusterOperation, error) {
+	hosts, err := ansible.MergeHosts([]byte(desiredData), appliedInventory, removed)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(util.TenantNamespace(cluster)).Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("%s-remove-node-%d", cluster.Spec.HostsConfRef.Name, time.Now().UnixMilli()),
+			Labels: map[string]string{constants.KubeanClusterLabelKey: cluster.Name},
+		},
+		Data: map[string]string{constants.Hosts_yml: string(hosts)},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the configmap is owned by the clusterOps once the clusterOps controller takes it.
+	hostsConfRef := &apis.ConfigMapRef{NameSpace: configMap.Namespace, Name: configMap.Name}
+	ops, err := c.createNodeOps(cluster, applied, entrypoint.RemoveNodePB, fmt.Sprintf("-e node=%s", strings.Join(removed, ",")), hostsConfRef)
+	if err != nil {
+		if deleteErr := c.ClientSet.CoreV1().ConfigMaps(configMap.Namespace).Delete(context.Background(), configMap.Name, metav1.DeleteOptions{}); deleteErr != nil {
+			klog.Warningf("failed to delete configmap %s/%s: %v", configMap.Namespace, configMap.Name, deleteErr)
+		}
+		return nil, err
+	}
+	return ops, nil
+}
+
+// createNodeOps creates the clusterOps with the image of the applied one, and the data of the cluster is backed up
+// by the clusterOps controller except the given hostsConfRef.
+func (c *Controller) createNodeOps(cluster *clusterv1alpha1.Cluster, applied *clusteroperationv1alpha1.ClusterOperation,
+	playbook, extraArgs string, hostsConfRef *apis.ConfigMapRef,
+) (*clusteroperationv1alpha1.ClusterOperation, error) {
+	name := strings.TrimSuffix(playbook, ".yml")
+	actionSource := clusteroperationv1alpha1.BuiltinActionSource
+	ops := &clusteroperationv1alpha1.ClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-%s-%d", cluster.Name, name, time.Now().Unix()),
+			Labels: map[string]string{
+				constants.KubeanClusterLabelKey: cluster.Name,
+				NodeReconcileLabelKey:           name,
+			},
+		},
+		Spec: clusteroperationv1alpha1.Spec{
+			Cluster:      cluster.Name,
+			HostsConfRef: hostsConfRef,
+			ActionType:   clusteroperationv1alpha1.PlaybookActionType,
+			Action:       playbook,
+			ActionSource: &actionSource,
+			ExtraArgs:    extraArgs,
+			Image:        applied.Spec.Image,
+		},
+	}
+	return c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), ops, metav1.CreateOptions{})
+}
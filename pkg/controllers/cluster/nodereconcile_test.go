@@ -0,0 +1,295 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+	clusteroperationv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned/fake"
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const twoNodesHosts = `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 172.30.41.10
+    node2:
+      ip: 172.30.41.11
+      ansible_host: 172.30.41.11
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+        node2:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`
+
+const threeNodesHosts = `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 172.30.41.10
+    node2:
+      ip: 172.30.41.11
+      ansible_host: 172.30.41.11
+    node3:
+      ip: 172.30.41.12
+      ansible_host: 172.30.41.12
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+        node2:
+        node3:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`
+
+const oneNodeHosts = `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 172.30.41.10
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`
+
+func TestRemovedNodes(t *testing.T) {
+	tests := []struct {
+		extraArgs string
+		want      []string
+	}{
+		{extraArgs: "", want: []string{}},
+		{extraArgs: "-e node=node2", want: []string{"node2"}},
+		{extraArgs: "-e reset_nodes=false -e node=node2,node3", want: []string{"node2", "node3"}},
+		{extraArgs: `-e "node=node4"`, want: []string{"node4"}},
+	}
+	for _, test := range tests {
+		if got := RemovedNodes(test.extraArgs); !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%q: got %v", test.extraArgs, got)
+		}
+	}
+}
+
+func TestReconcileNodes(t *testing.T) {
+	appliedOps := func(action, extraArgs string) clusteroperationv1alpha1.ClusterOperation {
+		ops := newOps("cluster1-applied", action, clusteroperationv1alpha1.SucceededStatus, 1)
+		ops.Spec.ExtraArgs = extraArgs
+		ops.Spec.Image = "spray-job:v0.7.0"
+		ops.Spec.HostsConfRef = &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-applied"}
+		return ops
+	}
+	tests := []struct {
+		name        string
+		desired     string
+		applied     string
+		annotations map[string]string
+		operations  []clusteroperationv1alpha1.ClusterOperation
+		wantApprove string
+		wantStatus  metav1.ConditionStatus
+		wantReason  string
+		wantOps     *clusteroperationv1alpha1.Spec
+		wantHosts   []string
+	}{
+		{
+			name:       "no applied inventory",
+			desired:    twoNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{newOps("ops1", "cluster.yml", clusteroperationv1alpha1.FailedStatus, 1)},
+			wantStatus: metav1.ConditionUnknown,
+			wantReason: NoAppliedInventoryReason,
+		},
+		{
+			name:    "operation in progress",
+			desired: threeNodesHosts,
+			applied: twoNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{
+				appliedOps("cluster.yml", ""),
+				newOps("ops2", "upgrade-cluster.yml", clusteroperationv1alpha1.RunningStatus, 2),
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: OperationInProgressReason,
+		},
+		{
+			name:       "in sync",
+			desired:    twoNodesHosts,
+			applied:    twoNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{appliedOps("cluster.yml", "")},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: NodesInSyncReason,
+		},
+		{
+			name:       "in sync after remove-node.yml",
+			desired:    oneNodeHosts,
+			applied:    twoNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{appliedOps("remove-node.yml", "-e node=node2")},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: NodesInSyncReason,
+		},
+		{
+			name:       "add nodes",
+			desired:    threeNodesHosts,
+			applied:    twoNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{appliedOps("cluster.yml", "")},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: ScalingNodesReason,
+			wantOps:    &clusteroperationv1alpha1.Spec{Action: "scale.yml", ExtraArgs: "--limit=node3"},
+		},
+		{
+			name:       "removal not approved",
+			desired:    oneNodeHosts,
+			applied:    threeNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{appliedOps("scale.yml", "--limit=node3")},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: RemovalNotApprovedReason,
+		},
+		{
+			name:        "remove nodes",
+			desired:     oneNodeHosts,
+			applied:     threeNodesHosts,
+			annotations: map[string]string{ApproveNodeRemovalAnno: "node2, node3"},
+			operations:  []clusteroperationv1alpha1.ClusterOperation{appliedOps("scale.yml", "--limit=node3")},
+			wantStatus:  metav1.ConditionFalse,
+			wantReason:  RemovingNodesReason,
+			wantOps:     &clusteroperationv1alpha1.Spec{Action: "remove-node.yml", ExtraArgs: "-e node=node2,node3"},
+			wantHosts:   []string{"node1", "node2", "node3"},
+		},
+		{
+			name:        "keep the approvals of the other nodes",
+			desired:     twoNodesHosts,
+			applied:     threeNodesHosts,
+			annotations: map[string]string{ApproveNodeRemovalAnno: "node3,node4"},
+			operations:  []clusteroperationv1alpha1.ClusterOperation{appliedOps("scale.yml", "--limit=node3")},
+			wantApprove: "node4",
+			wantStatus:  metav1.ConditionFalse,
+			wantReason:  RemovingNodesReason,
+			wantOps:     &clusteroperationv1alpha1.Spec{Action: "remove-node.yml", ExtraArgs: "-e node=node3"},
+			wantHosts:   []string{"node1", "node2", "node3"},
+		},
+		{
+			name:    "the generated clusterOps failed",
+			desired: threeNodesHosts,
+			applied: twoNodesHosts,
+			operations: []clusteroperationv1alpha1.ClusterOperation{
+				appliedOps("cluster.yml", ""),
+				func() clusteroperationv1alpha1.ClusterOperation {
+					ops := newOps("cluster1-scale", "scale.yml", clusteroperationv1alpha1.FailedStatus, 2)
+					ops.Labels = map[string]string{NodeReconcileLabelKey: "scale"}
+					return ops
+				}(),
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: NodeReconcileFailedReason,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller := &Controller{
+				Client:              newFakeClient(),
+				ClientSet:           clientsetfake.NewSimpleClientset(),
+				KubeanClusterOpsSet: clusteroperationv1alpha1fake.NewSimpleClientset(),
+				EventRecorder:       record.NewFakeRecorder(10),
+			}
+			for name, data := range map[string]string{"hosts-desired": test.desired, "hosts-applied": test.applied} {
+				controller.ClientSet.CoreV1().ConfigMaps("kubean-system").Create(context.Background(), &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kubean-system"},
+					Data:       map[string]string{constants.Hosts_yml: data},
+				}, metav1.CreateOptions{})
+			}
+			cluster := &clusterv1alpha1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Annotations: test.annotations},
+				Spec: clusterv1alpha1.Spec{
+					HostsConfRef:   &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-desired"},
+					ReconcileNodes: true,
+				},
+			}
+			controller.Client.Create(context.Background(), cluster)
+			condition := controller.ReconcileNodes(cluster, test.operations)
+			if condition.Status != test.wantStatus || condition.Reason != test.wantReason {
+				t.Fatalf("got %s %s: %s", condition.Status, condition.Reason, condition.Message)
+			}
+			if test.wantOps != nil && test.wantOps.Action == "remove-node.yml" {
+				// the approvals of the removed nodes are consumed.
+				stored := &clusterv1alpha1.Cluster{}
+				controller.Client.Get(context.Background(), client.ObjectKey{Name: "cluster1"}, stored)
+				if got, ok := stored.Annotations[ApproveNodeRemovalAnno]; got != test.wantApprove || ok != (test.wantApprove != "") {
+					t.Fatalf("got annotation %q", got)
+				}
+			}
+			created, _ := controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().List(context.Background(), metav1.ListOptions{})
+			if test.wantOps == nil {
+				if len(created.Items) != 0 {
+					t.Fatalf("unexpected clusterOps %v", created.Items)
+				}
+				return
+			}
+			if len(created.Items) != 1 {
+				t.Fatalf("got %d clusterOps", len(created.Items))
+			}
+			ops := created.Items[0]
+			if ops.Spec.Action != test.wantOps.Action || ops.Spec.ExtraArgs != test.wantOps.ExtraArgs || ops.Spec.Image != "spray-job:v0.7.0" ||
+				ops.Spec.Cluster != "cluster1" || ops.Labels[constants.KubeanClusterLabelKey] != "cluster1" || ops.Labels[NodeReconcileLabelKey] == "" {
+				t.Fatalf("got clusterOps %+v", ops)
+			}
+			if test.wantHosts == nil {
+				if !ops.Spec.HostsConfRef.IsEmpty() {
+					t.Fatalf("hostsConfRef should be backed up by the clusterOps controller")
+				}
+				return
+			}
+			configMap, err := controller.ClientSet.CoreV1().ConfigMaps(ops.Spec.HostsConfRef.NameSpace).Get(context.Background(), ops.Spec.HostsConfRef.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			inventory, err := ansible.ParseInventory([]byte(configMap.Data[constants.Hosts_yml]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hosts := inventory.GroupHosts(ansible.NodeGroup); !reflect.DeepEqual(hosts, test.wantHosts) {
+				t.Fatalf("got hosts %v", hosts)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/metrics"
+	"github.com/kubean-io/kubean/pkg/util"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// CreatedByAnnoKey is set to the user who creates the clusterOps by kubean-admission.
+	CreatedByAnnoKey = "kubean.io/created-by"
+	// ApprovedByAnnoKey is set by the user who approves the clusterOps whose action requires approval.
+	ApprovedByAnnoKey = "kubean.io/approved-by"
+)
+
+// LoopForApproval is the longest interval to check the approval, in case the update of the annotation is missed.
+const LoopForApproval = time.Minute
+
+// RequiresApproval returns whether the action, any hook or any playbook in their extraArgs of the clusterOps is one of
+// the approvalRequiredActions. The extraArgs which can not be parsed always requires approval.
+func RequiresApproval(clusterOps *clusteroperationv1alpha1.ClusterOperation, approvalRequiredActions []string) bool {
+	required := sets.NewString(approvalRequiredActions...)
+	for _, part := range actionParts(clusterOps) {
+		if required.Has(strings.TrimSpace(part.action)) {
+			return true
+		}
+		if part.actionType != clusteroperationv1alpha1.PlaybookActionType || part.extraArgs == "" {
+			continue
+		}
+		playbooks, err := extraPlaybooks(part.extraArgs)
+		if err != nil {
+			return true
+		}
+		for _, playbook := range playbooks {
+			if required.Has(playbook) || required.Has(path.Base(playbook)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsApproved returns whether the clusterOps needs no approval or has been approved by a user other than its creator.
+// The clusterOps whose creator is not recorded by kubean-admission is never approved.
+func IsApproved(clusterOps *clusteroperationv1alpha1.ClusterOperation, approvalRequiredActions []string) bool {
+	if clusterOps.Status.ApprovedBy != "" || !RequiresApproval(clusterOps, approvalRequiredActions) {
+		return true
+	}
+	creator, approver := clusterOps.Annotations[CreatedByAnnoKey], clusterOps.Annotations[ApprovedByAnnoKey]
+	return creator != "" && approver != "" && approver != creator
+}
+
+// WaitForApproval holds the clusterOps in PendingApproval until it is approved, and returns how long to wait.
+// The clusterOps not approved within the approval timeout since it entered PendingApproval is updated Failed.
+func (c *Controller) WaitForApproval(clusterOps *clusteroperationv1alpha1.ClusterOperation) (time.Duration, error) {
+	if !clusterOps.Status.JobRef.IsEmpty() || clusterOps.Status.ApprovedBy != "" {
+		return 0, nil
+	}
+	config := util.FetchKubeanConfigProperty(c.ClientSet)
+	if !RequiresApproval(clusterOps, config.GetApprovalRequiredActions()) {
+		return 0, nil
+	}
+	now := time.Now()
+	if IsApproved(clusterOps, config.GetApprovalRequiredActions()) {
+		approver := clusterOps.Annotations[ApprovedByAnnoKey]
+		klog.Warningf("clusterOps %s is approved by %s", clusterOps.Name, approver)
+		clusterOps.Status.ApprovedBy = approver
+		if clusterOps.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus {
+			clusterOps.Status.Status = clusteroperationv1alpha1.PendingStatus
+			clusterOps.Status.Message = ""
+		}
+		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+			return 0, err
+		}
+		c.recordEvent(clusterOps, corev1.EventTypeNormal, ApprovedReason, "clusterOps approved by %s", approver)
+		return 0, nil
+	}
+	requestedTime := clusterOps.Status.ApprovalRequestedTime
+	if requestedTime == nil {
+		requestedTime = &metav1.Time{Time: now}
+	}
+	deadline := requestedTime.Add(time.Duration(config.GetApprovalTimeoutSeconds()) * time.Second)
+	if !now.Before(deadline) {
+		message := fmt.Sprintf("not approved within %d seconds", config.GetApprovalTimeoutSeconds())
+		klog.Errorf("clusterOps %s is %s and update status Failed", clusterOps.Name, message)
+		clusterOps.Status.Status = clusteroperationv1alpha1.FailedStatus
+		clusterOps.Status.Message = message
+		clusterOps.Status.EndTime = &metav1.Time{Time: now}
+		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+			return 0, err
+		}
+		c.recordFailed(clusterOps, message)
+		metrics.ObserveFinishedClusterOperation(clusterOps)
+		return 0, nil
+	}
+	if clusterOps.Status.Status != clusteroperationv1alpha1.PendingApprovalStatus || clusterOps.Status.ApprovalRequestedTime == nil {
+		message := fmt.Sprintf("waiting for the approval by a user other than %q before %s", clusterOps.Annotations[CreatedByAnnoKey], deadline.Format(time.RFC3339))
+		if clusterOps.Annotations[CreatedByAnnoKey] == "" {
+			message = fmt.Sprintf("never approved since the creator is not recorded by kubean-admission, and fails at %s", deadline.Format(time.RFC3339))
+		}
+		klog.Warningf("clusterOps %s is %s", clusterOps.Name, message)
+		clusterOps.Status.Status = clusteroperationv1alpha1.PendingApprovalStatus
+		clusterOps.Status.Message = message
+		clusterOps.Status.ApprovalRequestedTime = requestedTime
+		// the clusterOps enters the queue once approved.
+		clusterOps.Status.QueuePosition = 0
+		clusterOps.Status.BlockedBy = ""
+		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+			return 0, err
+		}
+		c.recordEvent(clusterOps, corev1.EventTypeNormal, ApprovalRequestedReason, "clusterOps %s", message)
+	}
+	if wait := deadline.Sub(now); wait < LoopForApproval {
+		return wait, nil
+	}
+	return LoopForApproval, nil
+}
+
+// ValidateApproval checks the creator of the clusterOps is the requesting user, and the approver is the requesting
+// user other than the creator. The oldOps is nil at creation. Neither of them can be changed once set.
+func ValidateApproval(newOps, oldOps *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) field.ErrorList {
+	errs := field.ErrorList{}
+	annotationsPath := field.NewPath("metadata", "annotations")
+	creator, approver := newOps.Annotations[CreatedByAnnoKey], newOps.Annotations[ApprovedByAnnoKey]
+	if oldOps == nil {
+		if creator != "" && creator != userInfo.Username {
+			errs = append(errs, field.Forbidden(annotationsPath.Key(CreatedByAnnoKey), fmt.Sprintf("must be the creator %s", userInfo.Username)))
+		}
+		if approver != "" {
+			errs = append(errs, field.Forbidden(annotationsPath.Key(ApprovedByAnnoKey), "can not be approved at creation"))
+		}
+		return errs
+	}
+	if creator != oldOps.Annotations[CreatedByAnnoKey] {
+		errs = append(errs, field.Forbidden(annotationsPath.Key(CreatedByAnnoKey), "field is immutable"))
+	}
+	oldApprover := oldOps.Annotations[ApprovedByAnnoKey]
+	switch {
+	case approver == oldApprover:
+	case oldApprover != "":
+		errs = append(errs, field.Forbidden(annotationsPath.Key(ApprovedByAnnoKey), fmt.Sprintf("has been approved by %s", oldApprover)))
+	case approver != userInfo.Username:
+		errs = append(errs, field.Forbidden(annotationsPath.Key(ApprovedByAnnoKey), fmt.Sprintf("user %s can not approve on behalf of %s", userInfo.Username, approver)))
+	case approver == creator:
+		errs = append(errs, field.Forbidden(annotationsPath.Key(ApprovedByAnnoKey), fmt.Sprintf("must be approved by a user other than the creator %s", creator)))
+	}
+	return errs
+}
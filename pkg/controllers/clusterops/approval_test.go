@@ -0,0 +1,188 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsApproved(t *testing.T) {
+	actions := []string{"reset.yml", "remove-node.yml"}
+	newOps := func(action string, annotations map[string]string) *clusteroperationv1alpha1.ClusterOperation {
+		return &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1", Annotations: annotations},
+			Spec:       clusteroperationv1alpha1.Spec{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: action},
+		}
+	}
+	hooked := newOps("cluster.yml", nil)
+	hooked.Spec.PostHook = []clusteroperationv1alpha1.HookAction{{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: " reset.yml"}}
+	smuggled := newOps("cluster-info.yml", map[string]string{CreatedByAnnoKey: "alice"})
+	smuggled.Spec.ExtraArgs = "/kubespray/reset.yml -e reset_confirmation=yes"
+	unparsed := newOps("cluster-info.yml", map[string]string{CreatedByAnnoKey: "alice"})
+	unparsed.Spec.ExtraArgs = "-e a=b; ansible-playbook reset.yml"
+	tests := []struct {
+		name     string
+		ops      *clusteroperationv1alpha1.ClusterOperation
+		required bool
+		approved bool
+	}{
+		{name: "action without approval", ops: newOps("cluster.yml", nil), required: false, approved: true},
+		{name: "not approved", ops: newOps("reset.yml", map[string]string{CreatedByAnnoKey: "alice"}), required: true, approved: false},
+		{name: "hook requires approval", ops: hooked, required: true, approved: false},
+		{name: "approved by the creator", ops: newOps("reset.yml", map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "alice"}), required: true, approved: false},
+		{name: "approved by another user", ops: newOps("reset.yml", map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "bob"}), required: true, approved: true},
+		{name: "approved without the creator", ops: newOps("reset.yml", map[string]string{ApprovedByAnnoKey: "bob"}), required: true, approved: false},
+		{name: "playbook in the extraArgs requires approval", ops: smuggled, required: true, approved: false},
+		{name: "extraArgs not parsed requires approval", ops: unparsed, required: true, approved: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if RequiresApproval(test.ops, actions) != test.required || IsApproved(test.ops, actions) != test.approved {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestWaitForApproval(t *testing.T) {
+	genController := func(annotations map[string]string, created time.Time) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:    newFakeClient(),
+			ClientSet: clientsetfake.NewSimpleClientset(),
+		}
+		controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: constants.KubeanConfigMapName, Namespace: util.GetCurrentNSOrDefault()},
+			Data:       map[string]string{"APPROVAL_REQUIRED_ACTIONS": "reset.yml", "APPROVAL_TIMEOUT_SECONDS": "3600"},
+		}, metav1.CreateOptions{})
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1", Annotations: annotations},
+			Spec:       clusteroperationv1alpha1.Spec{Cluster: "cluster1", ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "reset.yml"},
+		}
+		controller.Client.Create(context.Background(), ops)
+		ops.CreationTimestamp = metav1.NewTime(created)
+		return controller, ops
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "action without approval",
+			args: func() bool {
+				controller, ops := genController(nil, time.Now())
+				ops.Spec.Action = "cluster.yml"
+				wait, err := controller.WaitForApproval(ops)
+				return err == nil && wait == 0 && ops.Status.Status == ""
+			},
+			want: true,
+		},
+		{
+			name: "wait for the approval",
+			args: func() bool {
+				controller, ops := genController(map[string]string{CreatedByAnnoKey: "alice"}, time.Now())
+				wait, err := controller.WaitForApproval(ops)
+				return err == nil && wait == LoopForApproval && ops.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus &&
+					strings.HasPrefix(ops.Status.Message, `waiting for the approval by a user other than "alice"`)
+			},
+			want: true,
+		},
+		{
+			name: "approved by another user",
+			args: func() bool {
+				controller, ops := genController(map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "bob"}, time.Now())
+				ops.Status.Status = clusteroperationv1alpha1.PendingApprovalStatus
+				wait, err := controller.WaitForApproval(ops)
+				return err == nil && wait == 0 && ops.Status.Status == clusteroperationv1alpha1.PendingStatus && ops.Status.ApprovedBy == "bob"
+			},
+			want: true,
+		},
+		{
+			name: "approval timeout",
+			args: func() bool {
+				controller, ops := genController(map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "alice"}, time.Now().Add(-2*time.Hour))
+				ops.Status.Status = clusteroperationv1alpha1.PendingApprovalStatus
+				ops.Status.ApprovalRequestedTime = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+				wait, err := controller.WaitForApproval(ops)
+				return err == nil && wait == 0 && ops.Status.Status == clusteroperationv1alpha1.FailedStatus && ops.Status.EndTime != nil &&
+					ops.Status.Message == "not approved within 3600 seconds"
+			},
+			want: true,
+		},
+		{
+			name: "the approval timeout starts when it enters PendingApproval",
+			args: func() bool {
+				controller, ops := genController(map[string]string{CreatedByAnnoKey: "alice"}, time.Now().Add(-2*time.Hour))
+				ops.Status.Status = clusteroperationv1alpha1.PendingStatus
+				ops.Status.QueuePosition, ops.Status.BlockedBy = 1, "ops0"
+				wait, err := controller.WaitForApproval(ops)
+				return err == nil && wait == LoopForApproval && ops.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus &&
+					ops.Status.ApprovalRequestedTime != nil && time.Since(ops.Status.ApprovalRequestedTime.Time) < time.Minute &&
+					ops.Status.QueuePosition == 0 && ops.Status.BlockedBy == ""
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestValidateApproval(t *testing.T) {
+	newOps := func(annotations map[string]string) *clusteroperationv1alpha1.ClusterOperation {
+		return &clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "ops1", Annotations: annotations}}
+	}
+	created := newOps(map[string]string{CreatedByAnnoKey: "alice"})
+	tests := []struct {
+		name   string
+		newOps *clusteroperationv1alpha1.ClusterOperation
+		oldOps *clusteroperationv1alpha1.ClusterOperation
+		user   string
+		want   []string
+	}{
+		{name: "create", newOps: created, user: "alice", want: []string{}},
+		{
+			name: "create on behalf of another user", newOps: newOps(map[string]string{CreatedByAnnoKey: "bob", ApprovedByAnnoKey: "bob"}), user: "alice",
+			want: []string{"metadata.annotations[kubean.io/created-by]", "metadata.annotations[kubean.io/approved-by]"},
+		},
+		{name: "approve", newOps: newOps(map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "bob"}), oldOps: created, user: "bob", want: []string{}},
+		{name: "approve by the creator", newOps: newOps(map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "alice"}), oldOps: created, user: "alice", want: []string{"metadata.annotations[kubean.io/approved-by]"}},
+		{name: "approve on behalf of another user", newOps: newOps(map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "bob"}), oldOps: created, user: "carol", want: []string{"metadata.annotations[kubean.io/approved-by]"}},
+		{
+			name: "change the creator and the approver", newOps: newOps(map[string]string{CreatedByAnnoKey: "carol", ApprovedByAnnoKey: "carol"}),
+			oldOps: newOps(map[string]string{CreatedByAnnoKey: "alice", ApprovedByAnnoKey: "bob"}), user: "carol",
+			want: []string{"metadata.annotations[kubean.io/created-by]", "metadata.annotations[kubean.io/approved-by]"},
+		},
+		{name: "other updates", newOps: created, oldOps: created, user: "system:serviceaccount:kubean-system:kubean", want: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, err := range ValidateApproval(test.newOps, test.oldOps, authenticationv1.UserInfo{Username: test.user}) {
+				got = append(got, err.Field)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
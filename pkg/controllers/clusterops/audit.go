@@ -0,0 +1,174 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// AuditPeriodLabelKey marks the audit configmaps with the month of their records, in the format of 2006-01.
+	AuditPeriodLabelKey = "kubean.io/audit-period"
+	// AuditRecordAnnoKey is set to the audit configmap which holds the record of the finished clusterOps.
+	AuditRecordAnnoKey = "kubean.io/audit-record"
+	auditPeriodLayout  = "2006-01"
+)
+
+// AuditRecord is who ran which action on which cluster and how it ended. The user recorded by kubean-admission is
+// verified, and the clusterOps which escaped kubean-admission has no verified user.
+type AuditRecord struct {
+	Cluster         string                              `json:"cluster"`
+	Operation       string                              `json:"operation"`
+	UID             types.UID                           `json:"uid"`
+	User            string                              `json:"user,omitempty"`
+	UserVerified    bool                                `json:"userVerified"`
+	ApprovedBy      string                              `json:"approvedBy,omitempty"`
+	ActionType      clusteroperationv1alpha1.ActionType `json:"actionType"`
+	Action          string                              `json:"action"`
+	ExtraArgs       string                              `json:"extraArgs,omitempty"`
+	Image           string                              `json:"image"`
+	Digest          string                              `json:"digest,omitempty"`
+	Result          clusteroperationv1alpha1.OpsStatus  `json:"result"`
+	Message         string                              `json:"message,omitempty"`
+	CreationTime    metav1.Time                         `json:"creationTime"`
+	StartTime       *metav1.Time                        `json:"startTime,omitempty"`
+	EndTime         *metav1.Time                        `json:"endTime,omitempty"`
+	DurationSeconds int64                               `json:"durationSeconds"`
+}
+
+// NewAuditRecord returns the audit record of the finished clusterOps.
+func NewAuditRecord(clusterOps *clusteroperationv1alpha1.ClusterOperation) *AuditRecord {
+	record := &AuditRecord{
+		Cluster:      clusterOps.Spec.Cluster,
+		Operation:    clusterOps.Name,
+		UID:          clusterOps.UID,
+		User:         clusterOps.Annotations[CreatedByAnnoKey],
+		UserVerified: clusterOps.Annotations[CreatedByAnnoKey] != "",
+		ApprovedBy:   clusterOps.Status.ApprovedBy,
+		ActionType:   clusterOps.Spec.ActionType,
+		Action:       clusterOps.Spec.Action,
+		ExtraArgs:    clusterOps.Spec.ExtraArgs,
+		Image:        clusterOps.Spec.Image,
+		Digest:       clusterOps.Status.Digest,
+		Result:       clusterOps.Status.Status,
+		Message:      clusterOps.Status.Message,
+		CreationTime: clusterOps.CreationTimestamp,
+		StartTime:    clusterOps.Status.StartTime,
+		EndTime:      clusterOps.Status.EndTime,
+	}
+	if record.StartTime != nil && record.EndTime != nil && record.EndTime.After(record.StartTime.Time) {
+		record.DurationSeconds = int64(record.EndTime.Sub(record.StartTime.Time).Seconds())
+	}
+	return record
+}
+
+// Period returns the month the record belongs to, by the time the clusterOps ended or else was created.
+func (record *AuditRecord) Period() string {
+	if record.EndTime != nil {
+		return record.EndTime.UTC().Format(auditPeriodLayout)
+	}
+	return record.CreationTime.UTC().Format(auditPeriodLayout)
+}
+
+// Key identifies the record in the audit configmap, because the name of the pruned clusterOps may be reused.
+func (record *AuditRecord) Key() string {
+	return fmt.Sprintf("%s.%s", record.Operation, record.UID)
+}
+
+// AuditConfigMapName returns the name of the audit configmap which holds the record.
+func AuditConfigMapName(record *AuditRecord) string {
+	return fmt.Sprintf("%s-audit-%s-%s", record.Cluster, record.Period(), record.UID)
+}
+
+// AuditClusterOperation writes the record of the finished clusterOps to an immutable audit configmap of its cluster.
+// The audit configmaps live in the kubean namespace without ownerReferences, so that they outlive the pruned
+// clusterOps and the deleted cluster until the retention of kubean-config expires.
+func (c *Controller) AuditClusterOperation(clusterOps *clusteroperationv1alpha1.ClusterOperation) error {
+	if !IsFinished(clusterOps) || clusterOps.Annotations[AuditRecordAnnoKey] != "" {
+		return nil
+	}
+	record := NewAuditRecord(clusterOps)
+	name, created, err := c.writeAuditRecord(record)
+	if err != nil {
+		return err
+	}
+	klog.Warningf("audit clusterOps %s in configmap %s", clusterOps.Name, name)
+	if created {
+		if err := c.PruneAuditRecords(util.FetchKubeanConfigProperty(c.ClientSet).GetAuditRetentionDays(), time.Now()); err != nil {
+			// prune is best effort and should not block the audit.
+			klog.ErrorS(err, "failed to prune audit records")
+		}
+	}
+	if clusterOps.Annotations == nil {
+		clusterOps.Annotations = map[string]string{}
+	}
+	clusterOps.Annotations[AuditRecordAnnoKey] = name
+	return c.Client.Update(context.Background(), clusterOps)
+}
+
+// writeAuditRecord creates the immutable audit configmap of the record, and returns the configmap name and whether
+// it is newly created. The record written before is kept as it is, so that the history can never be rewritten.
+func (c *Controller) writeAuditRecord(record *AuditRecord) (string, bool, error) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return "", false, err
+	}
+	immutable := true
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AuditConfigMapName(record),
+			Namespace: util.GetCurrentNSOrDefault(),
+			Labels: map[string]string{
+				constants.KubeanClusterLabelKey: record.Cluster,
+				AuditPeriodLabelKey:             record.Period(),
+			},
+		},
+		Data:      map[string]string{record.Key(): string(value)},
+		Immutable: &immutable,
+	}
+	_, err = c.ClientSet.CoreV1().ConfigMaps(configMap.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return configMap.Name, false, nil
+	}
+	return configMap.Name, err == nil, err
+}
+
+// PruneAuditRecords deletes the audit configmaps whose period ended retentionDays before now.
+func (c *Controller) PruneAuditRecords(retentionDays int, now time.Time) error {
+	namespace := util.GetCurrentNSOrDefault()
+	configMaps, err := c.ClientSet.CoreV1().ConfigMaps(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: AuditPeriodLabelKey})
+	if err != nil {
+		return err
+	}
+	expired := now.AddDate(0, 0, -retentionDays)
+	for _, configMap := range configMaps.Items {
+		period, err := time.Parse(auditPeriodLayout, configMap.Labels[AuditPeriodLabelKey])
+		if err != nil || !period.AddDate(0, 1, 0).Before(expired) {
+			continue
+		}
+		klog.Warningf("delete expired audit configmap %s", configMap.Name)
+		if err := c.ClientSet.CoreV1().ConfigMaps(namespace).Delete(context.Background(), configMap.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAuditClusterOperation(t *testing.T) {
+	endTime := time.Date(2026, time.October, 17, 10, 0, 0, 0, time.UTC)
+	genController := func(name string, status clusteroperationv1alpha1.OpsStatus) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:    newFakeClient(),
+			ClientSet: clientsetfake.NewSimpleClientset(),
+		}
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID("uid-" + name), Annotations: map[string]string{CreatedByAnnoKey: "alice"}},
+			Spec: clusteroperationv1alpha1.Spec{
+				Cluster: "cluster1", ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "reset.yml",
+				ExtraArgs: "-e reset_confirmation=yes", Image: "ghcr.io/kubean-io/spray-job:latest",
+			},
+			Status: clusteroperationv1alpha1.Status{
+				Status: status, ApprovedBy: "bob", Digest: "sha256:abc",
+				StartTime: &metav1.Time{Time: endTime.Add(-90 * time.Second)}, EndTime: &metav1.Time{Time: endTime},
+			},
+		}
+		controller.Client.Create(context.Background(), ops)
+		return controller, ops
+	}
+	fetchRecord := func(controller *Controller, name, key string) *AuditRecord {
+		configMap, err := controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil || configMap.Labels[AuditPeriodLabelKey] != "2026-10" || configMap.Labels[constants.KubeanClusterLabelKey] != "cluster1" {
+			return nil
+		}
+		record := &AuditRecord{}
+		if json.Unmarshal([]byte(configMap.Data[key]), record) != nil {
+			return nil
+		}
+		return record
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "unfinished clusterOps",
+			args: func() bool {
+				controller, ops := genController("ops1", clusteroperationv1alpha1.RunningStatus)
+				return controller.AuditClusterOperation(ops) == nil && ops.Annotations[AuditRecordAnnoKey] == ""
+			},
+			want: true,
+		},
+		{
+			name: "write the record once",
+			args: func() bool {
+				controller, ops := genController("ops1", clusteroperationv1alpha1.SucceededStatus)
+				if controller.AuditClusterOperation(ops) != nil || ops.Annotations[AuditRecordAnnoKey] != "cluster1-audit-2026-10-uid-ops1" {
+					return false
+				}
+				record := fetchRecord(controller, "cluster1-audit-2026-10-uid-ops1", "ops1.uid-ops1")
+				if record == nil || record.User != "alice" || !record.UserVerified || record.ApprovedBy != "bob" || record.Action != "reset.yml" || record.ExtraArgs != "-e reset_confirmation=yes" ||
+					record.Image != "ghcr.io/kubean-io/spray-job:latest" || record.Digest != "sha256:abc" || record.Result != clusteroperationv1alpha1.SucceededStatus ||
+					record.DurationSeconds != 90 {
+					return false
+				}
+				ops.Status.Status = clusteroperationv1alpha1.FailedStatus
+				delete(ops.Annotations, AuditRecordAnnoKey)
+				if controller.AuditClusterOperation(ops) != nil || ops.Annotations[AuditRecordAnnoKey] != "cluster1-audit-2026-10-uid-ops1" {
+					return false
+				}
+				return fetchRecord(controller, "cluster1-audit-2026-10-uid-ops1", "ops1.uid-ops1").Result == clusteroperationv1alpha1.SucceededStatus
+			},
+			want: true,
+		},
+		{
+			name: "one immutable configmap per record",
+			args: func() bool {
+				controller, ops1 := genController("ops1", clusteroperationv1alpha1.SucceededStatus)
+				ops2 := ops1.DeepCopy()
+				ops2.Name, ops2.UID, ops2.ResourceVersion = "ops2", types.UID("uid-ops2"), ""
+				controller.Client.Create(context.Background(), ops2)
+				if controller.AuditClusterOperation(ops1) != nil || controller.AuditClusterOperation(ops2) != nil {
+					return false
+				}
+				configMaps, _ := controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).List(context.Background(), metav1.ListOptions{LabelSelector: AuditPeriodLabelKey})
+				for _, configMap := range configMaps.Items {
+					if configMap.Immutable == nil || !*configMap.Immutable || len(configMap.Data) != 1 {
+						return false
+					}
+				}
+				return len(configMaps.Items) == 2 && fetchRecord(controller, "cluster1-audit-2026-10-uid-ops2", "ops2.uid-ops2") != nil
+			},
+			want: true,
+		},
+		{
+			name: "the user not recorded by kubean-admission",
+			args: func() bool {
+				controller, ops := genController("ops1", clusteroperationv1alpha1.SucceededStatus)
+				delete(ops.Annotations, CreatedByAnnoKey)
+				if controller.AuditClusterOperation(ops) != nil {
+					return false
+				}
+				record := fetchRecord(controller, "cluster1-audit-2026-10-uid-ops1", "ops1.uid-ops1")
+				return record != nil && record.User == "" && !record.UserVerified
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestPruneAuditRecords(t *testing.T) {
+	controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+	for name, period := range map[string]string{"cluster1-audit-2025-09-uid-ops1": "2025-09", "cluster1-audit-2025-11-uid-ops2": "2025-11", "other": ""} {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: util.GetCurrentNSOrDefault()}}
+		if period != "" {
+			configMap.Labels = map[string]string{AuditPeriodLabelKey: period}
+		}
+		controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).Create(context.Background(), configMap, metav1.CreateOptions{})
+	}
+	if err := controller.PruneAuditRecords(365, time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	configMaps, _ := controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).List(context.Background(), metav1.ListOptions{})
+	names := make([]string, 0)
+	for _, configMap := range configMaps.Items {
+		names = append(names, configMap.Name)
+	}
+	if strings.Join(names, ",") != "cluster1-audit-2025-11-uid-ops2,other" {
+		t.Fatalf("got %v", names)
+	}
+}
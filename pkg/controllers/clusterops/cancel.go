@@ -0,0 +1,113 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/metrics"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// CancelGracePeriodSeconds gives ansible time to finish the current task after receiving SIGTERM.
+const CancelGracePeriodSeconds = int64(300)
+
+// CancelledByAnnoKey is set to the user who sets spec.cancel by kubean-admission.
+const CancelledByAnnoKey = "kubean.io/cancelled-by"
+
+// CancelledBy returns the user recorded by kubean-admission and the time which spec.cancel was set according to managedFields.
+func CancelledBy(clusterOps *clusteroperationv1alpha1.ClusterOperation) (string, *metav1.Time) {
+	operationTime := (*metav1.Time)(nil)
+	for _, entry := range clusterOps.ManagedFields {
+		if entry.FieldsV1 == nil || entry.Subresource != "" {
+			continue
+		}
+		fields := map[string]map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["f:spec"]["f:cancel"]; !ok {
+			continue
+		}
+		if operationTime == nil || (entry.Time != nil && operationTime.Before(entry.Time)) {
+			operationTime = entry.Time
+		}
+	}
+	return clusterOps.Annotations[CancelledByAnnoKey], operationTime
+}
+
+func isJobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// TryCancel stops the clusterOps when spec.cancel is set and returns whether it needs to wait for the job pod to terminate.
+func (c *Controller) TryCancel(clusterOps *clusteroperationv1alpha1.ClusterOperation) (bool, error) {
+	if !clusterOps.Spec.Cancel {
+		return false, nil
+	}
+	if !clusterOps.Status.JobRef.IsEmpty() {
+		targetJob, err := c.ClientSet.BatchV1().Jobs(clusterOps.Status.JobRef.NameSpace).Get(context.Background(), clusterOps.Status.JobRef.Name, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		if err == nil {
+			if isJobFinished(targetJob) {
+				// too late to cancel, the job result will be recorded as usual.
+				return false, nil
+			}
+			if targetJob.Spec.Suspend == nil || !*targetJob.Spec.Suspend {
+				// job controller deletes the pod and kubelet sends SIGTERM to entrypoint.sh.
+				klog.Warningf("cancel clusterOps %s and suspend job %s", clusterOps.Name, targetJob.Name)
+				suspend := true
+				targetJob.Spec.Suspend = &suspend
+				if _, err := c.ClientSet.BatchV1().Jobs(targetJob.Namespace).Update(context.Background(), targetJob, metav1.UpdateOptions{}); err != nil {
+					return false, err
+				}
+				return true, nil
+			}
+			if _, err := c.GetRunningPodFromJob(targetJob); err == nil {
+				// ansible is finishing the current task, keep the stages up to date until the pod is gone.
+				if c.SyncStages(clusterOps, clusteroperationv1alpha1.RunningStatus, nil) {
+					if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+						return false, err
+					}
+				}
+				// the pod is deleted once ansible exits, so the log is archived while it is still there.
+				if err := c.archiveJobLog(clusterOps); err != nil {
+					klog.ErrorS(err, "failed to archive job log of the cancelled clusterOps", "clusterOps", clusterOps.Name)
+				}
+				return true, nil
+			}
+		}
+	}
+	clusterOps.Status.Status = clusteroperationv1alpha1.CancelledStatus
+	clusterOps.Status.EndTime = &metav1.Time{Time: time.Now()}
+	clusterOps.Status.CancelledBy, clusterOps.Status.CancelledTime = CancelledBy(clusterOps)
+	if clusterOps.Status.CancelledTime == nil {
+		clusterOps.Status.CancelledTime = clusterOps.Status.EndTime
+	}
+	c.SyncStages(clusterOps, clusteroperationv1alpha1.CancelledStatus, clusterOps.Status.EndTime)
+	FinishAttempt(clusterOps, clusteroperationv1alpha1.CancelledStatus, clusterOps.Status.EndTime)
+	klog.Warningf("clusterOps %s is cancelled by %s", clusterOps.Name, clusterOps.Status.CancelledBy)
+	if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+		return false, err
+	}
+	c.recordEvent(clusterOps, corev1.EventTypeNormal, CancelledReason, "cancelled by %s", clusterOps.Status.CancelledBy)
+	metrics.ObserveFinishedClusterOperation(clusterOps)
+	return false, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusterv1alpha1fake "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned/fake"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCancelledBy(t *testing.T) {
+	ops := &clusteroperationv1alpha1.ClusterOperation{}
+	if manager, operationTime := CancelledBy(ops); manager != "" || operationTime != nil {
+		t.Fatalf("got %s %v", manager, operationTime)
+	}
+	ops.Annotations = map[string]string{CancelledByAnnoKey: "alice"}
+	ops.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-create", Time: &metav1.Time{Time: time.Unix(100, 0)}, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:cluster":{}}}`)}},
+		{Manager: "kubectl-patch", Time: &metav1.Time{Time: time.Unix(200, 0)}, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:cancel":{}}}`)}},
+		{Manager: "kubean-operator", Subresource: "status", Time: &metav1.Time{Time: time.Unix(300, 0)}, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{}}`)}},
+	}
+	if user, operationTime := CancelledBy(ops); user != "alice" || operationTime.Unix() != 200 {
+		t.Fatalf("got %s %v", user, operationTime)
+	}
+}
+
+func TestTryCancel(t *testing.T) {
+	genController := func(suspend bool, podPhase corev1.PodPhase, conditions ...batchv1.JobCondition) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:           newFakeClient(),
+			ClientSet:        clientsetfake.NewSimpleClientset(),
+			KubeanClusterSet: clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}),
+		}
+		controller.ClientSet.BatchV1().Jobs("kubean-system").Create(context.Background(), &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+			Spec: batchv1.JobSpec{
+				Suspend:  &suspend,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}},
+			},
+			Status: batchv1.JobStatus{Conditions: conditions},
+		}, metav1.CreateOptions{})
+		if podPhase != "" {
+			controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "kubean-system", Labels: map[string]string{"job-name": "job1"}},
+				Status:     corev1.PodStatus{Phase: podPhase},
+			}, metav1.CreateOptions{})
+		}
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1"},
+			Spec: clusteroperationv1alpha1.Spec{
+				Cluster:    "cluster1",
+				ActionType: clusteroperationv1alpha1.PlaybookActionType,
+				Action:     "cluster.yml",
+				Cancel:     true,
+			},
+		}
+		controller.Client.Create(context.Background(), ops)
+		ops.Status.Status = clusteroperationv1alpha1.RunningStatus
+		ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+		return controller, ops
+	}
+	isSuspended := func(controller *Controller) bool {
+		job, err := controller.ClientSet.BatchV1().Jobs("kubean-system").Get(context.Background(), "job1", metav1.GetOptions{})
+		return err == nil && job.Spec.Suspend != nil && *job.Spec.Suspend
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "cancel is not set",
+			args: func() bool {
+				controller, ops := genController(false, corev1.PodRunning)
+				ops.Spec.Cancel = false
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && !needRequeue && !isSuspended(controller) && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "cancel before the job is created",
+			args: func() bool {
+				controller, ops := genController(false, "")
+				ops.Status.Status = ""
+				ops.Status.JobRef = nil
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && !needRequeue && ops.Status.Status == clusteroperationv1alpha1.CancelledStatus &&
+					ops.Status.CancelledTime != nil && ops.Status.EndTime != nil
+			},
+			want: true,
+		},
+		{
+			name: "suspend the running job",
+			args: func() bool {
+				controller, ops := genController(false, corev1.PodRunning)
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && needRequeue && isSuspended(controller) && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "wait for the pod to terminate",
+			args: func() bool {
+				controller, ops := genController(true, corev1.PodRunning)
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && needRequeue && ops.Status.Status == clusteroperationv1alpha1.RunningStatus && len(ops.Status.Stages) == 1 &&
+					ops.Status.LogRef != nil
+			},
+			want: true,
+		},
+		{
+			name: "pod terminated",
+			args: func() bool {
+				controller, ops := genController(true, "")
+				ops.Status.Stages = []clusteroperationv1alpha1.Stage{{Name: "action", Status: clusteroperationv1alpha1.RunningStatus}}
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && !needRequeue && ops.Status.Status == clusteroperationv1alpha1.CancelledStatus &&
+					ops.Status.Stages[0].Status == clusteroperationv1alpha1.CancelledStatus
+			},
+			want: true,
+		},
+		{
+			name: "job finished before cancel",
+			args: func() bool {
+				controller, ops := genController(false, corev1.PodSucceeded, batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue})
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && !needRequeue && !isSuspended(controller) && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "job is removed",
+			args: func() bool {
+				controller, ops := genController(false, "")
+				ops.Status.JobRef.Name = "job2"
+				needRequeue, err := controller.TryCancel(ops)
+				return err == nil && !needRequeue && ops.Status.Status == clusteroperationv1alpha1.CancelledStatus
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
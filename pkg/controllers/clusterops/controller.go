@@ -5,13 +5,17 @@ package clusterops
 
 import (
 	"context"
-	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
+	"github.com/kubean-io/kubean/pkg/metrics"
 	"github.com/kubean-io/kubean/pkg/util"
 	"github.com/kubean-io/kubean/pkg/util/entrypoint"
 
@@ -31,6 +35,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -53,6 +58,9 @@ type Controller struct {
 	KubeanClusterSet      clusterClientSet.Interface
 	KubeanClusterOpsSet   clusterOperationClientSet.Interface
 	InfoManifestClientSet manifestClientSet.Interface
+	EventRecorder         record.EventRecorder
+	// stageLogCursors keeps the stageLogCursor of each running clusterOps by its UID.
+	stageLogCursors sync.Map
 }
 
 func (c *Controller) Start(ctx context.Context) error {
@@ -61,63 +69,57 @@ func (c *Controller) Start(ctx context.Context) error {
 	return nil
 }
 
-const BaseSlat = "kubean"
+// DigestPrefix marks Status.Digest as the sha256 of the configs which the job runs with.
+const DigestPrefix = "sha256:"
 
-func (c *Controller) CalSalt(clusterOps *clusteroperationv1alpha1.ClusterOperation) string {
-	summaryStr := ""
-	summaryStr += BaseSlat
-	summaryStr += clusterOps.Spec.Cluster
-	summaryStr += string(clusterOps.Spec.ActionType)
-	summaryStr += strings.TrimSpace(clusterOps.Spec.Action)
-	summaryStr += clusterOps.Spec.Image
-	for _, action := range clusterOps.Spec.PreHook {
-		summaryStr += string(action.ActionType)
-		summaryStr += strings.TrimSpace(action.Action)
-	}
-	for _, action := range clusterOps.Spec.PostHook {
-		summaryStr += string(action.ActionType)
-		summaryStr += strings.TrimSpace(action.Action)
+// CalDigest hashes the content of the configmaps which the clusterOps refers to, including the rendered
+// entrypoint.sh, the backup of hosts.yml and group_vars.yml and the custom actions, so that what the job ran is auditable.
+func (c *Controller) CalDigest(clusterOps *clusteroperationv1alpha1.ClusterOperation) (string, error) {
+	hash := sha256.New()
+	for _, ref := range clusterOps.Spec.ConfigDataList() {
+		if ref.IsEmpty() {
+			continue
+		}
+		configMap, err := c.ClientSet.CoreV1().ConfigMaps(ref.NameSpace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		keys := make([]string, 0, len(configMap.Data)+len(configMap.BinaryData))
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+		for key := range configMap.BinaryData {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := configMap.Data[key]
+			if !ok {
+				value = string(configMap.BinaryData[key])
+			}
+			fmt.Fprintf(hash, "%s:%d:%s\n", key, len(value), value)
+		}
 	}
-	return fmt.Sprintf("%x", md5.Sum([]byte(summaryStr)))
+	return DigestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// UpdateClusterOpsStatusDigest records the digest once the entrypoint.sh is rendered and the configs are backed up.
 func (c *Controller) UpdateClusterOpsStatusDigest(clusterOps *clusteroperationv1alpha1.ClusterOperation) (bool, error) {
-	if len(clusterOps.Status.Digest) != 0 {
-		// already has value.
+	if len(clusterOps.Status.Digest) != 0 || clusterOps.Spec.EntrypointSHRef.IsEmpty() {
+		// already has value or not rendered yet.
 		return false, nil
 	}
-	// init salt value.
-	clusterOps.Status.Digest = c.CalSalt(clusterOps)
+	digest, err := c.CalDigest(clusterOps)
+	if err != nil {
+		return false, err
+	}
+	clusterOps.Status.Digest = digest
 	if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (c *Controller) compareDigest(clusterOps *clusteroperationv1alpha1.ClusterOperation) bool {
-	return clusterOps.Status.Digest == c.CalSalt(clusterOps)
-}
-
-func (c *Controller) UpdateStatusHasModified(clusterOps *clusteroperationv1alpha1.ClusterOperation) (bool, error) {
-	if len(clusterOps.Status.Digest) == 0 {
-		return false, nil
-	}
-	if clusterOps.Status.HasModified {
-		// already true.
-		return false, nil
-	}
-	if same := c.compareDigest(clusterOps); !same {
-		// compare
-		clusterOps.Status.HasModified = true
-		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
-			return false, err
-		}
-		klog.Warningf("clusterOps %s Spec has been modified", clusterOps.Name)
-		return true, nil
-	}
-	return false, nil
-}
-
 func (c *Controller) FetchGlobalInfoManifest() (*manifestv1alpha1.Manifest, error) {
 	global, err := c.InfoManifestClientSet.KubeanV1alpha1().Manifests().Get(context.Background(), constants.InfoManifestGlobal, metav1.GetOptions{})
 	if err != nil {
@@ -135,6 +137,11 @@ func (c *Controller) UpdateStatusLoop(clusterOps *clusteroperationv1alpha1.Clust
 		}
 		if jobStatus == clusteroperationv1alpha1.RunningStatus {
 			// still running
+			if c.SyncStages(clusterOps, jobStatus, nil) {
+				if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+					return false, err
+				}
+			}
 			return true, nil // requeue for loop ask for status
 		}
 		// the status  succeed or failed
@@ -143,9 +150,39 @@ func (c *Controller) UpdateStatusLoop(clusterOps *clusteroperationv1alpha1.Clust
 		if completionTime != nil {
 			clusterOps.Status.EndTime = completionTime
 		}
+		c.SyncStages(clusterOps, jobStatus, clusterOps.Status.EndTime)
+		if jobStatus == clusteroperationv1alpha1.FailedStatus && clusterOps.Status.FailureReason == nil && !clusterOps.Status.JobRef.IsEmpty() {
+			clusterOps.Status.FailureReason = c.FetchFailureReason(clusterOps)
+		}
+		if clusterOps.Spec.DryRun && !clusterOps.Status.JobRef.IsEmpty() {
+			clusterOps.Status.HostSummaries = c.FetchHostSummaries(clusterOps)
+		}
+		FinishAttempt(clusterOps, jobStatus, clusterOps.Status.EndTime)
+		if jobStatus == clusteroperationv1alpha1.FailedStatus && c.PrepareRetry(clusterOps) {
+			// the clusterOps keeps running and the next job will be created by CreateKubeSprayJob.
+			clusterOps.Status.Status = clusteroperationv1alpha1.RunningStatus
+			clusterOps.Status.EndTime = nil
+			if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+				return false, err
+			}
+			if _, ok := clusterOps.Annotations[JobActorPodAnnoKey]; ok {
+				// the pod of the next job is not the one suspended by TrySuspendPod.
+				delete(clusterOps.Annotations, JobActorPodAnnoKey)
+				if err := c.Client.Update(context.Background(), clusterOps); err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		}
 		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
 			return false, err
 		}
+		metrics.ObserveFinishedClusterOperation(clusterOps)
+		if jobStatus == clusteroperationv1alpha1.SucceededStatus {
+			c.recordEvent(clusterOps, corev1.EventTypeNormal, SucceededReason, "action %s succeeded", clusterOps.Spec.Action)
+		} else {
+			c.recordFailed(clusterOps, FailureMessage(clusterOps.Status.FailureReason))
+		}
 		return false, nil // need not requeue because the job is finished.
 	}
 	// already finished(succeed or failed)
@@ -205,10 +242,47 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
 	// stop reconcile if the clusterOps has been already finished
-	if clusterOps.Status.Status == clusteroperationv1alpha1.SucceededStatus || clusterOps.Status.Status == clusteroperationv1alpha1.FailedStatus {
+	if clusterOps.Status.Status == clusteroperationv1alpha1.SucceededStatus || clusterOps.Status.Status == clusteroperationv1alpha1.FailedStatus ||
+		clusterOps.Status.Status == clusteroperationv1alpha1.CancelledStatus {
+		c.stageLogCursors.Delete(clusterOps.UID)
+		if err := c.RevokeProvidedSSHAuth(clusterOps); err != nil {
+			klog.ErrorS(err, "failed to revoke the provided ssh credentials", "clusterOps", clusterOps.Name)
+			return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+		}
+		if err := c.ArchiveJobLog(clusterOps); err != nil {
+			klog.ErrorS(err, "failed to archive job log", "clusterOps", clusterOps.Name)
+			return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+		}
+		if err := c.AuditClusterOperation(clusterOps); err != nil {
+			klog.ErrorS(err, "failed to audit clusterOps", "clusterOps", clusterOps.Name)
+			return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+		}
 		return controllerruntime.Result{}, nil
 	}
-	needRequeue, err := c.TrySuspendPod(clusterOps)
+	needRequeue, err := c.TryCancel(clusterOps)
+	if err != nil {
+		klog.ErrorS(err, "failed to cancel clusterOps", "clusterOps", clusterOps.Name)
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
+	if needRequeue {
+		return controllerruntime.Result{RequeueAfter: LoopForJobStatus}, nil
+	}
+	if clusterOps.Status.Status == clusteroperationv1alpha1.CancelledStatus {
+		if err := c.UpdateStatusForLabel(clusterOps); err != nil {
+			klog.Error(err)
+			return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+		}
+		return controllerruntime.Result{}, nil
+	}
+	needRequeue, err = c.TryPreempt(clusterOps)
+	if err != nil {
+		klog.ErrorS(err, "failed to preempt clusterOps", "clusterOps", clusterOps.Name)
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
+	if needRequeue {
+		return controllerruntime.Result{RequeueAfter: LoopForJobStatus}, nil
+	}
+	needRequeue, err = c.TrySuspendPod(clusterOps)
 	if err != nil {
 		klog.ErrorS(err, "failed to TrySuspendPod", "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
@@ -231,6 +305,8 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 		if err := c.Client.Status().Update(ctx, clusterOps); err != nil {
 			klog.Error(err)
 		}
+		c.recordFailed(clusterOps, fmt.Sprintf("wrong image format %s", clusterOps.Spec.Image))
+		metrics.ObserveFinishedClusterOperation(clusterOps)
 		return controllerruntime.Result{}, nil
 	}
 
@@ -240,27 +316,45 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 		if err := c.Client.Status().Update(ctx, clusterOps); err != nil {
 			klog.Error(err)
 		}
+		c.recordFailed(clusterOps, err.Error())
+		metrics.ObserveFinishedClusterOperation(clusterOps)
 		return controllerruntime.Result{}, nil
 	}
-	needRequeue, err = c.UpdateOperationOwnReferenceForCluster(clusterOps, cluster)
+	// the clusterOps is approved before it enters the queue, so that it never blocks others while waiting for the approval.
+	waitForApproval, err := c.WaitForApproval(clusterOps)
 	if err != nil {
-		klog.ErrorS(err, "failed to update ownreference", "cluster", cluster.Name, "clusterOps", clusterOps.Name)
+		klog.ErrorS(err, "failed to check the approval", "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
-	if needRequeue {
-		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	if clusterOps.Status.Status == clusteroperationv1alpha1.FailedStatus {
+		return controllerruntime.Result{}, nil
 	}
-	needRequeue, err = c.UpdateClusterOpsStatusDigest(clusterOps)
+	if waitForApproval > 0 {
+		return controllerruntime.Result{RequeueAfter: waitForApproval}, nil
+	}
+	needRequeue, err = c.WaitInQueue(clusterOps, cluster)
 	if err != nil {
-		klog.ErrorS(err, "failed to get update clusterOps status digest", "clusterOps", clusterOps.Name)
+		klog.ErrorS(err, "failed to check the queue", "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
 	if needRequeue {
+		// waiting for the clusterOps before it.
+		return controllerruntime.Result{RequeueAfter: LoopForJobStatus}, nil
+	}
+	waitForWindow, err := c.WaitForMaintenanceWindow(clusterOps, cluster)
+	if err != nil {
+		klog.ErrorS(err, "failed to check the maintenance window", "cluster", cluster.Name, "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
-	needRequeue, err = c.UpdateStatusHasModified(clusterOps)
+	if clusterOps.Status.Status == clusteroperationv1alpha1.FailedStatus {
+		return controllerruntime.Result{}, nil
+	}
+	if waitForWindow > 0 {
+		return controllerruntime.Result{RequeueAfter: waitForWindow}, nil
+	}
+	needRequeue, err = c.UpdateOperationOwnReferenceForCluster(clusterOps, cluster)
 	if err != nil {
-		klog.ErrorS(err, "failed to update clusterOps status", "clusterOps", clusterOps.Name)
+		klog.ErrorS(err, "failed to update ownreference", "cluster", cluster.Name, "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
 	if needRequeue {
@@ -276,7 +370,7 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
 
-	needRequeue, err = c.CreateEntryPointShellConfigMap(clusterOps)
+	needRequeue, err = c.CreateEntryPointShellConfigMap(clusterOps, cluster)
 	if argsErr, ok := err.(entrypoint.ArgsError); ok {
 		// preHook or postHook or action error args
 		klog.Errorf("clusterOps %s wrong args %s and update status Failed", clusterOps.Name, argsErr.Error())
@@ -284,6 +378,8 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 		if err := c.Client.Status().Update(ctx, clusterOps); err != nil {
 			klog.Error(err)
 		}
+		c.recordFailed(clusterOps, argsErr.Error())
+		metrics.ObserveFinishedClusterOperation(clusterOps)
 		return controllerruntime.Result{Requeue: false}, err
 	}
 	if err != nil {
@@ -294,12 +390,20 @@ func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Reques
 		// something updated.
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
+	needRequeue, err = c.UpdateClusterOpsStatusDigest(clusterOps)
+	if err != nil {
+		klog.ErrorS(err, "failed to get update clusterOps status digest", "clusterOps", clusterOps.Name)
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
+	if needRequeue {
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
 	if err := c.UpdateOwnReferenceToClusterOps(clusterOps); err != nil {
 		klog.ErrorS(err, "failed to update the ownReference configData or secretData", "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
 	}
 
-	needRequeue, err = c.CreateKubeSprayJob(clusterOps)
+	needRequeue, err = c.CreateKubeSprayJob(clusterOps, cluster)
 	if err != nil {
 		klog.ErrorS(err, "failed to create kubespray job", "clusterOps", clusterOps.Name)
 		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
@@ -326,6 +430,9 @@ func (c *Controller) UpdateOperationOwnReferenceForCluster(operation *clusterope
 	if operation.Spec.Cluster != cluster.Name || len(cluster.UID) == 0 { // ignore and return
 		return false, nil
 	}
+	if metav1.GetControllerOf(operation) != nil {
+		return false, nil // owned by its ClusterOperationSchedule, which is deleted with its ClusterOperations.
+	}
 	for i := range operation.OwnerReferences {
 		if operation.OwnerReferences[i].UID == cluster.UID {
 			return false, nil // has been set.
@@ -357,12 +464,11 @@ func (c *Controller) FetchGlobalManifestImageTag() string {
 	return globalManifest.Spec.KubeanVersion
 }
 
-func (c *Controller) NewKubesprayJob(clusterOps *clusteroperationv1alpha1.ClusterOperation, serviceAccountName string) *batchv1.Job {
+func (c *Controller) NewKubesprayJob(clusterOps *clusteroperationv1alpha1.ClusterOperation, namespace, serviceAccountName string) *batchv1.Job {
 	BackoffLimit := int32(0)
+	TerminationGracePeriodSeconds := CancelGracePeriodSeconds
 	DefaultMode := int32(0o700)
-	PrivatekeyMode := int32(0o400)
 	jobName := c.GenerateJobName(clusterOps)
-	namespace := util.GetCurrentNSOrDefault()
 	job := &batchv1.Job{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "batch/v1",
@@ -376,8 +482,9 @@ func (c *Controller) NewKubesprayJob(clusterOps *clusteroperationv1alpha1.Cluste
 			BackoffLimit: &BackoffLimit,
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
-					RestartPolicy:      corev1.RestartPolicyNever,
-					ServiceAccountName: serviceAccountName,
+					RestartPolicy:                 corev1.RestartPolicyNever,
+					ServiceAccountName:            serviceAccountName,
+					TerminationGracePeriodSeconds: &TerminationGracePeriodSeconds,
 					Containers: []corev1.Container{
 						{
 							Name:    SprayJobPodName,
@@ -448,25 +555,7 @@ func (c *Controller) NewKubesprayJob(clusterOps *clusteroperationv1alpha1.Cluste
 	}
 	if !clusterOps.Spec.SSHAuthRef.IsEmpty() {
 		// mount ssh data
-		if len(job.Spec.Template.Spec.Containers) > 0 && job.Spec.Template.Spec.Containers[0].Name == SprayJobPodName {
-			job.Spec.Template.Spec.Containers[0].VolumeMounts = append(job.Spec.Template.Spec.Containers[0].VolumeMounts,
-				corev1.VolumeMount{
-					Name:      "ssh-auth",
-					MountPath: "/auth/ssh-privatekey",
-					SubPath:   "ssh-privatekey",
-					ReadOnly:  true,
-				})
-		}
-		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes,
-			corev1.Volume{
-				Name: "ssh-auth",
-				VolumeSource: corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName:  clusterOps.Spec.SSHAuthRef.Name,
-						DefaultMode: &PrivatekeyMode, // fix Permissions 0644 are too open
-					},
-				},
-			})
+		mountSSHAuth(job, clusterOps.Spec.SSHAuthRef.Name)
 	}
 	if clusterOps.Spec.ActiveDeadlineSeconds != nil && *clusterOps.Spec.ActiveDeadlineSeconds > 0 {
 		job.Spec.ActiveDeadlineSeconds = clusterOps.Spec.ActiveDeadlineSeconds
@@ -480,25 +569,42 @@ func (c *Controller) NewKubesprayJob(clusterOps *clusteroperationv1alpha1.Cluste
 }
 
 func (c *Controller) GenerateJobName(clusterOps *clusteroperationv1alpha1.ClusterOperation) string {
+	if len(clusterOps.Status.Attempts) > 1 {
+		// job name of the retry attempt.
+		return fmt.Sprintf("kubean-%s-job-%d", clusterOps.Name, len(clusterOps.Status.Attempts))
+	}
 	return fmt.Sprintf("kubean-%s-job", clusterOps.Name)
 }
 
-func (c *Controller) CreateKubeSprayJob(clusterOps *clusteroperationv1alpha1.ClusterOperation) (bool, error) {
+func (c *Controller) CreateKubeSprayJob(clusterOps *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster) (bool, error) {
 	if !clusterOps.Status.JobRef.IsEmpty() {
 		return false, nil
 	}
+	attempt := NextAttempt(clusterOps)
+	if AttemptBackoff(clusterOps) > 0 {
+		return true, nil // wait for the backoff of retry
+	}
 	jobName := c.GenerateJobName(clusterOps)
-	namespace := clusterOps.Spec.HostsConfRef.NameSpace
+	namespace := util.TenantNamespace(cluster)
 	job, err := c.ClientSet.BatchV1().Jobs(namespace).Get(context.Background(), jobName, metav1.GetOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			// the job doest not exist , and will create the job.
-			sa, err := c.GetServiceAccountName(util.GetCurrentNSOrDefault(), ServiceAccount)
+			sa, err := c.jobServiceAccountName(cluster)
 			if err != nil {
 				return false, err
 			}
 			klog.Warningf("create job %s for kuBeanClusterOp %s", jobName, clusterOps.Name)
-			job = c.NewKubesprayJob(clusterOps, sa)
+			job = c.NewKubesprayJob(clusterOps, namespace, sa)
+			SetAttemptEnv(job, attempt)
+			if cluster.Spec.SSHAuthProvider != nil && clusterOps.Spec.SSHAuthRef.IsEmpty() {
+				// the sshAuthRef set in the clusterOps takes precedence over the provider.
+				secretName, err := c.CreateProvidedSSHAuth(clusterOps, cluster, namespace, jobName)
+				if err != nil {
+					return false, err
+				}
+				mountSSHAuth(job, secretName)
+			}
 
 			if err := c.HookCustomAction(clusterOps, job); err != nil {
 				return false, err
@@ -509,6 +615,7 @@ func (c *Controller) CreateKubeSprayJob(clusterOps *clusteroperationv1alpha1.Clu
 			if err != nil {
 				return false, err
 			}
+			c.recordEvent(clusterOps, corev1.EventTypeNormal, JobCreatedReason, "created job %s/%s for attempt %d", job.Namespace, job.Name, attempt.Index)
 		} else {
 			// other error.
 			klog.Error(err)
@@ -519,9 +626,19 @@ func (c *Controller) CreateKubeSprayJob(clusterOps *clusteroperationv1alpha1.Clu
 		NameSpace: job.Namespace,
 		Name:      job.Name,
 	}
-	clusterOps.Status.StartTime = &metav1.Time{Time: time.Now()}
+	if clusterOps.Status.StartTime == nil {
+		clusterOps.Status.StartTime = &metav1.Time{Time: time.Now()}
+	}
 	clusterOps.Status.Status = clusteroperationv1alpha1.RunningStatus
 	clusterOps.Status.Action = clusterOps.Spec.Action
+	clusterOps.Status.Message = ""
+	if attempt.Index == 1 {
+		// the retry attempt keeps the stages which have succeeded.
+		clusterOps.Status.Stages = c.NewStages(clusterOps)
+	}
+	attempt.JobRef = &apis.JobRef{NameSpace: job.Namespace, Name: job.Name}
+	attempt.StartTime = &metav1.Time{Time: time.Now()}
+	attempt.Status = clusteroperationv1alpha1.RunningStatus
 
 	if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
 		return false, err
@@ -601,26 +718,14 @@ func (c *Controller) GetRunningPodFromJob(job *batchv1.Job) (*corev1.Pod, error)
 }
 
 // CreateEntryPointShellConfigMap create configMap to store entrypoint.sh.
-func (c *Controller) CreateEntryPointShellConfigMap(clusterOps *clusteroperationv1alpha1.ClusterOperation) (bool, error) {
+func (c *Controller) CreateEntryPointShellConfigMap(clusterOps *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster) (bool, error) {
 	if !clusterOps.Spec.EntrypointSHRef.IsEmpty() {
 		return false, nil
 	}
-	entryPointData := entrypoint.NewEntryPoint()
-	isPrivateKey := !clusterOps.Spec.SSHAuthRef.IsEmpty()
-	builtinActionSource := clusteroperationv1alpha1.BuiltinActionSource
-	for _, action := range clusterOps.Spec.PreHook {
-		if err := entryPointData.PreHookRunPart(string(action.ActionType), action.Action, action.ExtraArgs, isPrivateKey, action.ActionSource == nil || *action.ActionSource == builtinActionSource); err != nil {
-			return false, err
-		}
-	}
-	if err := entryPointData.SprayRunPart(string(clusterOps.Spec.ActionType), clusterOps.Spec.Action, clusterOps.Spec.ExtraArgs, isPrivateKey, clusterOps.Spec.ActionSource == nil || *clusterOps.Spec.ActionSource == builtinActionSource); err != nil {
+	entryPointData, _, err := buildEntryPoint(clusterOps)
+	if err != nil {
 		return false, err
 	}
-	for _, action := range clusterOps.Spec.PostHook {
-		if err := entryPointData.PostHookRunPart(string(action.ActionType), action.Action, action.ExtraArgs, isPrivateKey, action.ActionSource == nil || *action.ActionSource == builtinActionSource); err != nil {
-			return false, err
-		}
-	}
 	configMapData, err := entryPointData.Render()
 	if err != nil {
 		return false, err
@@ -633,7 +738,7 @@ func (c *Controller) CreateEntryPointShellConfigMap(clusterOps *clusteroperation
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-entrypoint", clusterOps.Name),
-			Namespace: util.GetCurrentNSOrDefault(),
+			Namespace: util.TenantNamespace(cluster),
 		},
 		Data: map[string]string{"entrypoint.sh": strings.TrimSpace(configMapData)}, // |2+
 	}
@@ -660,42 +765,23 @@ func (c *Controller) CreateEntryPointShellConfigMap(clusterOps *clusteroperation
 
 // HookCustomAction inject custom actions to spray job.
 func (c *Controller) HookCustomAction(clusterOps *clusteroperationv1alpha1.ClusterOperation, job *batchv1.Job) error {
-	errMsg := "actionSourceRef must be specified if actionSource set as configmap"
-	for _, action := range clusterOps.Spec.PreHook {
-		if action.ActionSource != nil && *action.ActionSource != clusteroperationv1alpha1.BuiltinActionSource {
-			if action.ActionSourceRef.IsEmpty() {
-				return fmt.Errorf(errMsg)
-			}
-			if err := c.injectCustomAction(clusterOps, job, action.Action, action.ActionType, action.ActionSourceRef); err != nil {
-				return err
-			}
+	for _, part := range actionParts(clusterOps) {
+		if part.isBuiltin() {
+			continue
 		}
-	}
-	if clusterOps.Spec.ActionSource != nil && *clusterOps.Spec.ActionSource != clusteroperationv1alpha1.BuiltinActionSource {
-		if clusterOps.Spec.ActionSourceRef.IsEmpty() {
-			return fmt.Errorf(errMsg)
+		if part.actionSourceRef.IsEmpty() {
+			return fmt.Errorf(actionSourceRefRequired)
 		}
-		if err := c.injectCustomAction(clusterOps, job, clusterOps.Spec.Action, clusterOps.Spec.ActionType, clusterOps.Spec.ActionSourceRef); err != nil {
+		if err := c.injectCustomAction(clusterOps, job, part.action, part.actionType, part.actionSourceRef); err != nil {
 			return err
 		}
 	}
-	for _, action := range clusterOps.Spec.PostHook {
-		if action.ActionSource != nil && *action.ActionSource != clusteroperationv1alpha1.BuiltinActionSource {
-			if action.ActionSourceRef.IsEmpty() {
-				return fmt.Errorf(errMsg)
-			}
-			if err := c.injectCustomAction(clusterOps, job, action.Action, action.ActionType, action.ActionSourceRef); err != nil {
-				return err
-			}
-		}
-	}
 	return nil
 }
 
 func (c *Controller) injectCustomAction(clusterOps *clusteroperationv1alpha1.ClusterOperation, job *batchv1.Job, action string, actionType clusteroperationv1alpha1.ActionType, actionRef *apis.ConfigMapRef) error {
-	currentNS := util.GetCurrentNSOrDefault()
-	if actionRef.NameSpace != currentNS {
-		_, err := c.CopyConfigMap(clusterOps, actionRef, actionRef.Name, currentNS)
+	if actionRef.NameSpace != job.Namespace {
+		_, err := c.CopyConfigMap(clusterOps, actionRef, actionRef.Name, job.Namespace)
 		if err != nil && !apierrors.IsAlreadyExists(err) {
 			return err
 		}
@@ -734,6 +820,14 @@ func (c *Controller) injectCustomAction(clusterOps *clusteroperationv1alpha1.Clu
 	return nil
 }
 
+// jobServiceAccountName returns the ServiceAccount of the tenant, or the one of kubean for the cluster without tenant.
+func (c *Controller) jobServiceAccountName(cluster *clusterv1alpha1.Cluster) (string, error) {
+	if cluster != nil && cluster.Spec.Tenant != nil {
+		return cluster.Spec.Tenant.ServiceAccountName, nil
+	}
+	return c.GetServiceAccountName(util.GetCurrentNSOrDefault(), ServiceAccount)
+}
+
 // GetServiceAccountName get serviceaccount name on kubean namespace by labelSelector.
 func (c *Controller) GetServiceAccountName(namespace, labelSelector string) (string, error) {
 	serviceAccounts, err := c.ClientSet.CoreV1().ServiceAccounts(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
@@ -822,7 +916,7 @@ func (c *Controller) UpdateStatusForLabel(ops *clusteroperationv1alpha1.ClusterO
 	if ops.Labels[constants.KubeanClusterHasCompleted] == "done" {
 		return nil
 	}
-	if ops.Status.Status == clusteroperationv1alpha1.SucceededStatus || ops.Status.Status == clusteroperationv1alpha1.FailedStatus {
+	if IsFinished(ops) {
 		ops.Labels[constants.KubeanClusterHasCompleted] = "done"
 		if err := c.Client.Update(context.Background(), ops); err != nil {
 			return err
@@ -843,7 +937,7 @@ func (c *Controller) BackUpDataRef(clusterOps *clusteroperationv1alpha1.ClusterO
 	} else {
 		clusterOps.Labels[constants.KubeanClusterLabelKey] = cluster.Name
 	}
-	currentNS := util.GetCurrentNSOrDefault()
+	currentNS := util.TenantNamespace(cluster)
 	if clusterOps.Spec.HostsConfRef.IsEmpty() {
 		newConfigMap, err := c.CopyConfigMap(clusterOps, cluster.Spec.HostsConfRef, cluster.Spec.HostsConfRef.Name+timestamp, currentNS)
 		if err != nil {
@@ -856,6 +950,7 @@ func (c *Controller) BackUpDataRef(clusterOps *clusteroperationv1alpha1.ClusterO
 		if err := c.Client.Update(context.Background(), clusterOps); err != nil {
 			return false, err
 		}
+		c.recordEvent(clusterOps, corev1.EventTypeNormal, BackupCreatedReason, "backed up hostsConfRef of cluster %s to configmap %s/%s", cluster.Name, newConfigMap.Namespace, newConfigMap.Name)
 		return true, nil
 	}
 	if clusterOps.Spec.VarsConfRef.IsEmpty() {
@@ -870,6 +965,7 @@ func (c *Controller) BackUpDataRef(clusterOps *clusteroperationv1alpha1.ClusterO
 		if err := c.Client.Update(context.Background(), clusterOps); err != nil {
 			return false, err
 		}
+		c.recordEvent(clusterOps, corev1.EventTypeNormal, BackupCreatedReason, "backed up varsConfRef of cluster %s to configmap %s/%s", cluster.Name, newConfigMap.Namespace, newConfigMap.Name)
 		return true, nil
 	}
 	if clusterOps.Spec.SSHAuthRef.IsEmpty() && !cluster.Spec.SSHAuthRef.IsEmpty() {
@@ -885,6 +981,7 @@ func (c *Controller) BackUpDataRef(clusterOps *clusteroperationv1alpha1.ClusterO
 		if err := c.Client.Update(context.Background(), clusterOps); err != nil {
 			return false, err
 		}
+		c.recordEvent(clusterOps, corev1.EventTypeNormal, BackupCreatedReason, "backed up sshAuthRef of cluster %s to secret %s/%s", cluster.Name, newSecret.Namespace, newSecret.Name)
 		return true, nil
 	}
 	return false, nil // needRequeue,err
@@ -936,38 +1033,8 @@ func (c *Controller) CheckSecretExist(namespace, name string) bool {
 }
 
 func (c *Controller) CheckClusterDataRef(cluster *clusterv1alpha1.Cluster, clusterOPS *clusteroperationv1alpha1.ClusterOperation) error {
-	namespaceSet := map[string]struct{}{}
-	if clusterOPS.Spec.HostsConfRef.IsEmpty() {
-		// check HostsConfRef in cluster before clusterSpec is not assigned backup data.
-		hostsConfRef := cluster.Spec.HostsConfRef
-		if hostsConfRef.IsEmpty() {
-			return fmt.Errorf("kubeanCluster %s hostsConfRef is empty", cluster.Name)
-		}
-		if !c.CheckConfigMapExist(hostsConfRef.NameSpace, hostsConfRef.Name) {
-			return fmt.Errorf("kubeanCluster %s hostsConfRef %s,%s not found", cluster.Name, hostsConfRef.NameSpace, hostsConfRef.Name)
-		}
-		namespaceSet[hostsConfRef.NameSpace] = struct{}{}
-	}
-	if clusterOPS.Spec.VarsConfRef.IsEmpty() {
-		varsConfRef := cluster.Spec.VarsConfRef
-		if varsConfRef.IsEmpty() {
-			return fmt.Errorf("kubeanCluster %s varsConfRef is empty", cluster.Name)
-		}
-		if !c.CheckConfigMapExist(varsConfRef.NameSpace, varsConfRef.Name) {
-			return fmt.Errorf("kubeanCluster %s varsConfRef %s,%s not found", cluster.Name, varsConfRef.NameSpace, varsConfRef.Name)
-		}
-		namespaceSet[varsConfRef.NameSpace] = struct{}{}
-	}
-	if clusterOPS.Spec.SSHAuthRef.IsEmpty() && !cluster.Spec.SSHAuthRef.IsEmpty() {
-		// check SSHAuthRef optionally.
-		sshAuthRef := cluster.Spec.SSHAuthRef
-		if !c.CheckSecretExist(sshAuthRef.NameSpace, sshAuthRef.Name) {
-			return fmt.Errorf("kubeanCluster %s sshAuthRef %s,%s not found", cluster.Name, sshAuthRef.NameSpace, sshAuthRef.Name)
-		}
-		namespaceSet[sshAuthRef.NameSpace] = struct{}{}
-	}
-	if len(namespaceSet) > 1 {
-		return fmt.Errorf("kubeanCluster %s hostsConfRef varsConfRef or sshAuthRef not in the same namespace", cluster.Name)
+	if errs := append(ValidateTenant(cluster, clusterOPS), ValidateDataRef(cluster, clusterOPS, c.CheckConfigMapExist, c.CheckSecretExist)...); len(errs) > 0 {
+		return errs.ToAggregate()
 	}
 	return nil
 }
@@ -26,6 +26,8 @@ import (
 	clusteroperationv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned/fake"
 	manifestv1alpha1fake "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned/fake"
 	"github.com/kubean-io/kubean/pkg/util"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+	"github.com/kubean-io/kubean/pkg/util/sshauth"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -175,73 +177,55 @@ func TestUpdateStatusLoop(t *testing.T) {
 	}
 }
 
-func TestCompareSalt(t *testing.T) {
-	controller := Controller{}
-	controller.Client = newFakeClient()
-	ops := clusteroperationv1alpha1.ClusterOperation{}
-	ops.ObjectMeta.Name = "clusteropsname"
-	controller.Client.Create(context.Background(), &ops)
-	tests := []struct {
-		name string
-		args func() bool
-		want bool
-	}{
-		{
-			name: "same salt",
-			args: func() bool {
-				ops.Status.Digest = ""
-				controller.UpdateClusterOpsStatusDigest(&ops)
-				if len(ops.Status.Digest) == 0 {
-					return false
-				}
-				return controller.compareDigest(&ops)
-			},
-			want: true,
-		},
-	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			if test.args() != test.want {
-				t.Fatal()
-			}
-		})
+func TestCalDigest(t *testing.T) {
+	controller := Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+	for name, data := range map[string]string{"hosts-1": "all: {}", "hosts-2": "all: {}", "vars-1": "a: b", "entrypoint-1": "ansible-playbook"} {
+		controller.ClientSet.CoreV1().ConfigMaps("kubean-system").Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kubean-system"},
+			Data:       map[string]string{"data": data},
+		}, metav1.CreateOptions{})
+	}
+	newOps := func(hosts string) *clusteroperationv1alpha1.ClusterOperation {
+		ops := &clusteroperationv1alpha1.ClusterOperation{}
+		ops.Spec.HostsConfRef = &apis.ConfigMapRef{NameSpace: "kubean-system", Name: hosts}
+		ops.Spec.VarsConfRef = &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "vars-1"}
+		ops.Spec.EntrypointSHRef = &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "entrypoint-1"}
+		return ops
+	}
+	digest, err := controller.CalDigest(newOps("hosts-1"))
+	if err != nil || !strings.HasPrefix(digest, DigestPrefix) {
+		t.Fatalf("got %s %v", digest, err)
 	}
-}
-
-func TestUpdateClusterOpsStatusSalt(t *testing.T) {
-	controller := Controller{}
-	controller.Client = newFakeClient()
-	ops := clusteroperationv1alpha1.ClusterOperation{}
-	ops.ObjectMeta.Name = "clusteropsname"
-	controller.Client.Create(context.Background(), &ops)
 	tests := []struct {
 		name string
 		args func() bool
 		want bool
 	}{
 		{
-			name: "salt empty value",
+			name: "the same content",
 			args: func() bool {
-				ops.Status.Digest = ""
-				needRequeue, err := controller.UpdateClusterOpsStatusDigest(&ops)
-				return needRequeue && err == nil && len(ops.Status.Digest) > 0
+				result, err := controller.CalDigest(newOps("hosts-2"))
+				return err == nil && result == digest
 			},
 			want: true,
 		},
 		{
-			name: "nothing changed",
+			name: "content changed",
 			args: func() bool {
-				needRequeue, err := controller.UpdateClusterOpsStatusDigest(&ops)
-				return !needRequeue && err == nil && len(ops.Status.Digest) > 0
+				controller.ClientSet.CoreV1().ConfigMaps("kubean-system").Update(context.Background(), &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "hosts-2", Namespace: "kubean-system"},
+					Data:       map[string]string{"data": "all: {hosts: {}}"},
+				}, metav1.UpdateOptions{})
+				result, err := controller.CalDigest(newOps("hosts-2"))
+				return err == nil && result != digest
 			},
 			want: true,
 		},
 		{
-			name: "salt empty value again",
+			name: "configmap not found",
 			args: func() bool {
-				ops.Status.Digest = ""
-				needRequeue, err := controller.UpdateClusterOpsStatusDigest(&ops)
-				return needRequeue && err == nil && len(ops.Status.Digest) > 0
+				_, err := controller.CalDigest(newOps("not-exist"))
+				return err != nil
 			},
 			want: true,
 		},
@@ -255,9 +239,12 @@ func TestUpdateClusterOpsStatusSalt(t *testing.T) {
 	}
 }
 
-func TestUpdateStatusHasModified(t *testing.T) {
-	controller := Controller{}
-	controller.Client = newFakeClient()
+func TestUpdateClusterOpsStatusDigest(t *testing.T) {
+	controller := Controller{Client: newFakeClient(), ClientSet: clientsetfake.NewSimpleClientset()}
+	controller.ClientSet.CoreV1().ConfigMaps("kubean-system").Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "entrypoint-1", Namespace: "kubean-system"},
+		Data:       map[string]string{"entrypoint.sh": "ansible-playbook"},
+	}, metav1.CreateOptions{})
 	ops := clusteroperationv1alpha1.ClusterOperation{}
 	ops.ObjectMeta.Name = "clusteropsname"
 	controller.Client.Create(context.Background(), &ops)
@@ -267,50 +254,27 @@ func TestUpdateStatusHasModified(t *testing.T) {
 		want bool
 	}{
 		{
-			name: "empty salt value",
-			args: func() bool {
-				ops.Status.Digest = ""
-				needRequeue, err := controller.UpdateStatusHasModified(&ops)
-				return !needRequeue && err == nil && !ops.Status.HasModified
-			},
-			want: true,
-		},
-		{
-			name: "already modified",
+			name: "entrypoint not rendered",
 			args: func() bool {
-				ops.Status.Digest = "123"
-				ops.Status.HasModified = true
-				needRequeue, err := controller.UpdateStatusHasModified(&ops)
-				return !needRequeue && err == nil && ops.Status.HasModified
+				needRequeue, err := controller.UpdateClusterOpsStatusDigest(&ops)
+				return !needRequeue && err == nil && len(ops.Status.Digest) == 0
 			},
 			want: true,
 		},
 		{
-			name: "nothing need update",
+			name: "digest empty value",
 			args: func() bool {
-				ops.Status.Digest = ""
-				ops.Status.HasModified = false
-				controller.UpdateClusterOpsStatusDigest(&ops)
-				if len(ops.Status.Digest) == 0 {
-					return false
-				}
-				needRequeue, err := controller.UpdateStatusHasModified(&ops)
-				return len(ops.Status.Digest) != 0 && err == nil && !needRequeue && !ops.Status.HasModified
+				ops.Spec.EntrypointSHRef = &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "entrypoint-1"}
+				needRequeue, err := controller.UpdateClusterOpsStatusDigest(&ops)
+				return needRequeue && err == nil && strings.HasPrefix(ops.Status.Digest, DigestPrefix)
 			},
 			want: true,
 		},
 		{
-			name: "something update",
+			name: "nothing changed",
 			args: func() bool {
-				ops.Status.Digest = ""
-				ops.Status.HasModified = false
-				controller.UpdateClusterOpsStatusDigest(&ops)
-				if len(ops.Status.Digest) == 0 {
-					return false
-				}
-				ops.Spec.Image = "abc"
-				needRequeue, err := controller.UpdateStatusHasModified(&ops)
-				return len(ops.Status.Digest) != 0 && err == nil && needRequeue && ops.Status.HasModified
+				needRequeue, err := controller.UpdateClusterOpsStatusDigest(&ops)
+				return !needRequeue && err == nil && len(ops.Status.Digest) > 0
 			},
 			want: true,
 		},
@@ -324,93 +288,6 @@ func TestUpdateStatusHasModified(t *testing.T) {
 	}
 }
 
-func TestCalSalt(t *testing.T) {
-	controller := Controller{}
-	ops := clusteroperationv1alpha1.ClusterOperation{}
-	ops.Spec.Cluster = "123456789"
-	ops.Spec.ActionType = "1"
-	ops.Spec.Action = "2"
-	ops.Spec.Image = "4"
-	ops.Spec.PreHook = []clusteroperationv1alpha1.HookAction{
-		{
-			ActionType: "11",
-			Action:     "22",
-		},
-		{
-			ActionType: "22",
-			Action:     "33",
-		},
-	}
-	ops.Spec.PostHook = []clusteroperationv1alpha1.HookAction{
-		{
-			ActionType: "55",
-			Action:     "66",
-		},
-	}
-	targetSaltValue := controller.CalSalt(&ops)
-	tests := []struct {
-		name string
-		args func(clusteroperationv1alpha1.ClusterOperation) string
-		want bool
-	}{
-		{
-			name: "change clusterName",
-			args: func(ops clusteroperationv1alpha1.ClusterOperation) string {
-				ops.Spec.Cluster = "ok1"
-				return controller.CalSalt(&ops)
-			},
-			want: false,
-		},
-		{
-			name: "change actionType",
-			args: func(ops clusteroperationv1alpha1.ClusterOperation) string {
-				ops.Spec.ActionType = "luck"
-				return controller.CalSalt(&ops)
-			},
-			want: false,
-		},
-		{
-			name: "change action",
-			args: func(ops clusteroperationv1alpha1.ClusterOperation) string {
-				ops.Spec.Action = "ok123"
-				return controller.CalSalt(&ops)
-			},
-			want: false,
-		},
-		{
-			name: "change image",
-			args: func(ops clusteroperationv1alpha1.ClusterOperation) string {
-				ops.Spec.Image = "image123"
-				return controller.CalSalt(&ops)
-			},
-			want: false,
-		},
-		{
-			name: "unchanged",
-			args: func(ops clusteroperationv1alpha1.ClusterOperation) string {
-				return controller.CalSalt(&ops)
-			},
-			want: true,
-		},
-		{
-			name: "change postHook",
-			args: func(ops clusteroperationv1alpha1.ClusterOperation) string {
-				ops.Spec.PostHook[0].ActionType = "ok12qaz"
-				return controller.CalSalt(&ops)
-			},
-			want: false,
-		},
-	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			result := test.args(ops) == targetSaltValue
-			if result != test.want {
-				t.Fatal()
-			}
-		})
-	}
-}
-
 func TestController_SetOwnerReferences(t *testing.T) {
 	cm := corev1.ConfigMap{}
 	ops := &clusteroperationv1alpha1.ClusterOperation{}
@@ -455,7 +332,7 @@ func TestNewKubesprayJob(t *testing.T) {
 		{
 			name: "no ssh args",
 			args: func() bool {
-				job := controller.NewKubesprayJob(clusterOps, "kubean")
+				job := controller.NewKubesprayJob(clusterOps, util.GetCurrentNSOrDefault(), "kubean")
 				return job.Namespace == "mynamespace" && job.Name == "kubean-myops-job" && len(job.Spec.Template.Spec.Containers) == 1 && len(job.Spec.Template.Spec.Containers[0].VolumeMounts) == 3 && len(job.Spec.Template.Spec.Volumes) == 3
 			},
 			want: true,
@@ -467,7 +344,7 @@ func TestNewKubesprayJob(t *testing.T) {
 					NameSpace: "mynamespace",
 					Name:      "secret",
 				}
-				job := controller.NewKubesprayJob(clusterOps, "kubean")
+				job := controller.NewKubesprayJob(clusterOps, util.GetCurrentNSOrDefault(), "kubean")
 				return job.Namespace == "mynamespace" && job.Name == "kubean-myops-job" && len(job.Spec.Template.Spec.Containers) == 1 && len(job.Spec.Template.Spec.Containers[0].VolumeMounts) == 4 && len(job.Spec.Template.Spec.Volumes) == 4
 			},
 			want: true,
@@ -477,7 +354,7 @@ func TestNewKubesprayJob(t *testing.T) {
 			args: func() bool {
 				ActiveDeadlineSeconds := int64(10)
 				clusterOps.Spec.ActiveDeadlineSeconds = &ActiveDeadlineSeconds
-				job := controller.NewKubesprayJob(clusterOps, "kubean")
+				job := controller.NewKubesprayJob(clusterOps, util.GetCurrentNSOrDefault(), "kubean")
 				return *job.Spec.ActiveDeadlineSeconds == 10
 			},
 			want: true,
@@ -486,7 +363,7 @@ func TestNewKubesprayJob(t *testing.T) {
 			name: "nil activeDeadlineSeconds args",
 			args: func() bool {
 				clusterOps.Spec.ActiveDeadlineSeconds = nil
-				job := controller.NewKubesprayJob(clusterOps, "kubean")
+				job := controller.NewKubesprayJob(clusterOps, util.GetCurrentNSOrDefault(), "kubean")
 				return job.Spec.ActiveDeadlineSeconds == nil
 			},
 			want: true,
@@ -495,7 +372,7 @@ func TestNewKubesprayJob(t *testing.T) {
 			name: "not empty Resources",
 			args: func() bool {
 				clusterOps.Spec.Resources = corev1.ResourceRequirements{Limits: map[corev1.ResourceName]resource.Quantity{corev1.ResourceCPU: resource.MustParse("1m")}}
-				job := controller.NewKubesprayJob(clusterOps, "kubean")
+				job := controller.NewKubesprayJob(clusterOps, util.GetCurrentNSOrDefault(), "kubean")
 				return len(job.Spec.Template.Spec.Containers[0].Resources.Limits) != 0
 			},
 			want: true,
@@ -653,7 +530,7 @@ func TestController_HookCustomAction(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			test.setAction()
-			err := controller.HookCustomAction(clusterOps, controller.NewKubesprayJob(clusterOps, "kubean"))
+			err := controller.HookCustomAction(clusterOps, controller.NewKubesprayJob(clusterOps, util.GetCurrentNSOrDefault(), "kubean"))
 			if (err != nil) != test.wantErr {
 				t.Fatal()
 			}
@@ -859,6 +736,26 @@ func TestReconcile(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "clusterOps cancelled before job created",
+			args: func() bool {
+				controller := genController()
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{
+					ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster"},
+					Spec: clusteroperationv1alpha1.Spec{
+						Cluster: "my_kubean_cluster",
+						Image:   "myimagename",
+						Cancel:  true,
+					},
+				}
+				controller.Client.Create(context.Background(), clusterOps)
+				result, _ := controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
+				opsResult := &clusteroperationv1alpha1.ClusterOperation{}
+				controller.Client.Get(context.Background(), types.NamespacedName{Name: "my_kubean_ops_cluster"}, opsResult)
+				return result.RequeueAfter == 0 && opsResult.Status.Status == clusteroperationv1alpha1.CancelledStatus && opsResult.Status.JobRef.IsEmpty()
+			},
+			want: true,
+		},
 		{
 			name: "clusterOps and cluster found successfully but not ValidImageName",
 			args: func() bool {
@@ -1030,17 +927,23 @@ func TestReconcile(t *testing.T) {
 						Labels: map[string]string{constants.KubeanClusterLabelKey: "my_kubean_cluster"},
 					},
 					Spec: clusteroperationv1alpha1.Spec{
-						Cluster: "my_kubean_cluster",
-						Image:   "myimagename",
+						Cluster:    "my_kubean_cluster",
+						Image:      "myimagename",
+						Action:     "ping.yml",
+						ActionType: "playbook",
 					},
 				}
 				controller.Client.Create(context.Background(), clusterOps)
 				controller.KubeanClusterSet.KubeanV1alpha1().Clusters().Create(context.Background(), cluster, metav1.CreateOptions{})
 				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps, metav1.CreateOptions{})
-				result, _ := controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
+				// backup hostsConfRef and varsConfRef, render the entrypoint and then update the digest.
+				var result controllerruntime.Result
+				for i := 0; i < 4; i++ {
+					result, _ = controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
+				}
 				opsResult := &clusteroperationv1alpha1.ClusterOperation{}
 				controller.Client.Get(context.Background(), types.NamespacedName{Name: "my_kubean_ops_cluster"}, opsResult)
-				return result.RequeueAfter > 0 && opsResult.Status.Digest != ""
+				return result.RequeueAfter > 0 && strings.HasPrefix(opsResult.Status.Digest, DigestPrefix)
 			},
 			want: true,
 		},
@@ -1117,7 +1020,6 @@ func TestReconcile(t *testing.T) {
 				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps, metav1.CreateOptions{})
 				_, _ = controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
 				_, _ = controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
-				_, _ = controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
 				_, err := controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "my_kubean_ops_cluster"}})
 				opsResult := &clusteroperationv1alpha1.ClusterOperation{}
 				controller.Client.Get(context.Background(), types.NamespacedName{Name: "my_kubean_ops_cluster"}, opsResult)
@@ -1287,7 +1189,7 @@ func TestCreateKubeSprayJob(t *testing.T) {
 				fetchTestingFake(controller.ClientSet.CoreV1()).PrependReactor("list", "serviceaccounts", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
 					return true, nil, fmt.Errorf("this is error")
 				})
-				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1)
+				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1, &clusterv1alpha1.Cluster{})
 				removeReactorFromTestingTake(controller.ClientSet.CoreV1(), "list", "serviceaccounts")
 				return !needRequeue && err != nil && err.Error() == "this is error"
 			},
@@ -1311,7 +1213,7 @@ func TestCreateKubeSprayJob(t *testing.T) {
 				fetchTestingFake(controller.ClientSet.CoreV1()).PrependReactor("create", "jobs", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
 					return true, nil, fmt.Errorf("this is error when create job")
 				})
-				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1)
+				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1, &clusterv1alpha1.Cluster{})
 				removeReactorFromTestingTake(controller.ClientSet.CoreV1(), "create", "jobs")
 				return !needRequeue && err != nil && err.Error() == "this is error when create job"
 			},
@@ -1336,7 +1238,7 @@ func TestCreateKubeSprayJob(t *testing.T) {
 					return true, nil, fmt.Errorf("this is error")
 				})
 				clusterOps1 := *clusterOps
-				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1)
+				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1, &clusterv1alpha1.Cluster{})
 				removeReactorFromTestingTake(controller.ClientSet.CoreV1(), "get", "jobs")
 				return !needRequeue && err != nil && err.Error() == "this is error"
 			},
@@ -1357,10 +1259,74 @@ func TestCreateKubeSprayJob(t *testing.T) {
 					},
 				}, metav1.CreateOptions{})
 
-				needRequeue, err := controller.CreateKubeSprayJob(clusterOps)
+				needRequeue, err := controller.CreateKubeSprayJob(clusterOps, &clusterv1alpha1.Cluster{})
 				return needRequeue && err == nil
 			},
 		},
+		{
+			name: "create job in the tenant namespace with the tenant serviceaccount",
+			args: func() bool {
+				clusterOps1 := *clusterOps
+				clusterOps1.Name = "tenant-ops"
+				clusterOps1.Status = clusteroperationv1alpha1.Status{}
+				cluster := &clusterv1alpha1.Cluster{}
+				cluster.Spec.Tenant = &clusterv1alpha1.Tenant{Namespace: "team-a", ServiceAccountName: "team-a-sa"}
+				controller.Client.Create(context.Background(), &clusterOps1)
+				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1, cluster)
+				if !needRequeue || err != nil {
+					return false
+				}
+				job, err := controller.ClientSet.BatchV1().Jobs("team-a").Get(context.Background(), controller.GenerateJobName(&clusterOps1), metav1.GetOptions{})
+				return err == nil && job.Spec.Template.Spec.ServiceAccountName == "team-a-sa" &&
+					clusterOps1.Status.JobRef.NameSpace == "team-a"
+			},
+			want: true,
+		},
+		{
+			name: "create job with the ssh credentials fetched by the provider",
+			args: func() bool {
+				sshauth.Register("mock-vault", mockSSHAuthProvider{"ssh-privatekey": []byte("key"), "ssh-certificate": []byte("cert")})
+				clusterOps1 := *clusterOps
+				clusterOps1.Name = "provider-ops"
+				clusterOps1.Spec.SSHAuthRef = nil
+				clusterOps1.Status = clusteroperationv1alpha1.Status{}
+				cluster := &clusterv1alpha1.Cluster{}
+				cluster.Spec.SSHAuthProvider = &clusterv1alpha1.SSHAuthProvider{Name: "mock-vault"}
+				controller.Client.Create(context.Background(), &clusterOps1)
+				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1, cluster)
+				if !needRequeue || err != nil {
+					return false
+				}
+				jobName := controller.GenerateJobName(&clusterOps1)
+				job, err := controller.ClientSet.BatchV1().Jobs("default").Get(context.Background(), jobName, metav1.GetOptions{})
+				if err != nil {
+					return false
+				}
+				secret, err := controller.ClientSet.CoreV1().Secrets("default").Get(context.Background(), ProvidedSSHAuthSecretName(jobName), metav1.GetOptions{})
+				if err != nil || string(secret.Data["ssh-certificate"]) != "cert" || secret.Labels[SSHAuthOpsLabelKey] != "provider-ops" {
+					return false
+				}
+				volumes := job.Spec.Template.Spec.Volumes
+				return volumes[len(volumes)-1].Secret.SecretName == secret.Name
+			},
+			want: true,
+		},
+		{
+			name: "fail to create job by the unregistered provider",
+			args: func() bool {
+				clusterOps1 := *clusterOps
+				clusterOps1.Name = "unregistered-provider-ops"
+				clusterOps1.Spec.SSHAuthRef = nil
+				clusterOps1.Status = clusteroperationv1alpha1.Status{}
+				cluster := &clusterv1alpha1.Cluster{}
+				cluster.Spec.SSHAuthProvider = &clusterv1alpha1.SSHAuthProvider{Name: "unregistered"}
+				controller.Client.Create(context.Background(), &clusterOps1)
+				_, err := controller.CreateKubeSprayJob(&clusterOps1, cluster)
+				_, getErr := controller.ClientSet.BatchV1().Jobs("default").Get(context.Background(), controller.GenerateJobName(&clusterOps1), metav1.GetOptions{})
+				return err != nil && apierrors.IsNotFound(getErr)
+			},
+			want: true,
+		},
 		{
 			name: "JobRef not empty",
 			args: func() bool {
@@ -1377,7 +1343,7 @@ func TestCreateKubeSprayJob(t *testing.T) {
 				}, metav1.CreateOptions{})
 				clusterOps1 := *clusterOps
 				clusterOps1.Status.JobRef = &apis.JobRef{NameSpace: "abc", Name: "abc"}
-				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1)
+				needRequeue, err := controller.CreateKubeSprayJob(&clusterOps1, &clusterv1alpha1.Cluster{})
 				return !needRequeue && err == nil
 			},
 			want: true,
@@ -2004,7 +1970,7 @@ func Test_CreateEntryPointShellConfigMap(t *testing.T) {
 					NameSpace: "a",
 					Name:      "b",
 				}
-				result, err := controller.CreateEntryPointShellConfigMap(clusterOps)
+				result, err := controller.CreateEntryPointShellConfigMap(clusterOps, &clusterv1alpha1.Cluster{})
 				return !result && err == nil
 			},
 			want: true,
@@ -2026,7 +1992,7 @@ func Test_CreateEntryPointShellConfigMap(t *testing.T) {
 				}
 				controller.Client.Create(context.Background(), clusterOps)
 				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps, metav1.CreateOptions{})
-				result, err := controller.CreateEntryPointShellConfigMap(clusterOps)
+				result, err := controller.CreateEntryPointShellConfigMap(clusterOps, &clusterv1alpha1.Cluster{})
 				return result && err == nil
 			},
 			want: true,
@@ -2062,11 +2028,54 @@ func Test_CreateEntryPointShellConfigMap(t *testing.T) {
 				}
 				controller.Client.Create(context.Background(), clusterOps)
 				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps, metav1.CreateOptions{})
-				result, err := controller.CreateEntryPointShellConfigMap(clusterOps)
+				result, err := controller.CreateEntryPointShellConfigMap(clusterOps, &clusterv1alpha1.Cluster{})
 				return result && err == nil
 			},
 			want: true,
 		},
+		{
+			name: "dry run",
+			args: func() bool {
+				controller := genController()
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{}
+				clusterOps.Name = "cluster1"
+				clusterOps.Spec.Action = "upgrade-cluster.yml"
+				clusterOps.Spec.ActionType = "playbook"
+				clusterOps.Spec.DryRun = true
+				clusterOps.Spec.PreHook = []clusteroperationv1alpha1.HookAction{
+					{ActionType: "shell", Action: "systemctl restart containerd"},
+					{ActionType: "shell", Action: "kubectl get nodes", DryRunSafe: true},
+				}
+				controller.Client.Create(context.Background(), clusterOps)
+				result, err := controller.CreateEntryPointShellConfigMap(clusterOps, &clusterv1alpha1.Cluster{})
+				if !result || err != nil {
+					return false
+				}
+				configMap, err := controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).Get(context.Background(), "cluster1-entrypoint", metav1.GetOptions{})
+				if err != nil {
+					return false
+				}
+				script := configMap.Data["entrypoint.sh"]
+				return strings.Contains(script, "--check --diff /kubespray/upgrade-cluster.yml") && strings.Contains(script, entrypoint.DryRunSkipCMD) &&
+					!strings.Contains(script, "systemctl restart containerd") && strings.Contains(script, "kubectl get nodes")
+			},
+			want: true,
+		},
+		{
+			name: "shell action in dry run",
+			args: func() bool {
+				controller := genController()
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{}
+				clusterOps.Name = "cluster1"
+				clusterOps.Spec.Action = "sleep 10"
+				clusterOps.Spec.ActionType = "shell"
+				clusterOps.Spec.DryRun = true
+				_, err := controller.CreateEntryPointShellConfigMap(clusterOps, &clusterv1alpha1.Cluster{})
+				_, ok := err.(entrypoint.ArgsError)
+				return ok
+			},
+			want: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -2740,6 +2749,22 @@ func TestUpdateOperationOwnReferenceForCluster(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "owned by the schedule",
+			arg: func() bool {
+				controller := genController()
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{}
+				clusterOps.Spec.Cluster = "cluster-origin"
+				isController := true
+				clusterOps.OwnerReferences = []metav1.OwnerReference{{Kind: "ClusterOperationSchedule", Name: "schedule1", UID: "schedule-uid-1", Controller: &isController}}
+				cluster := &clusterv1alpha1.Cluster{}
+				cluster.Name = "cluster-origin"
+				cluster.UID = "cluster-uid-1"
+				needRequeue, err := controller.UpdateOperationOwnReferenceForCluster(clusterOps, cluster)
+				return err == nil && needRequeue == false && len(clusterOps.OwnerReferences) == 1
+			},
+			want: true,
+		},
 		{
 			name: "the ownreference has been set",
 			arg: func() bool {
@@ -0,0 +1,47 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	klog "k8s.io/klog/v2"
+)
+
+// HookActionInDryRun returns the action to run for the hook, the shell hook which is not dryRunSafe is skipped in dry run.
+func HookActionInDryRun(hook *clusteroperationv1alpha1.HookAction, dryRun bool) string {
+	if dryRun && hook.ActionType == clusteroperationv1alpha1.ShellActionType && !hook.DryRunSafe {
+		return entrypoint.DryRunSkipCMD
+	}
+	return hook.Action
+}
+
+// FetchHostSummaries sums the PLAY RECAP of the job log per host, or returns nil if the log is not available.
+func (c *Controller) FetchHostSummaries(clusterOps *clusteroperationv1alpha1.ClusterOperation) []clusteroperationv1alpha1.HostSummary {
+	logStream, err := c.FetchJobPodLog(clusterOps, nil)
+	if err != nil {
+		klog.Warningf("clusterOps %s fetch job log for host summaries but %s", clusterOps.Name, err.Error())
+		return nil
+	}
+	defer logStream.Close()
+	stats, err := ansible.ParseHostStats(logStream)
+	if err != nil {
+		klog.Warningf("clusterOps %s parse play recap but %s", clusterOps.Name, err.Error())
+	}
+	summaries := make([]clusteroperationv1alpha1.HostSummary, 0, len(stats))
+	for _, stat := range stats {
+		summaries = append(summaries, clusteroperationv1alpha1.HostSummary{
+			Host:        stat.Host,
+			Ok:          stat.Ok,
+			Changed:     stat.Changed,
+			Unreachable: stat.Unreachable,
+			Failed:      stat.Failed,
+			Skipped:     stat.Skipped,
+		})
+	}
+	return summaries
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHookActionInDryRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		hook   clusteroperationv1alpha1.HookAction
+		dryRun bool
+		want   string
+	}{
+		{
+			name: "not dry run",
+			hook: clusteroperationv1alpha1.HookAction{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "reboot"},
+			want: "reboot",
+		},
+		{
+			name:   "unsafe shell hook in dry run",
+			hook:   clusteroperationv1alpha1.HookAction{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "reboot"},
+			dryRun: true,
+			want:   entrypoint.DryRunSkipCMD,
+		},
+		{
+			name:   "safe shell hook in dry run",
+			hook:   clusteroperationv1alpha1.HookAction{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "uptime", DryRunSafe: true},
+			dryRun: true,
+			want:   "uptime",
+		},
+		{
+			name:   "playbook hook in dry run",
+			hook:   clusteroperationv1alpha1.HookAction{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "ping.yml"},
+			dryRun: true,
+			want:   "ping.yml",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := HookActionInDryRun(&test.hook, test.dryRun); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchHostSummaries(t *testing.T) {
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "job not found",
+			args: func() bool {
+				controller := Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+				return controller.FetchHostSummaries(ops) == nil
+			},
+			want: true,
+		},
+		{
+			name: "no recap in log",
+			args: func() bool {
+				controller := Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+				controller.ClientSet.BatchV1().Jobs("kubean-system").Create(context.Background(), &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+					Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}}},
+				}, metav1.CreateOptions{})
+				controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "kubean-system", Labels: map[string]string{"job-name": "job1"}},
+				}, metav1.CreateOptions{})
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+				summaries := controller.FetchHostSummaries(ops)
+				return summaries != nil && len(summaries) == 0
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
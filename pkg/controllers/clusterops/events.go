@@ -0,0 +1,66 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"fmt"
+	"strings"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The reasons of the events about the lifecycle of clusterOps, shown by `kubectl describe`.
+const (
+	JobCreatedReason    = "JobCreated"
+	BackupCreatedReason = "BackupCreated"
+	FailedReason        = "Failed"
+	SucceededReason     = "Succeeded"
+	CancelledReason     = "Cancelled"
+	OpsPrunedReason     = "OpsPruned"
+
+	ApprovalRequestedReason = "ApprovalRequested"
+	ApprovedReason          = "Approved"
+
+	SSHAuthFetchFailedReason = "SSHAuthFetchFailed"
+)
+
+// recordEvent is a no-op when the controller has no EventRecorder.
+func (c *Controller) recordEvent(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.EventRecorder == nil {
+		return
+	}
+	c.EventRecorder.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+// recordFailed records the Failed event with the message of why the clusterOps failed.
+func (c *Controller) recordFailed(clusterOps *clusteroperationv1alpha1.ClusterOperation, message string) {
+	c.recordEvent(clusterOps, corev1.EventTypeWarning, FailedReason, "clusterOps failed: %s", message)
+}
+
+// FailureMessage describes the FailureReason in one line.
+func FailureMessage(reason *clusteroperationv1alpha1.FailureReason) string {
+	if reason == nil {
+		return "unknown reason"
+	}
+	parts := make([]string, 0, 4)
+	if reason.Stage != "" {
+		parts = append(parts, fmt.Sprintf("stage %s", reason.Stage))
+	}
+	if reason.Task != "" {
+		parts = append(parts, fmt.Sprintf("task %q", reason.Task))
+	}
+	if len(reason.Hosts) > 0 {
+		parts = append(parts, fmt.Sprintf("hosts %s", strings.Join(reason.Hosts, ",")))
+	}
+	if reason.Message != "" {
+		parts = append(parts, reason.Message)
+	}
+	if len(parts) == 0 {
+		return "unknown reason"
+	}
+	return strings.Join(parts, ", ")
+}
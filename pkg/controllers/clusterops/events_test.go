@@ -0,0 +1,101 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestFailureMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason *clusteroperationv1alpha1.FailureReason
+		want   string
+	}{
+		{
+			name: "nil reason",
+			want: "unknown reason",
+		},
+		{
+			name:   "empty reason",
+			reason: &clusteroperationv1alpha1.FailureReason{},
+			want:   "unknown reason",
+		},
+		{
+			name:   "full reason",
+			reason: &clusteroperationv1alpha1.FailureReason{Stage: "action", Task: "ping", Hosts: []string{"node1", "node2"}, Message: "timeout"},
+			want:   `stage action, task "ping", hosts node1,node2, timeout`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FailureMessage(test.reason); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecordEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "no event recorder",
+			args: func() bool {
+				controller := Controller{}
+				controller.recordEvent(&clusteroperationv1alpha1.ClusterOperation{}, "Normal", JobCreatedReason, "created job %s", "job1")
+				return true
+			},
+			want: true,
+		},
+		{
+			name: "succeeded event when the job completed",
+			args: func() bool {
+				recorder := record.NewFakeRecorder(10)
+				controller := Controller{Client: newFakeClient(), EventRecorder: recorder}
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				ops.Name = "ops1"
+				ops.Spec.Action = "cluster.yml"
+				ops.Status.Status = clusteroperationv1alpha1.RunningStatus
+				controller.Client.Create(context.Background(), ops)
+				controller.UpdateStatusLoop(ops, func(*clusteroperationv1alpha1.ClusterOperation) (clusteroperationv1alpha1.OpsStatus, *metav1.Time, error) {
+					return clusteroperationv1alpha1.SucceededStatus, nil, nil
+				})
+				return len(recorder.Events) == 1 && <-recorder.Events == "Normal Succeeded action cluster.yml succeeded"
+			},
+			want: true,
+		},
+		{
+			name: "failed event with the message",
+			args: func() bool {
+				recorder := record.NewFakeRecorder(10)
+				controller := Controller{Client: newFakeClient(), EventRecorder: recorder}
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				ops.Name = "ops1"
+				controller.Client.Create(context.Background(), ops)
+				controller.recordFailed(ops, "wrong image format")
+				event := <-recorder.Events
+				return strings.HasPrefix(event, "Warning Failed") && strings.Contains(event, "wrong image format")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// shellMetacharacters run other commands or redirect the output when the extraArgs is appended to the playbook command.
+const shellMetacharacters = "`$;&|<>()\\\n\r"
+
+// valueOptions are the options of ansible-playbook followed by their value in the next argument.
+var valueOptions = sets.NewString(
+	"-e", "--extra-vars", "-i", "--inventory", "--inventory-file", "-l", "--limit", "-t", "--tags", "--skip-tags",
+	"-u", "--user", "-c", "--connection", "-T", "--timeout", "-f", "--forks", "-M", "--module-path",
+	"--private-key", "--key-file", "--become-method", "--become-user", "--vault-id", "--vault-password-file",
+	"--start-at-task", "--ssh-common-args", "--ssh-extra-args", "--sftp-extra-args", "--scp-extra-args",
+)
+
+// splitExtraArgs splits the extraArgs into the arguments as the shell does, and rejects the shell metacharacters.
+func splitExtraArgs(extraArgs string) ([]string, error) {
+	if i := strings.IndexAny(extraArgs, shellMetacharacters); i >= 0 {
+		return nil, fmt.Errorf("shell metacharacter %q", extraArgs[i])
+	}
+	args := make([]string, 0)
+	arg, inArg, quote := &strings.Builder{}, false, rune(0)
+	for _, c := range extraArgs {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+			inArg = false
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %q", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// extraPlaybooks returns the positional arguments of the extraArgs, which ansible-playbook runs as the playbooks
+// after the action.
+func extraPlaybooks(extraArgs string) ([]string, error) {
+	args, err := splitExtraArgs(extraArgs)
+	if err != nil {
+		return nil, err
+	}
+	playbooks := make([]string, 0)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case valueOptions.Has(args[i]):
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			playbooks = append(playbooks, args[i])
+		}
+	}
+	return playbooks, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtraPlaybooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		extraArgs string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "options and their values",
+			extraArgs: `-e "a=b c=d" -e@vars.yml --limit node1 -vv --check`,
+			want:      []string{},
+		},
+		{
+			name:      "positional playbooks",
+			extraArgs: "'/kubespray/reset.yml' -e reset_confirmation=yes -- other.yml",
+			want:      []string{"/kubespray/reset.yml", "other.yml"},
+		},
+		{
+			name:      "shell metacharacter",
+			extraArgs: "-e a=$(id)",
+			wantErr:   true,
+		},
+		{
+			name:      "unterminated quote",
+			extraArgs: `-e "a=b`,
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := extraPlaybooks(test.extraArgs)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"fmt"
+
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	MaxFailureMessageLength = 1024
+	MaxFailureHosts         = 10
+)
+
+func truncateMessage(message string) string {
+	if len(message) <= MaxFailureMessageLength {
+		return message
+	}
+	return message[:MaxFailureMessageLength-3] + "..."
+}
+
+// FetchFailureReason summarizes the failed stage and the last failed ansible task from the job log.
+func (c *Controller) FetchFailureReason(clusterOps *clusteroperationv1alpha1.ClusterOperation) *clusteroperationv1alpha1.FailureReason {
+	reason := &clusteroperationv1alpha1.FailureReason{}
+	var sinceTime *metav1.Time
+	var exitCode *int32
+	for _, stage := range clusterOps.Status.Stages {
+		if stage.Status == clusteroperationv1alpha1.FailedStatus {
+			reason.Stage = stage.Name
+			sinceTime = stage.StartTime
+			exitCode = stage.ExitCode
+			break
+		}
+	}
+	logStream, err := c.FetchJobPodLog(clusterOps, sinceTime)
+	if err != nil {
+		klog.Warningf("clusterOps %s fetch job log for failure reason but %s", clusterOps.Name, err.Error())
+		reason.Message = truncateMessage(fmt.Sprintf("fetch job log: %s", err.Error()))
+		return reason
+	}
+	defer logStream.Close()
+	failure, err := ansible.ParseLastFailure(logStream)
+	if err != nil {
+		klog.Warningf("clusterOps %s parse failed task but %s", clusterOps.Name, err.Error())
+	}
+	if failure == nil {
+		if exitCode != nil {
+			reason.Message = fmt.Sprintf("exit code %d", *exitCode)
+		}
+		return reason
+	}
+	reason.Task = failure.Task
+	reason.Hosts = failure.Hosts
+	if len(reason.Hosts) > MaxFailureHosts {
+		reason.Hosts = reason.Hosts[:MaxFailureHosts]
+	}
+	reason.Message = truncateMessage(failure.Message)
+	return reason
+}
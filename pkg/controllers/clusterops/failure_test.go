@@ -0,0 +1,78 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTruncateMessage(t *testing.T) {
+	if truncateMessage("timeout") != "timeout" {
+		t.Fatal()
+	}
+	message := truncateMessage(strings.Repeat("a", MaxFailureMessageLength+1))
+	if len(message) != MaxFailureMessageLength || !strings.HasSuffix(message, "...") {
+		t.Fatal()
+	}
+}
+
+func TestFetchFailureReason(t *testing.T) {
+	exitCode := int32(2)
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "job not found",
+			args: func() bool {
+				controller := Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+				reason := controller.FetchFailureReason(ops)
+				return strings.HasPrefix(reason.Message, "fetch job log") && reason.Task == ""
+			},
+			want: true,
+		},
+		{
+			name: "no failed task in log",
+			args: func() bool {
+				controller := Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+				controller.ClientSet.BatchV1().Jobs("kubean-system").Create(context.Background(), &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+					Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}}},
+				}, metav1.CreateOptions{})
+				controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "kubean-system", Labels: map[string]string{"job-name": "job1"}},
+				}, metav1.CreateOptions{})
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+				ops.Status.Stages = []clusteroperationv1alpha1.Stage{
+					{Name: "prehook-0", Status: clusteroperationv1alpha1.SucceededStatus},
+					{Name: "action", Status: clusteroperationv1alpha1.FailedStatus, ExitCode: &exitCode},
+				}
+				reason := controller.FetchFailureReason(ops)
+				return reason.Stage == "action" && reason.Message == "exit code 2"
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
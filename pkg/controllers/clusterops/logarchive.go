@@ -0,0 +1,172 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"github.com/kubean-io/kubean/pkg/util"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	LogArchiveLabelKey      = "kubean.io/log-archive"
+	LogArchiveChunkLabelKey = "kubean.io/log-archive-chunk"
+	LogArchiveDataKey       = "log.gz"
+	// LogArchiveChunkSize keeps each configmap below the 1MiB limit of etcd objects.
+	LogArchiveChunkSize = 900 * 1024
+)
+
+// LogArchiveDir is where the persistentVolumeClaim for log archive is mounted in the operator pod.
+var LogArchiveDir = "/var/log/kubean-archive"
+
+// LogArchiveConfigMapName returns the configmap name of the chunk at index.
+func LogArchiveConfigMapName(logRef *clusteroperationv1alpha1.LogRef, index int) string {
+	return fmt.Sprintf("%s-%d", logRef.Name, index)
+}
+
+// ArchiveJobLog stores the compressed job log once the clusterOps has finished, and Status.LogRef marks it done,
+// so that a failed attempt is retried by the next reconcile of the finished clusterOps.
+func (c *Controller) ArchiveJobLog(clusterOps *clusteroperationv1alpha1.ClusterOperation) error {
+	if clusterOps.Status.LogRef != nil || clusterOps.Status.JobRef.IsEmpty() || !IsFinished(clusterOps) {
+		return nil
+	}
+	return c.archiveJobLog(clusterOps)
+}
+
+// archiveJobLog stores the current log of the job pod, and overwrites the previous archive of the clusterOps.
+func (c *Controller) archiveJobLog(clusterOps *clusteroperationv1alpha1.ClusterOperation) error {
+	config := util.FetchKubeanConfigProperty(c.ClientSet)
+	backend := config.GetLogArchiveBackend()
+	if backend == constants.LogArchiveBackendNone {
+		return nil
+	}
+	cluster, err := c.GetKuBeanCluster(clusterOps)
+	if err != nil {
+		return err
+	}
+	logStream, err := c.FetchJobPodLog(clusterOps, nil)
+	if apierrors.IsNotFound(err) || errors.Is(err, errNoJobPod) {
+		// nothing to archive any more, e.g. the job of the clusterOps cancelled before its pod started.
+		klog.Warningf("the job log of clusterOps %s is gone and not archived, %s", clusterOps.Name, err.Error())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer logStream.Close()
+	var logRef *clusteroperationv1alpha1.LogRef
+	if backend == constants.LogArchiveBackendPVC {
+		logRef, err = c.archiveLogToDir(clusterOps, config.LogArchivePVC, logStream)
+	} else {
+		logRef, err = c.archiveLogToConfigMaps(clusterOps, cluster, logStream)
+	}
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(clusterOps.Status.LogRef, logRef) {
+		return nil
+	}
+	klog.Warningf("archive job log of clusterOps %s to %s %s", clusterOps.Name, logRef.Backend, logRef.Name)
+	clusterOps.Status.LogRef = logRef
+	return c.Client.Status().Update(context.Background(), clusterOps)
+}
+
+func (c *Controller) archiveLogToConfigMaps(clusterOps *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster, logStream io.Reader) (*clusteroperationv1alpha1.LogRef, error) {
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	if _, err := io.Copy(gzipWriter, logStream); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	logRef := &clusteroperationv1alpha1.LogRef{
+		Backend:   clusteroperationv1alpha1.ConfigMapLogBackend,
+		NameSpace: util.TenantNamespace(cluster),
+		Name:      fmt.Sprintf("%s-log", clusterOps.Name),
+	}
+	data := compressed.Bytes()
+	for index := 0; index == 0 || len(data) > 0; index++ {
+		chunkSize := LogArchiveChunkSize
+		if len(data) < chunkSize {
+			chunkSize = len(data)
+		}
+		configMap := &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      LogArchiveConfigMapName(logRef, index),
+				Namespace: logRef.NameSpace,
+				Labels: map[string]string{
+					constants.KubeanClusterLabelKey: cluster.Name,
+					LogArchiveLabelKey:              clusterOps.Name,
+					LogArchiveChunkLabelKey:         strconv.Itoa(index),
+				},
+				// the archive belongs to cluster so that it outlives the pruned clusterOps.
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cluster, clusterv1alpha1.SchemeGroupVersion.WithKind("Cluster"))},
+			},
+			BinaryData: map[string][]byte{LogArchiveDataKey: data[:chunkSize]},
+		}
+		_, err := c.ClientSet.CoreV1().ConfigMaps(configMap.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			_, err = c.ClientSet.CoreV1().ConfigMaps(configMap.Namespace).Update(context.Background(), configMap, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = data[chunkSize:]
+		logRef.Chunks++
+	}
+	return logRef, nil
+}
+
+func (c *Controller) archiveLogToDir(clusterOps *clusteroperationv1alpha1.ClusterOperation, pvcName string, logStream io.Reader) (*clusteroperationv1alpha1.LogRef, error) {
+	clusterDir := filepath.Join(LogArchiveDir, clusterOps.Spec.Cluster)
+	if err := os.MkdirAll(clusterDir, 0o755); err != nil {
+		return nil, err
+	}
+	logFile := filepath.Join(clusterDir, fmt.Sprintf("%s.log.gz", clusterOps.Name))
+	f, err := os.Create(logFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gzipWriter := gzip.NewWriter(f)
+	if _, err := io.Copy(gzipWriter, logStream); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	relativePath, err := filepath.Rel(LogArchiveDir, logFile)
+	if err != nil {
+		return nil, err
+	}
+	return &clusteroperationv1alpha1.LogRef{
+		Backend:   clusteroperationv1alpha1.PVCLogBackend,
+		NameSpace: util.GetCurrentNSOrDefault(),
+		Name:      pvcName,
+		Path:      relativePath,
+	}, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubean-io/kubean/pkg/util"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+	clusterv1alpha1fake "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned/fake"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// setLogArchiveDir points LogArchiveDir to dir until the test ends.
+func setLogArchiveDir(t *testing.T, dir string) {
+	origin := LogArchiveDir
+	t.Cleanup(func() {
+		LogArchiveDir = origin
+	})
+	LogArchiveDir = dir
+}
+
+func TestArchiveJobLog(t *testing.T) {
+	cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1", UID: "cluster1-uid"}}
+	genController := func(backend string) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:           newFakeClient(),
+			ClientSet:        clientsetfake.NewSimpleClientset(),
+			KubeanClusterSet: clusterv1alpha1fake.NewSimpleClientset(cluster),
+		}
+		controller.ClientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: constants.KubeanConfigMapName, Namespace: util.GetCurrentNSOrDefault()},
+			Data:       map[string]string{"LOG_ARCHIVE_BACKEND": backend, "LOG_ARCHIVE_PVC": "kubean-log"},
+		}, metav1.CreateOptions{})
+		controller.ClientSet.BatchV1().Jobs("kubean-system").Create(context.Background(), &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+			Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}}},
+		}, metav1.CreateOptions{})
+		controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "kubean-system", Labels: map[string]string{"job-name": "job1"}},
+		}, metav1.CreateOptions{})
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1"},
+			Spec:       clusteroperationv1alpha1.Spec{Cluster: cluster.Name},
+		}
+		controller.Client.Create(context.Background(), ops)
+		ops.Status.Status = clusteroperationv1alpha1.FailedStatus
+		ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+		return controller, ops
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "clusterOps is still running",
+			args: func() bool {
+				controller, ops := genController("")
+				ops.Status.Status = clusteroperationv1alpha1.RunningStatus
+				return controller.ArchiveJobLog(ops) == nil && ops.Status.LogRef == nil
+			},
+			want: true,
+		},
+		{
+			name: "archive is disabled",
+			args: func() bool {
+				controller, ops := genController(constants.LogArchiveBackendNone)
+				return controller.ArchiveJobLog(ops) == nil && ops.Status.LogRef == nil
+			},
+			want: true,
+		},
+		{
+			name: "archive to configmaps",
+			args: func() bool {
+				controller, ops := genController(constants.LogArchiveBackendConfigMap)
+				if err := controller.ArchiveJobLog(ops); err != nil {
+					return false
+				}
+				logRef := ops.Status.LogRef
+				if logRef == nil || logRef.Backend != clusteroperationv1alpha1.ConfigMapLogBackend || logRef.Chunks != 1 {
+					return false
+				}
+				configMap, err := controller.ClientSet.CoreV1().ConfigMaps(logRef.NameSpace).Get(context.Background(), LogArchiveConfigMapName(logRef, 0), metav1.GetOptions{})
+				if err != nil || configMap.Labels[LogArchiveLabelKey] != "ops1" || configMap.OwnerReferences[0].UID != cluster.UID {
+					return false
+				}
+				gzipReader, err := gzip.NewReader(bytes.NewReader(configMap.BinaryData[LogArchiveDataKey]))
+				if err != nil {
+					return false
+				}
+				data, _ := io.ReadAll(gzipReader)
+				return string(data) == "fake logs"
+			},
+			want: true,
+		},
+		{
+			name: "archive to pvc",
+			args: func() bool {
+				controller, ops := genController(constants.LogArchiveBackendPVC)
+				setLogArchiveDir(t, t.TempDir())
+				if err := controller.ArchiveJobLog(ops); err != nil {
+					return false
+				}
+				logRef := ops.Status.LogRef
+				if logRef == nil || logRef.Backend != clusteroperationv1alpha1.PVCLogBackend || logRef.Name != "kubean-log" {
+					return false
+				}
+				_, err := os.Stat(filepath.Join(LogArchiveDir, logRef.Path))
+				return err == nil && logRef.Path == "cluster1/ops1.log.gz"
+			},
+			want: true,
+		},
+		{
+			name: "job is gone",
+			args: func() bool {
+				controller, ops := genController(constants.LogArchiveBackendConfigMap)
+				ops.Status.JobRef.Name = "job2"
+				return controller.ArchiveJobLog(ops) == nil && ops.Status.LogRef == nil
+			},
+			want: true,
+		},
+		{
+			name: "archive the cancelled clusterOps",
+			args: func() bool {
+				controller, ops := genController(constants.LogArchiveBackendConfigMap)
+				ops.Status.Status = clusteroperationv1alpha1.CancelledStatus
+				return controller.ArchiveJobLog(ops) == nil && ops.Status.LogRef != nil
+			},
+			want: true,
+		},
+		{
+			name: "retry the failed archive",
+			args: func() bool {
+				controller, ops := genController(constants.LogArchiveBackendPVC)
+				// the archive dir is not writable.
+				setLogArchiveDir(t, filepath.Join(t.TempDir(), "file"))
+				os.WriteFile(LogArchiveDir, nil, 0o600)
+				if controller.ArchiveJobLog(ops) == nil || ops.Status.LogRef != nil {
+					return false
+				}
+				setLogArchiveDir(t, t.TempDir())
+				return controller.ArchiveJobLog(ops) == nil && ops.Status.LogRef != nil
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"fmt"
+	"time"
+	_ "time/tzdata" // the operator image may have no zoneinfo for maintenance window timeZone
+
+	"github.com/kubean-io/kubean/pkg/metrics"
+	"github.com/kubean-io/kubean/pkg/util/cron"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// LoopForMaintenanceWindow is the longest interval to check the maintenance window, so that the changed window takes effect soon.
+const LoopForMaintenanceWindow = time.Minute
+
+// currentWindowEnd returns when the window which is open at now closes, or the zero time if no window is open.
+func currentWindowEnd(schedule *cron.Schedule, duration time.Duration, now time.Time) time.Time {
+	latestStart := time.Time{}
+	for start := schedule.Next(now.Add(-duration)); !start.IsZero() && !start.After(now); start = schedule.Next(start) {
+		latestStart = start // the latest opened window closes last
+	}
+	if latestStart.IsZero() {
+		return time.Time{}
+	}
+	return latestStart.Add(duration)
+}
+
+// CheckMaintenanceWindow returns the time to wait until and the reason if the job can not start at now.
+// The error means the clusterOps never fits the window.
+func CheckMaintenanceWindow(window *clusterv1alpha1.MaintenanceWindow, activeDeadlineSeconds *int64, now time.Time) (time.Time, string, error) {
+	if window == nil {
+		return time.Time{}, "", nil
+	}
+	location := time.UTC
+	if window.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(window.TimeZone); err != nil {
+			return time.Time{}, "", fmt.Errorf("maintenance window has invalid timeZone %q: %w", window.TimeZone, err)
+		}
+	}
+	schedule, err := cron.Parse(window.Schedule)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("maintenance window has invalid schedule: %w", err)
+	}
+	if window.DurationSeconds <= 0 {
+		return time.Time{}, "", fmt.Errorf("maintenance window has invalid durationSeconds %d", window.DurationSeconds)
+	}
+	deadline := int64(0)
+	if activeDeadlineSeconds != nil && *activeDeadlineSeconds > 0 {
+		deadline = *activeDeadlineSeconds
+	}
+	if deadline > window.DurationSeconds {
+		return time.Time{}, "", fmt.Errorf("activeDeadlineSeconds %d would overrun the maintenance window of %d seconds", deadline, window.DurationSeconds)
+	}
+	now = now.In(location)
+	windowEnd := currentWindowEnd(schedule, time.Duration(window.DurationSeconds)*time.Second, now)
+	if !windowEnd.IsZero() && !now.Add(time.Duration(deadline)*time.Second).After(windowEnd) {
+		return time.Time{}, "", nil
+	}
+	nextStart := schedule.Next(now)
+	if nextStart.IsZero() {
+		return time.Time{}, "", fmt.Errorf("maintenance window with schedule %q never opens", window.Schedule)
+	}
+	if !windowEnd.IsZero() {
+		return nextStart, fmt.Sprintf("waiting for maintenance window starting at %s, because activeDeadlineSeconds %d would overrun the current window ending at %s",
+			nextStart.Format(time.RFC3339), deadline, windowEnd.Format(time.RFC3339)), nil
+	}
+	return nextStart, fmt.Sprintf("waiting for maintenance window starting at %s", nextStart.Format(time.RFC3339)), nil
+}
+
+// WaitForMaintenanceWindow defers creating the job until the maintenance window of the cluster opens, and returns how long to wait.
+// The clusterOps which would overrun the window is updated Failed.
+func (c *Controller) WaitForMaintenanceWindow(clusterOps *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster) (time.Duration, error) {
+	if !clusterOps.Status.JobRef.IsEmpty() {
+		return 0, nil
+	}
+	now := time.Now()
+	waitUntil, message, err := CheckMaintenanceWindow(cluster.Spec.MaintenanceWindow, clusterOps.Spec.ActiveDeadlineSeconds, now)
+	if err != nil {
+		klog.Errorf("clusterOps %s refused by the maintenance window of cluster %s: %s", clusterOps.Name, cluster.Name, err.Error())
+		clusterOps.Status.Status = clusteroperationv1alpha1.FailedStatus
+		clusterOps.Status.Message = err.Error()
+		clusterOps.Status.EndTime = &metav1.Time{Time: now}
+		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+			return 0, err
+		}
+		c.recordFailed(clusterOps, err.Error())
+		metrics.ObserveFinishedClusterOperation(clusterOps)
+		return 0, nil
+	}
+	if message == "" {
+		return 0, nil
+	}
+	if clusterOps.Status.Message != message || clusterOps.Status.Status == "" {
+		klog.Warningf("clusterOps %s is %s", clusterOps.Name, message)
+		if clusterOps.Status.Status == "" {
+			clusterOps.Status.Status = clusteroperationv1alpha1.PendingStatus
+		}
+		clusterOps.Status.Message = message
+		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+			return 0, err
+		}
+	}
+	if wait := waitUntil.Sub(now); wait < LoopForMaintenanceWindow {
+		return wait, nil
+	}
+	return LoopForMaintenanceWindow, nil
+}
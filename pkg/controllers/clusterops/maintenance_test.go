@@ -0,0 +1,150 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckMaintenanceWindow(t *testing.T) {
+	// 2023-06-07 is a Wednesday.
+	now := time.Date(2023, 6, 7, 10, 30, 0, 0, time.UTC)
+	deadline := func(seconds int64) *int64 {
+		return &seconds
+	}
+	tests := []struct {
+		name          string
+		window        *clusterv1alpha1.MaintenanceWindow
+		deadline      *int64
+		wantWaitUntil time.Time
+		wantMessage   string
+		wantErr       bool
+	}{
+		{name: "no window"},
+		{
+			name:   "in the window",
+			window: &clusterv1alpha1.MaintenanceWindow{Schedule: "0 10 * * *", DurationSeconds: 3600},
+		},
+		{
+			name:     "in the window before the deadline",
+			window:   &clusterv1alpha1.MaintenanceWindow{Schedule: "0 10 * * *", DurationSeconds: 3600},
+			deadline: deadline(1200),
+		},
+		{
+			name:          "would overrun the current window",
+			window:        &clusterv1alpha1.MaintenanceWindow{Schedule: "0 10 * * *", DurationSeconds: 3600},
+			deadline:      deadline(2400),
+			wantWaitUntil: time.Date(2023, 6, 8, 10, 0, 0, 0, time.UTC),
+			wantMessage:   "waiting for maintenance window starting at 2023-06-08T10:00:00Z, because activeDeadlineSeconds 2400 would overrun the current window ending at 2023-06-07T11:00:00Z",
+		},
+		{
+			name:          "window closed",
+			window:        &clusterv1alpha1.MaintenanceWindow{Schedule: "0 22 * * 6", DurationSeconds: 3600},
+			wantWaitUntil: time.Date(2023, 6, 10, 22, 0, 0, 0, time.UTC),
+			wantMessage:   "waiting for maintenance window starting at 2023-06-10T22:00:00Z",
+		},
+		{
+			name:   "in the window of the time zone",
+			window: &clusterv1alpha1.MaintenanceWindow{Schedule: "0 18 * * *", DurationSeconds: 3600, TimeZone: "Asia/Shanghai"},
+		},
+		{
+			name:     "deadline longer than the window",
+			window:   &clusterv1alpha1.MaintenanceWindow{Schedule: "0 10 * * *", DurationSeconds: 3600},
+			deadline: deadline(7200),
+			wantErr:  true,
+		},
+		{
+			name:    "invalid schedule",
+			window:  &clusterv1alpha1.MaintenanceWindow{Schedule: "0 10 * *", DurationSeconds: 3600},
+			wantErr: true,
+		},
+		{
+			name:    "invalid time zone",
+			window:  &clusterv1alpha1.MaintenanceWindow{Schedule: "0 10 * * *", DurationSeconds: 3600, TimeZone: "Mars/Base"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waitUntil, message, err := CheckMaintenanceWindow(test.window, test.deadline, now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err %v, wantErr %v", err, test.wantErr)
+			}
+			if !waitUntil.Equal(test.wantWaitUntil) || message != test.wantMessage {
+				t.Fatalf("got %v %q, want %v %q", waitUntil, message, test.wantWaitUntil, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestWaitForMaintenanceWindow(t *testing.T) {
+	genController := func(window *clusterv1alpha1.MaintenanceWindow) (*Controller, *clusteroperationv1alpha1.ClusterOperation, *clusterv1alpha1.Cluster) {
+		controller := &Controller{Client: newFakeClient()}
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1"},
+			Spec:       clusteroperationv1alpha1.Spec{Cluster: "cluster1"},
+		}
+		controller.Client.Create(context.Background(), ops)
+		cluster := &clusterv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1"},
+			Spec:       clusterv1alpha1.Spec{MaintenanceWindow: window},
+		}
+		return controller, ops, cluster
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "always open",
+			args: func() bool {
+				controller, ops, cluster := genController(&clusterv1alpha1.MaintenanceWindow{Schedule: "* * * * *", DurationSeconds: 3600})
+				wait, err := controller.WaitForMaintenanceWindow(ops, cluster)
+				return err == nil && wait == 0 && ops.Status.Status == "" && ops.Status.Message == ""
+			},
+			want: true,
+		},
+		{
+			name: "wait for the window",
+			args: func() bool {
+				controller, ops, cluster := genController(&clusterv1alpha1.MaintenanceWindow{Schedule: "0 0 1 1 *", DurationSeconds: 60})
+				if time.Now().UTC().Month() == time.January && time.Now().UTC().Day() == 1 {
+					return true // the window may be open
+				}
+				wait, err := controller.WaitForMaintenanceWindow(ops, cluster)
+				return err == nil && wait == LoopForMaintenanceWindow && ops.Status.Status == clusteroperationv1alpha1.PendingStatus &&
+					strings.HasPrefix(ops.Status.Message, "waiting for maintenance window starting at ")
+			},
+			want: true,
+		},
+		{
+			name: "refuse the clusterOps which overruns the window",
+			args: func() bool {
+				controller, ops, cluster := genController(&clusterv1alpha1.MaintenanceWindow{Schedule: "* * * * *", DurationSeconds: 60})
+				deadline := int64(600)
+				ops.Spec.ActiveDeadlineSeconds = &deadline
+				wait, err := controller.WaitForMaintenanceWindow(ops, cluster)
+				return err == nil && wait == 0 && ops.Status.Status == clusteroperationv1alpha1.FailedStatus && ops.Status.EndTime != nil &&
+					strings.Contains(ops.Status.Message, "would overrun")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
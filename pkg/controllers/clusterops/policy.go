@@ -0,0 +1,195 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"fmt"
+	"strings"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationpolicyv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationpolicy/v1alpha1"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+// ImageRegistry returns the registry of the image, e.g. docker.io for kubean-io/spray-job.
+func ImageRegistry(image string) string {
+	slash := strings.Index(image, "/")
+	if slash < 0 {
+		return "docker.io"
+	}
+	host := image[:slash]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return "docker.io"
+}
+
+func policyAppliesTo(policy *clusteroperationpolicyv1alpha1.ClusterOperationPolicy, clusterName string) bool {
+	return len(policy.Spec.Clusters) == 0 || sets.NewString(policy.Spec.Clusters...).Has(clusterName)
+}
+
+func policyBinds(policy *clusteroperationpolicyv1alpha1.ClusterOperationPolicy, userInfo authenticationv1.UserInfo) bool {
+	for _, subject := range policy.Spec.Subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			if subject.Name == userInfo.Username {
+				return true
+			}
+		case rbacv1.GroupKind:
+			if sets.NewString(userInfo.Groups...).Has(subject.Name) {
+				return true
+			}
+		case rbacv1.ServiceAccountKind:
+			if serviceaccount.MatchesUsername(subject.Namespace, subject.Name, userInfo.Username) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// policyDenials returns the image and the actions of the clusterOps which the policy does not allow, and the extraArgs
+// of the playbooks which run other commands or playbooks.
+func policyDenials(policy *clusteroperationpolicyv1alpha1.ClusterOperationPolicy, clusterOps *clusteroperationv1alpha1.ClusterOperation) field.ErrorList {
+	errs := field.ErrorList{}
+	deny := func(path *field.Path, what string) {
+		errs = append(errs, field.Forbidden(path, fmt.Sprintf("%s is not allowed by ClusterOperationPolicy %s", what, policy.Name)))
+	}
+	if len(policy.Spec.ImageRegistries) > 0 && !sets.NewString(policy.Spec.ImageRegistries...).Has(ImageRegistry(clusterOps.Spec.Image)) {
+		deny(field.NewPath("spec", "image"), fmt.Sprintf("image registry %s", ImageRegistry(clusterOps.Spec.Image)))
+	}
+	for _, part := range actionParts(clusterOps) {
+		typeAllowed, sourceAllowed := true, true
+		if len(policy.Spec.ActionTypes) > 0 {
+			typeAllowed = false
+			for _, actionType := range policy.Spec.ActionTypes {
+				typeAllowed = typeAllowed || actionType == part.actionType
+			}
+			if !typeAllowed {
+				deny(part.path.Child("actionType"), fmt.Sprintf("actionType %s", part.actionType))
+			}
+		}
+		actionSource := clusteroperationv1alpha1.BuiltinActionSource
+		if part.actionSource != nil {
+			actionSource = *part.actionSource
+		}
+		if len(policy.Spec.ActionSources) > 0 {
+			sourceAllowed = false
+			for _, source := range policy.Spec.ActionSources {
+				sourceAllowed = sourceAllowed || source == actionSource
+			}
+			if !sourceAllowed {
+				deny(part.path.Child("actionSource"), fmt.Sprintf("actionSource %s", actionSource))
+			}
+		}
+		// the playbooks allow only the builtin playbooks, but neither the shell nor the playbooks of the configmap.
+		if len(policy.Spec.Playbooks) > 0 {
+			switch {
+			case part.actionType != clusteroperationv1alpha1.PlaybookActionType:
+				if typeAllowed {
+					deny(part.path.Child("actionType"), fmt.Sprintf("actionType %s", part.actionType))
+				}
+			case !part.isBuiltin():
+				if sourceAllowed {
+					deny(part.path.Child("actionSource"), fmt.Sprintf("actionSource %s", actionSource))
+				}
+			case !sets.NewString(policy.Spec.Playbooks...).Has(part.action):
+				deny(part.path.Child("action"), fmt.Sprintf("playbook %s", part.action))
+			}
+		}
+		if part.actionType != clusteroperationv1alpha1.PlaybookActionType || part.extraArgs == "" {
+			continue
+		}
+		// the extraArgs is appended to the playbook command, which must not run other commands or playbooks.
+		playbooks, err := extraPlaybooks(part.extraArgs)
+		if err != nil {
+			deny(part.path.Child("extraArgs"), fmt.Sprintf("extraArgs with %s", err))
+			continue
+		}
+		for _, playbook := range playbooks {
+			deny(part.path.Child("extraArgs"), fmt.Sprintf("playbook %s in extraArgs", playbook))
+		}
+	}
+	return errs
+}
+
+// ValidatePolicies checks the user is bound to one of the ClusterOperationPolicies of the cluster which allows
+// the image and all the actions of the clusterOps. The clusterOps of the cluster without policy is not limited.
+func ValidatePolicies(policies []clusteroperationpolicyv1alpha1.ClusterOperationPolicy, clusterOps *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) field.ErrorList {
+	applied := make([]string, 0)
+	denials := field.ErrorList{}
+	for i := range policies {
+		policy := &policies[i]
+		if !policyAppliesTo(policy, clusterOps.Spec.Cluster) {
+			continue
+		}
+		applied = append(applied, policy.Name)
+		if !policyBinds(policy, userInfo) {
+			continue
+		}
+		errs := policyDenials(policy, clusterOps)
+		if len(errs) == 0 {
+			return nil
+		}
+		denials = append(denials, errs...)
+	}
+	if len(applied) == 0 || len(denials) > 0 {
+		return denials
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec", "cluster"),
+		fmt.Sprintf("user %s is bound to none of the ClusterOperationPolicies %s of cluster %s", userInfo.Username, applied, clusterOps.Spec.Cluster))}
+}
+
+// ValidatePolicy checks the subjects, the actionTypes, the actionSources and the builtin playbooks of the policy.
+func ValidatePolicy(policy *clusteroperationpolicyv1alpha1.ClusterOperationPolicy) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+	if len(policy.Spec.Subjects) == 0 {
+		errs = append(errs, field.Required(specPath.Child("subjects"), ""))
+	}
+	for i, subject := range policy.Spec.Subjects {
+		subjectPath := specPath.Child("subjects").Index(i)
+		switch subject.Kind {
+		case rbacv1.UserKind, rbacv1.GroupKind:
+		case rbacv1.ServiceAccountKind:
+			if subject.Namespace == "" {
+				errs = append(errs, field.Required(subjectPath.Child("namespace"), "namespace of the ServiceAccount"))
+			}
+		default:
+			errs = append(errs, field.NotSupported(subjectPath.Child("kind"), subject.Kind, []string{rbacv1.UserKind, rbacv1.GroupKind, rbacv1.ServiceAccountKind}))
+		}
+		if subject.Name == "" {
+			errs = append(errs, field.Required(subjectPath.Child("name"), ""))
+		}
+	}
+	actions := entrypoint.NewActions()
+	for i, actionType := range policy.Spec.ActionTypes {
+		if !sets.NewString(actions.Types...).Has(string(actionType)) {
+			errs = append(errs, field.NotSupported(specPath.Child("actionTypes").Index(i), actionType, actions.Types))
+		}
+	}
+	actionSources := []string{string(clusteroperationv1alpha1.BuiltinActionSource), string(clusteroperationv1alpha1.ConfigMapActionSource)}
+	for i, actionSource := range policy.Spec.ActionSources {
+		if !sets.NewString(actionSources...).Has(string(actionSource)) {
+			errs = append(errs, field.NotSupported(specPath.Child("actionSources").Index(i), actionSource, actionSources))
+		}
+	}
+	for i, playbook := range policy.Spec.Playbooks {
+		if _, ok := actions.Playbooks.Dict[playbook]; !ok {
+			errs = append(errs, field.NotSupported(specPath.Child("playbooks").Index(i), playbook, actions.Playbooks.List))
+		}
+	}
+	for i, registry := range policy.Spec.ImageRegistries {
+		if registry == "" || strings.Contains(registry, "/") {
+			errs = append(errs, field.Invalid(specPath.Child("imageRegistries").Index(i), registry, "must be the host of the registry"))
+		}
+	}
+	return errs
+}
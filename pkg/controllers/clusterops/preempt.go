@@ -0,0 +1,128 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// PreemptedByAnnoKey is set on the running clusterOps by the Pending one with higher priority.
+const PreemptedByAnnoKey = "kubean.io/preempted-by"
+
+// IsPreemptible returns whether the action of the clusterOps may be stopped mid-run.
+func IsPreemptible(clusterOps *clusteroperationv1alpha1.ClusterOperation, nonPreemptibleActions []string) bool {
+	for _, action := range nonPreemptibleActions {
+		if strings.TrimSpace(clusterOps.Spec.Action) == action {
+			return false
+		}
+	}
+	return true
+}
+
+// PreemptBlocker asks the running blocker with lower priority to give way to the clusterOps.
+// The dry-run clusterOps and the one deferred by the maintenance window of the cluster never preempt,
+// since they would not start the job right after the blocker gives way.
+func (c *Controller) PreemptBlocker(clusterOps, blocker *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster) error {
+	if isQueued(blocker) || blocker.Spec.Priority >= clusterOps.Spec.Priority || blocker.Annotations[PreemptedByAnnoKey] != "" {
+		return nil
+	}
+	if clusterOps.Spec.DryRun {
+		return nil
+	}
+	if _, message, err := CheckMaintenanceWindow(cluster.Spec.MaintenanceWindow, clusterOps.Spec.ActiveDeadlineSeconds, time.Now()); err != nil || message != "" {
+		return nil
+	}
+	config := util.FetchKubeanConfigProperty(c.ClientSet)
+	if !IsPreemptible(blocker, config.GetNonPreemptibleActions()) || !IsApproved(clusterOps, config.GetApprovalRequiredActions()) {
+		return nil
+	}
+	klog.Warningf("clusterOps %s with priority %d preempts clusterOps %s", clusterOps.Name, clusterOps.Spec.Priority, blocker.Name)
+	if blocker.Annotations == nil {
+		blocker.Annotations = map[string]string{}
+	}
+	blocker.Annotations[PreemptedByAnnoKey] = clusterOps.Name
+	_, err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Update(context.Background(), blocker, metav1.UpdateOptions{})
+	return err
+}
+
+// TryPreempt stops the job of the preempted clusterOps and puts it back to the queue, and returns whether it needs requeue.
+func (c *Controller) TryPreempt(clusterOps *clusteroperationv1alpha1.ClusterOperation) (bool, error) {
+	preemptor := clusterOps.Annotations[PreemptedByAnnoKey]
+	if preemptor == "" {
+		return false, nil
+	}
+	if clusterOps.Status.Status == clusteroperationv1alpha1.RunningStatus && !clusterOps.Status.JobRef.IsEmpty() {
+		targetJob, err := c.ClientSet.BatchV1().Jobs(clusterOps.Status.JobRef.NameSpace).Get(context.Background(), clusterOps.Status.JobRef.Name, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		if err == nil {
+			if isJobFinished(targetJob) {
+				// too late to preempt, the job result will be recorded as usual.
+				return false, nil
+			}
+			if targetJob.Spec.Suspend == nil || !*targetJob.Spec.Suspend {
+				klog.Warningf("clusterOps %s is preempted by %s and suspend job %s", clusterOps.Name, preemptor, targetJob.Name)
+				suspend := true
+				targetJob.Spec.Suspend = &suspend
+				if _, err := c.ClientSet.BatchV1().Jobs(targetJob.Namespace).Update(context.Background(), targetJob, metav1.UpdateOptions{}); err != nil {
+					return false, err
+				}
+				return true, nil
+			}
+			if _, err := c.GetRunningPodFromJob(targetJob); err == nil {
+				// wait for ansible to finish the current task.
+				if c.SyncStages(clusterOps, clusteroperationv1alpha1.RunningStatus, nil) {
+					if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+						return false, err
+					}
+				}
+				return true, nil
+			}
+		}
+	}
+	if clusterOps.Status.Status == clusteroperationv1alpha1.RunningStatus {
+		// the interrupted stage runs again in the next attempt.
+		resumeStage := ""
+		for _, stage := range clusterOps.Status.Stages {
+			if stage.Status == clusteroperationv1alpha1.RunningStatus {
+				resumeStage = stage.Name
+				break
+			}
+		}
+		if lastAttempt := LastAttempt(clusterOps); lastAttempt != nil && lastAttempt.Status != clusteroperationv1alpha1.PendingStatus {
+			FinishAttempt(clusterOps, clusteroperationv1alpha1.PreemptedStatus, &metav1.Time{Time: time.Now()})
+			lastAttempt.PreemptedBy = preemptor
+			clusterOps.Status.Attempts = append(clusterOps.Status.Attempts, clusteroperationv1alpha1.Attempt{
+				Index:       int32(len(clusterOps.Status.Attempts) + 1),
+				Status:      clusteroperationv1alpha1.PendingStatus,
+				ResumeStage: resumeStage,
+				LimitHosts:  lastAttempt.LimitHosts,
+			})
+		}
+		ResetStagesFrom(clusterOps.Status.Stages, resumeStage)
+		clusterOps.Status.Status = clusteroperationv1alpha1.PendingStatus
+		clusterOps.Status.JobRef = nil
+		if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+			return false, err
+		}
+	}
+	delete(clusterOps.Annotations, PreemptedByAnnoKey)
+	delete(clusterOps.Annotations, JobActorPodAnnoKey)
+	if err := c.Client.Update(context.Background(), clusterOps); err != nil {
+		return false, err
+	}
+	return true, nil
+}
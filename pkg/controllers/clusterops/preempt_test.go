@@ -0,0 +1,158 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsPreemptible(t *testing.T) {
+	nonPreemptibleActions := []string{"upgrade-cluster.yml", "reset.yml"}
+	for action, want := range map[string]bool{
+		"cluster.yml":         true,
+		"scale.yml":           true,
+		"upgrade-cluster.yml": false,
+		" reset.yml ":         false,
+	} {
+		ops := &clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{Action: action}}
+		if IsPreemptible(ops, nonPreemptibleActions) != want {
+			t.Fatalf("action %q want %v", action, want)
+		}
+	}
+}
+
+func TestTryPreempt(t *testing.T) {
+	genController := func(suspend bool, podPhase corev1.PodPhase, conditions ...batchv1.JobCondition) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:    newFakeClient(),
+			ClientSet: clientsetfake.NewSimpleClientset(),
+		}
+		controller.ClientSet.BatchV1().Jobs("kubean-system").Create(context.Background(), &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+			Spec: batchv1.JobSpec{
+				Suspend:  &suspend,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}},
+			},
+			Status: batchv1.JobStatus{Conditions: conditions},
+		}, metav1.CreateOptions{})
+		if podPhase != "" {
+			controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "kubean-system", Labels: map[string]string{"job-name": "job1"}},
+				Status:     corev1.PodStatus{Phase: podPhase},
+			}, metav1.CreateOptions{})
+		}
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "ops1",
+				Annotations: map[string]string{PreemptedByAnnoKey: "ops-urgent", JobActorPodAnnoKey: "pod1"},
+			},
+			Spec: clusteroperationv1alpha1.Spec{
+				Cluster:    "cluster1",
+				ActionType: clusteroperationv1alpha1.PlaybookActionType,
+				Action:     "cluster.yml",
+			},
+		}
+		controller.Client.Create(context.Background(), ops)
+		ops.Status.Status = clusteroperationv1alpha1.RunningStatus
+		ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}
+		ops.Status.Stages = []clusteroperationv1alpha1.Stage{
+			{Name: "prehook-0", Status: clusteroperationv1alpha1.SucceededStatus},
+			{Name: "action", Status: clusteroperationv1alpha1.RunningStatus},
+		}
+		ops.Status.Attempts = []clusteroperationv1alpha1.Attempt{
+			{Index: 1, Status: clusteroperationv1alpha1.RunningStatus, JobRef: ops.Status.JobRef, LimitHosts: []string{"node2"}},
+		}
+		return controller, ops
+	}
+	isSuspended := func(controller *Controller) bool {
+		job, err := controller.ClientSet.BatchV1().Jobs("kubean-system").Get(context.Background(), "job1", metav1.GetOptions{})
+		return err == nil && job.Spec.Suspend != nil && *job.Spec.Suspend
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "not preempted",
+			args: func() bool {
+				controller, ops := genController(false, corev1.PodRunning)
+				delete(ops.Annotations, PreemptedByAnnoKey)
+				needRequeue, err := controller.TryPreempt(ops)
+				return err == nil && !needRequeue && !isSuspended(controller) && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "suspend the running job",
+			args: func() bool {
+				controller, ops := genController(false, corev1.PodRunning)
+				needRequeue, err := controller.TryPreempt(ops)
+				return err == nil && needRequeue && isSuspended(controller) && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "wait for the running pod",
+			args: func() bool {
+				controller, ops := genController(true, corev1.PodRunning)
+				needRequeue, err := controller.TryPreempt(ops)
+				return err == nil && needRequeue && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "job finished before preemption",
+			args: func() bool {
+				controller, ops := genController(false, corev1.PodSucceeded, batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue})
+				needRequeue, err := controller.TryPreempt(ops)
+				return err == nil && !needRequeue && ops.Status.Status == clusteroperationv1alpha1.RunningStatus
+			},
+			want: true,
+		},
+		{
+			name: "back to the queue",
+			args: func() bool {
+				controller, ops := genController(true, corev1.PodFailed)
+				needRequeue, err := controller.TryPreempt(ops)
+				attempts := ops.Status.Attempts
+				return err == nil && needRequeue && ops.Status.Status == clusteroperationv1alpha1.PendingStatus && ops.Status.JobRef.IsEmpty() &&
+					ops.Annotations[PreemptedByAnnoKey] == "" && ops.Annotations[JobActorPodAnnoKey] == "" &&
+					len(attempts) == 2 && attempts[0].Status == clusteroperationv1alpha1.PreemptedStatus && attempts[0].PreemptedBy == "ops-urgent" &&
+					attempts[0].EndTime != nil && attempts[1].Status == clusteroperationv1alpha1.PendingStatus && attempts[1].ResumeStage == "action" &&
+					reflect.DeepEqual(attempts[1].LimitHosts, []string{"node2"}) &&
+					ops.Status.Stages[0].Status == clusteroperationv1alpha1.SucceededStatus && ops.Status.Stages[1].Status == clusteroperationv1alpha1.PendingStatus
+			},
+			want: true,
+		},
+		{
+			name: "not running anymore",
+			args: func() bool {
+				controller, ops := genController(false, "")
+				ops.Status.Status = clusteroperationv1alpha1.PendingStatus
+				needRequeue, err := controller.TryPreempt(ops)
+				return err == nil && needRequeue && ops.Status.Status == clusteroperationv1alpha1.PendingStatus &&
+					ops.Annotations[PreemptedByAnnoKey] == "" && len(ops.Status.Attempts) == 1
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"sort"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// IsFinished returns whether the clusterOps has reached a terminal status.
+func IsFinished(clusterOps *clusteroperationv1alpha1.ClusterOperation) bool {
+	switch clusterOps.Status.Status {
+	case clusteroperationv1alpha1.SucceededStatus, clusteroperationv1alpha1.FailedStatus, clusteroperationv1alpha1.CancelledStatus:
+		return true
+	}
+	return false
+}
+
+// isQueued returns whether the clusterOps has not started its job yet.
+func isQueued(clusterOps *clusteroperationv1alpha1.ClusterOperation) bool {
+	switch clusterOps.Status.Status {
+	case "", clusteroperationv1alpha1.PendingStatus, clusteroperationv1alpha1.PendingApprovalStatus:
+		return true
+	}
+	return false
+}
+
+// isBefore sorts the started clusterOps first and then the queued ones by priority and creation order.
+func isBefore(opsA, opsB *clusteroperationv1alpha1.ClusterOperation) bool {
+	if isQueued(opsA) != isQueued(opsB) {
+		return !isQueued(opsA)
+	}
+	if opsA.Spec.Priority != opsB.Spec.Priority {
+		return opsA.Spec.Priority > opsB.Spec.Priority
+	}
+	if !opsA.CreationTimestamp.Equal(&opsB.CreationTimestamp) {
+		return opsA.CreationTimestamp.Before(&opsB.CreationTimestamp)
+	}
+	return opsA.Name < opsB.Name
+}
+
+// FetchQueueBlockers returns the unfinished clusterOps of the same cluster which run before the clusterOps, in running order.
+// The dry-run clusterOps and the one waiting for the approval never block others.
+func (c *Controller) FetchQueueBlockers(clusterOps *clusteroperationv1alpha1.ClusterOperation) ([]clusteroperationv1alpha1.ClusterOperation, error) {
+	opsList, err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	blockers := make([]clusteroperationv1alpha1.ClusterOperation, 0)
+	for i := range opsList.Items {
+		ops := &opsList.Items[i]
+		if ops.Name == clusterOps.Name || ops.Spec.Cluster != clusterOps.Spec.Cluster || ops.Spec.DryRun || IsFinished(ops) ||
+			ops.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus {
+			continue
+		}
+		if isBefore(ops, clusterOps) {
+			blockers = append(blockers, *ops)
+		}
+	}
+	sort.Slice(blockers, func(i, j int) bool {
+		return isBefore(&blockers[i], &blockers[j])
+	})
+	return blockers, nil
+}
+
+// WaitInQueue holds the clusterOps in Pending until the clusterOps before it have finished, and returns whether it is still waiting.
+func (c *Controller) WaitInQueue(clusterOps *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster) (bool, error) {
+	if !isQueued(clusterOps) {
+		return false, nil
+	}
+	blockers, err := c.FetchQueueBlockers(clusterOps)
+	if err != nil {
+		return false, err
+	}
+	status, position, blockedBy := clusterOps.Status.Status, int32(len(blockers)), ""
+	if len(blockers) > 0 {
+		status = clusteroperationv1alpha1.PendingStatus
+		blockedBy = blockers[0].Name
+		if err := c.PreemptBlocker(clusterOps, &blockers[0], cluster); err != nil {
+			return false, err
+		}
+	}
+	if clusterOps.Status.Status == status && clusterOps.Status.QueuePosition == position && clusterOps.Status.BlockedBy == blockedBy {
+		return len(blockers) > 0, nil
+	}
+	klog.Warningf("clusterOps %s queue position %d blocked by %q", clusterOps.Name, position, blockedBy)
+	clusterOps.Status.Status = status
+	clusterOps.Status.QueuePosition = position
+	clusterOps.Status.BlockedBy = blockedBy
+	if err := c.Client.Status().Update(context.Background(), clusterOps); err != nil {
+		return false, err
+	}
+	return len(blockers) > 0, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned/fake"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsFinished(t *testing.T) {
+	for status, want := range map[clusteroperationv1alpha1.OpsStatus]bool{
+		"":                                       false,
+		clusteroperationv1alpha1.PendingStatus:   false,
+		clusteroperationv1alpha1.RunningStatus:   false,
+		clusteroperationv1alpha1.SucceededStatus: true,
+		clusteroperationv1alpha1.FailedStatus:    true,
+		clusteroperationv1alpha1.CancelledStatus: true,
+	} {
+		ops := &clusteroperationv1alpha1.ClusterOperation{Status: clusteroperationv1alpha1.Status{Status: status}}
+		if IsFinished(ops) != want {
+			t.Fatalf("status %q want %v", status, want)
+		}
+	}
+}
+
+func TestWaitInQueue(t *testing.T) {
+	newOps := func(name, cluster string, created int64, status clusteroperationv1alpha1.OpsStatus) *clusteroperationv1alpha1.ClusterOperation {
+		return &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(time.Unix(created, 0))},
+			Spec:       clusteroperationv1alpha1.Spec{Cluster: cluster},
+			Status:     clusteroperationv1alpha1.Status{Status: status},
+		}
+	}
+	withPriority := func(ops *clusteroperationv1alpha1.ClusterOperation, priority int32) *clusteroperationv1alpha1.ClusterOperation {
+		ops.Spec.Priority = priority
+		return ops
+	}
+	genControllerWithPriority := func(priority int32, others ...*clusteroperationv1alpha1.ClusterOperation) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:              newFakeClient(),
+			ClientSet:           clientsetfake.NewSimpleClientset(),
+			KubeanClusterOpsSet: clusteroperationv1alpha1fake.NewSimpleClientset(),
+		}
+		ops := withPriority(newOps("ops-self", "cluster1", 200, ""), priority)
+		controller.Client.Create(context.Background(), ops)
+		for _, other := range append(others, ops) {
+			controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), other, metav1.CreateOptions{})
+		}
+		return controller, ops
+	}
+	genController := func(others ...*clusteroperationv1alpha1.ClusterOperation) (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		return genControllerWithPriority(0, others...)
+	}
+	cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	preemptedBy := func(controller *Controller, name string) string {
+		ops, err := controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		return ops.Annotations[PreemptedByAnnoKey]
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "nothing before",
+			args: func() bool {
+				controller, ops := genController(
+					newOps("ops-done", "cluster1", 100, clusteroperationv1alpha1.SucceededStatus),
+					newOps("ops-other-cluster", "cluster2", 100, clusteroperationv1alpha1.RunningStatus),
+					newOps("ops-later", "cluster1", 300, ""),
+				)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && !waiting && ops.Status.Status == "" && ops.Status.QueuePosition == 0
+			},
+			want: true,
+		},
+		{
+			name: "wait for the running and the earlier clusterOps",
+			args: func() bool {
+				controller, ops := genController(
+					newOps("ops-earlier", "cluster1", 150, clusteroperationv1alpha1.PendingStatus),
+					newOps("ops-running", "cluster1", 300, clusteroperationv1alpha1.RunningStatus),
+				)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && waiting && ops.Status.Status == clusteroperationv1alpha1.PendingStatus &&
+					ops.Status.QueuePosition == 2 && ops.Status.BlockedBy == "ops-running"
+			},
+			want: true,
+		},
+		{
+			name: "the running dry-run clusterOps does not block",
+			args: func() bool {
+				dryRun := newOps("ops-dry-run", "cluster1", 100, clusteroperationv1alpha1.RunningStatus)
+				dryRun.Spec.DryRun = true
+				controller, ops := genController(dryRun)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && !waiting && ops.Status.QueuePosition == 0
+			},
+			want: true,
+		},
+		{
+			name: "the clusterOps waiting for the approval does not block",
+			args: func() bool {
+				controller, ops := genController(newOps("ops-unapproved", "cluster1", 100, clusteroperationv1alpha1.PendingApprovalStatus))
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && !waiting && ops.Status.QueuePosition == 0 && ops.Status.BlockedBy == ""
+			},
+			want: true,
+		},
+		{
+			name: "leave the queue",
+			args: func() bool {
+				controller, ops := genController(newOps("ops-done", "cluster1", 100, clusteroperationv1alpha1.FailedStatus))
+				ops.Status.Status = clusteroperationv1alpha1.PendingStatus
+				ops.Status.QueuePosition = 1
+				ops.Status.BlockedBy = "ops-done"
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && !waiting && ops.Status.QueuePosition == 0 && ops.Status.BlockedBy == ""
+			},
+			want: true,
+		},
+		{
+			name: "higher priority goes before the earlier clusterOps",
+			args: func() bool {
+				controller, ops := genControllerWithPriority(10,
+					withPriority(newOps("ops-earlier", "cluster1", 100, clusteroperationv1alpha1.PendingStatus), 5),
+					newOps("ops-earlier-default", "cluster1", 150, ""),
+					withPriority(newOps("ops-later", "cluster1", 300, ""), 20),
+				)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && waiting && ops.Status.QueuePosition == 1 && ops.Status.BlockedBy == "ops-later"
+			},
+			want: true,
+		},
+		{
+			name: "preempt the running clusterOps with lower priority",
+			args: func() bool {
+				running := newOps("ops-running", "cluster1", 100, clusteroperationv1alpha1.RunningStatus)
+				running.Spec.Action = "scale.yml"
+				controller, ops := genControllerWithPriority(10, running)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && waiting && ops.Status.BlockedBy == "ops-running" && preemptedBy(controller, "ops-running") == "ops-self"
+			},
+			want: true,
+		},
+		{
+			name: "the dry-run clusterOps never preempts",
+			args: func() bool {
+				running := newOps("ops-running", "cluster1", 100, clusteroperationv1alpha1.RunningStatus)
+				running.Spec.Action = "scale.yml"
+				controller, ops := genControllerWithPriority(10, running)
+				ops.Spec.DryRun = true
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && waiting && preemptedBy(controller, "ops-running") == ""
+			},
+			want: true,
+		},
+		{
+			name: "never preempt outside the maintenance window",
+			args: func() bool {
+				running := newOps("ops-running", "cluster1", 100, clusteroperationv1alpha1.RunningStatus)
+				running.Spec.Action = "scale.yml"
+				controller, ops := genControllerWithPriority(10, running)
+				closed := cluster.DeepCopy()
+				closed.Spec.MaintenanceWindow = &clusterv1alpha1.MaintenanceWindow{Schedule: "0 0 1 1 *", DurationSeconds: 60}
+				waiting, err := controller.WaitInQueue(ops, closed)
+				return err == nil && waiting && preemptedBy(controller, "ops-running") == ""
+			},
+			want: true,
+		},
+		{
+			name: "never preempt the non-preemptible action",
+			args: func() bool {
+				running := newOps("ops-running", "cluster1", 100, clusteroperationv1alpha1.RunningStatus)
+				running.Spec.Action = "upgrade-cluster.yml"
+				controller, ops := genControllerWithPriority(10, running)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && waiting && preemptedBy(controller, "ops-running") == ""
+			},
+			want: true,
+		},
+		{
+			name: "never preempt the running clusterOps with the same priority",
+			args: func() bool {
+				running := newOps("ops-running", "cluster1", 100, clusteroperationv1alpha1.RunningStatus)
+				running.Spec.Action = "scale.yml"
+				controller, ops := genController(running)
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && waiting && preemptedBy(controller, "ops-running") == ""
+			},
+			want: true,
+		},
+		{
+			name: "already started",
+			args: func() bool {
+				controller, ops := genController(newOps("ops-earlier", "cluster1", 100, clusteroperationv1alpha1.RunningStatus))
+				ops.Status.Status = clusteroperationv1alpha1.RunningStatus
+				waiting, err := controller.WaitInQueue(ops, cluster)
+				return err == nil && !waiting && ops.Status.QueuePosition == 0
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
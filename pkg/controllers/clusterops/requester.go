@@ -0,0 +1,33 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"encoding/json"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// RequesterAnnoKey is set by kubean-admission to the user who creates or updates the ClusterOperationSchedule,
+// and copied to the clusterOps created by the schedule, which are authorized on behalf of the user.
+const RequesterAnnoKey = "kubean.io/requester"
+
+// Requester returns the user recorded in the annotations, and nil if none.
+func Requester(annotations map[string]string) (*authenticationv1.UserInfo, error) {
+	value, ok := annotations[RequesterAnnoKey]
+	if !ok {
+		return nil, nil
+	}
+	userInfo := &authenticationv1.UserInfo{}
+	if err := json.Unmarshal([]byte(value), userInfo); err != nil {
+		return nil, err
+	}
+	return userInfo, nil
+}
+
+// RequesterAnnotation returns the value of the requester annotation recording the user.
+func RequesterAnnotation(userInfo authenticationv1.UserInfo) (string, error) {
+	value, err := json.Marshal(userInfo)
+	return string(value), err
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestRequester(t *testing.T) {
+	alice := authenticationv1.UserInfo{Username: "alice", Groups: []string{"ops"}, Extra: map[string]authenticationv1.ExtraValue{"scopes": {"a"}}}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "no requester",
+			args: func() bool {
+				userInfo, err := Requester(map[string]string{"a": "b"})
+				return userInfo == nil && err == nil
+			},
+			want: true,
+		},
+		{
+			name: "the recorded requester",
+			args: func() bool {
+				value, err := RequesterAnnotation(alice)
+				if err != nil {
+					return false
+				}
+				userInfo, err := Requester(map[string]string{RequesterAnnoKey: value})
+				return err == nil && reflect.DeepEqual(*userInfo, alice)
+			},
+			want: true,
+		},
+		{
+			name: "malformed requester",
+			args: func() bool {
+				_, err := Requester(map[string]string{RequesterAnnoKey: "alice"})
+				return err != nil
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
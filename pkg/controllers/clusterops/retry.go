@@ -0,0 +1,170 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// LastAttempt returns the attempt of the current job, or nil before the first job is created.
+func LastAttempt(clusterOps *clusteroperationv1alpha1.ClusterOperation) *clusteroperationv1alpha1.Attempt {
+	if len(clusterOps.Status.Attempts) == 0 {
+		return nil
+	}
+	return &clusterOps.Status.Attempts[len(clusterOps.Status.Attempts)-1]
+}
+
+// NextAttempt returns the pending attempt which the next job is created for.
+func NextAttempt(clusterOps *clusteroperationv1alpha1.ClusterOperation) *clusteroperationv1alpha1.Attempt {
+	if attempt := LastAttempt(clusterOps); attempt != nil && attempt.Status == clusteroperationv1alpha1.PendingStatus {
+		return attempt
+	}
+	clusterOps.Status.Attempts = append(clusterOps.Status.Attempts, clusteroperationv1alpha1.Attempt{
+		Index:  int32(len(clusterOps.Status.Attempts) + 1),
+		Status: clusteroperationv1alpha1.PendingStatus,
+	})
+	return LastAttempt(clusterOps)
+}
+
+// AttemptBackoff returns how long to wait before creating the job of the pending attempt.
+func AttemptBackoff(clusterOps *clusteroperationv1alpha1.ClusterOperation) time.Duration {
+	attempts := clusterOps.Status.Attempts
+	if clusterOps.Spec.RetryPolicy == nil || len(attempts) < 2 || attempts[len(attempts)-2].EndTime == nil {
+		return 0
+	}
+	backoff := time.Duration(clusterOps.Spec.RetryPolicy.BackoffSeconds) * time.Second
+	return time.Until(attempts[len(attempts)-2].EndTime.Add(backoff))
+}
+
+// SetAttemptEnv passes the resume stage and the limited hosts of the attempt to entrypoint.sh.
+func SetAttemptEnv(job *batchv1.Job, attempt *clusteroperationv1alpha1.Attempt) {
+	if attempt == nil {
+		return
+	}
+	for i := range job.Spec.Template.Spec.Containers {
+		container := &job.Spec.Template.Spec.Containers[i]
+		if container.Name != SprayJobPodName {
+			continue
+		}
+		if attempt.ResumeStage != "" {
+			container.Env = append(container.Env, corev1.EnvVar{Name: entrypoint.ResumeStageEnv, Value: attempt.ResumeStage})
+		}
+		if len(attempt.LimitHosts) > 0 {
+			container.Env = append(container.Env, corev1.EnvVar{Name: entrypoint.LimitEnv, Value: strings.Join(attempt.LimitHosts, ",")})
+		}
+	}
+}
+
+// FinishAttempt records the result of the job into the last attempt.
+func FinishAttempt(clusterOps *clusteroperationv1alpha1.ClusterOperation, status clusteroperationv1alpha1.OpsStatus, endTime *metav1.Time) {
+	attempt := LastAttempt(clusterOps)
+	if attempt == nil {
+		return
+	}
+	attempt.Status = status
+	attempt.EndTime = endTime
+	if status == clusteroperationv1alpha1.FailedStatus {
+		attempt.FailureReason = clusterOps.Status.FailureReason.DeepCopy()
+	}
+}
+
+// IsRetryable checks the retryPolicy for the failed stage.
+func IsRetryable(clusterOps *clusteroperationv1alpha1.ClusterOperation, failedStage string) bool {
+	policy := clusterOps.Spec.RetryPolicy
+	if policy == nil {
+		return false
+	}
+	attempts := int32(0)
+	for _, attempt := range clusterOps.Status.Attempts {
+		if attempt.Status != clusteroperationv1alpha1.PreemptedStatus { // preemption is not counted
+			attempts++
+		}
+	}
+	if attempts >= policy.MaxAttempts {
+		return false
+	}
+	if len(policy.RetryableStages) == 0 {
+		return true
+	}
+	for _, stage := range policy.RetryableStages {
+		if stage == failedStage {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetStagesFrom makes the stage and the ones after it pending again, all stages are reset if name is not found.
+func ResetStagesFrom(stages []clusteroperationv1alpha1.Stage, name string) {
+	start := 0
+	for i := range stages {
+		if stages[i].Name == name {
+			start = i
+			break
+		}
+	}
+	for i := start; i < len(stages); i++ {
+		stages[i].Status = clusteroperationv1alpha1.PendingStatus
+		stages[i].StartTime = nil
+		stages[i].EndTime = nil
+		stages[i].ExitCode = nil
+	}
+}
+
+func (c *Controller) fetchFailedHosts(clusterOps *clusteroperationv1alpha1.ClusterOperation) []string {
+	logStream, err := c.FetchJobPodLog(clusterOps, nil)
+	if err != nil {
+		klog.Warningf("clusterOps %s fetch job log for failed hosts but %s", clusterOps.Name, err.Error())
+		return nil
+	}
+	defer logStream.Close()
+	hosts, err := ansible.ParseFailedHosts(logStream)
+	if err != nil {
+		klog.Warningf("clusterOps %s parse failed hosts but %s", clusterOps.Name, err.Error())
+	}
+	return hosts
+}
+
+// PrepareRetry appends the next attempt after the job failed and returns whether the clusterOps will be retried.
+func (c *Controller) PrepareRetry(clusterOps *clusteroperationv1alpha1.ClusterOperation) bool {
+	failedStage := ""
+	for _, stage := range clusterOps.Status.Stages {
+		if stage.Status == clusteroperationv1alpha1.FailedStatus {
+			failedStage = stage.Name
+			break
+		}
+	}
+	if !IsRetryable(clusterOps, failedStage) {
+		return false
+	}
+	attempt := clusteroperationv1alpha1.Attempt{
+		Index:       int32(len(clusterOps.Status.Attempts) + 1),
+		Status:      clusteroperationv1alpha1.PendingStatus,
+		ResumeStage: failedStage,
+	}
+	if clusterOps.Spec.RetryPolicy.OnlyFailedHosts {
+		attempt.LimitHosts = c.fetchFailedHosts(clusterOps)
+		if lastAttempt := LastAttempt(clusterOps); len(attempt.LimitHosts) == 0 && lastAttempt != nil {
+			// no host failed in ansible, e.g. a shell stage failed, keep the limit of the last attempt.
+			attempt.LimitHosts = lastAttempt.LimitHosts
+		}
+	}
+	klog.Warningf("clusterOps %s retry attempt %d from stage %q limit %v", clusterOps.Name, attempt.Index, attempt.ResumeStage, attempt.LimitHosts)
+	clusterOps.Status.Attempts = append(clusterOps.Status.Attempts, attempt)
+	clusterOps.Status.JobRef = nil
+	clusterOps.Status.FailureReason = nil
+	ResetStagesFrom(clusterOps.Status.Stages, failedStage)
+	return true
+}
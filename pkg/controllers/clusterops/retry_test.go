@@ -0,0 +1,196 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNextAttempt(t *testing.T) {
+	ops := &clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "ops1"}}
+	if LastAttempt(ops) != nil {
+		t.Fatal("expect no attempt")
+	}
+	attempt := NextAttempt(ops)
+	if attempt.Index != 1 || attempt.Status != clusteroperationv1alpha1.PendingStatus || len(ops.Status.Attempts) != 1 {
+		t.Fatalf("unexpected attempt %v", attempt)
+	}
+	if NextAttempt(ops) != attempt {
+		t.Fatal("expect the pending attempt is reused")
+	}
+	attempt.Status = clusteroperationv1alpha1.FailedStatus
+	if attempt := NextAttempt(ops); attempt.Index != 2 || len(ops.Status.Attempts) != 2 {
+		t.Fatalf("unexpected attempt %v", attempt)
+	}
+	if name := (&Controller{}).GenerateJobName(ops); name != "kubean-ops1-job-2" {
+		t.Fatalf("unexpected job name %s", name)
+	}
+}
+
+func TestAttemptBackoff(t *testing.T) {
+	ops := &clusteroperationv1alpha1.ClusterOperation{
+		Spec: clusteroperationv1alpha1.Spec{RetryPolicy: &clusteroperationv1alpha1.RetryPolicy{MaxAttempts: 3, BackoffSeconds: 60}},
+		Status: clusteroperationv1alpha1.Status{Attempts: []clusteroperationv1alpha1.Attempt{
+			{Index: 1, Status: clusteroperationv1alpha1.FailedStatus, EndTime: &metav1.Time{Time: time.Now()}},
+			{Index: 2, Status: clusteroperationv1alpha1.PendingStatus},
+		}},
+	}
+	if backoff := AttemptBackoff(ops); backoff <= 0 || backoff > time.Minute {
+		t.Fatalf("unexpected backoff %v", backoff)
+	}
+	ops.Status.Attempts[0].EndTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	if backoff := AttemptBackoff(ops); backoff > 0 {
+		t.Fatalf("unexpected backoff %v", backoff)
+	}
+	ops.Status.Attempts = ops.Status.Attempts[:1]
+	if backoff := AttemptBackoff(ops); backoff != 0 {
+		t.Fatalf("unexpected backoff %v", backoff)
+	}
+}
+
+func TestSetAttemptEnv(t *testing.T) {
+	job := &batchv1.Job{Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: SprayJobPodName}},
+	}}}}
+	SetAttemptEnv(job, &clusteroperationv1alpha1.Attempt{Index: 1})
+	if len(job.Spec.Template.Spec.Containers[0].Env) != 0 {
+		t.Fatal("expect no env for the first attempt")
+	}
+	SetAttemptEnv(job, &clusteroperationv1alpha1.Attempt{Index: 2, ResumeStage: "action", LimitHosts: []string{"node1", "node2"}})
+	want := []corev1.EnvVar{{Name: entrypoint.ResumeStageEnv, Value: "action"}, {Name: entrypoint.LimitEnv, Value: "node1,node2"}}
+	if !reflect.DeepEqual(job.Spec.Template.Spec.Containers[0].Env, want) {
+		t.Fatalf("got %v", job.Spec.Template.Spec.Containers[0].Env)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *clusteroperationv1alpha1.RetryPolicy
+		attempts    int
+		failedStage string
+		want        bool
+	}{
+		{name: "no retryPolicy", policy: nil, attempts: 1, want: false},
+		{name: "all stages are retryable", policy: &clusteroperationv1alpha1.RetryPolicy{MaxAttempts: 2}, attempts: 1, failedStage: "prehook-0", want: true},
+		{name: "attempts exhausted", policy: &clusteroperationv1alpha1.RetryPolicy{MaxAttempts: 2}, attempts: 2, want: false},
+		{name: "stage is retryable", policy: &clusteroperationv1alpha1.RetryPolicy{MaxAttempts: 3, RetryableStages: []string{"action"}}, attempts: 1, failedStage: "action", want: true},
+		{name: "stage is not retryable", policy: &clusteroperationv1alpha1.RetryPolicy{MaxAttempts: 3, RetryableStages: []string{"action"}}, attempts: 1, failedStage: "posthook-0", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops := &clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{RetryPolicy: test.policy}}
+			ops.Status.Attempts = make([]clusteroperationv1alpha1.Attempt, test.attempts)
+			if got := IsRetryable(ops, test.failedStage); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResetStagesFrom(t *testing.T) {
+	exitCode := int32(1)
+	newStages := func() []clusteroperationv1alpha1.Stage {
+		return []clusteroperationv1alpha1.Stage{
+			{Name: "prehook-0", Status: clusteroperationv1alpha1.SucceededStatus, StartTime: &metav1.Time{}},
+			{Name: "action", Status: clusteroperationv1alpha1.FailedStatus, StartTime: &metav1.Time{}, ExitCode: &exitCode},
+			{Name: "posthook-0", Status: clusteroperationv1alpha1.PendingStatus},
+		}
+	}
+	stages := newStages()
+	ResetStagesFrom(stages, "action")
+	if stages[0].Status != clusteroperationv1alpha1.SucceededStatus || stages[1].Status != clusteroperationv1alpha1.PendingStatus ||
+		stages[1].StartTime != nil || stages[1].ExitCode != nil {
+		t.Fatalf("unexpected stages %v", stages)
+	}
+	stages = newStages()
+	ResetStagesFrom(stages, "")
+	for _, stage := range stages {
+		if stage.Status != clusteroperationv1alpha1.PendingStatus {
+			t.Fatalf("unexpected stages %v", stages)
+		}
+	}
+}
+
+func TestUpdateStatusLoopWithRetry(t *testing.T) {
+	genController := func() (*Controller, *clusteroperationv1alpha1.ClusterOperation) {
+		controller := &Controller{
+			Client:    newFakeClient(),
+			ClientSet: clientsetfake.NewSimpleClientset(),
+		}
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1", Annotations: map[string]string{JobActorPodAnnoKey: "pod1"}},
+			Spec: clusteroperationv1alpha1.Spec{
+				Cluster:     "cluster1",
+				RetryPolicy: &clusteroperationv1alpha1.RetryPolicy{MaxAttempts: 2, OnlyFailedHosts: true},
+			},
+		}
+		controller.Client.Create(context.Background(), ops)
+		ops.Status.Status = clusteroperationv1alpha1.RunningStatus
+		ops.Status.JobRef = &apis.JobRef{NameSpace: "kubean-system", Name: "kubean-ops1-job"}
+		ops.Status.Stages = []clusteroperationv1alpha1.Stage{
+			{Name: "prehook-0", Status: clusteroperationv1alpha1.SucceededStatus},
+			{Name: "action", Status: clusteroperationv1alpha1.FailedStatus},
+		}
+		ops.Status.Attempts = []clusteroperationv1alpha1.Attempt{
+			{Index: 1, Status: clusteroperationv1alpha1.RunningStatus, JobRef: ops.Status.JobRef, LimitHosts: []string{"node2"}},
+		}
+		return controller, ops
+	}
+	fetchFailed := func(*clusteroperationv1alpha1.ClusterOperation) (clusteroperationv1alpha1.OpsStatus, *metav1.Time, error) {
+		return clusteroperationv1alpha1.FailedStatus, &metav1.Time{Time: time.Now()}, nil
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "failed job is retried",
+			args: func() bool {
+				controller, ops := genController()
+				needRequeue, err := controller.UpdateStatusLoop(ops, fetchFailed)
+				attempts := ops.Status.Attempts
+				return err == nil && needRequeue && ops.Status.Status == clusteroperationv1alpha1.RunningStatus && ops.Status.JobRef.IsEmpty() &&
+					ops.Status.EndTime == nil && ops.Status.FailureReason == nil && ops.Annotations[JobActorPodAnnoKey] == "" &&
+					len(attempts) == 2 && attempts[0].Status == clusteroperationv1alpha1.FailedStatus && attempts[0].FailureReason != nil &&
+					attempts[1].Status == clusteroperationv1alpha1.PendingStatus && attempts[1].ResumeStage == "action" &&
+					reflect.DeepEqual(attempts[1].LimitHosts, []string{"node2"}) &&
+					ops.Status.Stages[0].Status == clusteroperationv1alpha1.SucceededStatus && ops.Status.Stages[1].Status == clusteroperationv1alpha1.PendingStatus
+			},
+			want: true,
+		},
+		{
+			name: "attempts exhausted",
+			args: func() bool {
+				controller, ops := genController()
+				ops.Status.Attempts = append([]clusteroperationv1alpha1.Attempt{{Index: 1, Status: clusteroperationv1alpha1.FailedStatus}}, ops.Status.Attempts...)
+				needRequeue, err := controller.UpdateStatusLoop(ops, fetchFailed)
+				return err == nil && !needRequeue && ops.Status.Status == clusteroperationv1alpha1.FailedStatus &&
+					len(ops.Status.Attempts) == 2 && ops.Status.Attempts[1].Status == clusteroperationv1alpha1.FailedStatus
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubean-io/kubean/pkg/util/sshauth"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// SSHAuthOpsLabelKey marks the secrets of the provided ssh credentials with the name of their clusterOps.
+const SSHAuthOpsLabelKey = "kubean.io/ssh-auth-of"
+
+// ProvidedSSHAuthSecretName returns the name of the secret which holds the provided ssh credentials of the job.
+func ProvidedSSHAuthSecretName(jobName string) string {
+	return fmt.Sprintf("%s-ssh-auth", jobName)
+}
+
+// mountSSHAuth mounts the whole secret in sshauth.MountPath, so that the entrypoint finds every credential by its key.
+func mountSSHAuth(job *batchv1.Job, secretName string) {
+	PrivatekeyMode := int32(0o400)
+	if len(job.Spec.Template.Spec.Containers) > 0 && job.Spec.Template.Spec.Containers[0].Name == SprayJobPodName {
+		job.Spec.Template.Spec.Containers[0].VolumeMounts = append(job.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{
+				Name:      "ssh-auth",
+				MountPath: sshauth.MountPath,
+				ReadOnly:  true,
+			})
+	}
+	job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes,
+		corev1.Volume{
+			Name: "ssh-auth",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  secretName,
+					DefaultMode: &PrivatekeyMode, // fix Permissions 0644 are too open
+				},
+			},
+		})
+}
+
+// CreateProvidedSSHAuth fetches the ssh credentials by the provider of the cluster when the job starts, and stores
+// them in the secret owned by the clusterOps in the namespace of the job. It returns the name of the secret.
+func (c *Controller) CreateProvidedSSHAuth(clusterOps *clusteroperationv1alpha1.ClusterOperation, cluster *clusterv1alpha1.Cluster, namespace, jobName string) (string, error) {
+	data, err := sshauth.Fetch(context.Background(), cluster)
+	if err != nil {
+		c.recordEvent(clusterOps, corev1.EventTypeWarning, SSHAuthFetchFailedReason, "failed to fetch ssh credentials: %s", err)
+		return "", err
+	}
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ProvidedSSHAuthSecretName(jobName),
+			Namespace: namespace,
+			Labels:    map[string]string{SSHAuthOpsLabelKey: clusterOps.Name},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	c.SetOwnerReferences(&secret.ObjectMeta, clusterOps)
+	_, err = c.ClientSet.CoreV1().Secrets(namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// left by the job creation which failed, and refreshed with the credentials just fetched.
+		_, err = c.ClientSet.CoreV1().Secrets(namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return "", err
+	}
+	return secret.Name, nil
+}
+
+// RevokeProvidedSSHAuth deletes the secrets of the provided ssh credentials once the clusterOps finishes.
+func (c *Controller) RevokeProvidedSSHAuth(clusterOps *clusteroperationv1alpha1.ClusterOperation) error {
+	if clusterOps.Status.JobRef.IsEmpty() {
+		return nil
+	}
+	namespace := clusterOps.Status.JobRef.NameSpace
+	secrets, err := c.ClientSet.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", SSHAuthOpsLabelKey, clusterOps.Name),
+	})
+	if err != nil {
+		return err
+	}
+	for _, secret := range secrets.Items {
+		klog.Warningf("revoke ssh credentials %s of clusterOps %s", secret.Name, clusterOps.Name)
+		if err := c.ClientSet.CoreV1().Secrets(namespace).Delete(context.Background(), secret.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// mockSSHAuthProvider stands for a vault which issues the same credentials to every cluster.
+type mockSSHAuthProvider map[string][]byte
+
+func (p mockSSHAuthProvider) Fetch(_ context.Context, _ *clusterv1alpha1.Cluster) (map[string][]byte, error) {
+	return p, nil
+}
+
+func TestRevokeProvidedSSHAuth(t *testing.T) {
+	controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+	for name, ops := range map[string]string{"kubean-ops1-job-ssh-auth": "ops1", "kubean-ops1-job-2-ssh-auth": "ops1", "kubean-ops2-job-ssh-auth": "ops2"} {
+		controller.ClientSet.CoreV1().Secrets("default").Create(context.Background(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{SSHAuthOpsLabelKey: ops}},
+		}, metav1.CreateOptions{})
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "clusterOps without job",
+			args: func() bool {
+				ops := &clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "ops1"}}
+				return controller.RevokeProvidedSSHAuth(ops) == nil
+			},
+			want: true,
+		},
+		{
+			name: "delete the secrets of every attempt",
+			args: func() bool {
+				ops := &clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "ops1"}}
+				ops.Status.JobRef = &apis.JobRef{NameSpace: "default", Name: "kubean-ops1-job-2"}
+				if controller.RevokeProvidedSSHAuth(ops) != nil {
+					return false
+				}
+				secrets, _ := controller.ClientSet.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
+				return len(secrets.Items) == 1 && secrets.Items[0].Name == "kubean-ops2-job-ssh-auth"
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
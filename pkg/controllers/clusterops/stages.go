@@ -0,0 +1,270 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	klog "k8s.io/klog/v2"
+)
+
+// stageAction returns a short description of the action, the first line is enough for shell scripts.
+func stageAction(action string) string {
+	action = strings.TrimSpace(action)
+	if index := strings.Index(action, "\n"); index >= 0 {
+		action = strings.TrimSpace(action[:index])
+	}
+	return action
+}
+
+// NewStages builds the pending stages in the same order as entrypoint.sh runs them.
+func (c *Controller) NewStages(clusterOps *clusteroperationv1alpha1.ClusterOperation) []clusteroperationv1alpha1.Stage {
+	stages := make([]clusteroperationv1alpha1.Stage, 0, len(clusterOps.Spec.PreHook)+len(clusterOps.Spec.PostHook)+1)
+	for i, action := range clusterOps.Spec.PreHook {
+		stages = append(stages, clusteroperationv1alpha1.Stage{
+			Name:       entrypoint.PreHookStageName(i),
+			ActionType: action.ActionType,
+			Action:     stageAction(action.Action),
+			Status:     clusteroperationv1alpha1.PendingStatus,
+		})
+	}
+	stages = append(stages, clusteroperationv1alpha1.Stage{
+		Name:       entrypoint.SprayStageName,
+		ActionType: clusterOps.Spec.ActionType,
+		Action:     stageAction(clusterOps.Spec.Action),
+		Status:     clusteroperationv1alpha1.PendingStatus,
+	})
+	for i, action := range clusterOps.Spec.PostHook {
+		stages = append(stages, clusteroperationv1alpha1.Stage{
+			Name:       entrypoint.PostHookStageName(i),
+			ActionType: action.ActionType,
+			Action:     stageAction(action.Action),
+			Status:     clusteroperationv1alpha1.PendingStatus,
+		})
+	}
+	return stages
+}
+
+// ApplyStageEvents updates stages by the markers printed in job log and returns whether anything changed.
+func ApplyStageEvents(stages []clusteroperationv1alpha1.Stage, events []entrypoint.StageEvent) bool {
+	changed := false
+	for _, event := range events {
+		for i := range stages {
+			stage := &stages[i]
+			if stage.Name != event.Name {
+				continue
+			}
+			eventTime := metav1.NewTime(event.Time)
+			switch event.Phase {
+			case entrypoint.StageStartPhase:
+				if stage.StartTime == nil {
+					stage.StartTime = &eventTime
+					changed = true
+				}
+				if stage.Status == "" || stage.Status == clusteroperationv1alpha1.PendingStatus {
+					stage.Status = clusteroperationv1alpha1.RunningStatus
+					changed = true
+				}
+			case entrypoint.StageEndPhase:
+				if stage.ExitCode != nil {
+					break // already finished
+				}
+				exitCode := event.ExitCode
+				stage.ExitCode = &exitCode
+				stage.EndTime = &eventTime
+				if stage.StartTime == nil {
+					stage.StartTime = &eventTime
+				}
+				stage.Status = clusteroperationv1alpha1.SucceededStatus
+				if exitCode != 0 {
+					stage.Status = clusteroperationv1alpha1.FailedStatus
+				}
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// FinalizeStages closes the stages which are still open after the job finished and returns whether anything changed.
+func FinalizeStages(stages []clusteroperationv1alpha1.Stage, jobStatus clusteroperationv1alpha1.OpsStatus, endTime *metav1.Time) bool {
+	changed := false
+	for i := range stages {
+		stage := &stages[i]
+		switch {
+		case stage.Status == clusteroperationv1alpha1.RunningStatus:
+			// the end marker is lost, so the stage follows the result of the job.
+			stage.Status = jobStatus
+			stage.EndTime = endTime
+			changed = true
+		case jobStatus == clusteroperationv1alpha1.SucceededStatus && stage.Status == clusteroperationv1alpha1.PendingStatus:
+			stage.Status = clusteroperationv1alpha1.SucceededStatus
+			changed = true
+		}
+	}
+	return changed
+}
+
+// errNoJobPod is returned when the job has no pod, e.g. the pod is deleted after the job is suspended.
+var errNoJobPod = errors.New("no pod")
+
+// GetLatestPodFromJob returns the newest pod created by the job whatever phase it is in.
+func (c *Controller) GetLatestPodFromJob(job *batchv1.Job) (*corev1.Pod, error) {
+	if job.Spec.Selector == nil || len(job.Spec.Selector.MatchLabels) == 0 {
+		return nil, fmt.Errorf("job %s has no Selector", job.Name)
+	}
+	selector := labels.SelectorFromSet(job.Spec.Selector.MatchLabels)
+	pods, err := c.ClientSet.CoreV1().Pods(job.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	var latestPod *corev1.Pod
+	for i := range pods.Items {
+		if latestPod == nil || latestPod.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			latestPod = &pods.Items[i]
+		}
+	}
+	if latestPod == nil {
+		return nil, fmt.Errorf("%w from job %s", errNoJobPod, job.Name)
+	}
+	return latestPod, nil
+}
+
+// FetchJobPod returns the latest pod of the job of the clusterOps.
+func (c *Controller) FetchJobPod(clusterOps *clusteroperationv1alpha1.ClusterOperation) (*corev1.Pod, error) {
+	if clusterOps.Status.JobRef.IsEmpty() {
+		return nil, fmt.Errorf("clusterOps %s no job", clusterOps.Name)
+	}
+	targetJob, err := c.ClientSet.BatchV1().Jobs(clusterOps.Status.JobRef.NameSpace).Get(context.Background(), clusterOps.Status.JobRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return c.GetLatestPodFromJob(targetJob)
+}
+
+// FetchJobPodLog opens the log stream of the spray container in the job pod.
+func (c *Controller) FetchJobPodLog(clusterOps *clusteroperationv1alpha1.ClusterOperation, sinceTime *metav1.Time) (io.ReadCloser, error) {
+	pod, err := c.FetchJobPod(clusterOps)
+	if err != nil {
+		return nil, err
+	}
+	return c.ClientSet.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: SprayJobPodName, SinceTime: sinceTime}).Stream(context.Background())
+}
+
+// stageLogCursor is the position in the log of the job pod which SyncStages has read up to, so that each poll
+// reads only the lines after it.
+type stageLogCursor struct {
+	pod  string
+	time time.Time
+	// lines counts the lines at time which have been read, since the log is fetched again from the second of time.
+	lines int
+}
+
+// readStageEvents parses the stage markers in the log of the job pod after the cursor of the clusterOps. The log is
+// read from sinceTime when there is no cursor for the pod yet, e.g. the first poll or the job of a retry attempt.
+func (c *Controller) readStageEvents(clusterOps *clusteroperationv1alpha1.ClusterOperation, sinceTime *metav1.Time) ([]entrypoint.StageEvent, error) {
+	pod, err := c.FetchJobPod(clusterOps)
+	if err != nil {
+		return nil, err
+	}
+	cursor := stageLogCursor{pod: pod.Name}
+	if value, ok := c.stageLogCursors.Load(clusterOps.UID); ok && value.(stageLogCursor).pod == pod.Name {
+		cursor = value.(stageLogCursor)
+		sinceTime = &metav1.Time{Time: cursor.time}
+	}
+	logStream, err := c.ClientSet.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:  SprayJobPodName,
+		SinceTime:  sinceTime,
+		Timestamps: true,
+	}).Stream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer logStream.Close()
+	events, cursor, err := scanStageLog(logStream, cursor)
+	if err != nil {
+		return nil, err
+	}
+	c.stageLogCursors.Store(clusterOps.UID, cursor)
+	return events, nil
+}
+
+// scanStageLog parses the stage markers in the log with timestamps, skipping the lines up to the cursor, and returns
+// the cursor moved to the last line.
+func scanStageLog(log io.Reader, cursor stageLogCursor) ([]entrypoint.StageEvent, stageLogCursor, error) {
+	markers := strings.Builder{}
+	same := 0
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		stamp, line, _ := strings.Cut(scanner.Text(), " ")
+		lineTime, err := time.Parse(time.RFC3339Nano, stamp)
+		if err != nil || lineTime.Before(cursor.time) {
+			continue
+		}
+		if lineTime.Equal(cursor.time) {
+			if same++; same <= cursor.lines {
+				continue // read by the last poll
+			}
+			cursor.lines = same
+		} else {
+			cursor.time, cursor.lines, same = lineTime, 1, 1
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), entrypoint.StageMarker) {
+			markers.WriteString(line + "\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, cursor, err
+	}
+	events, err := entrypoint.ParseStageEvents(strings.NewReader(markers.String()))
+	return events, cursor, err
+}
+
+// SyncStages refreshes Status.Stages from the job log and returns whether the status needs to be updated.
+func (c *Controller) SyncStages(clusterOps *clusteroperationv1alpha1.ClusterOperation, jobStatus clusteroperationv1alpha1.OpsStatus, endTime *metav1.Time) bool {
+	if clusterOps.Status.JobRef.IsEmpty() {
+		return false
+	}
+	changed := false
+	if len(clusterOps.Status.Stages) == 0 {
+		clusterOps.Status.Stages = c.NewStages(clusterOps)
+		changed = true
+	}
+	// only read the log from the last started stage on when there is no cursor yet.
+	var sinceTime *metav1.Time
+	for _, stage := range clusterOps.Status.Stages {
+		if stage.StartTime != nil {
+			sinceTime = stage.StartTime
+		}
+	}
+	events, err := c.readStageEvents(clusterOps, sinceTime)
+	if err != nil {
+		klog.Warningf("clusterOps %s read stage events from job log but %s", clusterOps.Name, err.Error())
+	}
+	if ApplyStageEvents(clusterOps.Status.Stages, events) {
+		changed = true
+	}
+	if jobStatus == clusteroperationv1alpha1.SucceededStatus || jobStatus == clusteroperationv1alpha1.FailedStatus || jobStatus == clusteroperationv1alpha1.CancelledStatus {
+		c.stageLogCursors.Delete(clusterOps.UID)
+		if FinalizeStages(clusterOps.Status.Stages, jobStatus, endTime) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,284 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewStages(t *testing.T) {
+	controller := Controller{}
+	ops := &clusteroperationv1alpha1.ClusterOperation{
+		Spec: clusteroperationv1alpha1.Spec{
+			ActionType: clusteroperationv1alpha1.PlaybookActionType,
+			Action:     "cluster.yml",
+			PreHook: []clusteroperationv1alpha1.HookAction{
+				{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "ping.yml"},
+				{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "\n  echo hello\n  echo world\n"},
+			},
+			PostHook: []clusteroperationv1alpha1.HookAction{
+				{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "cluster-info.yml"},
+			},
+		},
+	}
+	stages := controller.NewStages(ops)
+	wantNames := []string{"prehook-0", "prehook-1", "action", "posthook-0"}
+	wantActions := []string{"ping.yml", "echo hello", "cluster.yml", "cluster-info.yml"}
+	if len(stages) != len(wantNames) {
+		t.Fatalf("got %d stages", len(stages))
+	}
+	for i := range stages {
+		if stages[i].Name != wantNames[i] || stages[i].Action != wantActions[i] || stages[i].Status != clusteroperationv1alpha1.PendingStatus {
+			t.Fatalf("unexpected stage %v", stages[i])
+		}
+	}
+}
+
+func TestApplyStageEvents(t *testing.T) {
+	newStages := func() []clusteroperationv1alpha1.Stage {
+		return []clusteroperationv1alpha1.Stage{
+			{Name: "prehook-0", Status: clusteroperationv1alpha1.PendingStatus},
+			{Name: "action", Status: clusteroperationv1alpha1.PendingStatus},
+		}
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "no events",
+			args: func() bool {
+				stages := newStages()
+				return !ApplyStageEvents(stages, nil) && stages[0].Status == clusteroperationv1alpha1.PendingStatus
+			},
+			want: true,
+		},
+		{
+			name: "stage started",
+			args: func() bool {
+				stages := newStages()
+				changed := ApplyStageEvents(stages, []entrypoint.StageEvent{
+					{Phase: entrypoint.StageStartPhase, Name: "prehook-0", Time: time.Unix(100, 0)},
+				})
+				return changed && stages[0].Status == clusteroperationv1alpha1.RunningStatus && stages[0].StartTime.Unix() == 100 &&
+					stages[1].Status == clusteroperationv1alpha1.PendingStatus
+			},
+			want: true,
+		},
+		{
+			name: "stage succeeded and next stage failed",
+			args: func() bool {
+				stages := newStages()
+				changed := ApplyStageEvents(stages, []entrypoint.StageEvent{
+					{Phase: entrypoint.StageStartPhase, Name: "prehook-0", Time: time.Unix(100, 0)},
+					{Phase: entrypoint.StageEndPhase, Name: "prehook-0", ExitCode: 0, Time: time.Unix(110, 0)},
+					{Phase: entrypoint.StageStartPhase, Name: "action", Time: time.Unix(111, 0)},
+					{Phase: entrypoint.StageEndPhase, Name: "action", ExitCode: 2, Time: time.Unix(120, 0)},
+				})
+				return changed &&
+					stages[0].Status == clusteroperationv1alpha1.SucceededStatus && *stages[0].ExitCode == 0 && stages[0].EndTime.Unix() == 110 &&
+					stages[1].Status == clusteroperationv1alpha1.FailedStatus && *stages[1].ExitCode == 2
+			},
+			want: true,
+		},
+		{
+			name: "events applied twice",
+			args: func() bool {
+				stages := newStages()
+				events := []entrypoint.StageEvent{
+					{Phase: entrypoint.StageStartPhase, Name: "prehook-0", Time: time.Unix(100, 0)},
+					{Phase: entrypoint.StageEndPhase, Name: "prehook-0", ExitCode: 0, Time: time.Unix(110, 0)},
+				}
+				return ApplyStageEvents(stages, events) && !ApplyStageEvents(stages, events)
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestFinalizeStages(t *testing.T) {
+	endTime := &metav1.Time{Time: time.Unix(200, 0)}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "job failed with running stage",
+			args: func() bool {
+				stages := []clusteroperationv1alpha1.Stage{
+					{Name: "action", Status: clusteroperationv1alpha1.RunningStatus},
+					{Name: "posthook-0", Status: clusteroperationv1alpha1.PendingStatus},
+				}
+				return FinalizeStages(stages, clusteroperationv1alpha1.FailedStatus, endTime) &&
+					stages[0].Status == clusteroperationv1alpha1.FailedStatus && stages[0].EndTime == endTime &&
+					stages[1].Status == clusteroperationv1alpha1.PendingStatus
+			},
+			want: true,
+		},
+		{
+			name: "job succeeded without markers",
+			args: func() bool {
+				stages := []clusteroperationv1alpha1.Stage{
+					{Name: "action", Status: clusteroperationv1alpha1.PendingStatus},
+				}
+				return FinalizeStages(stages, clusteroperationv1alpha1.SucceededStatus, endTime) &&
+					stages[0].Status == clusteroperationv1alpha1.SucceededStatus
+			},
+			want: true,
+		},
+		{
+			name: "all stages finished",
+			args: func() bool {
+				stages := []clusteroperationv1alpha1.Stage{
+					{Name: "action", Status: clusteroperationv1alpha1.SucceededStatus},
+				}
+				return !FinalizeStages(stages, clusteroperationv1alpha1.SucceededStatus, endTime)
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestGetLatestPodFromJob(t *testing.T) {
+	controller := Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+		Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}}},
+	}
+	if _, err := controller.GetLatestPodFromJob(job); err == nil {
+		t.Fatal("expect error when job has no pod")
+	}
+	for i, name := range []string{"pod-old", "pod-new"} {
+		controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "kubean-system",
+				Labels:            map[string]string{"job-name": "job1"},
+				CreationTimestamp: metav1.NewTime(time.Unix(int64(100+i), 0)),
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodFailed},
+		}, metav1.CreateOptions{})
+	}
+	pod, err := controller.GetLatestPodFromJob(job)
+	if err != nil || pod.Name != "pod-new" {
+		t.Fatalf("got %v %v", pod, err)
+	}
+	if _, err := controller.GetLatestPodFromJob(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "job2"}}); err == nil {
+		t.Fatal("expect error when job has no selector")
+	}
+}
+
+func TestSyncStages(t *testing.T) {
+	newController := func() *Controller {
+		controller := &Controller{ClientSet: clientsetfake.NewSimpleClientset()}
+		controller.ClientSet.BatchV1().Jobs("kubean-system").Create(context.Background(), &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "kubean-system"},
+			Spec:       batchv1.JobSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}}},
+		}, metav1.CreateOptions{})
+		controller.ClientSet.CoreV1().Pods("kubean-system").Create(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "kubean-system", Labels: map[string]string{"job-name": "job1"}},
+		}, metav1.CreateOptions{})
+		return controller
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "no job",
+			args: func() bool {
+				controller := newController()
+				ops := &clusteroperationv1alpha1.ClusterOperation{}
+				return !controller.SyncStages(ops, clusteroperationv1alpha1.RunningStatus, nil) && len(ops.Status.Stages) == 0
+			},
+			want: true,
+		},
+		{
+			name: "init stages for running job",
+			args: func() bool {
+				controller := newController()
+				ops := &clusteroperationv1alpha1.ClusterOperation{
+					Spec:   clusteroperationv1alpha1.Spec{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "cluster.yml"},
+					Status: clusteroperationv1alpha1.Status{JobRef: &apis.JobRef{NameSpace: "kubean-system", Name: "job1"}},
+				}
+				return controller.SyncStages(ops, clusteroperationv1alpha1.RunningStatus, nil) && len(ops.Status.Stages) == 1 &&
+					ops.Status.Stages[0].Status == clusteroperationv1alpha1.PendingStatus
+			},
+			want: true,
+		},
+		{
+			name: "finalize stages when job failed",
+			args: func() bool {
+				controller := newController()
+				ops := &clusteroperationv1alpha1.ClusterOperation{
+					Status: clusteroperationv1alpha1.Status{
+						JobRef: &apis.JobRef{NameSpace: "kubean-system", Name: "job1"},
+						Stages: []clusteroperationv1alpha1.Stage{{Name: "action", Status: clusteroperationv1alpha1.RunningStatus}},
+					},
+				}
+				return controller.SyncStages(ops, clusteroperationv1alpha1.FailedStatus, &metav1.Time{}) &&
+					ops.Status.Stages[0].Status == clusteroperationv1alpha1.FailedStatus
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestScanStageLog(t *testing.T) {
+	firstPoll := "2026-10-17T10:00:00.100000000Z [kubean-stage] start prehook-0 1792231200\n" +
+		"2026-10-17T10:00:01.000000000Z TASK [ping]\n" +
+		"2026-10-17T10:00:01.000000000Z [kubean-stage] end prehook-0 0 1792231201\n"
+	events, cursor, err := scanStageLog(strings.NewReader(firstPoll), stageLogCursor{pod: "pod1"})
+	if err != nil || len(events) != 2 || cursor.lines != 2 || !cursor.time.Equal(time.Date(2026, time.October, 17, 10, 0, 1, 0, time.UTC)) {
+		t.Fatalf("got %v, %v, %v", events, cursor, err)
+	}
+	// the log is fetched again from the second of the cursor, with the lines which have been read.
+	secondPoll := "2026-10-17T10:00:00.100000000Z [kubean-stage] start prehook-0 1792231200\n" +
+		"2026-10-17T10:00:01.000000000Z TASK [ping]\n" +
+		"2026-10-17T10:00:01.000000000Z [kubean-stage] end prehook-0 0 1792231201\n" +
+		"2026-10-17T10:00:01.000000000Z [kubean-stage] start action 1792231201\n" +
+		"2026-10-17T10:00:02.000000000Z TASK [kubernetes/preinstall]\n"
+	events, cursor, err = scanStageLog(strings.NewReader(secondPoll), cursor)
+	if err != nil || len(events) != 1 || events[0].Name != "action" || events[0].Phase != entrypoint.StageStartPhase ||
+		cursor.lines != 1 || !cursor.time.Equal(time.Date(2026, time.October, 17, 10, 0, 2, 0, time.UTC)) {
+		t.Fatalf("got %v, %v, %v", events, cursor, err)
+	}
+}
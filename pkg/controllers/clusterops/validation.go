@@ -0,0 +1,249 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	"github.com/kubean-io/kubean/pkg/util/entrypoint"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const actionSourceRefRequired = "actionSourceRef must be specified if actionSource set as configmap"
+
+type actionKind int
+
+const (
+	preHookKind actionKind = iota
+	sprayKind
+	postHookKind
+)
+
+// actionPart is one of the prehooks, the action and the posthooks of the clusterOps, in the order they run.
+type actionPart struct {
+	kind            actionKind
+	path            *field.Path
+	actionType      clusteroperationv1alpha1.ActionType
+	action          string
+	extraArgs       string
+	actionSource    *clusteroperationv1alpha1.ActionSource
+	actionSourceRef *apis.ConfigMapRef
+	hook            *clusteroperationv1alpha1.HookAction
+}
+
+func (part *actionPart) isBuiltin() bool {
+	return part.actionSource == nil || *part.actionSource == clusteroperationv1alpha1.BuiltinActionSource
+}
+
+func actionParts(clusterOps *clusteroperationv1alpha1.ClusterOperation) []actionPart {
+	specPath := field.NewPath("spec")
+	hookPart := func(kind actionKind, path *field.Path, hook *clusteroperationv1alpha1.HookAction) actionPart {
+		return actionPart{
+			kind: kind, path: path, actionType: hook.ActionType, action: hook.Action, extraArgs: hook.ExtraArgs,
+			actionSource: hook.ActionSource, actionSourceRef: hook.ActionSourceRef, hook: hook,
+		}
+	}
+	parts := make([]actionPart, 0, len(clusterOps.Spec.PreHook)+len(clusterOps.Spec.PostHook)+1)
+	for i := range clusterOps.Spec.PreHook {
+		parts = append(parts, hookPart(preHookKind, specPath.Child("preHook").Index(i), &clusterOps.Spec.PreHook[i]))
+	}
+	parts = append(parts, actionPart{
+		kind: sprayKind, path: specPath, actionType: clusterOps.Spec.ActionType, action: clusterOps.Spec.Action,
+		extraArgs: clusterOps.Spec.ExtraArgs, actionSource: clusterOps.Spec.ActionSource, actionSourceRef: clusterOps.Spec.ActionSourceRef,
+	})
+	for i := range clusterOps.Spec.PostHook {
+		parts = append(parts, hookPart(postHookKind, specPath.Child("postHook").Index(i), &clusterOps.Spec.PostHook[i]))
+	}
+	return parts
+}
+
+// buildEntryPoint adds the prehooks, the action and the posthooks of the clusterOps to the entrypoint.
+// The part which fails is returned with the entrypoint.ArgsError.
+func buildEntryPoint(clusterOps *clusteroperationv1alpha1.ClusterOperation) (*entrypoint.EntryPoint, *actionPart, error) {
+	entryPointData := entrypoint.NewEntryPoint()
+	entryPointData.DryRun = clusterOps.Spec.DryRun
+	for _, part := range actionParts(clusterOps) {
+		var err error
+		switch part.kind {
+		case preHookKind:
+			err = entryPointData.PreHookRunPart(string(part.actionType), HookActionInDryRun(part.hook, clusterOps.Spec.DryRun), part.extraArgs, part.isBuiltin())
+		case sprayKind:
+			err = entryPointData.SprayRunPart(string(part.actionType), part.action, part.extraArgs, part.isBuiltin())
+		case postHookKind:
+			err = entryPointData.PostHookRunPart(string(part.actionType), HookActionInDryRun(part.hook, clusterOps.Spec.DryRun), part.extraArgs, part.isBuiltin())
+		}
+		if err != nil {
+			return nil, &part, err
+		}
+	}
+	return entryPointData, nil, nil
+}
+
+// ValidateSpec checks the image, the actions and the action sources of the clusterOps,
+// which are otherwise rejected when the job is created.
+func ValidateSpec(clusterOps *clusteroperationv1alpha1.ClusterOperation) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+	if clusterOps.Spec.Cluster == "" {
+		errs = append(errs, field.Required(specPath.Child("cluster"), ""))
+	}
+	if !IsValidImageName(clusterOps.Spec.Image) {
+		errs = append(errs, field.Invalid(specPath.Child("image"), clusterOps.Spec.Image, "wrong image format"))
+	}
+	if clusterOps.Spec.DryRun && clusterOps.Spec.ActionType != clusteroperationv1alpha1.PlaybookActionType {
+		// the dry run ops runs along with the other ops of the cluster, so its main action must run in check mode.
+		errs = append(errs, field.Forbidden(specPath.Child("actionType"), "dry run only supports the playbook action"))
+	}
+	actionTypes := entrypoint.NewActions().Types
+	for _, part := range actionParts(clusterOps) {
+		supported := false
+		for _, actionType := range actionTypes {
+			supported = supported || string(part.actionType) == actionType
+		}
+		if !supported {
+			errs = append(errs, field.NotSupported(part.path.Child("actionType"), part.actionType, actionTypes))
+		}
+		if !part.isBuiltin() && part.actionSourceRef.IsEmpty() {
+			errs = append(errs, field.Required(part.path.Child("actionSourceRef"), actionSourceRefRequired))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if _, part, err := buildEntryPoint(clusterOps); err != nil {
+		errs = append(errs, field.Invalid(part.path.Child("action"), part.action, err.Error()))
+	}
+	return errs
+}
+
+// ValidateDataRef checks the configmaps and the secrets used by the clusterOps exist, and the ones
+// taken from the cluster are in the same namespace.
+func ValidateDataRef(cluster *clusterv1alpha1.Cluster, clusterOps *clusteroperationv1alpha1.ClusterOperation, configMapExist, secretExist func(namespace, name string) bool) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+	namespaceSet := map[string]struct{}{}
+	checkConfigMap := func(path *field.Path, fieldName string, opsRef, clusterRef *apis.ConfigMapRef) {
+		if !opsRef.IsEmpty() {
+			if !configMapExist(opsRef.NameSpace, opsRef.Name) {
+				errs = append(errs, field.NotFound(path, fmt.Sprintf("%s,%s", opsRef.NameSpace, opsRef.Name)))
+			}
+			return
+		}
+		// check the ref in cluster before clusterSpec is not assigned backup data.
+		if clusterRef.IsEmpty() {
+			errs = append(errs, field.Required(path, fmt.Sprintf("kubeanCluster %s %s is empty", cluster.Name, fieldName)))
+			return
+		}
+		if !configMapExist(clusterRef.NameSpace, clusterRef.Name) {
+			ref := fmt.Sprintf("%s,%s", clusterRef.NameSpace, clusterRef.Name)
+			errs = append(errs, field.Invalid(path, ref, fmt.Sprintf("kubeanCluster %s %s %s not found", cluster.Name, fieldName, ref)))
+			return
+		}
+		namespaceSet[clusterRef.NameSpace] = struct{}{}
+	}
+	checkConfigMap(specPath.Child("hostsConfRef"), "hostsConfRef", clusterOps.Spec.HostsConfRef, cluster.Spec.HostsConfRef)
+	checkConfigMap(specPath.Child("varsConfRef"), "varsConfRef", clusterOps.Spec.VarsConfRef, cluster.Spec.VarsConfRef)
+	sshAuthPath := specPath.Child("sshAuthRef")
+	if sshAuthRef := clusterOps.Spec.SSHAuthRef; !sshAuthRef.IsEmpty() {
+		if !secretExist(sshAuthRef.NameSpace, sshAuthRef.Name) {
+			errs = append(errs, field.NotFound(sshAuthPath, fmt.Sprintf("%s,%s", sshAuthRef.NameSpace, sshAuthRef.Name)))
+		}
+	} else if sshAuthRef := cluster.Spec.SSHAuthRef; !sshAuthRef.IsEmpty() {
+		// check SSHAuthRef optionally.
+		if !secretExist(sshAuthRef.NameSpace, sshAuthRef.Name) {
+			ref := fmt.Sprintf("%s,%s", sshAuthRef.NameSpace, sshAuthRef.Name)
+			errs = append(errs, field.Invalid(sshAuthPath, ref, fmt.Sprintf("kubeanCluster %s sshAuthRef %s not found", cluster.Name, ref)))
+		} else {
+			namespaceSet[sshAuthRef.NameSpace] = struct{}{}
+		}
+	}
+	if len(namespaceSet) > 1 {
+		errs = append(errs, field.Invalid(specPath.Child("cluster"), cluster.Name, fmt.Sprintf("kubeanCluster %s hostsConfRef varsConfRef or sshAuthRef not in the same namespace", cluster.Name)))
+	}
+	if ref := clusterOps.Spec.EntrypointSHRef; !ref.IsEmpty() && !configMapExist(ref.NameSpace, ref.Name) {
+		errs = append(errs, field.NotFound(specPath.Child("entrypointSHRef"), fmt.Sprintf("%s,%s", ref.NameSpace, ref.Name)))
+	}
+	for _, part := range actionParts(clusterOps) {
+		if ref := part.actionSourceRef; !part.isBuiltin() && !ref.IsEmpty() && !configMapExist(ref.NameSpace, ref.Name) {
+			errs = append(errs, field.NotFound(part.path.Child("actionSourceRef"), fmt.Sprintf("%s,%s", ref.NameSpace, ref.Name)))
+		}
+	}
+	return errs
+}
+
+// ValidateSpecUpdate rejects the changes of the spec after the clusterOps is created, except cancel and the refs
+// which are backfilled by the operator.
+func ValidateSpecUpdate(newOps, oldOps *clusteroperationv1alpha1.ClusterOperation) field.ErrorList {
+	expected := oldOps.Spec.DeepCopy()
+	expected.Cancel = newOps.Spec.Cancel
+	if expected.HostsConfRef.IsEmpty() {
+		expected.HostsConfRef = newOps.Spec.HostsConfRef
+	}
+	if expected.VarsConfRef.IsEmpty() {
+		expected.VarsConfRef = newOps.Spec.VarsConfRef
+	}
+	if expected.SSHAuthRef.IsEmpty() {
+		expected.SSHAuthRef = newOps.Spec.SSHAuthRef
+	}
+	if expected.EntrypointSHRef.IsEmpty() {
+		expected.EntrypointSHRef = newOps.Spec.EntrypointSHRef
+	}
+	errs := field.ErrorList{}
+	expectedValue, newValue := reflect.ValueOf(*expected), reflect.ValueOf(newOps.Spec)
+	for i := 0; i < expectedValue.NumField(); i++ {
+		if equality.Semantic.DeepEqual(expectedValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		name := strings.Split(expectedValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		errs = append(errs, field.Forbidden(field.NewPath("spec", name), "the spec of clusterOps is immutable after created, create another clusterOps instead"))
+	}
+	return errs
+}
+
+// ValidateTenant checks the refs of the clusterOps are in the tenant namespace of the cluster,
+// so that the tenant can not use the configs and the secrets of others.
+func ValidateTenant(cluster *clusterv1alpha1.Cluster, clusterOps *clusteroperationv1alpha1.ClusterOperation) field.ErrorList {
+	errs := field.ErrorList{}
+	if cluster.Spec.Tenant == nil {
+		return errs
+	}
+	namespace := cluster.Spec.Tenant.Namespace
+	specPath := field.NewPath("spec")
+	checkRef := func(path *field.Path, ref *apis.DataRef) {
+		if !ref.IsEmpty() && ref.NameSpace != namespace {
+			errs = append(errs, field.Forbidden(path, fmt.Sprintf("must be in the namespace %s of the tenant of kubeanCluster %s", namespace, cluster.Name)))
+		}
+	}
+	// the refs of the cluster are backed up into the tenant namespace.
+	for _, clusterRef := range []struct {
+		fieldName string
+		ref       *apis.DataRef
+	}{
+		{fieldName: "hostsConfRef", ref: cluster.Spec.HostsConfRef},
+		{fieldName: "varsConfRef", ref: cluster.Spec.VarsConfRef},
+		{fieldName: "sshAuthRef", ref: cluster.Spec.SSHAuthRef},
+	} {
+		if !clusterRef.ref.IsEmpty() && clusterRef.ref.NameSpace != namespace {
+			errs = append(errs, field.Invalid(specPath.Child("cluster"), cluster.Name,
+				fmt.Sprintf("kubeanCluster %s %s is not in the namespace %s of the tenant", cluster.Name, clusterRef.fieldName, namespace)))
+		}
+	}
+	checkRef(specPath.Child("hostsConfRef"), clusterOps.Spec.HostsConfRef)
+	checkRef(specPath.Child("varsConfRef"), clusterOps.Spec.VarsConfRef)
+	checkRef(specPath.Child("sshAuthRef"), clusterOps.Spec.SSHAuthRef)
+	checkRef(specPath.Child("entrypointSHRef"), clusterOps.Spec.EntrypointSHRef)
+	for _, part := range actionParts(clusterOps) {
+		if !part.isBuiltin() {
+			checkRef(part.path.Child("actionSourceRef"), part.actionSourceRef)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,271 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateSpec(t *testing.T) {
+	configMapSource := clusteroperationv1alpha1.ConfigMapActionSource
+	newOps := func(mutate func(spec *clusteroperationv1alpha1.Spec)) *clusteroperationv1alpha1.ClusterOperation {
+		ops := &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops1"},
+			Spec: clusteroperationv1alpha1.Spec{
+				Cluster:    "cluster1",
+				ActionType: clusteroperationv1alpha1.PlaybookActionType,
+				Action:     "cluster.yml",
+				Image:      "ghcr.io/kubean-io/spray-job:latest",
+			},
+		}
+		mutate(&ops.Spec)
+		return ops
+	}
+	tests := []struct {
+		name string
+		ops  *clusteroperationv1alpha1.ClusterOperation
+		want []string
+	}{
+		{
+			name: "valid",
+			ops:  newOps(func(spec *clusteroperationv1alpha1.Spec) {}),
+			want: []string{},
+		},
+		{
+			name: "bad image and action type",
+			ops: newOps(func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Image = "spray-job:latest "
+				spec.PostHook = []clusteroperationv1alpha1.HookAction{{ActionType: "python", Action: "a.py"}}
+			}),
+			want: []string{"spec.image", "spec.postHook[0].actionType"},
+		},
+		{
+			name: "configmap action source without ref",
+			ops: newOps(func(spec *clusteroperationv1alpha1.Spec) {
+				spec.PreHook = []clusteroperationv1alpha1.HookAction{{ActionType: "playbook", Action: "custom.yml", ActionSource: &configMapSource}}
+			}),
+			want: []string{"spec.preHook[0].actionSourceRef"},
+		},
+		{
+			name: "unknown builtin playbook",
+			ops: newOps(func(spec *clusteroperationv1alpha1.Spec) {
+				spec.PreHook = []clusteroperationv1alpha1.HookAction{{ActionType: "playbook", Action: "ping.yml"}, {ActionType: "playbook", Action: "not-exist.yml"}}
+			}),
+			want: []string{"spec.preHook[1].action"},
+		},
+		{
+			name: "shell action in dry run",
+			ops: newOps(func(spec *clusteroperationv1alpha1.Spec) {
+				spec.ActionType, spec.Action, spec.DryRun = clusteroperationv1alpha1.ShellActionType, "sleep 10", true
+			}),
+			want: []string{"spec.actionType"},
+		},
+		{
+			name: "shell hooks in dry run",
+			ops: newOps(func(spec *clusteroperationv1alpha1.Spec) {
+				spec.DryRun = true
+				spec.PreHook = []clusteroperationv1alpha1.HookAction{{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "sleep 10"}}
+			}),
+			want: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, err := range ValidateSpec(test.ops) {
+				got = append(got, err.Field)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestValidateDataRef(t *testing.T) {
+	configMapSource := clusteroperationv1alpha1.ConfigMapActionSource
+	existing := map[string]bool{"ns1/hosts": true, "ns1/vars": true, "ns2/ssh": true, "ns1/ssh": true, "ns1/custom": true}
+	exist := func(namespace, name string) bool { return existing[namespace+"/"+name] }
+	tests := []struct {
+		name    string
+		cluster clusterv1alpha1.Spec
+		ops     clusteroperationv1alpha1.Spec
+		want    []string
+	}{
+		{
+			name: "valid",
+			cluster: clusterv1alpha1.Spec{
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "hosts"},
+				VarsConfRef:  &apis.ConfigMapRef{NameSpace: "ns1", Name: "vars"},
+				SSHAuthRef:   &apis.SecretRef{NameSpace: "ns1", Name: "ssh"},
+			},
+			ops: clusteroperationv1alpha1.Spec{
+				ActionSource: &configMapSource, ActionSourceRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "custom"},
+			},
+			want: []string{},
+		},
+		{
+			name: "not found and not in one namespace",
+			cluster: clusterv1alpha1.Spec{
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "hosts"},
+				SSHAuthRef:   &apis.SecretRef{NameSpace: "ns2", Name: "ssh"},
+			},
+			ops: clusteroperationv1alpha1.Spec{
+				PostHook: []clusteroperationv1alpha1.HookAction{
+					{ActionSource: &configMapSource, ActionSourceRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "not-exist"}},
+				},
+			},
+			want: []string{"spec.varsConfRef", "spec.cluster", "spec.postHook[0].actionSourceRef"},
+		},
+		{
+			name:    "refs of the clusterOps",
+			cluster: clusterv1alpha1.Spec{},
+			ops: clusteroperationv1alpha1.Spec{
+				HostsConfRef:    &apis.ConfigMapRef{NameSpace: "ns1", Name: "hosts"},
+				VarsConfRef:     &apis.ConfigMapRef{NameSpace: "ns1", Name: "vars-1"},
+				SSHAuthRef:      &apis.SecretRef{NameSpace: "ns1", Name: "ssh-1"},
+				EntrypointSHRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "entrypoint"},
+			},
+			want: []string{"spec.varsConfRef", "spec.sshAuthRef", "spec.entrypointSHRef"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}, Spec: test.cluster}
+			ops := &clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "ops1"}, Spec: test.ops}
+			got := make([]string, 0)
+			for _, err := range ValidateDataRef(cluster, ops, exist, exist) {
+				got = append(got, err.Field)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestValidateSpecUpdate(t *testing.T) {
+	oldOps := &clusteroperationv1alpha1.ClusterOperation{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops1"},
+		Spec: clusteroperationv1alpha1.Spec{
+			Cluster:     "cluster1",
+			ActionType:  clusteroperationv1alpha1.PlaybookActionType,
+			Action:      "cluster.yml",
+			Image:       "ghcr.io/kubean-io/spray-job:latest",
+			VarsConfRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "vars"},
+		},
+	}
+	tests := []struct {
+		name   string
+		mutate func(spec *clusteroperationv1alpha1.Spec)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			mutate: func(spec *clusteroperationv1alpha1.Spec) {},
+			want:   []string{},
+		},
+		{
+			name: "cancel and backfill",
+			mutate: func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Cancel = true
+				spec.HostsConfRef = &apis.ConfigMapRef{NameSpace: "ns1", Name: "hosts"}
+				spec.SSHAuthRef = &apis.SecretRef{NameSpace: "ns1", Name: "ssh"}
+				spec.EntrypointSHRef = &apis.ConfigMapRef{NameSpace: "ns1", Name: "entrypoint"}
+			},
+			want: []string{},
+		},
+		{
+			name: "change the user-supplied fields",
+			mutate: func(spec *clusteroperationv1alpha1.Spec) {
+				spec.Action = "reset.yml"
+				spec.VarsConfRef = &apis.ConfigMapRef{NameSpace: "ns1", Name: "vars-1"}
+				spec.PostHook = []clusteroperationv1alpha1.HookAction{{ActionType: "playbook", Action: "ping.yml"}}
+			},
+			want: []string{"spec.varsConfRef", "spec.action", "spec.postHook"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newOps := oldOps.DeepCopy()
+			test.mutate(&newOps.Spec)
+			got := make([]string, 0)
+			for _, err := range ValidateSpecUpdate(newOps, oldOps) {
+				got = append(got, err.Field)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestValidateTenant(t *testing.T) {
+	configMapSource := clusteroperationv1alpha1.ConfigMapActionSource
+	tenant := &clusterv1alpha1.Tenant{Namespace: "team-a", ServiceAccountName: "team-a-sa"}
+	tests := []struct {
+		name    string
+		cluster clusterv1alpha1.Spec
+		ops     clusteroperationv1alpha1.Spec
+		want    []string
+	}{
+		{
+			name: "cluster without tenant",
+			cluster: clusterv1alpha1.Spec{
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: "ns1", Name: "hosts"},
+			},
+			ops:  clusteroperationv1alpha1.Spec{VarsConfRef: &apis.ConfigMapRef{NameSpace: "ns2", Name: "vars"}},
+			want: []string{},
+		},
+		{
+			name: "refs in the tenant namespace",
+			cluster: clusterv1alpha1.Spec{
+				Tenant:       tenant,
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: "team-a", Name: "hosts"},
+				VarsConfRef:  &apis.ConfigMapRef{NameSpace: "team-a", Name: "vars"},
+				SSHAuthRef:   &apis.SecretRef{NameSpace: "team-a", Name: "ssh"},
+			},
+			ops: clusteroperationv1alpha1.Spec{
+				EntrypointSHRef: &apis.ConfigMapRef{NameSpace: "team-a", Name: "entrypoint"},
+				ActionSource:    &configMapSource, ActionSourceRef: &apis.ConfigMapRef{NameSpace: "team-a", Name: "custom"},
+			},
+			want: []string{},
+		},
+		{
+			name: "refs out of the tenant namespace",
+			cluster: clusterv1alpha1.Spec{
+				Tenant:       tenant,
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: "team-a", Name: "hosts"},
+				SSHAuthRef:   &apis.SecretRef{NameSpace: "team-b", Name: "ssh"},
+			},
+			ops: clusteroperationv1alpha1.Spec{
+				VarsConfRef: &apis.ConfigMapRef{NameSpace: "team-b", Name: "vars"},
+				PreHook: []clusteroperationv1alpha1.HookAction{
+					{ActionSource: &configMapSource, ActionSourceRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "custom"}},
+				},
+			},
+			want: []string{"spec.cluster", "spec.varsConfRef", "spec.preHook[0].actionSourceRef"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}, Spec: test.cluster}
+			ops := &clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "ops1"}, Spec: test.ops}
+			got := make([]string, 0)
+			for _, err := range ValidateTenant(cluster, ops) {
+				got = append(got, err.Field)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
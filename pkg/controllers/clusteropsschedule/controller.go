@@ -0,0 +1,328 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusteropsschedule
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationschedulev1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationschedule/v1alpha1"
+	clusterOperationClientSet "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned"
+	clusterOperationScheduleClientSet "github.com/kubean-io/kubean-api/generated/clusteroperationschedule/clientset/versioned"
+	"github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/util/cron"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	klog "k8s.io/klog/v2"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	RequeueAfter = time.Second * 15
+	// ScheduleLabelKey is set on the created ClusterOperation with the name of the schedule.
+	ScheduleLabelKey = "kubean.io/schedule"
+	// ScheduledTimeAnnoKey is set on the created ClusterOperation with the scheduled time in RFC3339.
+	ScheduledTimeAnnoKey = "kubean.io/scheduled-time"
+	// MaxMissedSchedules bounds the missed schedules to look through, e.g. after the operator was down for long.
+	MaxMissedSchedules     = 100
+	DefaultSuccessfulLimit = int32(3)
+	DefaultFailedLimit     = int32(1)
+)
+
+type Controller struct {
+	KubeanClusterOpsSet         clusterOperationClientSet.Interface
+	KubeanClusterOpsScheduleSet clusterOperationScheduleClientSet.Interface
+}
+
+func (c *Controller) Start(ctx context.Context) error {
+	klog.Warningf("ClusterOperationSchedule Controller Start")
+	<-ctx.Done()
+	return nil
+}
+
+// ParseSchedule parses the cron schedule in the time zone of the ClusterOperationSchedule.
+func ParseSchedule(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule) (*cron.Schedule, *time.Location, error) {
+	location := time.UTC
+	if schedule.Spec.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(schedule.Spec.TimeZone); err != nil {
+			return nil, nil, fmt.Errorf("invalid timeZone %q: %w", schedule.Spec.TimeZone, err)
+		}
+	}
+	cronSchedule, err := cron.Parse(schedule.Spec.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cronSchedule, location, nil
+}
+
+// MostRecentScheduleTime returns the latest missed schedule time since the last schedule, or the zero time if none is missed.
+func MostRecentScheduleTime(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, cronSchedule *cron.Schedule, now time.Time) (time.Time, error) {
+	earliest := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliest = schedule.Status.LastScheduleTime.Time
+	}
+	if schedule.Spec.StartingDeadlineSeconds != nil {
+		// the schedules before the deadline are skipped anyway.
+		if deadline := now.Add(-time.Duration(*schedule.Spec.StartingDeadlineSeconds) * time.Second); deadline.After(earliest) {
+			earliest = deadline
+		}
+	}
+	mostRecent := time.Time{}
+	missed := 0
+	for t := cronSchedule.Next(earliest.In(now.Location())); !t.IsZero() && !t.After(now); t = cronSchedule.Next(t) {
+		mostRecent = t
+		if missed++; missed > MaxMissedSchedules {
+			return time.Time{}, fmt.Errorf("too many missed schedules (> %d) since %s", MaxMissedSchedules, earliest.Format(time.RFC3339))
+		}
+	}
+	return mostRecent, nil
+}
+
+// ListClusterOps returns the ClusterOperations created by the schedule in creation order.
+func (c *Controller) ListClusterOps(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule) ([]clusteroperationv1alpha1.ClusterOperation, error) {
+	opsList, err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().List(context.Background(),
+		metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", ScheduleLabelKey, schedule.Name)})
+	if err != nil {
+		return nil, err
+	}
+	items := opsList.Items
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreationTimestamp.Equal(&items[j].CreationTimestamp) {
+			return items[i].CreationTimestamp.Before(&items[j].CreationTimestamp)
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+// SyncStatus records the active and the last ClusterOperations into the status of the schedule.
+func SyncStatus(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, opsList []clusteroperationv1alpha1.ClusterOperation) {
+	schedule.Status.Active = nil
+	for i := range opsList {
+		ops := &opsList[i]
+		if !clusterops.IsFinished(ops) {
+			schedule.Status.Active = append(schedule.Status.Active, ops.Name)
+		}
+		if ops.Status.Status == clusteroperationv1alpha1.SucceededStatus && ops.Status.EndTime != nil &&
+			(schedule.Status.LastSuccessfulTime == nil || schedule.Status.LastSuccessfulTime.Before(ops.Status.EndTime)) {
+			schedule.Status.LastSuccessfulTime = ops.Status.EndTime.DeepCopy()
+		}
+		if ops.Name == schedule.Status.LastOperation {
+			schedule.Status.LastOperationStatus = ops.Status.Status
+		}
+	}
+}
+
+func historyLimit(limit *int32, defaultLimit int32) int {
+	if limit == nil {
+		return int(defaultLimit)
+	}
+	return int(*limit)
+}
+
+// CleanHistory deletes the oldest finished ClusterOperations beyond the history limits.
+func (c *Controller) CleanHistory(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, opsList []clusteroperationv1alpha1.ClusterOperation) error {
+	succeeded, failed := make([]string, 0), make([]string, 0)
+	for i := range opsList {
+		switch opsList[i].Status.Status {
+		case clusteroperationv1alpha1.SucceededStatus:
+			succeeded = append(succeeded, opsList[i].Name)
+		case clusteroperationv1alpha1.FailedStatus, clusteroperationv1alpha1.CancelledStatus:
+			failed = append(failed, opsList[i].Name)
+		}
+	}
+	excess := make([]string, 0)
+	if limit := historyLimit(schedule.Spec.SuccessfulHistoryLimit, DefaultSuccessfulLimit); len(succeeded) > limit {
+		excess = append(excess, succeeded[:len(succeeded)-limit]...)
+	}
+	if limit := historyLimit(schedule.Spec.FailedHistoryLimit, DefaultFailedLimit); len(failed) > limit {
+		excess = append(excess, failed[:len(failed)-limit]...)
+	}
+	for _, name := range excess {
+		klog.Warningf("clusterOperationSchedule %s deletes history clusterOps %s", schedule.Name, name)
+		if err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateClusterOpsName is stable for the scheduled time, so the same schedule never creates twice.
+func GenerateClusterOpsName(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, scheduledTime time.Time) string {
+	return fmt.Sprintf("%s-%d", schedule.Name, scheduledTime.Unix()/60)
+}
+
+// NewClusterOps instantiates the template of the schedule, and the ClusterOperation is deleted with the schedule.
+func NewClusterOps(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, scheduledTime time.Time) *clusteroperationv1alpha1.ClusterOperation {
+	template := schedule.Spec.Template.DeepCopy()
+	ops := &clusteroperationv1alpha1.ClusterOperation{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: clusteroperationv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ClusterOperation",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            GenerateClusterOpsName(schedule, scheduledTime),
+			Labels:          template.Metadata.Labels,
+			Annotations:     template.Metadata.Annotations,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(schedule, clusteroperationschedulev1alpha1.SchemeGroupVersion.WithKind("ClusterOperationSchedule"))},
+		},
+		Spec: template.Spec,
+	}
+	if ops.Labels == nil {
+		ops.Labels = map[string]string{}
+	}
+	ops.Labels[ScheduleLabelKey] = schedule.Name
+	if ops.Annotations == nil {
+		ops.Annotations = map[string]string{}
+	}
+	ops.Annotations[ScheduledTimeAnnoKey] = scheduledTime.Format(time.RFC3339)
+	// the clusterOps is authorized on behalf of the requester of the schedule, which the template can not forge.
+	delete(ops.Annotations, clusterops.RequesterAnnoKey)
+	if requester, ok := schedule.Annotations[clusterops.RequesterAnnoKey]; ok {
+		ops.Annotations[clusterops.RequesterAnnoKey] = requester
+	}
+	return ops
+}
+
+// RunSchedule creates the ClusterOperation for the scheduled time by the concurrencyPolicy, and returns whether the schedule has been consumed.
+func (c *Controller) RunSchedule(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, opsList []clusteroperationv1alpha1.ClusterOperation, scheduledTime time.Time) (bool, error) {
+	if len(schedule.Status.Active) > 0 {
+		switch schedule.Spec.ConcurrencyPolicy {
+		case clusteroperationschedulev1alpha1.AllowConcurrent:
+		case clusteroperationschedulev1alpha1.ReplaceConcurrent:
+			replaced := make([]string, 0)
+			for i := range opsList {
+				ops := &opsList[i]
+				if clusterops.IsFinished(ops) {
+					continue
+				}
+				replaced = append(replaced, ops.Name)
+				if ops.Spec.Cancel {
+					continue
+				}
+				klog.Warningf("clusterOperationSchedule %s cancels clusterOps %s to replace it", schedule.Name, ops.Name)
+				ops.Spec.Cancel = true
+				if _, err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Update(context.Background(), ops, metav1.UpdateOptions{}); err != nil {
+					return false, err
+				}
+			}
+			if len(replaced) > 0 {
+				// the new one would queue behind the cancelled ones, so it is created once they have finished.
+				klog.Warningf("clusterOperationSchedule %s waits for the cancelled clusterOps %v to finish", schedule.Name, replaced)
+				return false, nil
+			}
+		default:
+			klog.Warningf("clusterOperationSchedule %s skips the schedule at %s because %v have not finished", schedule.Name, scheduledTime.Format(time.RFC3339), schedule.Status.Active)
+			return false, nil
+		}
+	}
+	ops := NewClusterOps(schedule, scheduledTime)
+	klog.Warningf("clusterOperationSchedule %s creates clusterOps %s", schedule.Name, ops.Name)
+	if _, err := c.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), ops, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return false, err
+	}
+	schedule.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+	schedule.Status.LastOperation = ops.Name
+	schedule.Status.LastOperationStatus = ""
+	schedule.Status.Active = append(schedule.Status.Active, ops.Name)
+	return true, nil
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req controllerruntime.Request) (controllerruntime.Result, error) {
+	schedule, err := c.KubeanClusterOpsScheduleSet.KubeanV1alpha1().ClusterOperationSchedules().Get(ctx, req.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return controllerruntime.Result{}, nil
+		}
+		klog.ErrorS(err, "failed to get clusterOperationSchedule", "clusterOperationSchedule", req.Name)
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
+	opsList, err := c.ListClusterOps(schedule)
+	if err != nil {
+		klog.ErrorS(err, "failed to list clusterOps", "clusterOperationSchedule", schedule.Name)
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
+	if err := c.CleanHistory(schedule, opsList); err != nil {
+		klog.ErrorS(err, "failed to clean history clusterOps", "clusterOperationSchedule", schedule.Name)
+		return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+	}
+	oldStatus := schedule.Status.DeepCopy()
+	SyncStatus(schedule, opsList)
+
+	result := controllerruntime.Result{}
+	cronSchedule, location, err := ParseSchedule(schedule)
+	if err != nil {
+		// wait for the spec to be fixed.
+		klog.ErrorS(err, "invalid schedule", "clusterOperationSchedule", schedule.Name)
+	} else if !schedule.Spec.Suspend {
+		now := time.Now().In(location)
+		scheduledTime, err := MostRecentScheduleTime(schedule, cronSchedule, now)
+		if err != nil {
+			klog.ErrorS(err, "failed to find the missed schedule", "clusterOperationSchedule", schedule.Name)
+			scheduledTime = time.Time{}
+			schedule.Status.LastScheduleTime = &metav1.Time{Time: now} // start over from now
+		}
+		result.RequeueAfter = RequeueAfter
+		if !scheduledTime.IsZero() {
+			if _, err := c.RunSchedule(schedule, opsList, scheduledTime); err != nil {
+				klog.ErrorS(err, "failed to run the schedule", "clusterOperationSchedule", schedule.Name)
+				return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+			}
+		}
+		if next := cronSchedule.Next(now); !next.IsZero() && next.Sub(now) < result.RequeueAfter {
+			result.RequeueAfter = next.Sub(now)
+		}
+	}
+	if !equalStatus(oldStatus, &schedule.Status) {
+		if _, err := c.KubeanClusterOpsScheduleSet.KubeanV1alpha1().ClusterOperationSchedules().UpdateStatus(ctx, schedule, metav1.UpdateOptions{}); err != nil {
+			klog.ErrorS(err, "failed to update clusterOperationSchedule status", "clusterOperationSchedule", schedule.Name)
+			return controllerruntime.Result{RequeueAfter: RequeueAfter}, nil
+		}
+	}
+	return result, nil
+}
+
+func equalStatus(a, b *clusteroperationschedulev1alpha1.Status) bool {
+	unixMilli := func(t *metav1.Time) int64 {
+		if t == nil {
+			return -1
+		}
+		return t.UnixMilli()
+	}
+	if len(a.Active) != len(b.Active) {
+		return false
+	}
+	for i := range a.Active {
+		if a.Active[i] != b.Active[i] {
+			return false
+		}
+	}
+	return unixMilli(a.LastScheduleTime) == unixMilli(b.LastScheduleTime) && unixMilli(a.LastSuccessfulTime) == unixMilli(b.LastSuccessfulTime) &&
+		a.LastOperation == b.LastOperation && a.LastOperationStatus == b.LastOperationStatus
+}
+
+func (c *Controller) SetupWithManager(mgr controllerruntime.Manager) error {
+	return utilerrors.NewAggregate([]error{
+		controllerruntime.NewControllerManagedBy(mgr).For(&clusteroperationschedulev1alpha1.ClusterOperationSchedule{}).
+			Watches(&source.Kind{Type: &clusteroperationv1alpha1.ClusterOperation{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+				// the ClusterOperations created before the ownerReference was set are found by label.
+				if name := obj.GetLabels()[ScheduleLabelKey]; name != "" {
+					return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+				}
+				return nil
+			})).Complete(c),
+		mgr.Add(c),
+	})
+}
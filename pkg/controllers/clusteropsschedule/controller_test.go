@@ -0,0 +1,273 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusteropsschedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationschedulev1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationschedule/v1alpha1"
+	clusteroperationv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned/fake"
+	clusteroperationschedulev1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperationschedule/clientset/versioned/fake"
+	"github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/util/cron"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+)
+
+func newSchedule(name, spec string, created time.Time) *clusteroperationschedulev1alpha1.ClusterOperationSchedule {
+	return &clusteroperationschedulev1alpha1.ClusterOperationSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Spec: clusteroperationschedulev1alpha1.Spec{
+			Schedule:          spec,
+			ConcurrencyPolicy: clusteroperationschedulev1alpha1.ForbidConcurrent,
+			Template: clusteroperationschedulev1alpha1.ClusterOperationTemplate{
+				Metadata: clusteroperationschedulev1alpha1.TemplateMeta{Labels: map[string]string{"team": "infra"}},
+				Spec: clusteroperationv1alpha1.Spec{
+					Cluster:    "cluster1",
+					ActionType: clusteroperationv1alpha1.PlaybookActionType,
+					Action:     "renew-certs.yml",
+					Image:      "ghcr.io/kubean-io/spray-job:latest",
+				},
+			},
+		},
+	}
+}
+
+func TestMostRecentScheduleTime(t *testing.T) {
+	now := time.Date(2023, 6, 7, 10, 30, 0, 0, time.UTC)
+	cronSchedule, _ := cron.Parse("0 * * * *")
+	tests := []struct {
+		name    string
+		args    func() *clusteroperationschedulev1alpha1.ClusterOperationSchedule
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "nothing missed since creation",
+			args: func() *clusteroperationschedulev1alpha1.ClusterOperationSchedule {
+				return newSchedule("s1", "0 * * * *", now.Add(-time.Minute*10))
+			},
+			want: time.Time{},
+		},
+		{
+			name: "the latest missed schedule since the last schedule",
+			args: func() *clusteroperationschedulev1alpha1.ClusterOperationSchedule {
+				schedule := newSchedule("s1", "0 * * * *", now.Add(-time.Hour*24))
+				schedule.Status.LastScheduleTime = &metav1.Time{Time: time.Date(2023, 6, 7, 7, 0, 0, 0, time.UTC)}
+				return schedule
+			},
+			want: time.Date(2023, 6, 7, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "missed schedule beyond the starting deadline",
+			args: func() *clusteroperationschedulev1alpha1.ClusterOperationSchedule {
+				schedule := newSchedule("s1", "0 * * * *", now.Add(-time.Hour*24))
+				schedule.Status.LastScheduleTime = &metav1.Time{Time: time.Date(2023, 6, 7, 7, 0, 0, 0, time.UTC)}
+				deadline := int64(600)
+				schedule.Spec.StartingDeadlineSeconds = &deadline
+				return schedule
+			},
+			want: time.Time{},
+		},
+		{
+			name: "too many missed schedules",
+			args: func() *clusteroperationschedulev1alpha1.ClusterOperationSchedule {
+				return newSchedule("s1", "0 * * * *", now.Add(-time.Hour*24*30))
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := MostRecentScheduleTime(test.args(), cronSchedule, now)
+			if (err != nil) != test.wantErr || !got.Equal(test.want) {
+				t.Fatalf("got %v %v, want %v wantErr %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClusterOps(t *testing.T) {
+	scheduledTime := time.Date(2023, 6, 7, 10, 0, 0, 0, time.UTC)
+	schedule := newSchedule("renew-certs", "0 * * * *", scheduledTime)
+	ops := NewClusterOps(schedule, scheduledTime)
+	if ops.Name != "renew-certs-28102200" || ops.Labels[ScheduleLabelKey] != "renew-certs" || ops.Labels["team"] != "infra" ||
+		ops.Annotations[ScheduledTimeAnnoKey] != "2023-06-07T10:00:00Z" || ops.Spec.Action != "renew-certs.yml" {
+		t.Fatalf("unexpected clusterOps %v", ops)
+	}
+	if owner := metav1.GetControllerOf(ops); owner == nil || owner.Kind != "ClusterOperationSchedule" || owner.Name != "renew-certs" {
+		t.Fatalf("unexpected owner %v", owner)
+	}
+	if _, ok := schedule.Spec.Template.Metadata.Labels[ScheduleLabelKey]; ok {
+		t.Fatal("expect the template is not changed")
+	}
+	if _, ok := ops.Annotations[clusterops.RequesterAnnoKey]; ok {
+		t.Fatal("expect no requester without the one of the schedule")
+	}
+	schedule.Spec.Template.Metadata.Annotations = map[string]string{clusterops.RequesterAnnoKey: `{"username":"admin"}`}
+	schedule.Annotations = map[string]string{clusterops.RequesterAnnoKey: `{"username":"alice"}`}
+	if ops := NewClusterOps(schedule, scheduledTime); ops.Annotations[clusterops.RequesterAnnoKey] != `{"username":"alice"}` {
+		t.Fatalf("expect the requester of the schedule, but got %v", ops.Annotations)
+	}
+	schedule.Annotations = nil
+	if ops := NewClusterOps(schedule, scheduledTime); ops.Annotations[clusterops.RequesterAnnoKey] != "" {
+		t.Fatalf("expect the requester of the template is dropped, but got %v", ops.Annotations)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	genController := func(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, opsList ...*clusteroperationv1alpha1.ClusterOperation) *Controller {
+		controller := &Controller{
+			KubeanClusterOpsSet:         clusteroperationv1alpha1fake.NewSimpleClientset(),
+			KubeanClusterOpsScheduleSet: clusteroperationschedulev1alpha1fake.NewSimpleClientset(),
+		}
+		controller.KubeanClusterOpsScheduleSet.KubeanV1alpha1().ClusterOperationSchedules().Create(context.Background(), schedule, metav1.CreateOptions{})
+		for _, ops := range opsList {
+			controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), ops, metav1.CreateOptions{})
+		}
+		return controller
+	}
+	newOps := func(name string, created time.Time, status clusteroperationv1alpha1.OpsStatus) *clusteroperationv1alpha1.ClusterOperation {
+		return &clusteroperationv1alpha1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created), Labels: map[string]string{ScheduleLabelKey: "s1"}},
+			Spec:       clusteroperationv1alpha1.Spec{Cluster: "cluster1"},
+			Status:     clusteroperationv1alpha1.Status{Status: status, EndTime: &metav1.Time{Time: created.Add(time.Minute)}},
+		}
+	}
+	reconcile := func(controller *Controller) (controllerruntime.Result, *clusteroperationschedulev1alpha1.ClusterOperationSchedule, []clusteroperationv1alpha1.ClusterOperation) {
+		result, _ := controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "s1"}})
+		schedule, _ := controller.KubeanClusterOpsScheduleSet.KubeanV1alpha1().ClusterOperationSchedules().Get(context.Background(), "s1", metav1.GetOptions{})
+		opsList, _ := controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().List(context.Background(), metav1.ListOptions{})
+		return result, schedule, opsList.Items
+	}
+	// every minute, so that a schedule is always missed since two minutes ago.
+	created := time.Now().Add(-time.Minute * 2)
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "schedule not found",
+			args: func() bool {
+				controller := genController(newSchedule("other", "* * * * *", created))
+				result, err := controller.Reconcile(context.Background(), controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "s1"}})
+				return err == nil && result.RequeueAfter == 0
+			},
+			want: true,
+		},
+		{
+			name: "create the clusterOps for the missed schedule",
+			args: func() bool {
+				controller := genController(newSchedule("s1", "* * * * *", created))
+				result, schedule, opsList := reconcile(controller)
+				return result.RequeueAfter > 0 && result.RequeueAfter <= time.Minute && len(opsList) == 1 &&
+					schedule.Status.LastOperation == opsList[0].Name && len(schedule.Status.Active) == 1 && schedule.Status.LastScheduleTime != nil
+			},
+			want: true,
+		},
+		{
+			name: "suspended",
+			args: func() bool {
+				schedule := newSchedule("s1", "* * * * *", created)
+				schedule.Spec.Suspend = true
+				result, _, opsList := reconcile(genController(schedule))
+				return result.RequeueAfter == 0 && len(opsList) == 0
+			},
+			want: true,
+		},
+		{
+			name: "invalid schedule",
+			args: func() bool {
+				result, _, opsList := reconcile(genController(newSchedule("s1", "* * *", created)))
+				return result.RequeueAfter == 0 && len(opsList) == 0
+			},
+			want: true,
+		},
+		{
+			name: "forbid concurrent",
+			args: func() bool {
+				controller := genController(newSchedule("s1", "* * * * *", created), newOps("s1-running", created, clusteroperationv1alpha1.RunningStatus))
+				_, schedule, opsList := reconcile(controller)
+				return len(opsList) == 1 && len(schedule.Status.Active) == 1 && schedule.Status.LastScheduleTime == nil
+			},
+			want: true,
+		},
+		{
+			name: "allow concurrent",
+			args: func() bool {
+				schedule := newSchedule("s1", "* * * * *", created)
+				schedule.Spec.ConcurrencyPolicy = clusteroperationschedulev1alpha1.AllowConcurrent
+				_, schedule, opsList := reconcile(genController(schedule, newOps("s1-running", created, clusteroperationv1alpha1.RunningStatus)))
+				return len(opsList) == 2 && len(schedule.Status.Active) == 2
+			},
+			want: true,
+		},
+		{
+			name: "replace concurrent",
+			args: func() bool {
+				schedule := newSchedule("s1", "* * * * *", created)
+				schedule.Spec.ConcurrencyPolicy = clusteroperationschedulev1alpha1.ReplaceConcurrent
+				_, schedule, opsList := reconcile(genController(schedule, newOps("s1-running", created, clusteroperationv1alpha1.RunningStatus)))
+				return len(opsList) == 1 && opsList[0].Spec.Cancel && schedule.Status.LastScheduleTime == nil
+			},
+			want: true,
+		},
+		{
+			name: "replace after the cancelled clusterOps finished",
+			args: func() bool {
+				schedule := newSchedule("s1", "* * * * *", created)
+				schedule.Spec.ConcurrencyPolicy = clusteroperationschedulev1alpha1.ReplaceConcurrent
+				controller := genController(schedule, newOps("s1-running", created, clusteroperationv1alpha1.RunningStatus))
+				reconcile(controller)
+				if _, _, opsList := reconcile(controller); len(opsList) != 1 {
+					return false // wait while the cancelled one is still running.
+				}
+				cancelled, _ := controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Get(context.Background(), "s1-running", metav1.GetOptions{})
+				cancelled.Status.Status = clusteroperationv1alpha1.CancelledStatus
+				controller.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().Update(context.Background(), cancelled, metav1.UpdateOptions{})
+				_, schedule, opsList := reconcile(controller)
+				return len(opsList) == 2 && schedule.Status.LastScheduleTime != nil && len(schedule.Status.Active) == 1
+			},
+			want: true,
+		},
+		{
+			name: "history limits and last status",
+			args: func() bool {
+				schedule := newSchedule("s1", "* * * * *", created)
+				schedule.Spec.Suspend = true
+				successfulLimit := int32(1)
+				schedule.Spec.SuccessfulHistoryLimit = &successfulLimit
+				schedule.Status.LastOperation = "s1-failed-2"
+				base := created.Add(-time.Hour)
+				_, schedule, opsList := reconcile(genController(schedule,
+					newOps("s1-succeeded-1", base, clusteroperationv1alpha1.SucceededStatus),
+					newOps("s1-succeeded-2", base.Add(time.Minute*10), clusteroperationv1alpha1.SucceededStatus),
+					newOps("s1-failed-1", base.Add(time.Minute*20), clusteroperationv1alpha1.FailedStatus),
+					newOps("s1-failed-2", base.Add(time.Minute*30), clusteroperationv1alpha1.CancelledStatus),
+				))
+				names := map[string]bool{}
+				for _, ops := range opsList {
+					names[ops.Name] = true
+				}
+				return len(opsList) == 2 && names["s1-succeeded-2"] && names["s1-failed-2"] &&
+					schedule.Status.LastOperationStatus == clusteroperationv1alpha1.CancelledStatus &&
+					schedule.Status.LastSuccessfulTime != nil && schedule.Status.LastSuccessfulTime.Time.Equal(base.Add(time.Minute*11))
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
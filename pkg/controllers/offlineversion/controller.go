@@ -14,6 +14,7 @@ import (
 	localartifactsetClientSet "github.com/kubean-io/kubean-api/generated/localartifactset/clientset/versioned"
 	manifestClientSet "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned"
 	"github.com/kubean-io/kubean/pkg/controllers/infomanifest"
+	"github.com/kubean-io/kubean/pkg/metrics"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -53,6 +54,7 @@ func (c *Controller) MergeManifestsStatus(localartifactset *localartifactsetv1al
 				updated = true
 			}
 		}
+		metrics.SetOfflineAvailableVersions(manifest)
 		if !updated {
 			continue
 		}
@@ -0,0 +1,125 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	manifestv1alpha1 "github.com/kubean-io/kubean-api/apis/manifest/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The metrics are registered on the registry of controller-runtime, which is served by the manager of kubean-operator.
+
+const namespace = "kubean"
+
+// The admission decisions of AdmissionReviewHandler.
+const (
+	AllowedDecision = "allowed"
+	QueuedDecision  = "queued"
+	DeniedDecision  = "denied"
+	InvalidDecision = "invalid"
+	ErrorDecision   = "error"
+)
+
+var (
+	ClusterOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cluster_operations_total",
+		Help:      "Number of ClusterOperations which reached a terminal status, by action and status.",
+	}, []string{"action", "status"})
+
+	ClusterOperationDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cluster_operation_duration_seconds",
+		Help:      "Duration of the finished ClusterOperations from status.startTime to status.endTime, by action.",
+		Buckets:   []float64{60, 300, 600, 1200, 1800, 3600, 7200, 14400, 28800},
+	}, []string{"action"})
+
+	ClusterOperations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cluster_operations",
+		Help:      "Number of the running and queued ClusterOperations of each cluster.",
+	}, []string{"cluster", "status"})
+
+	AdmissionReviewsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "admission_reviews_total",
+		Help:      "Number of ClusterOperation admission reviews, by decision.",
+	}, []string{"decision"})
+
+	OfflineAvailableVersions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "offline_available_versions",
+		Help:      "Number of the versions available in the offline artifacts of each manifest, by kind and name of the software.",
+	}, []string{"manifest", "kind", "name"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		ClusterOperationsTotal,
+		ClusterOperationDurationSeconds,
+		ClusterOperations,
+		AdmissionReviewsTotal,
+		OfflineAvailableVersions,
+	)
+}
+
+// ActionLabel keeps the label bounded, the shell action is a script rather than a name.
+func ActionLabel(clusterOps *clusteroperationv1alpha1.ClusterOperation) string {
+	if clusterOps.Spec.ActionType == clusteroperationv1alpha1.ShellActionType {
+		return string(clusteroperationv1alpha1.ShellActionType)
+	}
+	return clusterOps.Spec.Action
+}
+
+// ObserveFinishedClusterOperation counts the clusterOps which has just reached a terminal status.
+func ObserveFinishedClusterOperation(clusterOps *clusteroperationv1alpha1.ClusterOperation) {
+	action := ActionLabel(clusterOps)
+	ClusterOperationsTotal.WithLabelValues(action, string(clusterOps.Status.Status)).Inc()
+	if clusterOps.Status.StartTime == nil || clusterOps.Status.EndTime == nil {
+		return // never started
+	}
+	duration := clusterOps.Status.EndTime.Sub(clusterOps.Status.StartTime.Time)
+	if duration < 0 {
+		duration = 0
+	}
+	ClusterOperationDurationSeconds.WithLabelValues(action).Observe(duration.Seconds())
+}
+
+// SetClusterOperations sets the numbers of running and queued clusterOps of the cluster.
+func SetClusterOperations(cluster string, opsList []clusteroperationv1alpha1.ClusterOperation) {
+	running, queued := 0, 0
+	for i := range opsList {
+		switch opsList[i].Status.Status {
+		case clusteroperationv1alpha1.RunningStatus:
+			running++
+		case "", clusteroperationv1alpha1.PendingStatus, clusteroperationv1alpha1.PendingApprovalStatus:
+			queued++
+		}
+	}
+	ClusterOperations.WithLabelValues(cluster, string(clusteroperationv1alpha1.RunningStatus)).Set(float64(running))
+	ClusterOperations.WithLabelValues(cluster, string(clusteroperationv1alpha1.PendingStatus)).Set(float64(queued))
+}
+
+// DeleteClusterOperations removes the gauges of the deleted cluster.
+func DeleteClusterOperations(cluster string) {
+	ClusterOperations.DeletePartialMatch(prometheus.Labels{"cluster": cluster})
+}
+
+// SetOfflineAvailableVersions sets the numbers of the versions in status.localAvailable of the manifest.
+func SetOfflineAvailableVersions(manifest *manifestv1alpha1.Manifest) {
+	OfflineAvailableVersions.DeletePartialMatch(prometheus.Labels{"manifest": manifest.Name})
+	for _, component := range manifest.Status.LocalAvailable.Components {
+		if component != nil {
+			OfflineAvailableVersions.WithLabelValues(manifest.Name, "component", component.Name).Set(float64(len(component.VersionRange)))
+		}
+	}
+	for _, docker := range manifest.Status.LocalAvailable.Docker {
+		if docker != nil {
+			OfflineAvailableVersions.WithLabelValues(manifest.Name, "docker", docker.OS).Set(float64(len(docker.VersionRange)))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	manifestv1alpha1 "github.com/kubean-io/kubean-api/apis/manifest/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestActionLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  clusteroperationv1alpha1.ClusterOperation
+		want string
+	}{
+		{
+			name: "playbook",
+			ops:  clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "cluster.yml"}},
+			want: "cluster.yml",
+		},
+		{
+			name: "shell",
+			ops:  clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{ActionType: clusteroperationv1alpha1.ShellActionType, Action: "echo hello"}},
+			want: "shell",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ActionLabel(&test.ops); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestObserveFinishedClusterOperation(t *testing.T) {
+	start := time.Date(2023, 6, 7, 10, 0, 0, 0, time.UTC)
+	ops := &clusteroperationv1alpha1.ClusterOperation{
+		Spec: clusteroperationv1alpha1.Spec{ActionType: clusteroperationv1alpha1.PlaybookActionType, Action: "metrics-test.yml"},
+		Status: clusteroperationv1alpha1.Status{
+			Status:    clusteroperationv1alpha1.SucceededStatus,
+			StartTime: &metav1.Time{Time: start},
+			EndTime:   &metav1.Time{Time: start.Add(time.Minute * 10)},
+		},
+	}
+	ObserveFinishedClusterOperation(ops)
+	if got := testutil.ToFloat64(ClusterOperationsTotal.WithLabelValues("metrics-test.yml", "Succeeded")); got != 1 {
+		t.Fatalf("got counter %v", got)
+	}
+	if got := testutil.CollectAndCount(ClusterOperationDurationSeconds, "kubean_cluster_operation_duration_seconds"); got == 0 {
+		t.Fatal("expect the duration is observed")
+	}
+
+	// the clusterOps which never started is counted without duration.
+	ops.Spec.Action = "metrics-test-never-started.yml"
+	ops.Status.Status = clusteroperationv1alpha1.FailedStatus
+	ops.Status.StartTime = nil
+	before := testutil.CollectAndCount(ClusterOperationDurationSeconds)
+	ObserveFinishedClusterOperation(ops)
+	if got := testutil.ToFloat64(ClusterOperationsTotal.WithLabelValues("metrics-test-never-started.yml", "Failed")); got != 1 {
+		t.Fatalf("got counter %v", got)
+	}
+	if after := testutil.CollectAndCount(ClusterOperationDurationSeconds); after != before {
+		t.Fatalf("expect no duration observed, got %d series, want %d", after, before)
+	}
+}
+
+func TestSetClusterOperations(t *testing.T) {
+	SetClusterOperations("metrics-cluster", []clusteroperationv1alpha1.ClusterOperation{
+		{Status: clusteroperationv1alpha1.Status{Status: clusteroperationv1alpha1.RunningStatus}},
+		{Status: clusteroperationv1alpha1.Status{Status: clusteroperationv1alpha1.PendingStatus}},
+		{Status: clusteroperationv1alpha1.Status{}},
+		{Status: clusteroperationv1alpha1.Status{Status: clusteroperationv1alpha1.SucceededStatus}},
+	})
+	if got := testutil.ToFloat64(ClusterOperations.WithLabelValues("metrics-cluster", "Running")); got != 1 {
+		t.Fatalf("got running %v", got)
+	}
+	if got := testutil.ToFloat64(ClusterOperations.WithLabelValues("metrics-cluster", "Pending")); got != 2 {
+		t.Fatalf("got pending %v", got)
+	}
+	DeleteClusterOperations("metrics-cluster")
+	if got := testutil.CollectAndCount(ClusterOperations); got != 0 {
+		t.Fatalf("expect the gauges deleted, got %d", got)
+	}
+}
+
+func TestSetOfflineAvailableVersions(t *testing.T) {
+	manifest := &manifestv1alpha1.Manifest{ObjectMeta: metav1.ObjectMeta{Name: "manifest-1"}}
+	manifest.Status.LocalAvailable.Components = []*manifestv1alpha1.SoftwareInfoStatus{{Name: "etcd", VersionRange: []string{"3.5.6", "3.5.7"}}, nil}
+	manifest.Status.LocalAvailable.Docker = []*manifestv1alpha1.DockerInfoStatus{{OS: "redhat-7", VersionRange: []string{"20.10"}}}
+	SetOfflineAvailableVersions(manifest)
+	if got := testutil.ToFloat64(OfflineAvailableVersions.WithLabelValues("manifest-1", "component", "etcd")); got != 2 {
+		t.Fatalf("got %v", got)
+	}
+	if got := testutil.ToFloat64(OfflineAvailableVersions.WithLabelValues("manifest-1", "docker", "redhat-7")); got != 1 {
+		t.Fatalf("got %v", got)
+	}
+	manifest.Status.LocalAvailable.Docker = nil
+	SetOfflineAvailableVersions(manifest)
+	if got := testutil.CollectAndCount(OfflineAvailableVersions); got != 1 {
+		t.Fatalf("expect the stale gauge deleted, got %d", got)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package ansible
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The groups of the kubespray inventory which decide the roles of the nodes.
+const (
+	ControlPlaneGroup = "kube_control_plane"
+	NodeGroup         = "kube_node"
+	EtcdGroup         = "etcd"
+	K8sClusterGroup   = "k8s_cluster"
+)
+
+// RoleGroups are the groups reported as the roles of the nodes, in order.
+var RoleGroups = []string{ControlPlaneGroup, NodeGroup, EtcdGroup}
+
+// RequiredGroups are the groups which must have hosts for kubespray to deploy the cluster.
+var RequiredGroups = []string{K8sClusterGroup, ControlPlaneGroup, EtcdGroup, NodeGroup}
+
+type inventoryGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts"`
+	Children map[string]*inventoryGroup        `yaml:"children"`
+}
+
+// Inventory is the ansible inventory in yaml format, e.g. the hosts.yml of kubespray.
+type Inventory struct {
+	hosts     []string
+	hostVars  map[string]map[string]interface{}
+	groups    map[string]map[string]struct{}
+	subgroups map[string][]string
+}
+
+// ParseInventory parses the yaml inventory, and merges the groups which are declared more than once.
+func ParseInventory(data []byte) (*Inventory, error) {
+	content := map[string]*inventoryGroup{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	inventory := &Inventory{
+		hostVars:  map[string]map[string]interface{}{},
+		groups:    map[string]map[string]struct{}{},
+		subgroups: map[string][]string{},
+	}
+	names := make([]string, 0, len(content))
+	for name := range content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		inventory.addGroup(name, content[name])
+	}
+	return inventory, nil
+}
+
+func (inventory *Inventory) addGroup(name string, group *inventoryGroup) {
+	if _, ok := inventory.groups[name]; !ok {
+		inventory.groups[name] = map[string]struct{}{}
+	}
+	if group == nil {
+		return
+	}
+	hosts := make([]string, 0, len(group.Hosts))
+	for host := range group.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		if _, ok := inventory.hostVars[host]; !ok {
+			inventory.hosts = append(inventory.hosts, host)
+			inventory.hostVars[host] = map[string]interface{}{}
+		}
+		for key, value := range group.Hosts[host] {
+			inventory.hostVars[host][key] = value
+		}
+		inventory.groups[name][host] = struct{}{}
+	}
+	children := make([]string, 0, len(group.Children))
+	for child := range group.Children {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		inventory.subgroups[name] = append(inventory.subgroups[name], child)
+		inventory.addGroup(child, group.Children[child])
+	}
+}
+
+// Hosts returns all the hosts in the order they are found.
+func (inventory *Inventory) Hosts() []string {
+	return append([]string{}, inventory.hosts...)
+}
+
+// GroupHosts returns the hosts of the group and of its child groups.
+func (inventory *Inventory) GroupHosts(name string) []string {
+	members := map[string]struct{}{}
+	visited := map[string]struct{}{}
+	var walk func(string)
+	walk = func(group string) {
+		if _, ok := visited[group]; ok {
+			return
+		}
+		visited[group] = struct{}{}
+		for host := range inventory.groups[group] {
+			members[host] = struct{}{}
+		}
+		for _, child := range inventory.subgroups[group] {
+			walk(child)
+		}
+	}
+	walk(name)
+	hosts := make([]string, 0, len(members))
+	for _, host := range inventory.hosts {
+		if _, ok := members[host]; ok {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// HostVar returns the variable of the host as string, and empty if it is not set.
+func (inventory *Inventory) HostVar(host, key string) string {
+	value, ok := inventory.hostVars[host][key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// Validate checks the groups required by kubespray, the addresses of the hosts and the size of etcd.
+func (inventory *Inventory) Validate() []error {
+	errs := make([]error, 0)
+	for _, group := range RequiredGroups {
+		if len(inventory.GroupHosts(group)) == 0 {
+			errs = append(errs, fmt.Errorf("group %s is missing or has no hosts", group))
+		}
+	}
+	for _, host := range inventory.hosts {
+		if inventory.HostVar(host, "ansible_host") == "" {
+			errs = append(errs, fmt.Errorf("host %s has no ansible_host", host))
+		}
+		for _, key := range []string{"ip", "access_ip"} {
+			if address := inventory.HostVar(host, key); address != "" && net.ParseIP(address) == nil {
+				errs = append(errs, fmt.Errorf("host %s has an invalid %s %q", host, key, address))
+			}
+		}
+	}
+	for _, key := range []string{"ip", "ansible_host"} {
+		owners := map[string]string{}
+		for _, host := range inventory.hosts {
+			address := inventory.HostVar(host, key)
+			if address == "" {
+				continue
+			}
+			if owner, ok := owners[address]; ok {
+				errs = append(errs, fmt.Errorf("hosts %s and %s have the same %s %s", owner, host, key, address))
+				continue
+			}
+			owners[address] = host
+		}
+	}
+	if etcd := inventory.GroupHosts(EtcdGroup); len(etcd) > 0 && len(etcd)%2 == 0 {
+		errs = append(errs, fmt.Errorf("group %s has %d hosts, but an odd number is required for the quorum", EtcdGroup, len(etcd)))
+	}
+	return errs
+}
+
+// MergeHosts adds the hosts, with their variables and role groups in the other inventory, into the yaml inventory.
+func MergeHosts(data []byte, from *Inventory, hosts []string) ([]byte, error) {
+	content := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	all := childMap(content, "all")
+	allHosts := childMap(all, "hosts")
+	children := childMap(all, "children")
+	for _, host := range hosts {
+		vars := map[interface{}]interface{}{}
+		for key, value := range from.hostVars[host] {
+			vars[key] = value
+		}
+		allHosts[host] = vars
+		for _, group := range RoleGroups {
+			for _, member := range from.GroupHosts(group) {
+				if member == host {
+					childMap(childMap(children, group), "hosts")[host] = nil
+				}
+			}
+		}
+	}
+	return yaml.Marshal(content)
+}
+
+func childMap(parent map[interface{}]interface{}, key string) map[interface{}]interface{} {
+	if child, ok := parent[key].(map[interface{}]interface{}); ok {
+		return child
+	}
+	child := map[interface{}]interface{}{}
+	parent[key] = child
+	return child
+}
@@ -0,0 +1,210 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package ansible
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sampleInventory = `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 172.30.41.10
+      ansible_user: root
+    node2:
+      ip: 172.30.41.11
+      ansible_host: 172.30.41.11
+    node3:
+      ip: 172.30.41.12
+      ansible_host: 172.30.41.12
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node2:
+        node3:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+    calico_rr:
+      hosts: {}
+`
+
+func TestParseInventory(t *testing.T) {
+	inventory, err := ParseInventory([]byte(sampleInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts := inventory.Hosts(); !reflect.DeepEqual(hosts, []string{"node1", "node2", "node3"}) {
+		t.Fatalf("got hosts %v", hosts)
+	}
+	if hosts := inventory.GroupHosts("k8s_cluster"); !reflect.DeepEqual(hosts, []string{"node1", "node2", "node3"}) {
+		t.Fatalf("got k8s_cluster %v", hosts)
+	}
+	if hosts := inventory.GroupHosts(NodeGroup); !reflect.DeepEqual(hosts, []string{"node2", "node3"}) {
+		t.Fatalf("got kube_node %v", hosts)
+	}
+	if hosts := inventory.GroupHosts("not_exist"); len(hosts) != 0 {
+		t.Fatalf("got not_exist %v", hosts)
+	}
+	if ip := inventory.HostVar("node2", "ip"); ip != "172.30.41.11" {
+		t.Fatalf("got ip %s", ip)
+	}
+	if errs := inventory.Validate(); len(errs) != 0 {
+		t.Fatalf("got errors %v", errs)
+	}
+	if _, err := ParseInventory([]byte("all: [")); err == nil {
+		t.Fatal("invalid yaml should fail")
+	}
+}
+
+func TestInventoryValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		inventory string
+		want      []string
+	}{
+		{
+			name: "missing groups",
+			inventory: `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+`,
+			want: []string{
+				"group k8s_cluster is missing or has no hosts",
+				"group etcd is missing or has no hosts",
+				"group kube_node is missing or has no hosts",
+				"host node1 has no ansible_host",
+			},
+		},
+		{
+			name: "invalid addresses",
+			inventory: `all:
+  hosts:
+    node1:
+      ip: 172.30.41.300
+      access_ip: node1.local
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`,
+			want: []string{
+				"host node1 has no ansible_host",
+				`host node1 has an invalid ip "172.30.41.300"`,
+				`host node1 has an invalid access_ip "node1.local"`,
+			},
+		},
+		{
+			name: "duplicate ip and even etcd",
+			inventory: `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 10.6.0.1
+    node2:
+      ip: 172.30.41.10
+      ansible_host: 10.6.0.2
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node2:
+    etcd:
+      hosts:
+        node1:
+        node2:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`,
+			want: []string{
+				"hosts node1 and node2 have the same ip 172.30.41.10",
+				"group etcd has 2 hosts, but an odd number is required for the quorum",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inventory, err := ParseInventory([]byte(test.inventory))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0)
+			for _, err := range inventory.Validate() {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestMergeHosts(t *testing.T) {
+	from, err := ParseInventory([]byte(sampleInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired := `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+    node2:
+      ip: 172.30.41.11
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node2:
+    etcd:
+      hosts:
+        node1:
+`
+	data, err := MergeHosts([]byte(desired), from, []string{"node3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := ParseInventory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts := merged.GroupHosts(NodeGroup); !reflect.DeepEqual(hosts, []string{"node2", "node3"}) {
+		t.Fatalf("got kube_node %v", hosts)
+	}
+	if hosts := merged.GroupHosts(EtcdGroup); !reflect.DeepEqual(hosts, []string{"node1"}) {
+		t.Fatalf("got etcd %v", hosts)
+	}
+	if ip := merged.HostVar("node3", "ip"); ip != "172.30.41.12" {
+		t.Fatalf("got ip %s", ip)
+	}
+}
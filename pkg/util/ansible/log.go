@@ -0,0 +1,182 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package ansible
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parse the output of ansible default stdout callback with json result format.
+
+var (
+	taskLinePattern    = regexp.MustCompile(`^TASK \[(.*)\]`)
+	failureLinePattern = regexp.MustCompile(`^(?:fatal|failed): \[([^\]]+)\].*? => (\{.*\})$`)
+	ignoringLine       = "...ignoring"
+	recapHeaderPattern = regexp.MustCompile(`^PLAY RECAP `)
+	recapLinePattern   = regexp.MustCompile(`^(\S+)\s*:\s*ok=\d+\s+changed=\d+\s+unreachable=(\d+)\s+failed=(\d+)`)
+	recapCountPattern  = regexp.MustCompile(`(\w+)=(\d+)`)
+)
+
+// TaskFailure describes the last failed task found in the ansible output.
+type TaskFailure struct {
+	Task    string
+	Hosts   []string
+	Message string
+}
+
+func (failure *TaskFailure) copy() *TaskFailure {
+	if failure == nil {
+		return nil
+	}
+	result := *failure
+	result.Hosts = append([]string{}, failure.Hosts...)
+	return &result
+}
+
+// resultMessage picks the most useful message from the json result of a failed task.
+func resultMessage(rawResult string) string {
+	result := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(rawResult), &result); err != nil {
+		return rawResult
+	}
+	for _, key := range []string{"msg", "stderr", "reason", "stdout"} {
+		value, ok := result[key]
+		if !ok {
+			continue
+		}
+		if message, ok := value.(string); ok {
+			if len(strings.TrimSpace(message)) != 0 {
+				return strings.TrimSpace(message)
+			}
+			continue
+		}
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+	return rawResult
+}
+
+// ParseLastFailure returns the last task which failed without being ignored, or nil if none failed.
+func ParseLastFailure(log io.Reader) (*TaskFailure, error) {
+	var lastFailure, beforeLastLine *TaskFailure
+	currentTask := ""
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == ignoringLine {
+			// the failure printed on the previous line is ignored by ignore_errors.
+			lastFailure = beforeLastLine
+			continue
+		}
+		beforeLastLine = lastFailure.copy()
+		if matches := taskLinePattern.FindStringSubmatch(line); matches != nil {
+			currentTask = matches[1]
+			continue
+		}
+		matches := failureLinePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		host, message := matches[1], resultMessage(matches[2])
+		if lastFailure != nil && lastFailure.Task == currentTask {
+			// the same task failed on more hosts.
+			if !contains(lastFailure.Hosts, host) {
+				lastFailure.Hosts = append(lastFailure.Hosts, host)
+			}
+			continue
+		}
+		lastFailure = &TaskFailure{Task: currentTask, Hosts: []string{host}, Message: message}
+	}
+	return lastFailure, scanner.Err()
+}
+
+// ParseFailedHosts returns the hosts which failed or were unreachable in the last PLAY RECAP,
+// they are the same hosts as ansible writes into the retry file.
+func ParseFailedHosts(log io.Reader) ([]string, error) {
+	failedHosts := []string{}
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if recapHeaderPattern.MatchString(line) {
+			failedHosts = []string{}
+			continue
+		}
+		matches := recapLinePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		if (matches[2] != "0" || matches[3] != "0") && !contains(failedHosts, matches[1]) {
+			failedHosts = append(failedHosts, matches[1])
+		}
+	}
+	return failedHosts, scanner.Err()
+}
+
+// HostStats is the task counts of one host in PLAY RECAP.
+type HostStats struct {
+	Host        string
+	Ok          int32
+	Changed     int32
+	Unreachable int32
+	Failed      int32
+	Skipped     int32
+}
+
+// ParseHostStats sums the counts of every PLAY RECAP, because the job may run more than one playbook.
+// The hosts are returned in the order they first appear.
+func ParseHostStats(log io.Reader) ([]HostStats, error) {
+	stats := []HostStats{}
+	indexes := map[string]int{}
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		matches := recapLinePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		index, ok := indexes[matches[1]]
+		if !ok {
+			index = len(stats)
+			indexes[matches[1]] = index
+			stats = append(stats, HostStats{Host: matches[1]})
+		}
+		for _, count := range recapCountPattern.FindAllStringSubmatch(line[len(matches[1]):], -1) {
+			value, err := strconv.ParseInt(count[2], 10, 32)
+			if err != nil {
+				continue
+			}
+			switch count[1] {
+			case "ok":
+				stats[index].Ok += int32(value)
+			case "changed":
+				stats[index].Changed += int32(value)
+			case "unreachable":
+				stats[index].Unreachable += int32(value)
+			case "failed":
+				stats[index].Failed += int32(value)
+			case "skipped":
+				stats[index].Skipped += int32(value)
+			}
+		}
+	}
+	return stats, scanner.Err()
+}
+
+func contains(list []string, item string) bool {
+	for _, value := range list {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
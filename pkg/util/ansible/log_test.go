@@ -0,0 +1,174 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package ansible
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLastFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want *TaskFailure
+	}{
+		{
+			name: "no failure",
+			log: `PLAY [all] *******
+TASK [Gathering Facts] *******
+ok: [node1]
+PLAY RECAP *******
+node1 : ok=1 changed=0 unreachable=0 failed=0`,
+			want: nil,
+		},
+		{
+			name: "task failed on one host",
+			log: `TASK [etcd : Configure etcd] *******
+ok: [node1]
+fatal: [node3]: FAILED! => {"changed": false, "msg": "timeout"}
+PLAY RECAP *******`,
+			want: &TaskFailure{Task: "etcd : Configure etcd", Hosts: []string{"node3"}, Message: "timeout"},
+		},
+		{
+			name: "task failed on many hosts and the last task wins",
+			log: `TASK [kubernetes/preinstall : Stop if swap enabled] *******
+fatal: [node1]: FAILED! => {"msg": "swap enabled"}
+...ignoring
+TASK [container-engine : Download containerd] *******
+fatal: [node1]: UNREACHABLE! => {"changed": false, "msg": "Failed to connect to the host via ssh", "unreachable": true}
+failed: [node2] (item=containerd) => {"ansible_loop_var": "item", "rc": 1, "stderr": "no space left on device"}
+fatal: [node2]: FAILED! => {"msg": "ignored duplicate"}`,
+			want: &TaskFailure{Task: "container-engine : Download containerd", Hosts: []string{"node1", "node2"}, Message: "Failed to connect to the host via ssh"},
+		},
+		{
+			name: "ignored failure",
+			log: `TASK [check something] *******
+fatal: [node1]: FAILED! => {"msg": "not important"}
+...ignoring
+TASK [next] *******
+ok: [node1]`,
+			want: nil,
+		},
+		{
+			name: "message from stderr and raw result",
+			log: `TASK [run command] *******
+fatal: [node1]: FAILED! => {"rc": 2, "stderr": "command not found", "msg": ""}
+TASK [run another command] *******
+fatal: [node1]: FAILED! => {not json}`,
+			want: &TaskFailure{Task: "run another command", Hosts: []string{"node1"}, Message: "{not json}"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseLastFailure(strings.NewReader(test.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResultMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{name: "msg", args: `{"msg": " timeout "}`, want: "timeout"},
+		{name: "stderr when msg is empty", args: `{"msg": "", "stderr": "no such file"}`, want: "no such file"},
+		{name: "msg is a list", args: `{"msg": ["a", "b"]}`, want: `["a","b"]`},
+		{name: "no known key", args: `{"rc": 1}`, want: `{"rc": 1}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := resultMessage(test.args); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseFailedHosts(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []string
+	}{
+		{
+			name: "no recap",
+			log:  "TASK [ping] *******\nok: [node1]",
+			want: []string{},
+		},
+		{
+			name: "failed and unreachable hosts",
+			log: `PLAY RECAP *******
+node1                      : ok=10   changed=2    unreachable=0    failed=0    skipped=5    rescued=0    ignored=0
+node2                      : ok=3    changed=0    unreachable=1    failed=0    skipped=0    rescued=0    ignored=0
+node3                      : ok=8    changed=1    unreachable=0    failed=1    skipped=2    rescued=0    ignored=0`,
+			want: []string{"node2", "node3"},
+		},
+		{
+			name: "the last recap wins",
+			log: `PLAY RECAP *******
+node1 : ok=1 changed=0 unreachable=0 failed=1 skipped=0 rescued=0 ignored=0
+PLAY RECAP *******
+node1 : ok=1 changed=0 unreachable=0 failed=0 skipped=0 rescued=0 ignored=0
+node2 : ok=1 changed=0 unreachable=0 failed=2 skipped=0 rescued=0 ignored=0`,
+			want: []string{"node2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseFailedHosts(strings.NewReader(test.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseHostStats(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []HostStats
+	}{
+		{
+			name: "no recap",
+			log:  "TASK [ping] *******\nok: [node1]",
+			want: []HostStats{},
+		},
+		{
+			name: "sum the recaps of all playbooks",
+			log: `PLAY RECAP *******
+node1                      : ok=10   changed=2    unreachable=0    failed=0    skipped=5    rescued=0    ignored=0
+node2                      : ok=3    changed=0    unreachable=1    failed=0    skipped=0    rescued=0    ignored=0
+PLAY RECAP *******
+node1                      : ok=8    changed=1    unreachable=0    failed=1    skipped=2    rescued=0    ignored=0`,
+			want: []HostStats{
+				{Host: "node1", Ok: 18, Changed: 3, Failed: 1, Skipped: 7},
+				{Host: "node2", Ok: 3, Unreachable: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseHostStats(strings.NewReader(test.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
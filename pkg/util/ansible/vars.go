@@ -0,0 +1,89 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package ansible
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SupportedNetworkPlugins are the values of kube_network_plugin which kubespray is able to deploy.
+var SupportedNetworkPlugins = []string{"calico", "cilium", "cni", "custom_cni", "flannel", "kube-ovn", "kube-router", "macvlan", "weave", "none"}
+
+// SupportedContainerManagers are the values of container_manager which kubespray is able to deploy.
+var SupportedContainerManagers = []string{"containerd", "crio", "docker"}
+
+// SupportedKubeProxyModes are the values of kube_proxy_mode which kubespray is able to deploy.
+var SupportedKubeProxyModes = []string{"ipvs", "iptables", "nftables"}
+
+// Vars are the ansible variables in yaml format, e.g. the group_vars.yml of kubean.
+type Vars map[string]interface{}
+
+// ParseVars parses the yaml variables, which must be a mapping.
+func ParseVars(data []byte) (Vars, error) {
+	vars := Vars{}
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// Get returns the variable as string, and empty if it is not set.
+func (vars Vars) Get(key string) string {
+	value, ok := vars[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// varsRule rejects the variables combination which kubespray fails on.
+type varsRule func(vars Vars) error
+
+func oneOf(key string, supported []string) varsRule {
+	return func(vars Vars) error {
+		value := vars.Get(key)
+		if value == "" {
+			return nil
+		}
+		for _, item := range supported {
+			if value == item {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s %q is not supported, the supported values are %s", key, value, strings.Join(supported, ", "))
+	}
+}
+
+var varsRules = []varsRule{
+	oneOf("kube_network_plugin", SupportedNetworkPlugins),
+	oneOf("container_manager", SupportedContainerManagers),
+	oneOf("kube_proxy_mode", SupportedKubeProxyModes),
+	func(vars Vars) error {
+		if vars.Get("etcd_deployment_type") == "docker" && vars.Get("container_manager") != "" && vars.Get("container_manager") != "docker" {
+			return fmt.Errorf("etcd_deployment_type docker requires container_manager docker, but got %s", vars.Get("container_manager"))
+		}
+		return nil
+	},
+	func(vars Vars) error {
+		ipip, vxlan := vars.Get("calico_ipip_mode"), vars.Get("calico_vxlan_mode")
+		if ipip != "" && ipip != "Never" && vxlan != "" && vxlan != "Never" {
+			return fmt.Errorf("calico_ipip_mode %s and calico_vxlan_mode %s can not be enabled at the same time", ipip, vxlan)
+		}
+		return nil
+	},
+}
+
+// Validate checks the values and the combinations of the variables which kubespray does not support.
+func (vars Vars) Validate() []error {
+	errs := make([]error, 0)
+	for _, rule := range varsRules {
+		if err := rule(vars); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package ansible
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVarsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		vars string
+		want []string
+	}{
+		{
+			name: "valid",
+			vars: "kube_network_plugin: calico\ncontainer_manager: containerd\nkube_proxy_mode: ipvs\ncalico_ipip_mode: Always\ncalico_vxlan_mode: Never\n",
+			want: []string{},
+		},
+		{
+			name: "unsupported values",
+			vars: "kube_network_plugin: canal\nkube_proxy_mode: userspace\n",
+			want: []string{
+				`kube_network_plugin "canal" is not supported, the supported values are calico, cilium, cni, custom_cni, flannel, kube-ovn, kube-router, macvlan, weave, none`,
+				`kube_proxy_mode "userspace" is not supported, the supported values are ipvs, iptables, nftables`,
+			},
+		},
+		{
+			name: "bad combinations",
+			vars: "etcd_deployment_type: docker\ncontainer_manager: containerd\ncalico_ipip_mode: Always\ncalico_vxlan_mode: CrossSubnet\n",
+			want: []string{
+				"etcd_deployment_type docker requires container_manager docker, but got containerd",
+				"calico_ipip_mode Always and calico_vxlan_mode CrossSubnet can not be enabled at the same time",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars, err := ParseVars([]byte(test.vars))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0)
+			for _, err := range vars.Validate() {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+	if _, err := ParseVars([]byte("- a\n- b\n")); err == nil {
+		t.Fatal("vars which are not a mapping should fail")
+	}
+}
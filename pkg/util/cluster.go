@@ -19,6 +19,7 @@ import (
 	"github.com/kubean-io/kubean-api/apis"
 	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
 	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationschedulev1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationschedule/v1alpha1"
 	localartifactsetv1alpha1 "github.com/kubean-io/kubean-api/apis/localartifactset/v1alpha1"
 	manifestv1alpha1 "github.com/kubean-io/kubean-api/apis/manifest/v1alpha1"
 	"github.com/kubean-io/kubean-api/cluster"
@@ -34,6 +35,7 @@ func init() {
 	_ = clusterv1alpha1.AddToScheme(aggregatedScheme)          // add cluster schemes
 	_ = localartifactsetv1alpha1.AddToScheme(aggregatedScheme)
 	_ = manifestv1alpha1.AddToScheme(aggregatedScheme)
+	_ = clusteroperationschedulev1alpha1.AddToScheme(aggregatedScheme)
 }
 
 // NewSchema returns a singleton schema set which aggregated Kubernetes's schemes and extended schemes.
@@ -72,6 +74,15 @@ func GetCurrentNSOrDefault() string {
 	return ns
 }
 
+// TenantNamespace returns the namespace where the configs of the cluster are backed up and the jobs run,
+// which is the tenant namespace of the cluster, or the namespace of kubean.
+func TenantNamespace(cluster *clusterv1alpha1.Cluster) string {
+	if cluster != nil && cluster.Spec.Tenant != nil && cluster.Spec.Tenant.Namespace != "" {
+		return cluster.Spec.Tenant.Namespace
+	}
+	return GetCurrentNSOrDefault()
+}
+
 func UpdateOwnReference(client kubernetes.Interface, configMapList []*apis.ConfigMapRef, secretList []*apis.SecretRef, belongToReference metav1.OwnerReference) error {
 	for _, ref := range configMapList {
 		if ref.IsEmpty() {
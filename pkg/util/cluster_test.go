@@ -7,6 +7,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
@@ -68,6 +69,29 @@ func TestGetCurrentNSOrDefault(t *testing.T) {
 	}
 }
 
+func TestTenantNamespace(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster *clusterv1alpha1.Cluster
+		want    string
+	}{
+		{name: "nil cluster", cluster: nil, want: GetCurrentNSOrDefault()},
+		{name: "cluster without tenant", cluster: &clusterv1alpha1.Cluster{}, want: GetCurrentNSOrDefault()},
+		{
+			name:    "cluster with tenant",
+			cluster: &clusterv1alpha1.Cluster{Spec: clusterv1alpha1.Spec{Tenant: &clusterv1alpha1.Tenant{Namespace: "team-a"}}},
+			want:    "team-a",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if TenantNamespace(test.cluster) != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
 func TestGetCurrentNS(t *testing.T) {
 	tests := []struct {
 		name string
@@ -308,3 +332,208 @@ func TestGetClusterOperationsBackEndLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLogArchiveBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		backend  string
+		pvc      string
+		expected string
+	}{
+		{
+			name:     "empty backend, use configmap",
+			expected: constants.LogArchiveBackendConfigMap,
+		},
+		{
+			name:     "disabled",
+			backend:  "None",
+			expected: constants.LogArchiveBackendNone,
+		},
+		{
+			name:     "pvc",
+			backend:  "pvc",
+			pvc:      "kubean-log",
+			expected: constants.LogArchiveBackendPVC,
+		},
+		{
+			name:     "pvc without claim name, use configmap",
+			backend:  "pvc",
+			expected: constants.LogArchiveBackendConfigMap,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{
+				LogArchiveBackend: tt.backend,
+				LogArchivePVC:     tt.pvc,
+			}
+			if got := config.GetLogArchiveBackend(); got != tt.expected {
+				t.Errorf("GetLogArchiveBackend() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsClusterOperationQueueMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{name: "empty", value: "", expected: false},
+		{name: "enabled", value: "true", expected: true},
+		{name: "disabled", value: "false", expected: false},
+		{name: "invalid", value: "yes", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{ClusterOperationQueueMode: tt.value}
+			if got := config.IsClusterOperationQueueMode(); got != tt.expected {
+				t.Errorf("IsClusterOperationQueueMode() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetNonPreemptibleActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "default", value: "", expected: []string{"upgrade-cluster.yml", "reset.yml"}},
+		{name: "custom", value: " reset.yml, ,remove-node.yml ", expected: []string{"reset.yml", "remove-node.yml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{NonPreemptibleActions: tt.value}
+			if got := config.GetNonPreemptibleActions(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetNonPreemptibleActions() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetApprovalRequiredActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "default", value: "", expected: []string{}},
+		{name: "custom", value: " reset.yml, ,remove-node.yml ", expected: []string{"reset.yml", "remove-node.yml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{ApprovalRequiredActions: tt.value}
+			if got := config.GetApprovalRequiredActions(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetApprovalRequiredActions() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetApprovalTimeoutSeconds(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int64
+	}{
+		{name: "default", value: "", expected: 86400},
+		{name: "invalid", value: "-1", expected: 86400},
+		{name: "custom", value: " 3600 ", expected: 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{ApprovalTimeoutSeconds: tt.value}
+			if got := config.GetApprovalTimeoutSeconds(); got != tt.expected {
+				t.Errorf("GetApprovalTimeoutSeconds() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetAuditRetentionDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int
+	}{
+		{name: "default", value: "", expected: 365},
+		{name: "invalid", value: "0", expected: 365},
+		{name: "custom", value: " 730 ", expected: 730},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{AuditRetentionDays: tt.value}
+			if got := config.GetAuditRetentionDays(); got != tt.expected {
+				t.Errorf("GetAuditRetentionDays() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetSprayJobImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   cluster.ConfigProperty
+		expected string
+	}{
+		{name: "default", config: cluster.ConfigProperty{}, expected: "ghcr.io/kubean-io/spray-job"},
+		{name: "custom", config: cluster.ConfigProperty{SprayJobImageRegistry: "registry:5000", SprayJobImageRepository: "mirror/spray-job"}, expected: "registry:5000/mirror/spray-job"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.GetSprayJobImage(); got != tt.expected {
+				t.Errorf("GetSprayJobImage() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetSprayJobImageTag(t *testing.T) {
+	if got := (&cluster.ConfigProperty{}).GetSprayJobImageTag(); got != "" {
+		t.Errorf("GetSprayJobImageTag() = %v, want empty", got)
+	}
+	if got := (&cluster.ConfigProperty{SprayJobImageTag: " v0.7.0 "}).GetSprayJobImageTag(); got != "v0.7.0" {
+		t.Errorf("GetSprayJobImageTag() = %v, want v0.7.0", got)
+	}
+}
+
+func TestGetActiveDeadlineSeconds(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int64
+	}{
+		{name: "empty", value: "", expected: 0},
+		{name: "zero", value: "0", expected: 0},
+		{name: "invalid", value: "1h", expected: 0},
+		{name: "seconds", value: " 3600 ", expected: 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &cluster.ConfigProperty{ActiveDeadlineSeconds: tt.value}
+			got := config.GetActiveDeadlineSeconds()
+			if (got == nil && tt.expected != 0) || (got != nil && *got != tt.expected) {
+				t.Errorf("GetActiveDeadlineSeconds() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetSprayJobResourceRequests(t *testing.T) {
+	config := &cluster.ConfigProperty{SprayJobCPURequest: "500m", SprayJobMemoryRequest: "1 Gi"}
+	requests := config.GetSprayJobResourceRequests()
+	if len(requests) != 1 || requests.Cpu().String() != "500m" {
+		t.Errorf("GetSprayJobResourceRequests() = %v", requests)
+	}
+}
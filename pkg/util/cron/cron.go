@@ -0,0 +1,131 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchDays bounds Next for the expressions which rarely or never fire, e.g. `0 0 30 2 *`.
+const maxSearchDays = 366 * 5
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// Schedule is a parsed standard cron expression with five fields: minute hour day-of-month month day-of-week.
+type Schedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// domStar and dowStar keep the cron semantic that a day matches either field when both are restricted.
+	domStar, dowStar bool
+}
+
+// Parse parses the cron expression, which supports `*`, lists, ranges and steps in each field.
+func Parse(spec string) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q expects %d fields but got %d", spec, len(fields), len(parts))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", spec, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true // both 0 and 7 are Sunday
+	}
+	return &Schedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domStar: strings.HasPrefix(parts[2], "*"), dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(value string, f field) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+		if index := strings.Index(item, "/"); index >= 0 {
+			rangePart = item[:index]
+			var err error
+			if step, err = strconv.Atoi(item[index+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q in %s", item, f.name)
+			}
+		}
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q in %s", item, f.name)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q in %s", item, f.name)
+				}
+			} else if strings.Contains(item, "/") {
+				end = f.max // `5/15` means from 5 to the max
+			}
+		}
+		if start < f.min || end > f.max || start > end {
+			return nil, fmt.Errorf("value %q out of range [%d, %d] in %s", item, f.min, f.max, f.name)
+		}
+		for i := start; i <= end; i += step {
+			set[i] = true
+		}
+	}
+	return set, nil
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	if !s.month[int(t.Month())] {
+		return false
+	}
+	domMatch, dowMatch := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first activation strictly after t in the location of t, or the zero time if none is found.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < maxSearchDays; i++ {
+		if s.matchDay(day) {
+			for hour := 0; hour < 24; hour++ {
+				if !s.hour[hour] {
+					continue
+				}
+				for minute := 0; minute < 60; minute++ {
+					if !s.minute[minute] {
+						continue
+					}
+					activation := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+					if !activation.Before(t) {
+						return activation
+					}
+				}
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, day.Location())
+	}
+	return time.Time{}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "every minute", spec: "* * * * *"},
+		{name: "lists ranges and steps", spec: "0,30 1-5 */2 1-12/3 1-5"},
+		{name: "sunday as 7", spec: "0 2 * * 7"},
+		{name: "too few fields", spec: "0 2 * *", wantErr: true},
+		{name: "out of range", spec: "60 2 * * *", wantErr: true},
+		{name: "reverse range", spec: "0 5-2 * * *", wantErr: true},
+		{name: "invalid step", spec: "*/0 * * * *", wantErr: true},
+		{name: "not a number", spec: "0 two * * *", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse(test.spec); (err != nil) != test.wantErr {
+				t.Fatalf("Parse(%q) error %v, wantErr %v", test.spec, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2023-06-07 is a Wednesday.
+	from := time.Date(2023, 6, 7, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name string
+		spec string
+		want time.Time
+	}{
+		{name: "every minute", spec: "* * * * *", want: time.Date(2023, 6, 7, 10, 31, 0, 0, time.UTC)},
+		{name: "later today", spec: "0 22 * * *", want: time.Date(2023, 6, 7, 22, 0, 0, 0, time.UTC)},
+		{name: "tomorrow", spec: "0 2 * * *", want: time.Date(2023, 6, 8, 2, 0, 0, 0, time.UTC)},
+		{name: "sunday", spec: "0 2 * * 7", want: time.Date(2023, 6, 11, 2, 0, 0, 0, time.UTC)},
+		{name: "day of month or day of week", spec: "0 2 1 * 5", want: time.Date(2023, 6, 9, 2, 0, 0, 0, time.UTC)},
+		{name: "next year", spec: "0 0 1 1 *", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "never", spec: "0 0 30 2 *", want: time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := Parse(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(from); !got.Equal(test.want) {
+				t.Fatalf("Next() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
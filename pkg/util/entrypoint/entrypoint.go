@@ -4,10 +4,14 @@
 package entrypoint
 
 import (
+	"bufio"
 	_ "embed"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	klog "k8s.io/klog/v2"
 )
@@ -43,6 +47,83 @@ const (
 	ConfigDockerCgroupDriverForKylinSP2 = "config-docker-cgroup-driver-for-kylinSP2.yml"
 )
 
+const (
+	// StageMarker prefixes the lines printed by entrypoint.sh at the boundaries of each stage.
+	StageMarker     = "[kubean-stage]"
+	StageStartPhase = "start"
+	StageEndPhase   = "end"
+
+	SprayStageName = "action"
+
+	// ResumeStageEnv makes entrypoint.sh skip the stages before it.
+	ResumeStageEnv = "KUBEAN_RESUME_STAGE"
+	// LimitEnv is the comma separated hosts passed by `--limit` to the playbooks of the resumed stage
+	// which are not limited by their own arguments.
+	LimitEnv = "KUBEAN_LIMIT"
+
+	// DryRunSkipCMD takes the place of the shell hook which is not dryRunSafe in dry run,
+	// so that the stage names of the other hooks are kept.
+	DryRunSkipCMD = "echo \"skip the shell hook which is not dryRunSafe in dry run\""
+)
+
+func PreHookStageName(index int) string {
+	return fmt.Sprintf("prehook-%d", index)
+}
+
+func PostHookStageName(index int) string {
+	return fmt.Sprintf("posthook-%d", index)
+}
+
+// StageEvent is one stage marker parsed from the output of entrypoint.sh.
+type StageEvent struct {
+	Phase    string
+	Name     string
+	ExitCode int32
+	Time     time.Time
+}
+
+// ParseStageEvents scans the job log and returns stage markers in the order they were printed.
+func ParseStageEvents(log io.Reader) ([]StageEvent, error) {
+	events := make([]StageEvent, 0)
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, StageMarker) {
+			continue
+		}
+		// [kubean-stage] start <name> <unix> or [kubean-stage] end <name> <exitCode> <unix>
+		fields := strings.Fields(strings.TrimPrefix(line, StageMarker))
+		if len(fields) < 3 {
+			continue
+		}
+		event := StageEvent{Phase: fields[0], Name: fields[1]}
+		timeField := fields[2]
+		switch event.Phase {
+		case StageStartPhase:
+		case StageEndPhase:
+			if len(fields) < 4 {
+				continue
+			}
+			exitCode, err := strconv.ParseInt(fields[2], 10, 32)
+			if err != nil {
+				continue
+			}
+			event.ExitCode = int32(exitCode)
+			timeField = fields[3]
+		default:
+			continue
+		}
+		unix, err := strconv.ParseInt(timeField, 10, 64)
+		if err != nil {
+			continue
+		}
+		event.Time = time.Unix(unix, 0)
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
 //go:embed entrypoint.sh.template
 var entrypointTemplate string
 
@@ -91,6 +172,8 @@ type EntryPoint struct {
 	SprayCMD     string
 	PostHookCMDs []string
 	Actions      *Actions
+	// DryRun runs the playbooks in ansible check mode.
+	DryRun bool
 }
 
 func NewEntryPoint() *EntryPoint {
@@ -99,22 +182,22 @@ func NewEntryPoint() *EntryPoint {
 	return ep
 }
 
-func (ep *EntryPoint) buildPlaybookCmd(action, extraArgs string, isPrivateKey, builtinAction bool) (string, error) {
+func (ep *EntryPoint) buildPlaybookCmd(action, extraArgs string, builtinAction bool) (string, error) {
 	if builtinAction {
 		if _, ok := ep.Actions.Playbooks.Dict[action]; !ok {
 			return "", ArgsError{fmt.Sprintf("unknown playbook type, the currently supported ranges include: %s", ep.Actions.Playbooks.List)}
 		}
 	}
 	playbookCmd := "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\""
-	if isPrivateKey {
-		playbookCmd = fmt.Sprintf("%s --private-key /auth/ssh-privatekey", playbookCmd)
-	}
 	if action == ResetPB {
 		playbookCmd = fmt.Sprintf("%s -e \"reset_confirmation=yes\"", playbookCmd)
 	}
 	if action == RemoveNodePB {
 		playbookCmd = fmt.Sprintf("%s -e \"skip_confirmation=true\"", playbookCmd)
 	}
+	if ep.DryRun {
+		playbookCmd = fmt.Sprintf("%s --check --diff", playbookCmd)
+	}
 	playbookCmd = fmt.Sprintf("%s /kubespray/%s", playbookCmd, action)
 	if len(extraArgs) > 0 {
 		playbookCmd = fmt.Sprintf("%s %s", playbookCmd, extraArgs)
@@ -122,13 +205,13 @@ func (ep *EntryPoint) buildPlaybookCmd(action, extraArgs string, isPrivateKey, b
 	return playbookCmd, nil
 }
 
-func (ep *EntryPoint) hookRunPart(actionType, action, extraArgs string, isPrivateKey, builtinAction bool) (string, error) {
+func (ep *EntryPoint) hookRunPart(actionType, action, extraArgs string, builtinAction bool) (string, error) {
 	if !builtinAction {
 		klog.Infof("use external action %s, type %s", action, actionType)
 	}
 	hookRunCmd := ""
 	if actionType == PBAction {
-		playbookCmd, err := ep.buildPlaybookCmd(action, extraArgs, isPrivateKey, builtinAction)
+		playbookCmd, err := ep.buildPlaybookCmd(action, extraArgs, builtinAction)
 		if err != nil {
 			return "", ArgsError{fmt.Sprintf("buildPlaybookCmd: %s", err)}
 		}
@@ -141,8 +224,8 @@ func (ep *EntryPoint) hookRunPart(actionType, action, extraArgs string, isPrivat
 	return hookRunCmd, nil
 }
 
-func (ep *EntryPoint) PreHookRunPart(actionType, action, extraArgs string, isPrivateKey, builtinAction bool) error {
-	prehook, err := ep.hookRunPart(actionType, action, extraArgs, isPrivateKey, builtinAction)
+func (ep *EntryPoint) PreHookRunPart(actionType, action, extraArgs string, builtinAction bool) error {
+	prehook, err := ep.hookRunPart(actionType, action, extraArgs, builtinAction)
 	if err != nil {
 		return ArgsError{fmt.Sprintf("prehook: %s", err)}
 	}
@@ -150,8 +233,8 @@ func (ep *EntryPoint) PreHookRunPart(actionType, action, extraArgs string, isPri
 	return nil
 }
 
-func (ep *EntryPoint) PostHookRunPart(actionType, action, extraArgs string, isPrivateKey, builtinAction bool) error {
-	posthook, err := ep.hookRunPart(actionType, action, extraArgs, isPrivateKey, builtinAction)
+func (ep *EntryPoint) PostHookRunPart(actionType, action, extraArgs string, builtinAction bool) error {
+	posthook, err := ep.hookRunPart(actionType, action, extraArgs, builtinAction)
 	if err != nil {
 		return ArgsError{fmt.Sprintf("posthook: %s", err)}
 	}
@@ -159,17 +242,20 @@ func (ep *EntryPoint) PostHookRunPart(actionType, action, extraArgs string, isPr
 	return nil
 }
 
-func (ep *EntryPoint) SprayRunPart(actionType, action, extraArgs string, isPrivateKey, builtinAction bool) error {
+func (ep *EntryPoint) SprayRunPart(actionType, action, extraArgs string, builtinAction bool) error {
 	if !builtinAction {
 		klog.Infof("use external action %s, type %s", action, actionType)
 	}
 	if actionType == PBAction {
-		playbookCmd, err := ep.buildPlaybookCmd(action, extraArgs, isPrivateKey, builtinAction)
+		playbookCmd, err := ep.buildPlaybookCmd(action, extraArgs, builtinAction)
 		if err != nil {
 			return ArgsError{fmt.Sprintf("buildPlaybookCmd: %s", err)}
 		}
 		ep.SprayCMD = playbookCmd
 	} else if actionType == SHAction {
+		if ep.DryRun {
+			return ArgsError{"shell action does not support dry run"}
+		}
 		ep.SprayCMD = action
 	} else {
 		return ArgsError{fmt.Sprintf("unknown action type, the currently supported ranges include: %s", ep.Actions.Types)}
@@ -179,7 +265,12 @@ func (ep *EntryPoint) SprayRunPart(actionType, action, extraArgs string, isPriva
 
 func (ep *EntryPoint) Render() (string, error) {
 	b := &strings.Builder{}
-	tmpl := template.Must(template.New("entrypoint").Parse(entrypointTemplate))
+	tmpl := template.Must(template.New("entrypoint").Funcs(template.FuncMap{
+		"stageMarker":   func() string { return StageMarker },
+		"preHookStage":  PreHookStageName,
+		"sprayStage":    func() string { return SprayStageName },
+		"postHookStage": PostHookStageName,
+	}).Parse(entrypointTemplate))
 	if err := tmpl.Execute(b, ep); err != nil {
 		return "", err
 	}
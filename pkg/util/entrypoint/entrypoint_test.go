@@ -4,8 +4,10 @@
 package entrypoint
 
 import (
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
 
@@ -17,7 +19,6 @@ var testData = `
   input:
     actionType: playbook
     action: cluster.yml
-    isPrivateKey: true
     prehook:
       - actionType: shell
         action: |
@@ -32,14 +33,13 @@ var testData = `
       - actionType: shell
         action: |
           ansible -i host.yml node1 -m shell -a "systemctl status kubelet"
-  matchString: "--private-key"
+  matchString: "export ANSIBLE_PRIVATE_KEY_FILE=/auth/ssh-privatekey"
   output: true
 
 - message: "Check ansible password mode"
   input:
     actionType: playbook
     action: cluster.yml
-    isPrivateKey: false
     prehook:
       - actionType: shell
         action: |
@@ -55,7 +55,6 @@ var testData = `
   input:
     actionType: playbook
     action: reset.yml
-    isPrivateKey: false
     prehook:
       - actionType: shell
         action: |
@@ -144,8 +143,7 @@ var testData = `
   input:
     actionType: playbook
     action: kubeconfig.yml
-    isPrivateKey: true
-  matchString: "--private-key /auth/ssh-privatekey /kubespray/kubeconfig.yml"
+  matchString: "-e \"@/conf/group_vars.yml\" /kubespray/kubeconfig.yml"
   output: true
 
 - message: "Check clean up kubeconf after cluster reset"
@@ -182,12 +180,11 @@ type SubAction struct {
 }
 
 type ActionData struct {
-	ActionType   string       `yaml:"actionType"`
-	Action       string       `yaml:"action"`
-	ExtraArgs    string       `yaml:"extraArgs"`
-	PreHooks     []*SubAction `yaml:"prehook"`
-	PostHooks    []*SubAction `yaml:"posthook"`
-	IsPrivateKey bool         `yaml:"isPrivateKey"`
+	ActionType string       `yaml:"actionType"`
+	Action     string       `yaml:"action"`
+	ExtraArgs  string       `yaml:"extraArgs"`
+	PreHooks   []*SubAction `yaml:"prehook"`
+	PostHooks  []*SubAction `yaml:"posthook"`
 }
 
 type UnitTestData struct {
@@ -210,19 +207,19 @@ func TestEntrypoint(t *testing.T) {
 			ep := NewEntryPoint()
 			// Prehook 命令处理
 			for _, prehook := range item.Input.PreHooks {
-				err = ep.PreHookRunPart(prehook.ActionType, prehook.Action, prehook.ExtraArgs, item.Input.IsPrivateKey, true)
+				err = ep.PreHookRunPart(prehook.ActionType, prehook.Action, prehook.ExtraArgs, true)
 				if err != nil {
 					t.Fatalf("error: %v", err)
 				}
 			}
 			// Kubespray 命令处理
-			err = ep.SprayRunPart(item.Input.ActionType, item.Input.Action, item.Input.ExtraArgs, item.Input.IsPrivateKey, true)
+			err = ep.SprayRunPart(item.Input.ActionType, item.Input.Action, item.Input.ExtraArgs, true)
 			if err != nil {
 				t.Fatalf("error: %v", err)
 			}
 			// Posthook 命令处理
 			for _, posthook := range item.Input.PostHooks {
-				err = ep.PostHookRunPart(posthook.ActionType, posthook.Action, posthook.ExtraArgs, item.Input.IsPrivateKey, true)
+				err = ep.PostHookRunPart(posthook.ActionType, posthook.Action, posthook.ExtraArgs, true)
 				if err != nil {
 					t.Fatalf("error: %v", err)
 				}
@@ -251,7 +248,7 @@ func Test_SprayRunPart(t *testing.T) {
 			name: "right builtin action",
 			args: func() bool {
 				ep := NewEntryPoint()
-				return ep.SprayRunPart(PBAction, ResetPB, "-vvv", false, true) == nil
+				return ep.SprayRunPart(PBAction, ResetPB, "-vvv", true) == nil
 			},
 			want: true,
 		},
@@ -259,7 +256,7 @@ func Test_SprayRunPart(t *testing.T) {
 			name: "right builtin action",
 			args: func() bool {
 				ep := NewEntryPoint()
-				return ep.SprayRunPart(PBAction, ResetPB, "-vvv", false, true) == nil
+				return ep.SprayRunPart(PBAction, ResetPB, "-vvv", true) == nil
 			},
 			want: true,
 		},
@@ -267,7 +264,7 @@ func Test_SprayRunPart(t *testing.T) {
 			name: "wrong builtin action",
 			args: func() bool {
 				ep := NewEntryPoint()
-				return ep.SprayRunPart(PBAction, "abc.yml", "-vvv", false, true) == nil
+				return ep.SprayRunPart(PBAction, "abc.yml", "-vvv", true) == nil
 			},
 			want: false,
 		},
@@ -275,7 +272,7 @@ func Test_SprayRunPart(t *testing.T) {
 			name: "shell action",
 			args: func() bool {
 				ep := NewEntryPoint()
-				return ep.SprayRunPart(SHAction, "sleep 10", "", false, true) == nil
+				return ep.SprayRunPart(SHAction, "sleep 10", "", true) == nil
 			},
 			want: true,
 		},
@@ -283,7 +280,16 @@ func Test_SprayRunPart(t *testing.T) {
 			name: "other wrong action",
 			args: func() bool {
 				ep := NewEntryPoint()
-				return ep.SprayRunPart("OtherAction", "sleep 10", "", false, true) == nil
+				return ep.SprayRunPart("OtherAction", "sleep 10", "", true) == nil
+			},
+			want: false,
+		},
+		{
+			name: "shell action in dry run",
+			args: func() bool {
+				ep := NewEntryPoint()
+				ep.DryRun = true
+				return ep.SprayRunPart(SHAction, "sleep 10", "", true) == nil
 			},
 			want: false,
 		},
@@ -313,11 +319,11 @@ func TestEntryPoint_buildPlaybookCmd(t *testing.T) {
 		SprayCMD     string
 		PostHookCMDs []string
 		Actions      *Actions
+		DryRun       bool
 	}
 	type args struct {
-		action       string
-		extraArgs    string
-		isPrivateKey bool
+		action    string
+		extraArgs string
 	}
 	tests := []struct {
 		name    string
@@ -341,7 +347,7 @@ func TestEntryPoint_buildPlaybookCmd(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "test private key case",
+			name:    "test reset case",
 			wantErr: false,
 			fields: fields{
 				Actions: &Actions{
@@ -353,10 +359,9 @@ func TestEntryPoint_buildPlaybookCmd(t *testing.T) {
 				},
 			},
 			args: args{
-				action:       ResetPB,
-				isPrivateKey: true,
+				action: ResetPB,
 			},
-			want: "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" --private-key /auth/ssh-privatekey -e \"reset_confirmation=yes\" /kubespray/reset.yml",
+			want: "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" -e \"reset_confirmation=yes\" /kubespray/reset.yml",
 		},
 		{
 			name:    "test extra args case",
@@ -371,11 +376,29 @@ func TestEntryPoint_buildPlaybookCmd(t *testing.T) {
 				},
 			},
 			args: args{
-				isPrivateKey: true,
-				action:       ResetPB,
-				extraArgs:    "-e \"reset_confirmation=yes\"",
+				action:    ResetPB,
+				extraArgs: "-e \"reset_confirmation=yes\"",
+			},
+			want: "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" -e \"reset_confirmation=yes\" /kubespray/reset.yml -e \"reset_confirmation=yes\"",
+		},
+		{
+			name:    "test dry run case",
+			wantErr: false,
+			fields: fields{
+				Actions: &Actions{
+					Playbooks: &Playbooks{
+						Dict: map[string]void{
+							UpgradeClusterPB: {},
+						},
+					},
+				},
+				DryRun: true,
+			},
+			args: args{
+				action:    UpgradeClusterPB,
+				extraArgs: "-vvv",
 			},
-			want: "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" --private-key /auth/ssh-privatekey -e \"reset_confirmation=yes\" /kubespray/reset.yml -e \"reset_confirmation=yes\"",
+			want: "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" --check --diff /kubespray/upgrade-cluster.yml -vvv",
 		},
 	}
 	for _, tt := range tests {
@@ -385,8 +408,9 @@ func TestEntryPoint_buildPlaybookCmd(t *testing.T) {
 				SprayCMD:     tt.fields.SprayCMD,
 				PostHookCMDs: tt.fields.PostHookCMDs,
 				Actions:      tt.fields.Actions,
+				DryRun:       tt.fields.DryRun,
 			}
-			got, err := ep.buildPlaybookCmd(tt.args.action, tt.args.extraArgs, tt.args.isPrivateKey, true)
+			got, err := ep.buildPlaybookCmd(tt.args.action, tt.args.extraArgs, true)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("buildPlaybookCmd() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -406,10 +430,9 @@ func Test_entryPoint_hookRunPart(t *testing.T) {
 		Actions      *Actions
 	}
 	type args struct {
-		actionType   string
-		action       string
-		extraArgs    string
-		isPrivateKey bool
+		actionType string
+		action     string
+		extraArgs  string
 	}
 	tests := []struct {
 		name    string
@@ -445,12 +468,11 @@ func Test_entryPoint_hookRunPart(t *testing.T) {
 				},
 			},
 			args: args{
-				actionType:   PBAction,
-				action:       ResetPB,
-				isPrivateKey: true,
+				actionType: PBAction,
+				action:     ResetPB,
 			},
 			wantErr: false,
-			want:    "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" --private-key /auth/ssh-privatekey -e \"reset_confirmation=yes\" /kubespray/reset.yml",
+			want:    "ansible-playbook -i /conf/hosts.yml -b --become-user root -e \"@/conf/group_vars.yml\" -e \"reset_confirmation=yes\" /kubespray/reset.yml",
 		},
 		{
 			name: "test shell action case",
@@ -480,7 +502,7 @@ func Test_entryPoint_hookRunPart(t *testing.T) {
 				PostHookCMDs: tt.fields.PostHookCMDs,
 				Actions:      tt.fields.Actions,
 			}
-			got, err := ep.hookRunPart(tt.args.actionType, tt.args.action, tt.args.extraArgs, tt.args.isPrivateKey, true)
+			got, err := ep.hookRunPart(tt.args.actionType, tt.args.action, tt.args.extraArgs, true)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("hookRunPart() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -492,6 +514,109 @@ func Test_entryPoint_hookRunPart(t *testing.T) {
 	}
 }
 
+const renderHeader = `#!/bin/bash
+
+set -o errexit
+set -o nounset
+set -o pipefail
+# run ansible in its own process group so that SIGTERM can be forwarded to all of its processes
+set -o monitor
+
+# print task results as json so that kubean-operator can summarize the failed task
+export ANSIBLE_CALLBACK_RESULT_FORMAT=json
+
+# a retry attempt resumes from the failed stage and limits the playbooks of that stage to the failed hosts
+KUBEAN_RESUME_STAGE="${KUBEAN_RESUME_STAGE:-}"
+KUBEAN_LIMIT="${KUBEAN_LIMIT:-}"
+KUBEAN_STAGE_LIMIT=""
+function has_limit() {
+  local arg
+  for arg in "$@"; do
+    case "${arg}" in
+      --limit | --limit=* | -l*) return 0 ;;
+    esac
+  done
+  return 1
+}
+function ansible-playbook() {
+  if [ -n "${KUBEAN_STAGE_LIMIT}" ] && ! has_limit "$@"; then
+    run_child command ansible-playbook --limit "${KUBEAN_STAGE_LIMIT}" "$@"
+  else
+    run_child command ansible-playbook "$@"
+  fi
+}
+function ansible() {
+  run_child command ansible "$@"
+}
+
+# the stages run in this shell so that the variables exported by the hooks reach the later stages, while ansible
+# runs as a child in the background since bash defers the TERM trap until the foreground command exits
+KUBEAN_CHILD_PID=""
+function run_child() {
+  "$@" &
+  KUBEAN_CHILD_PID=$!
+  local exit_code=0
+  wait "${KUBEAN_CHILD_PID}" || exit_code=$?
+  KUBEAN_CHILD_PID=""
+  return "${exit_code}"
+}
+
+# the ssh credentials are mounted in /auth and passed to ansible by their paths, so they are never written in this script
+if [ -f /auth/ssh-privatekey ]; then
+  export ANSIBLE_PRIVATE_KEY_FILE=/auth/ssh-privatekey
+fi
+# the certificate is added by the ssh config, which ssh reads along with ansible_ssh_extra_args of the inventory
+if [ -f /auth/ssh-certificate ]; then
+  mkdir -p "${HOME:-/root}/.ssh"
+  printf 'Host *\n  CertificateFile /auth/ssh-certificate\n' >> "${HOME:-/root}/.ssh/config"
+fi
+if [ -f /auth/ansible_password ]; then
+  export ANSIBLE_CONNECTION_PASSWORD_FILE=/auth/ansible_password
+fi
+if [ -f /auth/become_password ]; then
+  export ANSIBLE_BECOME_PASSWORD_FILE=/auth/become_password
+fi
+# the private key of a host group, e.g. /auth/ssh-privatekey.kube_control_plane, overrides the one of all hosts
+for group_key in /auth/ssh-privatekey.*; do
+  group="${group_key#/auth/ssh-privatekey.}"
+  if [ -f "${group_key}" ] && [[ "${group}" =~ ^[A-Za-z_][A-Za-z0-9_]*$ ]]; then
+    mkdir -p /conf/group_vars
+    echo "ansible_ssh_private_key_file: ${group_key}" > "/conf/group_vars/${group}.yml"
+  fi
+done
+
+# stage markers are parsed by kubean-operator to report progress
+KUBEAN_STAGE=""
+function stage_start() {
+  if [ -n "${KUBEAN_RESUME_STAGE}" ] && [ "${KUBEAN_RESUME_STAGE}" != "$1" ]; then
+    echo "skip stage $1 and resume from ${KUBEAN_RESUME_STAGE}"
+    return 1
+  fi
+  KUBEAN_RESUME_STAGE=""
+  # only the first stage which runs is limited, the later stages have not run on any host yet
+  KUBEAN_STAGE_LIMIT="${KUBEAN_LIMIT}"
+  KUBEAN_LIMIT=""
+  KUBEAN_STAGE="$1"
+  echo "[kubean-stage] start ${KUBEAN_STAGE} $(date +%s)"
+}
+function stage_end() {
+  echo "[kubean-stage] end ${KUBEAN_STAGE} $1 $(date +%s)"
+  KUBEAN_STAGE=""
+  KUBEAN_STAGE_LIMIT=""
+}
+function stage_terminate() {
+  echo "receive SIGTERM and stop stage ${KUBEAN_STAGE}"
+  if [ -n "${KUBEAN_CHILD_PID}" ]; then
+    kill -TERM -- "-${KUBEAN_CHILD_PID}" || true
+    wait "${KUBEAN_CHILD_PID}" || true
+  fi
+  exit 143
+}
+trap 'exit_code=$?; if [ -n "${KUBEAN_STAGE}" ]; then stage_end ${exit_code}; fi' EXIT
+trap stage_terminate TERM
+
+`
+
 func TestEntryPoint_Render(t *testing.T) {
 	type fields struct {
 		PreHookCMDs  []string
@@ -511,7 +636,7 @@ func TestEntryPoint_Render(t *testing.T) {
 				PreHookCMDs: []string{"cmd1", "cmd2", "cmd3"},
 			},
 			wantErr: false,
-			want:    "#!/bin/bash\n\nset -o errexit\nset -o nounset\nset -o pipefail\n\n# preinstall\ncmd1\ncmd2\ncmd3\n\n\n# run kubespray\n\n\n# postinstall\n\n",
+			want:    renderHeader + "# preinstall\nif stage_start prehook-0; then\ncmd1\nstage_end 0\nfi\nif stage_start prehook-1; then\ncmd2\nstage_end 0\nfi\nif stage_start prehook-2; then\ncmd3\nstage_end 0\nfi\n\n\n# run kubespray\nif stage_start action; then\n\nstage_end 0\nfi\n\n# postinstall\n\n",
 		},
 		{
 			name: "test SprayCMD not empty case",
@@ -519,7 +644,7 @@ func TestEntryPoint_Render(t *testing.T) {
 				PreHookCMDs: []string{"cmd1", "cmd2", "cmd3"},
 				SprayCMD:    "echo $TEST",
 			},
-			want:    "#!/bin/bash\n\nset -o errexit\nset -o nounset\nset -o pipefail\n\n# preinstall\ncmd1\ncmd2\ncmd3\n\n\n# run kubespray\necho $TEST\n\n# postinstall\n\n",
+			want:    renderHeader + "# preinstall\nif stage_start prehook-0; then\ncmd1\nstage_end 0\nfi\nif stage_start prehook-1; then\ncmd2\nstage_end 0\nfi\nif stage_start prehook-2; then\ncmd3\nstage_end 0\nfi\n\n\n# run kubespray\nif stage_start action; then\necho $TEST\nstage_end 0\nfi\n\n# postinstall\n\n",
 			wantErr: false,
 		},
 		{
@@ -530,7 +655,7 @@ func TestEntryPoint_Render(t *testing.T) {
 				PostHookCMDs: []string{"cmd4"},
 			},
 			wantErr: false,
-			want:    "#!/bin/bash\n\nset -o errexit\nset -o nounset\nset -o pipefail\n\n# preinstall\ncmd1\ncmd2\ncmd3\n\n\n# run kubespray\necho $TEST\n\n# postinstall\ncmd4\n\n",
+			want:    renderHeader + "# preinstall\nif stage_start prehook-0; then\ncmd1\nstage_end 0\nfi\nif stage_start prehook-1; then\ncmd2\nstage_end 0\nfi\nif stage_start prehook-2; then\ncmd3\nstage_end 0\nfi\n\n\n# run kubespray\nif stage_start action; then\necho $TEST\nstage_end 0\nfi\n\n# postinstall\nif stage_start posthook-0; then\ncmd4\nstage_end 0\nfi\n\n",
 		},
 	}
 	for _, tt := range tests {
@@ -552,3 +677,50 @@ func TestEntryPoint_Render(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStageEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []StageEvent
+	}{
+		{
+			name: "no markers",
+			log:  "PLAY [all] ***\nok: [node1]\n",
+			want: []StageEvent{},
+		},
+		{
+			name: "start and end markers",
+			log: "[kubean-stage] start prehook-0 1700000000\nhello\n[kubean-stage] end prehook-0 0 1700000010\n" +
+				"[kubean-stage] start action 1700000011\nfatal: [node1]: FAILED!\n[kubean-stage] end action 2 1700000020\n",
+			want: []StageEvent{
+				{Phase: StageStartPhase, Name: "prehook-0", Time: time.Unix(1700000000, 0)},
+				{Phase: StageEndPhase, Name: "prehook-0", ExitCode: 0, Time: time.Unix(1700000010, 0)},
+				{Phase: StageStartPhase, Name: "action", Time: time.Unix(1700000011, 0)},
+				{Phase: StageEndPhase, Name: "action", ExitCode: 2, Time: time.Unix(1700000020, 0)},
+			},
+		},
+		{
+			name: "malformed markers are ignored",
+			log:  "[kubean-stage] start action\n[kubean-stage] end action x 1700000020\n[kubean-stage] stop action 1700000020\n[kubean-stage] end action 1700000020\n",
+			want: []StageEvent{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseStageEvents(strings.NewReader(test.log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStageName(t *testing.T) {
+	if PreHookStageName(1) != "prehook-1" || PostHookStageName(0) != "posthook-0" {
+		t.Fatal()
+	}
+}
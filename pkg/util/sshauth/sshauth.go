@@ -0,0 +1,160 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package sshauth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+)
+
+// The keys of the ssh credentials in the secret, which is mounted in MountPath of the spray job. The entrypoint
+// passes the files to ansible-playbook by their paths, so the credentials are never rendered in the entrypoint.
+const (
+	// PrivateKeyKey is the private key of all the hosts.
+	PrivateKeyKey = "ssh-privatekey"
+	// GroupPrivateKeyPrefix prefixes the private key of a host group, e.g. ssh-privatekey.kube_control_plane,
+	// which overrides PrivateKeyKey for the hosts of the group.
+	GroupPrivateKeyPrefix = PrivateKeyKey + "."
+	// CertificateKey is the certificate of the private key signed by the ssh CA.
+	CertificateKey = "ssh-certificate"
+	// PasswordKey is the ssh password, i.e. ansible_password.
+	PasswordKey = "ansible_password"
+	// BecomePasswordKey is the sudo password, i.e. ansible_become_password.
+	BecomePasswordKey = "become_password"
+
+	// MountPath is where the secret of the credentials is mounted in the spray job.
+	MountPath = "/auth"
+)
+
+var groupNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsCredentialKey reports whether the key of the secret is one of the ssh credentials.
+func IsCredentialKey(key string) bool {
+	switch key {
+	case PrivateKeyKey, CertificateKey, PasswordKey, BecomePasswordKey:
+		return true
+	}
+	group, ok := strings.CutPrefix(key, GroupPrivateKeyPrefix)
+	return ok && groupNameRegexp.MatchString(group)
+}
+
+// Provider fetches the ssh credentials of the cluster when the job starts, so that short-lived credentials,
+// e.g. the certificates signed by a vault, are issued for each ClusterOperation.
+type Provider interface {
+	// Fetch returns the credentials keyed by the credential keys.
+	Fetch(ctx context.Context, cluster *clusterv1alpha1.Cluster) (map[string][]byte, error)
+}
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]Provider{}
+)
+
+// Register makes the provider available by the name in Cluster spec.sshAuthProvider, and replaces the provider
+// registered with the same name.
+func Register(name string, provider Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[name] = provider
+}
+
+// Get returns the provider registered with the name.
+func Get(name string) (Provider, error) {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("ssh auth provider %q is not registered, registered providers are %v", name, names())
+	}
+	return provider, nil
+}
+
+// Names returns the sorted names of the registered providers.
+func Names() []string {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	return names()
+}
+
+func names() []string {
+	result := make([]string, 0, len(providers))
+	for name := range providers {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Fetch fetches the credentials of the cluster by its provider, and rejects the result without any credential key.
+func Fetch(ctx context.Context, cluster *clusterv1alpha1.Cluster) (map[string][]byte, error) {
+	if cluster.Spec.SSHAuthProvider == nil {
+		return nil, fmt.Errorf("cluster %s has no ssh auth provider", cluster.Name)
+	}
+	provider, err := Get(cluster.Spec.SSHAuthProvider.Name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := provider.Fetch(ctx, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("ssh auth provider %s: %w", cluster.Spec.SSHAuthProvider.Name, err)
+	}
+	for key := range data {
+		if !IsCredentialKey(key) {
+			return nil, fmt.Errorf("ssh auth provider %s returns the unknown key %q", cluster.Spec.SSHAuthProvider.Name, key)
+		}
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("ssh auth provider %s returns no credentials for cluster %s", cluster.Spec.SSHAuthProvider.Name, cluster.Name)
+	}
+	return data, nil
+}
+
+const (
+	FileProviderName = "file"
+	// FileProviderDir is where the operator mounts the credentials of the file provider, e.g. by a vault agent.
+	FileProviderDir = "/etc/kubean/ssh-auth"
+)
+
+// FileProvider reads the credential files in the directory named after the cluster, e.g.
+// /etc/kubean/ssh-auth/mycluster/ssh-privatekey, at the time the job starts. The files which are not
+// credential keys are ignored, so that the agents refreshing the files may keep their state aside.
+type FileProvider struct {
+	Dir string
+}
+
+func (p *FileProvider) Fetch(_ context.Context, cluster *clusterv1alpha1.Cluster) (map[string][]byte, error) {
+	if !filepath.IsLocal(cluster.Name) {
+		return nil, fmt.Errorf("invalid cluster name %q", cluster.Name)
+	}
+	dir := filepath.Join(p.Dir, cluster.Name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	for _, entry := range entries {
+		if !IsCredentialKey(entry.Name()) {
+			continue
+		}
+		// os.ReadFile follows the symlinks of the projected volumes.
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		data[entry.Name()] = content
+	}
+	return data, nil
+}
+
+func init() {
+	Register(FileProviderName, &FileProvider{Dir: FileProviderDir})
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package sshauth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsCredentialKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "ssh-privatekey", want: true},
+		{key: "ssh-certificate", want: true},
+		{key: "ansible_password", want: true},
+		{key: "become_password", want: true},
+		{key: "ssh-privatekey.kube_control_plane", want: true},
+		{key: "ssh-privatekey.", want: false},
+		{key: "ssh-privatekey.kube-node", want: false},
+		{key: "ssh-privatekey..", want: false},
+		{key: "ssh-private-key", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if IsCredentialKey(test.key) != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+type staticProvider map[string][]byte
+
+func (p staticProvider) Fetch(_ context.Context, _ *clusterv1alpha1.Cluster) (map[string][]byte, error) {
+	return p, nil
+}
+
+func TestFetch(t *testing.T) {
+	Register("static", staticProvider{"ansible_password": []byte("secret")})
+	Register("unknown-key", staticProvider{"password": []byte("secret")})
+	Register("empty", staticProvider{})
+	genCluster := func(provider string) *clusterv1alpha1.Cluster {
+		cluster := &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+		if provider != "" {
+			cluster.Spec.SSHAuthProvider = &clusterv1alpha1.SSHAuthProvider{Name: provider}
+		}
+		return cluster
+	}
+	tests := []struct {
+		name     string
+		provider string
+		wantErr  bool
+	}{
+		{name: "no provider", wantErr: true},
+		{name: "unregistered provider", provider: "unregistered", wantErr: true},
+		{name: "unknown key", provider: "unknown-key", wantErr: true},
+		{name: "no credentials", provider: "empty", wantErr: true},
+		{name: "fetch the credentials", provider: "static"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Fetch(context.Background(), genCluster(test.provider))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if err == nil && string(data["ansible_password"]) != "secret" {
+				t.Fatalf("got %v", data)
+			}
+		})
+	}
+	if names := Names(); len(names) < 4 || names[0] != "empty" {
+		t.Fatalf("got names %v", names)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "cluster1"), 0o700)
+	os.WriteFile(filepath.Join(dir, "cluster1", "ssh-privatekey"), []byte("key"), 0o400)
+	os.WriteFile(filepath.Join(dir, "cluster1", "ssh-certificate"), []byte("cert"), 0o400)
+	os.WriteFile(filepath.Join(dir, "cluster1", "agent.state"), []byte("state"), 0o400)
+	provider := &FileProvider{Dir: dir}
+
+	data, err := provider.Fetch(context.Background(), &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}})
+	if err != nil || len(data) != 2 || string(data["ssh-privatekey"]) != "key" || string(data["ssh-certificate"]) != "cert" {
+		t.Fatalf("got %v, %v", data, err)
+	}
+	if _, err := provider.Fetch(context.Background(), &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2"}}); err == nil {
+		t.Fatal("expect error for the cluster without credentials")
+	}
+	if _, err := provider.Fetch(context.Background(), &clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: ".."}}); err == nil {
+		t.Fatal("expect error for the cluster name out of the dir")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/kubean-io/kubean/pkg/metrics"
+	"github.com/kubean-io/kubean/pkg/util/ansible"
+)
+
+// ValidateHosts checks the yaml syntax, the groups and the host variables of the inventory.
+func ValidateHosts(data string) []error {
+	inventory, err := ansible.ParseInventory([]byte(data))
+	if err != nil {
+		return []error{fmt.Errorf("%s: %w", constants.Hosts_yml, err)}
+	}
+	return prefixErrors(constants.Hosts_yml, inventory.Validate())
+}
+
+// ValidateVars checks the yaml syntax and the variables combination of the group vars.
+func ValidateVars(data string) []error {
+	vars, err := ansible.ParseVars([]byte(data))
+	if err != nil {
+		return []error{fmt.Errorf("%s: %w", constants.Group_vars_yml, err)}
+	}
+	return prefixErrors(constants.Group_vars_yml, vars.Validate())
+}
+
+// ValidateConfigMap validates the inventory or the vars in the ConfigMap according to its config-type label.
+func ValidateConfigMap(configMap *corev1.ConfigMap) []error {
+	var key string
+	var validate func(string) []error
+	switch configMap.Labels[constants.KubeanConfigTypeLabelKey] {
+	case constants.ConfigTypeInventory:
+		key, validate = constants.Hosts_yml, ValidateHosts
+	case constants.ConfigTypeVars:
+		key, validate = constants.Group_vars_yml, ValidateVars
+	default:
+		return nil
+	}
+	data, ok := configMap.Data[key]
+	if !ok {
+		return []error{fmt.Errorf("configmap %s/%s has no %s", configMap.Namespace, configMap.Name, key)}
+	}
+	return validate(data)
+}
+
+func prefixErrors(prefix string, errs []error) []error {
+	result := make([]error, 0, len(errs))
+	for _, err := range errs {
+		result = append(result, fmt.Errorf("%s: %w", prefix, err))
+	}
+	return result
+}
+
+// reviewFunc validates the object of the admission request, and returns the warnings and the reasons of the denial.
+type reviewFunc func(request *admissionv1.AdmissionRequest) (warnings []string, errs []error, err error)
+
+func serveReview(writer http.ResponseWriter, request *http.Request, review reviewFunc) {
+	decision := metrics.InvalidDecision
+	defer func() {
+		metrics.AdmissionReviewsTotal.WithLabelValues(decision).Inc()
+	}()
+	admissionReviewReq := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(request.Body).Decode(&admissionReviewReq); err != nil {
+		klog.ErrorS(err, "parse http body to AdmissionReview")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse http body to AdmissionReview")))
+		return
+	}
+	if admissionReviewReq.Request == nil || len(admissionReviewReq.Request.Object.Raw) == 0 {
+		klog.Error("parse http body to AdmissionReview but no object")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("parse http body to AdmissionReview but no object"))
+		return
+	}
+	warnings, errs, err := review(admissionReviewReq.Request)
+	if err != nil {
+		klog.ErrorS(err, "review the object but failed", "kind", admissionReviewReq.Request.Kind.Kind, "name", admissionReviewReq.Request.Name)
+		decision = metrics.ErrorDecision
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	admissionReviewResponse := admissionv1.AdmissionReview{
+		TypeMeta: admissionReviewReq.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{
+			UID:      admissionReviewReq.Request.UID,
+			Allowed:  true,
+			Warnings: warnings,
+		},
+	}
+	decision = metrics.AllowedDecision
+	if len(errs) > 0 {
+		admissionReviewResponse.Response.Allowed = false
+		decision = metrics.DeniedDecision
+		admissionReviewResponse.Response.Result = &metav1.Status{
+			Message: fmt.Sprintf("Not Accept %s %s, because %s", admissionReviewReq.Request.Kind.Kind, admissionReviewReq.Request.Name, utilerrors.NewAggregate(errs).Error()),
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	httpResult, _ := json.Marshal(admissionReviewResponse)
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(httpResult)
+}
+
+// ClusterReviewHandler validates the inventory and the vars referenced by the Cluster.
+type ClusterReviewHandler struct {
+	ClientSet kubernetes.Interface
+}
+
+func (handler ClusterReviewHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	serveReview(writer, request, handler.review)
+}
+
+func (handler ClusterReviewHandler) review(request *admissionv1.AdmissionRequest) ([]string, []error, error) {
+	cluster := clusterv1alpha1.Cluster{}
+	if err := json.Unmarshal(request.Object.Raw, &cluster); err != nil {
+		return nil, nil, fmt.Errorf("parse AdmissionReview.Object.Raw in Cluster but failed: %w", err)
+	}
+	warnings := SSHAuthRefWarnings(handler.ClientSet, &cluster)
+	errs := ValidateTenant(&cluster)
+	errs = append(errs, ValidateSSHAuth(&cluster)...)
+	// the user must be allowed to operate the cluster by the current tenant and by the new one.
+	tenants := []*clusterv1alpha1.Tenant{cluster.Spec.Tenant}
+	if request.Operation == admissionv1.Update && len(request.OldObject.Raw) > 0 {
+		oldCluster := clusterv1alpha1.Cluster{}
+		if err := json.Unmarshal(request.OldObject.Raw, &oldCluster); err != nil {
+			return nil, nil, fmt.Errorf("parse AdmissionReview.OldObject.Raw in Cluster but failed: %w", err)
+		}
+		if oldCluster.Spec.Tenant != nil && !reflect.DeepEqual(oldCluster.Spec.Tenant, cluster.Spec.Tenant) {
+			tenants = append(tenants, oldCluster.Spec.Tenant)
+		}
+	}
+	for _, tenant := range tenants {
+		reason, err := AuthorizeTenant(handler.ClientSet, request.UserInfo, cluster.Name, tenant)
+		if err != nil {
+			return nil, nil, fmt.Errorf("review the tenant of Cluster but failed: %w", err)
+		}
+		if reason != "" {
+			errs = append(errs, fmt.Errorf("spec.tenant: %s", reason))
+		}
+	}
+	for _, ref := range []struct {
+		field    string
+		ref      *apis.ConfigMapRef
+		key      string
+		validate func(string) []error
+	}{
+		{field: "spec.hostsConfRef", ref: cluster.Spec.HostsConfRef, key: constants.Hosts_yml, validate: ValidateHosts},
+		{field: "spec.varsConfRef", ref: cluster.Spec.VarsConfRef, key: constants.Group_vars_yml, validate: ValidateVars},
+	} {
+		if ref.ref.IsEmpty() {
+			continue
+		}
+		configMap, err := handler.ClientSet.CoreV1().ConfigMaps(ref.ref.NameSpace).Get(context.Background(), ref.ref.Name, metav1.GetOptions{})
+		if err != nil {
+			// the ConfigMap may be created after the Cluster, and it is validated by itself when labelled.
+			warnings = append(warnings, fmt.Sprintf("%s: configmap %s/%s is not validated, %v", ref.field, ref.ref.NameSpace, ref.ref.Name, err))
+			continue
+		}
+		data, ok := configMap.Data[ref.key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: configmap %s/%s has no %s", ref.field, ref.ref.NameSpace, ref.ref.Name, ref.key))
+			continue
+		}
+		errs = append(errs, prefixErrors(ref.field, ref.validate(data))...)
+	}
+	return warnings, errs, nil
+}
+
+// ConfigMapReviewHandler validates the ConfigMaps labelled as the kubean inventory or vars.
+type ConfigMapReviewHandler struct{}
+
+func (handler ConfigMapReviewHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	serveReview(writer, request, handler.review)
+}
+
+func (handler ConfigMapReviewHandler) review(request *admissionv1.AdmissionRequest) ([]string, []error, error) {
+	configMap := corev1.ConfigMap{}
+	if err := json.Unmarshal(request.Object.Raw, &configMap); err != nil {
+		return nil, nil, fmt.Errorf("parse AdmissionReview.Object.Raw in ConfigMap but failed: %w", err)
+	}
+	if configMap.Namespace == "" {
+		configMap.Namespace = request.Namespace
+	}
+	return nil, ValidateConfigMap(&configMap), nil
+}
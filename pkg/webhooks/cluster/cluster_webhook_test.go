@@ -0,0 +1,367 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const validHosts = `all:
+  hosts:
+    node1:
+      ip: 172.30.41.10
+      ansible_host: 172.30.41.10
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+`
+
+func review(handler http.Handler, object interface{}) (int, *admissionv1.AdmissionReview) {
+	return reviewAs(handler, authenticationv1.UserInfo{}, object)
+}
+
+func reviewAs(handler http.Handler, userInfo authenticationv1.UserInfo, object interface{}) (int, *admissionv1.AdmissionReview) {
+	raw, _ := json.Marshal(object)
+	body, _ := json.Marshal(admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{UID: "uid-1", Namespace: "kubean-system", UserInfo: userInfo, Object: runtime.RawExtension{Raw: raw}},
+	})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	result := &admissionv1.AdmissionReview{}
+	json.Unmarshal(recorder.Body.Bytes(), result)
+	return recorder.Code, result
+}
+
+func TestValidateConfigMap(t *testing.T) {
+	tests := []struct {
+		name      string
+		configMap *corev1.ConfigMap
+		want      int
+	}{
+		{
+			name:      "not labelled",
+			configMap: &corev1.ConfigMap{Data: map[string]string{constants.Hosts_yml: "all: ["}},
+			want:      0,
+		},
+		{
+			name: "valid inventory",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{constants.KubeanConfigTypeLabelKey: constants.ConfigTypeInventory}},
+				Data:       map[string]string{constants.Hosts_yml: validHosts},
+			},
+			want: 0,
+		},
+		{
+			name: "inventory without hosts.yml",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{constants.KubeanConfigTypeLabelKey: constants.ConfigTypeInventory}},
+			},
+			want: 1,
+		},
+		{
+			name: "inventory yaml syntax",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{constants.KubeanConfigTypeLabelKey: constants.ConfigTypeInventory}},
+				Data:       map[string]string{constants.Hosts_yml: "all: ["},
+			},
+			want: 1,
+		},
+		{
+			name: "unsupported network plugin",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{constants.KubeanConfigTypeLabelKey: constants.ConfigTypeVars}},
+				Data:       map[string]string{constants.Group_vars_yml: "kube_network_plugin: canal\n"},
+			},
+			want: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if errs := ValidateConfigMap(test.configMap); len(errs) != test.want {
+				t.Fatalf("got %v", errs)
+			}
+		})
+	}
+}
+
+func TestConfigMapReviewHandler(t *testing.T) {
+	code, result := review(ConfigMapReviewHandler{}, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Labels: map[string]string{constants.KubeanConfigTypeLabelKey: constants.ConfigTypeVars}},
+		Data:       map[string]string{constants.Group_vars_yml: "container_manager: containerd\netcd_deployment_type: docker\n"},
+	})
+	if code != http.StatusOK || result.Response.Allowed || result.Response.UID != "uid-1" ||
+		!strings.Contains(result.Response.Result.Message, "etcd_deployment_type docker requires container_manager docker") {
+		t.Fatalf("got %d %+v", code, result.Response)
+	}
+	recorder := httptest.NewRecorder()
+	ConfigMapReviewHandler{}.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("got %d", recorder.Code)
+	}
+}
+
+func TestClusterReviewHandler(t *testing.T) {
+	clientSet := clientsetfake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "kubean-system"},
+			Data:       map[string]string{constants.Hosts_yml: validHosts},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "bad-hosts-conf", Namespace: "kubean-system"},
+			Data:       map[string]string{constants.Hosts_yml: "all:\n  hosts:\n    node1:\n      ip: 10.0.0.256\n"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Namespace: "kubean-system"},
+			Data:       map[string]string{constants.Group_vars_yml: "kube_network_plugin: calico\n"},
+		},
+	)
+	newCluster := func(hosts, vars string) *clusterv1alpha1.Cluster {
+		return &clusterv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1"},
+			Spec: clusterv1alpha1.Spec{
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: hosts},
+				VarsConfRef:  &apis.ConfigMapRef{NameSpace: "kubean-system", Name: vars},
+			},
+		}
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "valid",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("hosts-conf", "vars-conf"))
+				return code == http.StatusOK && result.Response.Allowed && len(result.Response.Warnings) == 0
+			},
+			want: true,
+		},
+		{
+			name: "configmap not found",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("hosts-conf", "not-exist"))
+				return code == http.StatusOK && result.Response.Allowed && len(result.Response.Warnings) == 1 &&
+					strings.HasPrefix(result.Response.Warnings[0], "spec.varsConfRef: configmap kubean-system/not-exist is not validated")
+			},
+			want: true,
+		},
+		{
+			name: "invalid inventory",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("bad-hosts-conf", "vars-conf"))
+				return code == http.StatusOK && !result.Response.Allowed &&
+					strings.Contains(result.Response.Result.Message, `spec.hostsConfRef: hosts.yml: host node1 has an invalid ip "10.0.0.256"`)
+			},
+			want: true,
+		},
+		{
+			name: "configmap without the key",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("vars-conf", "vars-conf"))
+				return code == http.StatusOK && !result.Response.Allowed &&
+					strings.Contains(result.Response.Result.Message, "spec.hostsConfRef: configmap kubean-system/vars-conf has no hosts.yml")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestClusterReviewHandlerTenant(t *testing.T) {
+	clientSet := clientsetfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "team-a"},
+		Data:       map[string]string{constants.Hosts_yml: validHosts},
+	})
+	// only alice is allowed to operate the clusters in team-a.
+	clientSet.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "alice" && attributes.Namespace == "team-a" &&
+			attributes.Verb == OperateVerb && attributes.Group == "kubean.io" && attributes.Resource == "clusters" && attributes.Name == "cluster1"
+		return true, sar, nil
+	})
+	newCluster := func(namespace string) *clusterv1alpha1.Cluster {
+		return &clusterv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1"},
+			Spec: clusterv1alpha1.Spec{
+				Tenant:       &clusterv1alpha1.Tenant{Namespace: "team-a", ServiceAccountName: "team-a-sa"},
+				HostsConfRef: &apis.ConfigMapRef{NameSpace: namespace, Name: "hosts-conf"},
+			},
+		}
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "allowed user",
+			args: func() bool {
+				code, result := reviewAs(ClusterReviewHandler{ClientSet: clientSet}, authenticationv1.UserInfo{Username: "alice"}, newCluster("team-a"))
+				return code == http.StatusOK && result.Response.Allowed
+			},
+			want: true,
+		},
+		{
+			name: "denied user",
+			args: func() bool {
+				code, result := reviewAs(ClusterReviewHandler{ClientSet: clientSet}, authenticationv1.UserInfo{Username: "bob"}, newCluster("team-a"))
+				return code == http.StatusOK && !result.Response.Allowed &&
+					strings.Contains(result.Response.Result.Message, "spec.tenant: user bob is not allowed to operate cluster cluster1 in the namespace team-a of the tenant")
+			},
+			want: true,
+		},
+		{
+			name: "ref out of the tenant namespace",
+			args: func() bool {
+				code, result := reviewAs(ClusterReviewHandler{ClientSet: clientSet}, authenticationv1.UserInfo{Username: "alice"}, newCluster("team-b"))
+				return code == http.StatusOK && !result.Response.Allowed &&
+					strings.Contains(result.Response.Result.Message, "spec.hostsConfRef: must be in the namespace team-a of the tenant")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestClusterReviewHandlerSSHAuth(t *testing.T) {
+	clientSet := clientsetfake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "kubean-system"},
+			Data:       map[string]string{constants.Hosts_yml: validHosts},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Namespace: "kubean-system"},
+			Data:       map[string]string{constants.Group_vars_yml: "kube_network_plugin: calico\n"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ssh-auth", Namespace: "kubean-system"},
+			Data:       map[string][]byte{"ssh-privatekey.kube_control_plane": []byte("key"), "ansible_password": []byte("password")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "misspelled-ssh-auth", Namespace: "kubean-system"},
+			Data:       map[string][]byte{"ssh-private-key": []byte("key")},
+		},
+	)
+	newCluster := func(sshAuthRef string, provider *clusterv1alpha1.SSHAuthProvider) *clusterv1alpha1.Cluster {
+		cluster := &clusterv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1"},
+			Spec: clusterv1alpha1.Spec{
+				HostsConfRef:    &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-conf"},
+				VarsConfRef:     &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "vars-conf"},
+				SSHAuthProvider: provider,
+			},
+		}
+		if sshAuthRef != "" {
+			cluster.Spec.SSHAuthRef = &apis.SecretRef{NameSpace: "kubean-system", Name: sshAuthRef}
+		}
+		return cluster
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "password and per-group keys in sshAuthRef",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("ssh-auth", nil))
+				return code == http.StatusOK && result.Response.Allowed && len(result.Response.Warnings) == 0
+			},
+			want: true,
+		},
+		{
+			name: "warn about the key which is not an ssh credential",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("misspelled-ssh-auth", nil))
+				return code == http.StatusOK && result.Response.Allowed && len(result.Response.Warnings) == 1 &&
+					strings.Contains(result.Response.Warnings[0], `has the key "ssh-private-key"`)
+			},
+			want: true,
+		},
+		{
+			name: "builtin provider",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("", &clusterv1alpha1.SSHAuthProvider{Name: "file"}))
+				return code == http.StatusOK && result.Response.Allowed
+			},
+			want: true,
+		},
+		{
+			name: "provider registered only in kubean-operator",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("", &clusterv1alpha1.SSHAuthProvider{Name: "vault"}))
+				return code == http.StatusOK && result.Response.Allowed
+			},
+			want: true,
+		},
+		{
+			name: "invalid provider name",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("", &clusterv1alpha1.SSHAuthProvider{Name: "Vault/KV"}))
+				return code == http.StatusOK && !result.Response.Allowed &&
+					strings.Contains(result.Response.Result.Message, `spec.sshAuthProvider.name: Invalid value: "Vault/KV"`)
+			},
+			want: true,
+		},
+		{
+			name: "both sshAuthRef and provider",
+			args: func() bool {
+				code, result := review(ClusterReviewHandler{ClientSet: clientSet}, newCluster("ssh-auth", &clusterv1alpha1.SSHAuthProvider{Name: "file"}))
+				return code == http.StatusOK && !result.Response.Allowed &&
+					strings.Contains(result.Response.Result.Message, "spec.sshAuthProvider: must not be set together with spec.sshAuthRef")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubean-io/kubean/pkg/util/sshauth"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ValidateSSHAuth checks the cluster takes the ssh credentials either from the secret or from a provider. The providers
+// are registered in kubean-operator rather than kubean-admission, so only the name of the provider is checked here and
+// the unregistered provider fails the job when it starts.
+func ValidateSSHAuth(cluster *clusterv1alpha1.Cluster) []error {
+	provider := cluster.Spec.SSHAuthProvider
+	if provider == nil {
+		return nil
+	}
+	errs := make([]error, 0)
+	if !cluster.Spec.SSHAuthRef.IsEmpty() {
+		errs = append(errs, fmt.Errorf("spec.sshAuthProvider: must not be set together with spec.sshAuthRef"))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(provider.Name) {
+		errs = append(errs, fmt.Errorf("spec.sshAuthProvider.name: Invalid value: %q: %s", provider.Name, msg))
+	}
+	return errs
+}
+
+// SSHAuthRefWarnings warns about the keys of the secret in sshAuthRef which are not ssh credentials, since they are
+// ignored by the jobs, e.g. the misspelled `ssh-private-key`.
+func SSHAuthRefWarnings(client kubernetes.Interface, cluster *clusterv1alpha1.Cluster) []string {
+	ref := cluster.Spec.SSHAuthRef
+	if ref.IsEmpty() {
+		return nil
+	}
+	secret, err := client.CoreV1().Secrets(ref.NameSpace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		// the Secret may be created after the Cluster.
+		return []string{fmt.Sprintf("spec.sshAuthRef: secret %s/%s is not validated, %v", ref.NameSpace, ref.Name, err)}
+	}
+	warnings := make([]string, 0)
+	for key := range secret.Data {
+		if !sshauth.IsCredentialKey(key) {
+			warnings = append(warnings, fmt.Sprintf("spec.sshAuthRef: secret %s/%s has the key %q which is not an ssh credential", ref.NameSpace, ref.Name, key))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// OperateVerb is the verb on the kubean.io clusters which the user must be allowed in the tenant namespace,
+// to change the tenant cluster and to create or change its ClusterOperations.
+const OperateVerb = "operate"
+
+// AuthorizeTenant reviews whether the user may operate the cluster in the namespace of the tenant,
+// and returns the reason if not. The cluster without tenant is operated by anyone.
+func AuthorizeTenant(client kubernetes.Interface, userInfo authenticationv1.UserInfo, clusterName string, tenant *clusterv1alpha1.Tenant) (string, error) {
+	if tenant == nil {
+		return "", nil
+	}
+	extra := make(map[string]authorizationv1.ExtraValue, len(userInfo.Extra))
+	for key, value := range userInfo.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review, err := client.AuthorizationV1().SubjectAccessReviews().Create(context.Background(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: tenant.Namespace,
+				Verb:      OperateVerb,
+				Group:     clusterv1alpha1.SchemeGroupVersion.Group,
+				Resource:  "clusters",
+				Name:      clusterName,
+			},
+			User:   userInfo.Username,
+			Groups: userInfo.Groups,
+			UID:    userInfo.UID,
+			Extra:  extra,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	if review.Status.Allowed {
+		return "", nil
+	}
+	return fmt.Sprintf("user %s is not allowed to %s cluster %s in the namespace %s of the tenant", userInfo.Username, OperateVerb, clusterName, tenant.Namespace), nil
+}
+
+// ValidateTenant checks the tenant of the cluster and the refs of the cluster are in the tenant namespace.
+func ValidateTenant(cluster *clusterv1alpha1.Cluster) []error {
+	tenant := cluster.Spec.Tenant
+	if tenant == nil {
+		return nil
+	}
+	errs := make([]error, 0)
+	for _, msg := range validation.IsDNS1123Label(tenant.Namespace) {
+		errs = append(errs, fmt.Errorf("spec.tenant.namespace: %s", msg))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(tenant.ServiceAccountName) {
+		errs = append(errs, fmt.Errorf("spec.tenant.serviceAccountName: %s", msg))
+	}
+	for _, ref := range []struct {
+		field string
+		ref   *apis.DataRef
+	}{
+		{field: "spec.hostsConfRef", ref: cluster.Spec.HostsConfRef},
+		{field: "spec.varsConfRef", ref: cluster.Spec.VarsConfRef},
+		{field: "spec.kubeconfRef", ref: cluster.Spec.KubeConfRef},
+		{field: "spec.sshAuthRef", ref: cluster.Spec.SSHAuthRef},
+		{field: "spec.preCheckRef", ref: cluster.Spec.PreCheckRef},
+	} {
+		if !ref.ref.IsEmpty() && ref.ref.NameSpace != tenant.Namespace {
+			errs = append(errs, fmt.Errorf("%s: must be in the namespace %s of the tenant", ref.field, tenant.Namespace))
+		}
+	}
+	return errs
+}
@@ -20,18 +20,31 @@ import (
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
 	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
 	"github.com/kubean-io/kubean-api/constants"
+	clusterClientSet "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned"
 	clusterOperationClientSet "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned"
+	clusterOperationPolicyClientSet "github.com/kubean-io/kubean-api/generated/clusteroperationpolicy/clientset/versioned"
+	manifestClientSet "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 
+	clustercontroller "github.com/kubean-io/kubean/pkg/controllers/cluster"
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/metrics"
 	"github.com/kubean-io/kubean/pkg/util"
+	clusterwebhook "github.com/kubean-io/kubean/pkg/webhooks/cluster"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 var (
@@ -46,12 +59,22 @@ var (
 	// #nosec
 	CAStoreSecret = "webhook-http-ca-secret"
 
-	WebHookPath             = "/webhook"
-	WebhookSVCNamespace, _  = os.LookupEnv("WEBHOOK_SERVICE_NAMESPACE")
-	WebhookSVCName, _       = os.LookupEnv("WEBHOOK_SERVICE_NAME")
-	ClusterOperationWebhook = "kubean-admission-webhook"
-	FailurePolicy, _        = os.LookupEnv("WEBHOOK_FAILURE_POLICY")
-	dnsNames                = []string{
+	WebHookPath                 = "/webhook"
+	MutatingWebHookPath         = "/mutate"
+	ClusterWebHookPath          = "/validate-cluster"
+	ConfigMapWebHookPath        = "/validate-configmap"
+	PolicyWebHookPath           = "/validate-clusteroperationpolicy"
+	ScheduleWebHookPath         = "/validate-clusteroperationschedule"
+	MutatingScheduleWebHookPath = "/mutate-clusteroperationschedule"
+	WebhookSVCNamespace, _      = os.LookupEnv("WEBHOOK_SERVICE_NAMESPACE")
+	WebhookSVCName, _           = os.LookupEnv("WEBHOOK_SERVICE_NAME")
+	ClusterOperationWebhook     = "kubean-admission-webhook"
+	// FailurePolicy is the failurePolicy of the configmap webhook. The webhooks of the kubean resources always fail closed,
+	// since the tenant, the authorization, the ClusterOperationPolicies and the approval are only checked by them.
+	FailurePolicy, _ = os.LookupEnv("WEBHOOK_FAILURE_POLICY")
+	// OperatorServiceAccount is the service account of kubean-operator in the namespace of the webhook service.
+	OperatorServiceAccount, _ = os.LookupEnv("OPERATOR_SERVICE_ACCOUNT")
+	dnsNames                  = []string{
 		WebhookSVCName,
 		WebhookSVCName + "." + WebhookSVCNamespace,
 		WebhookSVCName + "." + WebhookSVCNamespace + "." + "svc",
@@ -196,11 +219,113 @@ func (p PingHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request
 }
 
 type AdmissionReviewHandler struct {
-	KubeanClusterOpsSet clusterOperationClientSet.Interface
+	ClientSet                 kubernetes.Interface
+	KubeanClusterSet          clusterClientSet.Interface
+	KubeanClusterOpsSet       clusterOperationClientSet.Interface
+	KubeanClusterOpsPolicySet clusterOperationPolicyClientSet.Interface
+}
+
+// validate checks the spec of the clusterOps and the configmaps and secrets it refers to,
+// the same as the operator does before it creates the job.
+func (handler AdmissionReviewHandler) validate(clusterOps *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) (field.ErrorList, error) {
+	if errs := clusteropscontroller.ValidateSpec(clusterOps); len(errs) > 0 {
+		return errs, nil
+	}
+	if errs := clusteropscontroller.ValidateApproval(clusterOps, nil, userInfo); len(errs) > 0 {
+		return errs, nil
+	}
+	if handler.ClientSet == nil || handler.KubeanClusterSet == nil {
+		return nil, nil
+	}
+	cluster, err := handler.KubeanClusterSet.KubeanV1alpha1().Clusters().Get(context.Background(), clusterOps.Spec.Cluster, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(field.NewPath("spec", "cluster"), clusterOps.Spec.Cluster)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if errs, err := handler.authorize(cluster, clusterOps, userInfo); err != nil || len(errs) > 0 {
+		return errs, err
+	}
+	if errs, err := handler.validatePolicies(clusterOps, userInfo); err != nil || len(errs) > 0 {
+		return errs, err
+	}
+	configMapExist := func(namespace, name string) bool {
+		_, err := handler.ClientSet.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		return !apierrors.IsNotFound(err)
+	}
+	secretExist := func(namespace, name string) bool {
+		_, err := handler.ClientSet.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		return !apierrors.IsNotFound(err)
+	}
+	return clusteropscontroller.ValidateDataRef(cluster, clusterOps, configMapExist, secretExist), nil
+}
+
+// authorize checks the user is allowed to operate the tenant cluster of the clusterOps,
+// and the refs of the clusterOps are in the tenant namespace.
+func (handler AdmissionReviewHandler) authorize(cluster *clusterv1alpha1.Cluster, clusterOps *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) (field.ErrorList, error) {
+	errs := clusteropscontroller.ValidateTenant(cluster, clusterOps)
+	reason, err := clusterwebhook.AuthorizeTenant(handler.ClientSet, userInfo, cluster.Name, cluster.Spec.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "cluster"), reason))
+	}
+	return errs, nil
+}
+
+// validatePolicies checks the ClusterOperationPolicies allow the user to run the actions of the clusterOps. The clusterOps
+// of the schedule is checked on behalf of its requester, and the one created by kubean-operator for the reconciled
+// nodes is not checked, whose hosts are authorized at the update of the cluster. Any other clusterOps of kubean-operator
+// is bound to no policy, otherwise the policy would allow everyone who creates the schedule.
+func (handler AdmissionReviewHandler) validatePolicies(clusterOps *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) (field.ErrorList, error) {
+	if handler.KubeanClusterOpsPolicySet == nil {
+		return nil, nil
+	}
+	if isOperator(userInfo) {
+		if clusterOps.Labels[clustercontroller.NodeReconcileLabelKey] != "" {
+			return nil, nil
+		}
+		userInfo = authenticationv1.UserInfo{Username: userInfo.Username}
+	}
+	policies, err := handler.KubeanClusterOpsPolicySet.KubeanV1alpha1().ClusterOperationPolicies().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return clusteropscontroller.ValidatePolicies(policies.Items, clusterOps, userInfo), nil
+}
+
+// authorizeUpdate checks the user is allowed to operate the tenant cluster of the updated clusterOps,
+// e.g. to cancel it. The clusterOps of the removed cluster is not checked.
+func (handler AdmissionReviewHandler) authorizeUpdate(clusterOps *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) (field.ErrorList, error) {
+	if handler.ClientSet == nil || handler.KubeanClusterSet == nil {
+		return nil, nil
+	}
+	cluster, err := handler.KubeanClusterSet.KubeanV1alpha1().Clusters().Get(context.Background(), clusterOps.Spec.Cluster, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return handler.authorize(cluster, clusterOps, userInfo)
+}
+
+// isQueueMode returns whether the concurrent ClusterOperations are admitted and queued by the operator.
+func (handler AdmissionReviewHandler) isQueueMode() bool {
+	if handler.ClientSet == nil {
+		return false
+	}
+	return util.FetchKubeanConfigProperty(handler.ClientSet).IsClusterOperationQueueMode()
 }
 
 func (handler AdmissionReviewHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	klog.Warning("receive webhook request")
+	decision := metrics.InvalidDecision
+	defer func() {
+		metrics.AdmissionReviewsTotal.WithLabelValues(decision).Inc()
+	}()
 	admissionReviewReq := admissionv1.AdmissionReview{}
 	if err := json.NewDecoder(request.Body).Decode(&admissionReviewReq); err != nil {
 		klog.ErrorS(err, "parse http body to AdmissionReview")
@@ -228,9 +353,70 @@ func (handler AdmissionReviewHandler) ServeHTTP(writer http.ResponseWriter, requ
 		return
 	}
 	klog.Warningf("receive webhook request for clusterOperation %s", clusterOperation.Name)
+	if admissionReviewReq.Request.Operation == admissionv1.Update {
+		oldClusterOperation := clusteroperationv1alpha1.ClusterOperation{}
+		if err := json.Unmarshal(admissionReviewReq.Request.OldObject.Raw, &oldClusterOperation); err != nil {
+			klog.ErrorS(err, "parse AdmissionReview.OldObject.Raw in ClusterOperation but failed")
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.OldObject.Raw in ClusterOperation but failed")))
+			return
+		}
+		decision = metrics.AllowedDecision
+		invalidErrs := clusteropscontroller.ValidateSpecUpdate(&clusterOperation, &oldClusterOperation)
+		invalidErrs = append(invalidErrs, clusteropscontroller.ValidateApproval(&clusterOperation, &oldClusterOperation, admissionReviewReq.Request.UserInfo)...)
+		if clusterOperation.Annotations[clusteropscontroller.RequesterAnnoKey] != oldClusterOperation.Annotations[clusteropscontroller.RequesterAnnoKey] {
+			invalidErrs = append(invalidErrs, field.Forbidden(field.NewPath("metadata", "annotations").Key(clusteropscontroller.RequesterAnnoKey), "field is immutable"))
+		}
+		if len(invalidErrs) > 0 {
+			decision = metrics.DeniedDecision
+			writeInvalidResponse(writer, &admissionReviewReq, clusterOperation.Name, invalidErrs)
+			return
+		}
+		invalidErrs, err := handler.authorizeUpdate(&clusterOperation, admissionReviewReq.Request.UserInfo)
+		if err != nil {
+			klog.ErrorS(err, "authorize ClusterOperation but failed")
+			decision = metrics.ErrorDecision
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(fmt.Sprint(err, "authorize ClusterOperation but failed")))
+			return
+		}
+		if len(invalidErrs) > 0 {
+			decision = metrics.DeniedDecision
+			writeInvalidResponse(writer, &admissionReviewReq, clusterOperation.Name, invalidErrs)
+			return
+		}
+		httpResult, _ := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: admissionReviewReq.TypeMeta,
+			Response: &admissionv1.AdmissionResponse{UID: admissionReviewReq.Request.UID, Allowed: true},
+		})
+		writer.WriteHeader(http.StatusOK)
+		writer.Write(httpResult)
+		return
+	}
+	// the clusterOps created by kubean-operator for the schedule is validated on behalf of the requester of the schedule.
+	userInfo, invalidErrs := onBehalfOf(clusterOperation.Annotations, admissionReviewReq.Request.UserInfo)
+	if len(invalidErrs) > 0 {
+		decision = metrics.DeniedDecision
+		writeInvalidResponse(writer, &admissionReviewReq, clusterOperation.Name, invalidErrs)
+		return
+	}
+	invalidErrs, err := handler.validate(&clusterOperation, userInfo)
+	if err != nil {
+		klog.ErrorS(err, "validate ClusterOperation but failed")
+		decision = metrics.ErrorDecision
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "validate ClusterOperation but failed")))
+		return
+	}
+	if len(invalidErrs) > 0 {
+		decision = metrics.DeniedDecision
+		writeInvalidResponse(writer, &admissionReviewReq, clusterOperation.Name, invalidErrs)
+		return
+	}
 	requirement, err := labels.NewRequirement(constants.KubeanClusterHasCompleted, selection.DoesNotExist, []string{}) // only when the operation has succeed or failed , then has this label
 	if err != nil {                                                                                                    // todo
 		klog.Error(err)
+		decision = metrics.ErrorDecision
 		return
 	}
 	selector := labels.NewSelector()
@@ -238,6 +424,7 @@ func (handler AdmissionReviewHandler) ServeHTTP(writer http.ResponseWriter, requ
 	opsList, err := handler.KubeanClusterOpsSet.KubeanV1alpha1().ClusterOperations().List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
 	if err != nil {
 		klog.ErrorS(err, "fetch ClusterOperations but failed")
+		decision = metrics.ErrorDecision
 		writer.WriteHeader(http.StatusBadRequest)
 		writer.Write([]byte(fmt.Sprint(err, "fetch ClusterOperations but failed")))
 		return
@@ -250,15 +437,31 @@ func (handler AdmissionReviewHandler) ServeHTTP(writer http.ResponseWriter, requ
 	}
 	// allow default
 	admissionReviewResponse.Response.Allowed = true
+	decision = metrics.AllowedDecision
 
+	queueMode := handler.isQueueMode()
 	for _, ops := range opsList.Items {
-		if ops.Status.Status == clusteroperationv1alpha1.FailedStatus || ops.Status.Status == clusteroperationv1alpha1.SucceededStatus {
+		if ops.Status.Status == clusteroperationv1alpha1.FailedStatus || ops.Status.Status == clusteroperationv1alpha1.SucceededStatus ||
+			ops.Status.Status == clusteroperationv1alpha1.CancelledStatus {
 			continue // ignore
 		}
+		if ops.Spec.DryRun {
+			continue // the dry-run clusterOps changes nothing and does not block others
+		}
 		if ops.Name != clusterOperation.Name && ops.Spec.Cluster == clusterOperation.Spec.Cluster &&
-			(ops.Status.Status == "" || ops.Status.Status == clusteroperationv1alpha1.RunningStatus) { // belongs to the same cluster and still running
+			(ops.Status.Status == "" || ops.Status.Status == clusteroperationv1alpha1.RunningStatus || ops.Status.Status == clusteroperationv1alpha1.PendingStatus ||
+				ops.Status.Status == clusteroperationv1alpha1.PendingApprovalStatus) { // belongs to the same cluster and still running
+			if queueMode {
+				// allow and the operator keeps it Pending until the clusterOps before it finished.
+				admissionReviewResponse.Response.Warnings = []string{
+					fmt.Sprintf("clusterOperation %s is queued, because clusterOperation %s has not completed which belongs to Cluster %s", clusterOperation.Name, ops.Name, clusterOperation.Spec.Cluster),
+				}
+				decision = metrics.QueuedDecision
+				break
+			}
 			// not allow
 			admissionReviewResponse.Response.Allowed = false
+			decision = metrics.DeniedDecision
 			admissionReviewResponse.Response.Result = &metav1.Status{
 				Message: fmt.Sprintf("Not Accept %s , because clusterOperation %s has not completed which belongs to Cluster %s", clusterOperation.Name, ops.Name, clusterOperation.Spec.Cluster),
 				Reason:  metav1.StatusReasonNotAcceptable,
@@ -272,10 +475,35 @@ func (handler AdmissionReviewHandler) ServeHTTP(writer http.ResponseWriter, requ
 	writer.Write(httpResult)
 }
 
-func PrepareWebHookHTTPSServer(KubeanClusterOpsSet clusterOperationClientSet.Interface) *http.Server {
+// writeInvalidResponse denies the clusterOps with the invalid fields.
+func writeInvalidResponse(writer http.ResponseWriter, admissionReviewReq *admissionv1.AdmissionReview, name string, invalidErrs field.ErrorList) {
+	httpResult, _ := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: admissionReviewReq.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{
+			UID:     admissionReviewReq.Request.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("Not Accept %s , because %s", name, invalidErrs.ToAggregate().Error()),
+				Reason:  metav1.StatusReasonInvalid,
+				Code:    http.StatusUnprocessableEntity,
+			},
+		},
+	})
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(httpResult)
+}
+
+func PrepareWebHookHTTPSServer(ClientSet kubernetes.Interface, KubeanClusterSet clusterClientSet.Interface, KubeanClusterOpsSet clusterOperationClientSet.Interface, KubeanManifestSet manifestClientSet.Interface, KubeanClusterOpsPolicySet clusterOperationPolicyClientSet.Interface) *http.Server {
 	mux := http.NewServeMux()
-	mux.Handle(WebHookPath, AdmissionReviewHandler{KubeanClusterOpsSet: KubeanClusterOpsSet})
+	mux.Handle(WebHookPath, AdmissionReviewHandler{ClientSet: ClientSet, KubeanClusterSet: KubeanClusterSet, KubeanClusterOpsSet: KubeanClusterOpsSet, KubeanClusterOpsPolicySet: KubeanClusterOpsPolicySet})
+	mux.Handle(MutatingWebHookPath, MutationHandler{ClientSet: ClientSet, KubeanClusterSet: KubeanClusterSet, KubeanManifestSet: KubeanManifestSet})
+	mux.Handle(ClusterWebHookPath, clusterwebhook.ClusterReviewHandler{ClientSet: ClientSet})
+	mux.Handle(ConfigMapWebHookPath, clusterwebhook.ConfigMapReviewHandler{})
+	mux.Handle(PolicyWebHookPath, PolicyReviewHandler{})
+	mux.Handle(ScheduleWebHookPath, ScheduleReviewHandler{ClientSet: ClientSet, KubeanClusterSet: KubeanClusterSet, KubeanClusterOpsPolicySet: KubeanClusterOpsPolicySet})
+	mux.Handle(MutatingScheduleWebHookPath, ScheduleMutationHandler{})
 	mux.Handle("/ping", PingHandler{})
+	mux.Handle("/metrics", promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{}))
 	server := &http.Server{
 		Addr:    ":10443",
 		Handler: mux,
@@ -308,58 +536,71 @@ func UpdateClusterOperationWebhook(clientSet kubernetes.Interface) error {
 		klog.Error(err)
 		return err
 	}
-	m, err := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
-	if err == nil && len(m.Webhooks) > 0 && string(m.Webhooks[0].ClientConfig.CABundle) == string(caCertData) {
-		// need not update mutating-webhook
-		return nil
-	}
 	newWebHook := &admissionregistrationv1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: ClusterOperationWebhook,
 		},
 		Webhooks: []admissionregistrationv1.ValidatingWebhook{
-			{
-				Name: Organization + ".webhook",
-				ClientConfig: admissionregistrationv1.WebhookClientConfig{
-					CABundle: caCertData, // CA bundle created earlier
-					Service: &admissionregistrationv1.ServiceReference{
-						Name:      WebhookSVCName,
-						Namespace: WebhookSVCNamespace,
-						Path:      &WebHookPath,
-						Port: func() *int32 {
-							httpsPort := int32(443)
-							return &httpsPort
-						}(),
-					},
+			// the clusterOps webhook stays the first one, whose CABundle is compared to skip the update.
+			newValidatingWebhook(Organization+".webhook", caCertData, &WebHookPath, admissionregistrationv1.RuleWithOperations{
+				Operations: []admissionregistrationv1.OperationType{
+					admissionregistrationv1.Create,
+					admissionregistrationv1.Update, // the spec is immutable
 				},
-				Rules: []admissionregistrationv1.RuleWithOperations{
-					{
-						Operations: []admissionregistrationv1.OperationType{
-							admissionregistrationv1.Create, // only for create , not for update or delete
-						},
-						Rule: admissionregistrationv1.Rule{
-							APIGroups:   []string{"kubean.io"},
-							APIVersions: []string{"v1alpha1"},
-							Resources:   []string{"clusteroperations"},
-						},
-					},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"kubean.io"},
+					APIVersions: []string{"v1alpha1"},
+					Resources:   []string{"clusteroperations"},
 				},
-				FailurePolicy: func() *admissionregistrationv1.FailurePolicyType {
-					policy := admissionregistrationv1.FailurePolicyType(FailurePolicy)
-					return &policy
-				}(),
-				TimeoutSeconds: func() *int32 {
-					timeout := int32(10)
-					return &timeout
-				}(),
-				AdmissionReviewVersions: []string{"v1", "v1beta1"},
-				SideEffects: func() *admissionregistrationv1.SideEffectClass {
-					se := admissionregistrationv1.SideEffectClassNone
-					return &se
-				}(),
-			},
+			}, admissionregistrationv1.Fail, nil),
+			newValidatingWebhook("cluster."+Organization+".webhook", caCertData, &ClusterWebHookPath, admissionregistrationv1.RuleWithOperations{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"kubean.io"},
+					APIVersions: []string{"v1alpha1"},
+					Resources:   []string{"clusters"},
+				},
+			}, admissionregistrationv1.Fail, nil),
+			newValidatingWebhook("configmap."+Organization+".webhook", caCertData, &ConfigMapWebHookPath, admissionregistrationv1.RuleWithOperations{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"configmaps"},
+				},
+			}, admissionregistrationv1.FailurePolicyType(FailurePolicy), &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      constants.KubeanConfigTypeLabelKey,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{constants.ConfigTypeInventory, constants.ConfigTypeVars},
+				}},
+			}),
+			newValidatingWebhook("policy."+Organization+".webhook", caCertData, &PolicyWebHookPath, admissionregistrationv1.RuleWithOperations{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"kubean.io"},
+					APIVersions: []string{"v1alpha1"},
+					Resources:   []string{"clusteroperationpolicies"},
+				},
+			}, admissionregistrationv1.Fail, nil),
+			newValidatingWebhook("schedule."+Organization+".webhook", caCertData, &ScheduleWebHookPath, admissionregistrationv1.RuleWithOperations{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"kubean.io"},
+					APIVersions: []string{"v1alpha1"},
+					Resources:   []string{"clusteroperationschedules"},
+				},
+			}, admissionregistrationv1.Fail, nil),
 		},
 	}
+	if err := updateMutatingWebhook(clientSet, caCertData); err != nil {
+		return err
+	}
+	m, err := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+	if err == nil && webhooksUpToDate(m.Webhooks, newWebHook.Webhooks) {
+		// need not update validating-webhook
+		return nil
+	}
 	if err != nil && apierrors.IsNotFound(err) { // create
 		if _, err := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(context.Background(), newWebHook, metav1.CreateOptions{}); err != nil {
 			klog.Error(err)
@@ -374,3 +615,137 @@ func UpdateClusterOperationWebhook(clientSet kubernetes.Interface) error {
 	}
 	return nil
 }
+
+// updateMutatingWebhook creates or updates the webhooks which default the clusterOps at creation and record its canceller,
+// and record the requester of the ClusterOperationSchedule.
+func updateMutatingWebhook(clientSet kubernetes.Interface, caCertData []byte) error {
+	webhooks := []admissionregistrationv1.ValidatingWebhook{
+		newValidatingWebhook("mutate."+Organization+".webhook", caCertData, &MutatingWebHookPath, admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"kubean.io"},
+				APIVersions: []string{"v1alpha1"},
+				Resources:   []string{"clusteroperations"},
+			},
+		}, admissionregistrationv1.Fail, nil),
+		newValidatingWebhook("schedule.mutate."+Organization+".webhook", caCertData, &MutatingScheduleWebHookPath, admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{"kubean.io"},
+				APIVersions: []string{"v1alpha1"},
+				Resources:   []string{"clusteroperationschedules"},
+			},
+		}, admissionregistrationv1.Fail, nil),
+	}
+	newWebHook := &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ClusterOperationWebhook,
+		},
+	}
+	for _, webhook := range webhooks {
+		newWebHook.Webhooks = append(newWebHook.Webhooks, admissionregistrationv1.MutatingWebhook{
+			Name:                    webhook.Name,
+			ClientConfig:            webhook.ClientConfig,
+			Rules:                   webhook.Rules,
+			FailurePolicy:           webhook.FailurePolicy,
+			TimeoutSeconds:          webhook.TimeoutSeconds,
+			AdmissionReviewVersions: webhook.AdmissionReviewVersions,
+			SideEffects:             webhook.SideEffects,
+		})
+	}
+	m, err := clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+	if err == nil {
+		current := make([]admissionregistrationv1.ValidatingWebhook, 0, len(m.Webhooks))
+		for _, webhook := range m.Webhooks {
+			current = append(current, admissionregistrationv1.ValidatingWebhook{
+				Name: webhook.Name, ClientConfig: webhook.ClientConfig, Rules: webhook.Rules, FailurePolicy: webhook.FailurePolicy, ObjectSelector: webhook.ObjectSelector,
+			})
+		}
+		if webhooksUpToDate(current, webhooks) {
+			// need not update mutating-webhook
+			return nil
+		}
+	}
+	if err != nil && apierrors.IsNotFound(err) { // create
+		if _, err := clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Create(context.Background(), newWebHook, metav1.CreateOptions{}); err != nil {
+			klog.Error(err)
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	newWebHook.ResourceVersion = m.ResourceVersion // update
+	if _, err := clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Update(context.Background(), newWebHook, metav1.UpdateOptions{}); err != nil {
+		klog.Error(err)
+		return err
+	}
+	return nil
+}
+
+// webhooksUpToDate compares the webhooks by the fields which kubean-admission sets and the apiserver does not default.
+func webhooksUpToDate(current, desired []admissionregistrationv1.ValidatingWebhook) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+	for i := range desired {
+		if current[i].Name != desired[i].Name || string(current[i].ClientConfig.CABundle) != string(desired[i].ClientConfig.CABundle) ||
+			!equality.Semantic.DeepEqual(current[i].Rules, desired[i].Rules) || failurePolicyOf(current[i]) != failurePolicyOf(desired[i]) ||
+			!equalSelector(current[i].ObjectSelector, desired[i].ObjectSelector) {
+			return false
+		}
+	}
+	return true
+}
+
+// failurePolicyOf returns the failurePolicy of the webhook, which the apiserver defaults to Fail.
+func failurePolicyOf(webhook admissionregistrationv1.ValidatingWebhook) admissionregistrationv1.FailurePolicyType {
+	if webhook.FailurePolicy == nil {
+		return admissionregistrationv1.Fail
+	}
+	return *webhook.FailurePolicy
+}
+
+// equalSelector compares the selectors, and the nil one equals the empty one which the apiserver defaults it to.
+func equalSelector(a, b *metav1.LabelSelector) bool {
+	if a == nil {
+		a = &metav1.LabelSelector{}
+	}
+	if b == nil {
+		b = &metav1.LabelSelector{}
+	}
+	return equality.Semantic.DeepEqual(a, b)
+}
+
+func newValidatingWebhook(name string, caCertData []byte, path *string, rule admissionregistrationv1.RuleWithOperations,
+	failurePolicy admissionregistrationv1.FailurePolicyType, objectSelector *metav1.LabelSelector) admissionregistrationv1.ValidatingWebhook {
+	return admissionregistrationv1.ValidatingWebhook{
+		Name: name,
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			CABundle: caCertData, // CA bundle created earlier
+			Service: &admissionregistrationv1.ServiceReference{
+				Name:      WebhookSVCName,
+				Namespace: WebhookSVCNamespace,
+				Path:      path,
+				Port: func() *int32 {
+					httpsPort := int32(443)
+					return &httpsPort
+				}(),
+			},
+		},
+		Rules:          []admissionregistrationv1.RuleWithOperations{rule},
+		ObjectSelector: objectSelector,
+		FailurePolicy:  &failurePolicy,
+		TimeoutSeconds: func() *int32 {
+			timeout := int32(10)
+			return &timeout
+		}(),
+		AdmissionReviewVersions: []string{"v1", "v1beta1"},
+		SideEffects: func() *admissionregistrationv1.SideEffectClass {
+			se := admissionregistrationv1.SideEffectClassNone
+			return &se
+		}(),
+	}
+}
@@ -17,7 +17,11 @@ import (
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/errors"
@@ -26,10 +30,20 @@ import (
 	"k8s.io/client-go/rest"
 	k8stesting "k8s.io/client-go/testing"
 
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
 	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	clusteroperationpolicyv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationpolicy/v1alpha1"
+	"github.com/kubean-io/kubean-api/constants"
+	clusterv1alpha1fake "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned/fake"
 	clusteroperationv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperation/clientset/versioned/fake"
+	clusteroperationpolicyv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperationpolicy/clientset/versioned/fake"
 
+	clustercontroller "github.com/kubean-io/kubean/pkg/controllers/cluster"
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/metrics"
 	"github.com/kubean-io/kubean/pkg/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func fetchTestingFake(obj interface{ RESTClient() rest.Interface }) *k8stesting.Fake {
@@ -88,7 +102,7 @@ func TestCreateHTTPSCAFiles(t *testing.T) {
 }
 
 func TestPrepareWebHookHTTPSServer(t *testing.T) {
-	server := PrepareWebHookHTTPSServer(nil)
+	server := PrepareWebHookHTTPSServer(nil, nil, nil, nil, nil)
 	if server == nil {
 		t.Fatal()
 	}
@@ -329,6 +343,140 @@ func TestUpdateClusterOperationWebhook(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "add the cluster, configmap, policy and schedule webhooks to the existing configuration",
+			arg: func() bool {
+				certsDir = strings.TrimRight(os.TempDir(), "/")
+				fakeClientSet := clientsetfake.NewSimpleClientset()
+				EnsureCASecretExist(fakeClientSet, createHTTPSCAInSecret)
+				UpdateClusterOperationWebhook(fakeClientSet)
+				webhook, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				webhook.Webhooks = webhook.Webhooks[:1]
+				fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(context.Background(), webhook, metav1.UpdateOptions{})
+				if err := UpdateClusterOperationWebhook(fakeClientSet); err != nil {
+					return false
+				}
+				result, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				return len(result.Webhooks) == 5 && *result.Webhooks[1].ClientConfig.Service.Path == ClusterWebHookPath &&
+					*result.Webhooks[2].ClientConfig.Service.Path == ConfigMapWebHookPath && result.Webhooks[2].ObjectSelector != nil &&
+					*result.Webhooks[3].ClientConfig.Service.Path == PolicyWebHookPath && *result.Webhooks[4].ClientConfig.Service.Path == ScheduleWebHookPath
+			},
+			want: true,
+		},
+		{
+			name: "create the mutating webhook",
+			arg: func() bool {
+				certsDir = strings.TrimRight(os.TempDir(), "/")
+				fakeClientSet := clientsetfake.NewSimpleClientset()
+				EnsureCASecretExist(fakeClientSet, createHTTPSCAInSecret)
+				if err := UpdateClusterOperationWebhook(fakeClientSet); err != nil {
+					return false
+				}
+				result, err := fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				return err == nil && len(result.Webhooks) == 2 && *result.Webhooks[0].ClientConfig.Service.Path == MutatingWebHookPath &&
+					reflect.DeepEqual(result.Webhooks[0].Rules[0].Operations, []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}) &&
+					*result.Webhooks[1].ClientConfig.Service.Path == MutatingScheduleWebHookPath
+			},
+			want: true,
+		},
+		{
+			name: "add the schedule mutating webhook to the existing configuration",
+			arg: func() bool {
+				certsDir = strings.TrimRight(os.TempDir(), "/")
+				fakeClientSet := clientsetfake.NewSimpleClientset()
+				EnsureCASecretExist(fakeClientSet, createHTTPSCAInSecret)
+				UpdateClusterOperationWebhook(fakeClientSet)
+				webhook, _ := fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				webhook.Webhooks = webhook.Webhooks[:1]
+				fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Update(context.Background(), webhook, metav1.UpdateOptions{})
+				if err := UpdateClusterOperationWebhook(fakeClientSet); err != nil {
+					return false
+				}
+				result, _ := fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				return len(result.Webhooks) == 2 && *result.Webhooks[1].ClientConfig.Service.Path == MutatingScheduleWebHookPath
+			},
+			want: true,
+		},
+		{
+			name: "add the update operation to the rules of the existing configuration",
+			arg: func() bool {
+				certsDir = strings.TrimRight(os.TempDir(), "/")
+				fakeClientSet := clientsetfake.NewSimpleClientset()
+				EnsureCASecretExist(fakeClientSet, createHTTPSCAInSecret)
+				UpdateClusterOperationWebhook(fakeClientSet)
+				webhook, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				webhook.Webhooks[0].Rules[0].Operations = []admissionregistrationv1.OperationType{admissionregistrationv1.Create}
+				fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(context.Background(), webhook, metav1.UpdateOptions{})
+				if err := UpdateClusterOperationWebhook(fakeClientSet); err != nil {
+					return false
+				}
+				result, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				return reflect.DeepEqual(result.Webhooks[0].Rules[0].Operations, []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update})
+			},
+			want: true,
+		},
+		{
+			name: "update the changed object selector of the existing configuration",
+			arg: func() bool {
+				certsDir = strings.TrimRight(os.TempDir(), "/")
+				fakeClientSet := clientsetfake.NewSimpleClientset()
+				EnsureCASecretExist(fakeClientSet, createHTTPSCAInSecret)
+				UpdateClusterOperationWebhook(fakeClientSet)
+				webhook, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				desired := webhook.Webhooks[2].ObjectSelector.DeepCopy()
+				webhook.Webhooks[2].ObjectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"stale": "true"}}
+				// the apiserver defaults the nil selector to the empty one.
+				webhook.Webhooks[0].ObjectSelector = &metav1.LabelSelector{}
+				fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(context.Background(), webhook, metav1.UpdateOptions{})
+				if err := UpdateClusterOperationWebhook(fakeClientSet); err != nil {
+					return false
+				}
+				result, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				return reflect.DeepEqual(result.Webhooks[2].ObjectSelector, desired)
+			},
+			want: true,
+		},
+		{
+			name: "fail closed for the kubean resources and update the existing configuration which fails open",
+			arg: func() bool {
+				defer func(policy string) {
+					FailurePolicy = policy
+				}(FailurePolicy)
+				FailurePolicy = string(admissionregistrationv1.Ignore)
+				certsDir = strings.TrimRight(os.TempDir(), "/")
+				fakeClientSet := clientsetfake.NewSimpleClientset()
+				EnsureCASecretExist(fakeClientSet, createHTTPSCAInSecret)
+				UpdateClusterOperationWebhook(fakeClientSet)
+				ignore := admissionregistrationv1.Ignore
+				validating, _ := fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				validating.Webhooks[0].FailurePolicy = &ignore
+				fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(context.Background(), validating, metav1.UpdateOptions{})
+				mutating, _ := fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				mutating.Webhooks[0].FailurePolicy = &ignore
+				fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Update(context.Background(), mutating, metav1.UpdateOptions{})
+				if err := UpdateClusterOperationWebhook(fakeClientSet); err != nil {
+					return false
+				}
+				validating, _ = fakeClientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				mutating, _ = fakeClientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(context.Background(), ClusterOperationWebhook, metav1.GetOptions{})
+				for i, webhook := range validating.Webhooks {
+					want := admissionregistrationv1.Fail
+					if i == 2 { // the configmap webhook
+						want = admissionregistrationv1.Ignore
+					}
+					if *webhook.FailurePolicy != want {
+						return false
+					}
+				}
+				for _, webhook := range mutating.Webhooks {
+					if *webhook.FailurePolicy != admissionregistrationv1.Fail {
+						return false
+					}
+				}
+				return true
+			},
+			want: true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -354,9 +502,19 @@ func (fw *FakeResponseWriter) Write(bs []byte) (int, error) {
 	return len(bs), nil
 }
 
+// newValidSpec returns the spec which passes the validation of the clusterOps.
+func newValidSpec(cluster string) clusteroperationv1alpha1.Spec {
+	return clusteroperationv1alpha1.Spec{
+		Cluster:    cluster,
+		ActionType: clusteroperationv1alpha1.PlaybookActionType,
+		Action:     "cluster.yml",
+		Image:      "ghcr.io/kubean-io/spray-job:latest",
+	}
+}
+
 func TestAdmissionReviewHandlerHttp(t *testing.T) {
 	clusterOperationClientSet := clusteroperationv1alpha1fake.NewSimpleClientset()
-	handler := AdmissionReviewHandler{clusterOperationClientSet}
+	handler := AdmissionReviewHandler{KubeanClusterOpsSet: clusterOperationClientSet}
 	tests := []struct {
 		name string
 		args func() bool
@@ -440,9 +598,7 @@ func TestAdmissionReviewHandlerHttp(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "my_kubean_ops_cluster",
 					},
-					Spec: clusteroperationv1alpha1.Spec{
-						Cluster: "abc_cluster",
-					},
+					Spec: newValidSpec("abc_cluster"),
 				}
 				raw, _ := json.Marshal(clusterOps)
 				admissionReview := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}}
@@ -467,9 +623,7 @@ func TestAdmissionReviewHandlerHttp(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "my_kubean_ops_cluster",
 					},
-					Spec: clusteroperationv1alpha1.Spec{
-						Cluster: "abc_cluster",
-					},
+					Spec: newValidSpec("abc_cluster"),
 				}
 				raw, _ := json.Marshal(clusterOps)
 				admissionReview := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}}
@@ -498,9 +652,7 @@ func TestAdmissionReviewHandlerHttp(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "my_kubean_ops_cluster_1",
 					},
-					Spec: clusteroperationv1alpha1.Spec{
-						Cluster: "abc_cluster",
-					},
+					Spec: newValidSpec("abc_cluster"),
 				}
 				clusterOperationClientSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps1, metav1.CreateOptions{})
 				clusterOps2 := &clusteroperationv1alpha1.ClusterOperation{
@@ -511,9 +663,69 @@ func TestAdmissionReviewHandlerHttp(t *testing.T) {
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "my_kubean_ops_cluster_2",
 					},
-					Spec: clusteroperationv1alpha1.Spec{
-						Cluster: "abc_cluster",
-					},
+					Spec: newValidSpec("abc_cluster"),
+				}
+				raw, _ := json.Marshal(clusterOps2)
+				admissionReview := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}}
+				admissionReviewBytes, _ := json.Marshal(admissionReview)
+				request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+				handler.ServeHTTP(response, request)
+				admissionReviewResponse := &admissionv1.AdmissionReview{}
+				json.Unmarshal([]byte(response.result), admissionReviewResponse)
+				return response.code == http.StatusOK && !admissionReviewResponse.Response.Allowed && admissionReviewResponse.Response.Result.Reason == metav1.StatusReasonNotAcceptable &&
+					testutil.ToFloat64(metrics.AdmissionReviewsTotal.WithLabelValues(metrics.DeniedDecision)) == 1
+			},
+			want: true,
+		},
+		{
+			name: "allow and queue",
+			args: func() bool {
+				clientSet := clientsetfake.NewSimpleClientset()
+				clientSet.CoreV1().ConfigMaps(util.GetCurrentNSOrDefault()).Create(context.Background(), &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: constants.KubeanConfigMapName, Namespace: util.GetCurrentNSOrDefault()},
+					Data:       map[string]string{"CLUSTER_OPERATION_QUEUE_MODE": "true"},
+				}, metav1.CreateOptions{})
+				handler := AdmissionReviewHandler{ClientSet: clientSet, KubeanClusterOpsSet: clusterOperationClientSet}
+				response := &FakeResponseWriter{}
+				clusterOps1 := &clusteroperationv1alpha1.ClusterOperation{
+					ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_3"},
+					Spec:       newValidSpec("queue_cluster"),
+					Status:     clusteroperationv1alpha1.Status{Status: clusteroperationv1alpha1.PendingStatus},
+				}
+				clusterOperationClientSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps1, metav1.CreateOptions{})
+				clusterOps2 := &clusteroperationv1alpha1.ClusterOperation{
+					ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_4"},
+					Spec:       newValidSpec("queue_cluster"),
+				}
+				raw, _ := json.Marshal(clusterOps2)
+				admissionReview := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}}
+				admissionReviewBytes, _ := json.Marshal(admissionReview)
+				request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+				handler.ServeHTTP(response, request)
+				admissionReviewResponse := &admissionv1.AdmissionReview{}
+				json.Unmarshal([]byte(response.result), admissionReviewResponse)
+				return response.code == http.StatusOK && admissionReviewResponse.Response.Allowed &&
+					len(admissionReviewResponse.Response.Warnings) == 1 && strings.Contains(admissionReviewResponse.Response.Warnings[0], "my_kubean_ops_cluster_3")
+			},
+			want: true,
+		},
+		{
+			name: "allow when the running one is dry run",
+			args: func() bool {
+				response := &FakeResponseWriter{}
+				clusterOps1 := &clusteroperationv1alpha1.ClusterOperation{
+					ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_5"},
+					Spec: func() clusteroperationv1alpha1.Spec {
+						spec := newValidSpec("dry_run_cluster")
+						spec.DryRun = true
+						return spec
+					}(),
+					Status: clusteroperationv1alpha1.Status{Status: clusteroperationv1alpha1.RunningStatus},
+				}
+				clusterOperationClientSet.KubeanV1alpha1().ClusterOperations().Create(context.Background(), clusterOps1, metav1.CreateOptions{})
+				clusterOps2 := &clusteroperationv1alpha1.ClusterOperation{
+					ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_6"},
+					Spec:       newValidSpec("dry_run_cluster"),
 				}
 				raw, _ := json.Marshal(clusterOps2)
 				admissionReview := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}}
@@ -522,7 +734,308 @@ func TestAdmissionReviewHandlerHttp(t *testing.T) {
 				handler.ServeHTTP(response, request)
 				admissionReviewResponse := &admissionv1.AdmissionReview{}
 				json.Unmarshal([]byte(response.result), admissionReviewResponse)
-				return response.code == http.StatusOK && !admissionReviewResponse.Response.Allowed && admissionReviewResponse.Response.Result.Reason == metav1.StatusReasonNotAcceptable
+				return response.code == http.StatusOK && admissionReviewResponse.Response.Allowed && len(admissionReviewResponse.Response.Warnings) == 0
+			},
+			want: true,
+		},
+		{
+			name: "deny the invalid spec",
+			args: func() bool {
+				response := &FakeResponseWriter{}
+				spec := newValidSpec("invalid_cluster")
+				spec.PreHook = []clusteroperationv1alpha1.HookAction{{ActionType: "playbook", Action: "not-exist.yml"}}
+				raw, _ := json.Marshal(&clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_7"}, Spec: spec})
+				admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}})
+				request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+				handler.ServeHTTP(response, request)
+				admissionReviewResponse := &admissionv1.AdmissionReview{}
+				json.Unmarshal([]byte(response.result), admissionReviewResponse)
+				return response.code == http.StatusOK && !admissionReviewResponse.Response.Allowed &&
+					admissionReviewResponse.Response.Result.Reason == metav1.StatusReasonInvalid &&
+					strings.Contains(admissionReviewResponse.Response.Result.Message, "spec.preHook[0].action")
+			},
+			want: true,
+		},
+		{
+			name: "deny the missing cluster and configmaps",
+			args: func() bool {
+				clientSet := clientsetfake.NewSimpleClientset(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "kubean-system"}})
+				clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "ref_cluster"},
+					Spec: clusterv1alpha1.Spec{
+						HostsConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-conf"},
+						VarsConfRef:  &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "vars-conf"},
+					},
+				})
+				handler := AdmissionReviewHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanClusterOpsSet: clusterOperationClientSet}
+				review := func(cluster string) *admissionv1.AdmissionResponse {
+					response := &FakeResponseWriter{}
+					raw, _ := json.Marshal(&clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_8"}, Spec: newValidSpec(cluster)})
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				notFoundCluster, missingVars := review("not_exist_cluster"), review("ref_cluster")
+				return !notFoundCluster.Allowed && strings.Contains(notFoundCluster.Result.Message, "spec.cluster: Not found") &&
+					!missingVars.Allowed && strings.Contains(missingVars.Result.Message, "spec.varsConfRef") && !strings.Contains(missingVars.Result.Message, "spec.hostsConfRef")
+			},
+			want: true,
+		},
+		{
+			name: "deny the spec update but allow cancel and backfill",
+			args: func() bool {
+				review := func(mutate func(spec *clusteroperationv1alpha1.Spec)) *admissionv1.AdmissionResponse {
+					oldOps := clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_9"}, Spec: newValidSpec("update_cluster")}
+					newOps := oldOps.DeepCopy()
+					mutate(&newOps.Spec)
+					oldRaw, _ := json.Marshal(&oldOps)
+					newRaw, _ := json.Marshal(newOps)
+					response := &FakeResponseWriter{}
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+						Operation: admissionv1.Update, Object: runtime.RawExtension{Raw: newRaw}, OldObject: runtime.RawExtension{Raw: oldRaw},
+					}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				changed := review(func(spec *clusteroperationv1alpha1.Spec) { spec.Action = "reset.yml" })
+				backfilled := review(func(spec *clusteroperationv1alpha1.Spec) {
+					spec.Cancel = true
+					spec.HostsConfRef = &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-conf"}
+				})
+				return !changed.Allowed && changed.Result.Reason == metav1.StatusReasonInvalid &&
+					strings.Contains(changed.Result.Message, "spec.action: Forbidden") && backfilled.Allowed
+			},
+			want: true,
+		},
+		{
+			name: "allow the approval only by a user other than the creator",
+			args: func() bool {
+				review := func(user, approver string) *admissionv1.AdmissionResponse {
+					oldOps := clusteroperationv1alpha1.ClusterOperation{
+						ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_11", Annotations: map[string]string{clusteropscontroller.CreatedByAnnoKey: "alice"}},
+						Spec:       newValidSpec("update_cluster"),
+					}
+					newOps := oldOps.DeepCopy()
+					newOps.Annotations[clusteropscontroller.ApprovedByAnnoKey] = approver
+					oldRaw, _ := json.Marshal(&oldOps)
+					newRaw, _ := json.Marshal(newOps)
+					response := &FakeResponseWriter{}
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+						Operation: admissionv1.Update, UserInfo: authenticationv1.UserInfo{Username: user},
+						Object: runtime.RawExtension{Raw: newRaw}, OldObject: runtime.RawExtension{Raw: oldRaw},
+					}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				byCreator := review("alice", "alice")
+				return review("bob", "bob").Allowed && !byCreator.Allowed &&
+					strings.Contains(byCreator.Result.Message, "must be approved by a user other than the creator alice")
+			},
+			want: true,
+		},
+		{
+			name: "authorize the user on the tenant cluster",
+			args: func() bool {
+				clientSet := clientsetfake.NewSimpleClientset(
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "team-a"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Namespace: "team-a"}},
+				)
+				clientSet.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+					sar.Status.Allowed = sar.Spec.User == "alice" && sar.Spec.ResourceAttributes.Namespace == "team-a"
+					return true, sar, nil
+				})
+				clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "tenant_cluster"},
+					Spec: clusterv1alpha1.Spec{
+						Tenant:       &clusterv1alpha1.Tenant{Namespace: "team-a", ServiceAccountName: "team-a-sa"},
+						HostsConfRef: &apis.ConfigMapRef{NameSpace: "team-a", Name: "hosts-conf"},
+						VarsConfRef:  &apis.ConfigMapRef{NameSpace: "team-a", Name: "vars-conf"},
+					},
+				})
+				handler := AdmissionReviewHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanClusterOpsSet: clusterOperationClientSet}
+				review := func(user string, operation admissionv1.Operation) *admissionv1.AdmissionResponse {
+					ops := clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_10"}, Spec: newValidSpec("tenant_cluster")}
+					oldRaw, _ := json.Marshal(&ops)
+					ops.Spec.Cancel = operation == admissionv1.Update
+					raw, _ := json.Marshal(&ops)
+					response := &FakeResponseWriter{}
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+						Operation: operation, UserInfo: authenticationv1.UserInfo{Username: user},
+						Object: runtime.RawExtension{Raw: raw}, OldObject: runtime.RawExtension{Raw: oldRaw},
+					}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				deniedCreate, deniedCancel := review("bob", admissionv1.Create), review("bob", admissionv1.Update)
+				return review("alice", admissionv1.Create).Allowed && review("alice", admissionv1.Update).Allowed &&
+					!deniedCreate.Allowed && strings.Contains(deniedCreate.Result.Message, "spec.cluster: Forbidden: user bob is not allowed to operate cluster tenant_cluster") &&
+					!deniedCancel.Allowed && strings.Contains(deniedCancel.Result.Message, "user bob is not allowed")
+			},
+			want: true,
+		},
+		{
+			name: "authorize the clusterOps of the schedule on behalf of its requester",
+			args: func() bool {
+				defer func(namespace, serviceAccount string) {
+					WebhookSVCNamespace, OperatorServiceAccount = namespace, serviceAccount
+				}(WebhookSVCNamespace, OperatorServiceAccount)
+				WebhookSVCNamespace, OperatorServiceAccount = "kubean-system", "kubean"
+				clientSet := clientsetfake.NewSimpleClientset(
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "team-a"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Namespace: "team-a"}},
+				)
+				clientSet.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+					sar.Status.Allowed = sar.Spec.User == "alice" || sar.Spec.User == "system:serviceaccount:kubean-system:kubean"
+					return true, sar, nil
+				})
+				clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "tenant_cluster"},
+					Spec: clusterv1alpha1.Spec{
+						Tenant:       &clusterv1alpha1.Tenant{Namespace: "team-a", ServiceAccountName: "team-a-sa"},
+						HostsConfRef: &apis.ConfigMapRef{NameSpace: "team-a", Name: "hosts-conf"},
+						VarsConfRef:  &apis.ConfigMapRef{NameSpace: "team-a", Name: "vars-conf"},
+					},
+				})
+				handler := AdmissionReviewHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanClusterOpsSet: clusterOperationClientSet}
+				review := func(user, requester string) *admissionv1.AdmissionResponse {
+					value, _ := clusteropscontroller.RequesterAnnotation(authenticationv1.UserInfo{Username: requester})
+					ops := clusteroperationv1alpha1.ClusterOperation{
+						ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_12", Annotations: map[string]string{
+							clusteropscontroller.RequesterAnnoKey: value, clusteropscontroller.CreatedByAnnoKey: requester,
+						}},
+						Spec: newValidSpec("tenant_cluster"),
+					}
+					raw, _ := json.Marshal(&ops)
+					response := &FakeResponseWriter{}
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+						Operation: admissionv1.Create, UserInfo: authenticationv1.UserInfo{Username: user}, Object: runtime.RawExtension{Raw: raw},
+					}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				operator := "system:serviceaccount:kubean-system:kubean"
+				deniedRequester, forged := review(operator, "bob"), review("bob", "alice")
+				return review(operator, "alice").Allowed &&
+					!deniedRequester.Allowed && strings.Contains(deniedRequester.Result.Message, "user bob is not allowed to operate cluster tenant_cluster") &&
+					!forged.Allowed && strings.Contains(forged.Result.Message, "must be the user bob")
+			},
+			want: true,
+		},
+		{
+			name: "deny the actions out of the ClusterOperationPolicy",
+			args: func() bool {
+				clientSet := clientsetfake.NewSimpleClientset(
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "kubean-system"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Namespace: "kubean-system"}},
+				)
+				clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "prod_cluster"},
+					Spec: clusterv1alpha1.Spec{
+						HostsConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-conf"},
+						VarsConfRef:  &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "vars-conf"},
+					},
+				})
+				policyClientSet := clusteroperationpolicyv1alpha1fake.NewSimpleClientset(&clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "operators"},
+					Spec: clusteroperationpolicyv1alpha1.Spec{
+						Clusters:  []string{"prod_cluster"},
+						Subjects:  []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "operators"}},
+						Playbooks: []string{"cluster.yml"},
+					},
+				})
+				handler := AdmissionReviewHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanClusterOpsSet: clusterOperationClientSet, KubeanClusterOpsPolicySet: policyClientSet}
+				review := func(action string, userInfo authenticationv1.UserInfo) *admissionv1.AdmissionResponse {
+					ops := clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_11"}, Spec: newValidSpec("prod_cluster")}
+					ops.Spec.Action = action
+					raw, _ := json.Marshal(&ops)
+					response := &FakeResponseWriter{}
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+						Operation: admissionv1.Create, UserInfo: userInfo, Object: runtime.RawExtension{Raw: raw},
+					}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				operator := authenticationv1.UserInfo{Username: "alice", Groups: []string{"operators"}}
+				deniedReset := review("reset.yml", operator)
+				deniedUser := review("cluster.yml", authenticationv1.UserInfo{Username: "bob"})
+				return review("cluster.yml", operator).Allowed &&
+					!deniedReset.Allowed && strings.Contains(deniedReset.Result.Message, "playbook reset.yml is not allowed by ClusterOperationPolicy operators") &&
+					!deniedUser.Allowed && strings.Contains(deniedUser.Result.Message, "user bob is bound to none of the ClusterOperationPolicies [operators]")
+			},
+			want: true,
+		},
+		{
+			name: "check the clusterOps of kubean-operator by the ClusterOperationPolicy",
+			args: func() bool {
+				defer func(namespace, serviceAccount string) {
+					WebhookSVCNamespace, OperatorServiceAccount = namespace, serviceAccount
+				}(WebhookSVCNamespace, OperatorServiceAccount)
+				WebhookSVCNamespace, OperatorServiceAccount = "kubean-system", "kubean"
+				clientSet := clientsetfake.NewSimpleClientset(
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "hosts-conf", Namespace: "kubean-system"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "vars-conf", Namespace: "kubean-system"}},
+				)
+				clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "prod_cluster"},
+					Spec: clusterv1alpha1.Spec{
+						HostsConfRef: &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "hosts-conf"},
+						VarsConfRef:  &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "vars-conf"},
+					},
+				})
+				policyClientSet := clusteroperationpolicyv1alpha1fake.NewSimpleClientset(&clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "operators"},
+					Spec: clusteroperationpolicyv1alpha1.Spec{
+						Clusters:  []string{"prod_cluster"},
+						Subjects:  []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "operators"}, {Kind: rbacv1.GroupKind, Name: "system:serviceaccounts"}},
+						Playbooks: []string{"cluster.yml", "scale.yml"},
+					},
+				})
+				handler := AdmissionReviewHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanClusterOpsSet: clusterOperationClientSet, KubeanClusterOpsPolicySet: policyClientSet}
+				operator := authenticationv1.UserInfo{Username: "system:serviceaccount:kubean-system:kubean", Groups: []string{"system:serviceaccounts"}}
+				review := func(labels map[string]string, requester *authenticationv1.UserInfo) *admissionv1.AdmissionResponse {
+					ops := clusteroperationv1alpha1.ClusterOperation{ObjectMeta: metav1.ObjectMeta{Name: "my_kubean_ops_cluster_13", Labels: labels}, Spec: newValidSpec("prod_cluster")}
+					ops.Spec.Action = "scale.yml"
+					if requester != nil {
+						value, _ := clusteropscontroller.RequesterAnnotation(*requester)
+						ops.Annotations = map[string]string{clusteropscontroller.RequesterAnnoKey: value, clusteropscontroller.CreatedByAnnoKey: requester.Username}
+					}
+					raw, _ := json.Marshal(&ops)
+					response := &FakeResponseWriter{}
+					admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+						Operation: admissionv1.Create, UserInfo: operator, Object: runtime.RawExtension{Raw: raw},
+					}})
+					request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+					handler.ServeHTTP(response, request)
+					admissionReviewResponse := &admissionv1.AdmissionReview{}
+					json.Unmarshal([]byte(response.result), admissionReviewResponse)
+					return admissionReviewResponse.Response
+				}
+				withoutRequester := review(nil, nil)
+				deniedRequester := review(nil, &authenticationv1.UserInfo{Username: "bob"})
+				return review(map[string]string{clustercontroller.NodeReconcileLabelKey: "scale"}, nil).Allowed &&
+					review(nil, &authenticationv1.UserInfo{Username: "alice", Groups: []string{"operators"}}).Allowed &&
+					!deniedRequester.Allowed && strings.Contains(deniedRequester.Result.Message, "user bob is bound to none") &&
+					!withoutRequester.Allowed && strings.Contains(withoutRequester.Result.Message, "user system:serviceaccount:kubean-system:kubean is bound to none")
 			},
 			want: true,
 		},
@@ -604,3 +1117,49 @@ func TestCreateHTTPSCASecretWithLock(t *testing.T) {
 		})
 	}
 }
+
+func TestWebhooksUpToDate(t *testing.T) {
+	fail, ignore := admissionregistrationv1.Fail, admissionregistrationv1.Ignore
+	desired := []admissionregistrationv1.ValidatingWebhook{
+		{Name: "a"},
+		{Name: "b", ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"foo": "bar"}}},
+	}
+	tests := []struct {
+		name    string
+		current []admissionregistrationv1.ValidatingWebhook
+		want    bool
+	}{
+		{
+			name:    "the selectors defaulted by the apiserver",
+			current: []admissionregistrationv1.ValidatingWebhook{{Name: "a", ObjectSelector: &metav1.LabelSelector{}}, *desired[1].DeepCopy()},
+			want:    true,
+		},
+		{
+			name:    "the changed selector",
+			current: []admissionregistrationv1.ValidatingWebhook{{Name: "a"}, {Name: "b", ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"foo": "baz"}}}},
+			want:    false,
+		},
+		{
+			name:    "the removed selector",
+			current: []admissionregistrationv1.ValidatingWebhook{{Name: "a"}, {Name: "b"}},
+			want:    false,
+		},
+		{
+			name:    "the failure policy defaulted by the apiserver",
+			current: []admissionregistrationv1.ValidatingWebhook{{Name: "a", FailurePolicy: &fail}, *desired[1].DeepCopy()},
+			want:    true,
+		},
+		{
+			name:    "the changed failure policy",
+			current: []admissionregistrationv1.ValidatingWebhook{{Name: "a", FailurePolicy: &ignore}, *desired[1].DeepCopy()},
+			want:    false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := webhooksUpToDate(test.current, desired); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
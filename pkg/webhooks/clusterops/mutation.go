@@ -0,0 +1,287 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	manifestv1alpha1 "github.com/kubean-io/kubean-api/apis/manifest/v1alpha1"
+	"github.com/kubean-io/kubean-api/cluster"
+	"github.com/kubean-io/kubean-api/constants"
+	clusterClientSet "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned"
+	manifestClientSet "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned"
+	"gomodules.xyz/jsonpatch/v2"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/metrics"
+	"github.com/kubean-io/kubean/pkg/util"
+)
+
+// MutationHandler defaults the ClusterOperation at creation with the kubean-config and the Manifests.
+type MutationHandler struct {
+	ClientSet         kubernetes.Interface
+	KubeanClusterSet  clusterClientSet.Interface
+	KubeanManifestSet manifestClientSet.Interface
+}
+
+// hasImageTag returns whether the image has a tag or a digest, e.g. registry:5000/spray-job has not.
+func hasImageTag(image string) bool {
+	return strings.ContainsAny(image[strings.LastIndex(image, "/")+1:], ":@")
+}
+
+// manifestImageTag returns the tag of the spray-job image released with the Manifest.
+func manifestImageTag(manifest *manifestv1alpha1.Manifest) string {
+	sprayRelease := manifest.Annotations[constants.KeySprayRelease]
+	sprayCommit := manifest.Annotations[constants.KeySprayCommit]
+	if sprayRelease != "" && sprayCommit != "" {
+		return fmt.Sprintf("%s-%s", sprayRelease, sprayCommit)
+	}
+	return manifest.Spec.KubeanVersion
+}
+
+// sprayJobImageTag returns the image tag of the Manifest whose sprayRelease label matches the one of the cluster,
+// otherwise the kubeanVersion of the global Manifest, and latest at last.
+func (handler MutationHandler) sprayJobImageTag(clusterName string) string {
+	if handler.KubeanManifestSet == nil {
+		return "latest"
+	}
+	if handler.KubeanClusterSet != nil && clusterName != "" {
+		kubeanCluster, err := handler.KubeanClusterSet.KubeanV1alpha1().Clusters().Get(context.Background(), clusterName, metav1.GetOptions{})
+		if err != nil {
+			klog.Warningf("get cluster %s for the spray-job image but failed, %v", clusterName, err)
+		} else if sprayRelease := kubeanCluster.Labels[constants.KeySprayRelease]; sprayRelease != "" {
+			manifests, err := handler.KubeanManifestSet.KubeanV1alpha1().Manifests().List(context.Background(), metav1.ListOptions{
+				LabelSelector: labels.Set{constants.KeySprayRelease: sprayRelease}.String(),
+			})
+			if err != nil {
+				klog.Warningf("list manifests of sprayRelease %s but failed, %v", sprayRelease, err)
+			} else {
+				for i := range manifests.Items {
+					if tag := manifestImageTag(&manifests.Items[i]); tag != "" {
+						return tag
+					}
+				}
+			}
+		}
+	}
+	global, err := handler.KubeanManifestSet.KubeanV1alpha1().Manifests().Get(context.Background(), constants.InfoManifestGlobal, metav1.GetOptions{})
+	if err != nil || global.Spec.KubeanVersion == "" {
+		return "latest"
+	}
+	return global.Spec.KubeanVersion
+}
+
+// DefaultClusterOperation fills the clusterName label, the spray-job image, the activeDeadlineSeconds,
+// the resource requests and the builtin actionSource which are not set by the user. The image tag is the
+// configured one if any, otherwise given by imageTag.
+func DefaultClusterOperation(clusterOps *clusteroperationv1alpha1.ClusterOperation, config *cluster.ConfigProperty, imageTag func() string) {
+	if clusterOps.Spec.Cluster != "" {
+		if clusterOps.Labels == nil {
+			clusterOps.Labels = map[string]string{}
+		}
+		clusterOps.Labels[constants.KubeanClusterLabelKey] = clusterOps.Spec.Cluster
+	}
+	image := strings.TrimSpace(clusterOps.Spec.Image)
+	if image == "" {
+		image = config.GetSprayJobImage()
+	}
+	if !hasImageTag(image) {
+		tag := config.GetSprayJobImageTag()
+		if tag == "" {
+			tag = imageTag()
+		}
+		image = fmt.Sprintf("%s:%s", image, tag)
+	}
+	clusterOps.Spec.Image = image
+	if clusterOps.Spec.ActiveDeadlineSeconds == nil {
+		clusterOps.Spec.ActiveDeadlineSeconds = config.GetActiveDeadlineSeconds()
+	}
+	for name, quantity := range config.GetSprayJobResourceRequests() {
+		if _, ok := clusterOps.Spec.Resources.Requests[name]; ok {
+			continue
+		}
+		if _, ok := clusterOps.Spec.Resources.Limits[name]; ok {
+			continue // the request is the same as the limit if not set.
+		}
+		if clusterOps.Spec.Resources.Requests == nil {
+			clusterOps.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		clusterOps.Spec.Resources.Requests[name] = quantity
+	}
+	builtin := clusteroperationv1alpha1.BuiltinActionSource
+	if clusterOps.Spec.ActionSource == nil {
+		clusterOps.Spec.ActionSource = &builtin
+	}
+	for _, hooks := range [][]clusteroperationv1alpha1.HookAction{clusterOps.Spec.PreHook, clusterOps.Spec.PostHook} {
+		for i := range hooks {
+			if hooks[i].ActionSource == nil {
+				hooks[i].ActionSource = &builtin
+			}
+		}
+	}
+}
+
+func (handler MutationHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	decision := metrics.InvalidDecision
+	defer func() {
+		metrics.AdmissionReviewsTotal.WithLabelValues(decision).Inc()
+	}()
+	admissionReviewReq := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(request.Body).Decode(&admissionReviewReq); err != nil {
+		klog.ErrorS(err, "parse http body to AdmissionReview")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse http body to AdmissionReview")))
+		return
+	}
+	if admissionReviewReq.Request == nil || len(admissionReviewReq.Request.Object.Raw) == 0 {
+		klog.Error("parse http body to AdmissionReview but no object")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("parse http body to AdmissionReview but no object"))
+		return
+	}
+	clusterOperation := clusteroperationv1alpha1.ClusterOperation{}
+	if err := json.Unmarshal(admissionReviewReq.Request.Object.Raw, &clusterOperation); err != nil {
+		klog.ErrorS(err, "parse AdmissionReview.Object.Raw in ClusterOperation but failed")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.Object.Raw in ClusterOperation but failed")))
+		return
+	}
+	if admissionReviewReq.Request.Operation == admissionv1.Update {
+		oldClusterOperation := clusteroperationv1alpha1.ClusterOperation{}
+		if err := json.Unmarshal(admissionReviewReq.Request.OldObject.Raw, &oldClusterOperation); err != nil {
+			klog.ErrorS(err, "parse AdmissionReview.OldObject.Raw in ClusterOperation but failed")
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.OldObject.Raw in ClusterOperation but failed")))
+			return
+		}
+		// the spec is immutable, so only the canceller is recorded at update.
+		recordCanceller(&clusterOperation, &oldClusterOperation, admissionReviewReq.Request.UserInfo.Username)
+	} else {
+		handler.defaultClusterOperation(&clusterOperation, admissionReviewReq.Request.UserInfo)
+	}
+	httpResult, err := patchResponse(&admissionReviewReq, &clusterOperation, admissionReviewReq.Request.Operation == admissionv1.Update)
+	if err != nil {
+		klog.ErrorS(err, "default ClusterOperation but failed", "name", clusterOperation.Name)
+		decision = metrics.ErrorDecision
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	decision = metrics.AllowedDecision
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(httpResult)
+}
+
+// defaultClusterOperation defaults the clusterOps at creation and records its creator, which is the requester of
+// the schedule for the clusterOps created by kubean-operator.
+func (handler MutationHandler) defaultClusterOperation(clusterOperation *clusteroperationv1alpha1.ClusterOperation, userInfo authenticationv1.UserInfo) {
+	username := userInfo.Username
+	if !isOperator(userInfo) {
+		delete(clusterOperation.Annotations, clusteropscontroller.RequesterAnnoKey)
+	} else if requester, err := clusteropscontroller.Requester(clusterOperation.Annotations); err == nil && requester != nil {
+		username = requester.Username
+	}
+	config := &cluster.ConfigProperty{}
+	if handler.ClientSet != nil {
+		config = util.FetchKubeanConfigProperty(handler.ClientSet)
+	}
+	DefaultClusterOperation(clusterOperation, config, func() string { return handler.sprayJobImageTag(clusterOperation.Spec.Cluster) })
+	if clusterOperation.Annotations == nil {
+		clusterOperation.Annotations = map[string]string{}
+	}
+	// the creator is recorded for the approval, which must be done by another user.
+	clusterOperation.Annotations[clusteropscontroller.CreatedByAnnoKey] = username
+	recordCanceller(clusterOperation, &clusteroperationv1alpha1.ClusterOperation{}, username)
+}
+
+// recordCanceller sets the cancelled-by annotation to the user who sets spec.cancel, and otherwise keeps the
+// annotation of the old clusterOps, so that the canceller reported by the operator is not forged.
+func recordCanceller(clusterOps, oldClusterOps *clusteroperationv1alpha1.ClusterOperation, username string) {
+	canceller, ok := oldClusterOps.Annotations[clusteropscontroller.CancelledByAnnoKey]
+	if clusterOps.Spec.Cancel && !oldClusterOps.Spec.Cancel {
+		canceller, ok = username, true
+	}
+	if !ok {
+		delete(clusterOps.Annotations, clusteropscontroller.CancelledByAnnoKey)
+		return
+	}
+	if clusterOps.Annotations == nil {
+		clusterOps.Annotations = map[string]string{}
+	}
+	clusterOps.Annotations[clusteropscontroller.CancelledByAnnoKey] = canceller
+}
+
+// patchResponse admits the request with the json patch from the object in the request to the defaulted one,
+// or only to its annotations, which leaves the fields of the object unknown to this version as they are.
+func patchResponse(admissionReviewReq *admissionv1.AdmissionReview, clusterOps *clusteroperationv1alpha1.ClusterOperation, annotationsOnly bool) ([]byte, error) {
+	if annotationsOnly {
+		return annotationsPatchResponse(admissionReviewReq, clusterOps.Annotations)
+	}
+	mutated, err := json.Marshal(clusterOps)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPatchResponse(admissionReviewReq, mutated)
+}
+
+// annotationsPatchResponse admits the request with the json patch to the annotations of the object in the request.
+func annotationsPatchResponse(admissionReviewReq *admissionv1.AdmissionReview, annotations map[string]string) ([]byte, error) {
+	mutated, err := patchAnnotations(admissionReviewReq.Request.Object.Raw, annotations)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPatchResponse(admissionReviewReq, mutated)
+}
+
+// jsonPatchResponse admits the request with the json patch from the object in the request to the mutated one.
+func jsonPatchResponse(admissionReviewReq *admissionv1.AdmissionReview, mutated []byte) ([]byte, error) {
+	patch, err := jsonpatch.CreatePatch(admissionReviewReq.Request.Object.Raw, mutated)
+	if err != nil {
+		return nil, err
+	}
+	admissionReviewResponse := admissionv1.AdmissionReview{
+		TypeMeta: admissionReviewReq.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{UID: admissionReviewReq.Request.UID, Allowed: true},
+	}
+	if len(patch) > 0 {
+		patchType := admissionv1.PatchTypeJSONPatch
+		admissionReviewResponse.Response.PatchType = &patchType
+		if admissionReviewResponse.Response.Patch, err = json.Marshal(patch); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(admissionReviewResponse)
+}
+
+// patchAnnotations replaces the annotations of the raw object.
+func patchAnnotations(raw []byte, annotations map[string]string) ([]byte, error) {
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	metadata, _ := object["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		object["metadata"] = metadata
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	} else {
+		metadata["annotations"] = annotations
+	}
+	return json.Marshal(object)
+}
@@ -0,0 +1,305 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/kubean-io/kubean-api/apis"
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperation/v1alpha1"
+	manifestv1alpha1 "github.com/kubean-io/kubean-api/apis/manifest/v1alpha1"
+	"github.com/kubean-io/kubean-api/cluster"
+	"github.com/kubean-io/kubean-api/constants"
+	clusterv1alpha1fake "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned/fake"
+	manifestv1alpha1fake "github.com/kubean-io/kubean-api/generated/manifest/clientset/versioned/fake"
+	"gomodules.xyz/jsonpatch/v2"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/util"
+)
+
+func TestHasImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{image: "ghcr.io/kubean-io/spray-job", want: false},
+		{image: "ghcr.io/kubean-io/spray-job:v0.1.0", want: true},
+		{image: "registry:5000/kubean-io/spray-job", want: false},
+		{image: "spray-job@sha256:abc", want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			if hasImageTag(test.image) != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestDefaultClusterOperation(t *testing.T) {
+	config := &cluster.ConfigProperty{
+		SprayJobImageRegistry: "registry:5000",
+		ActiveDeadlineSeconds: "3600",
+		SprayJobCPURequest:    "100m",
+		SprayJobMemoryRequest: "bad",
+	}
+	imageTag := func() string { return "v0.1.0" }
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "default the empty spec",
+			args: func() bool {
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{
+					Spec: clusteroperationv1alpha1.Spec{
+						Cluster: "cluster1",
+						PreHook: []clusteroperationv1alpha1.HookAction{{ActionType: "playbook", Action: "ping.yml"}},
+					},
+				}
+				DefaultClusterOperation(clusterOps, config, imageTag)
+				return clusterOps.Labels[constants.KubeanClusterLabelKey] == "cluster1" &&
+					clusterOps.Spec.Image == "registry:5000/kubean-io/spray-job:v0.1.0" &&
+					*clusterOps.Spec.ActiveDeadlineSeconds == 3600 &&
+					clusterOps.Spec.Resources.Requests.Cpu().String() == "100m" && clusterOps.Spec.Resources.Requests.Memory().IsZero() &&
+					*clusterOps.Spec.ActionSource == clusteroperationv1alpha1.BuiltinActionSource &&
+					*clusterOps.Spec.PreHook[0].ActionSource == clusteroperationv1alpha1.BuiltinActionSource
+			},
+			want: true,
+		},
+		{
+			name: "keep the fields set by the user",
+			args: func() bool {
+				configMapSource := clusteroperationv1alpha1.ConfigMapActionSource
+				deadline := int64(60)
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"foo": "bar"}},
+					Spec: clusteroperationv1alpha1.Spec{
+						Cluster:               "cluster1",
+						Image:                 "my-registry/spray-job",
+						ActiveDeadlineSeconds: &deadline,
+						ActionSource:          &configMapSource,
+						ActionSourceRef:       &apis.ConfigMapRef{NameSpace: "kubean-system", Name: "custom"},
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+						},
+					},
+				}
+				DefaultClusterOperation(clusterOps, config, imageTag)
+				return clusterOps.Labels["foo"] == "bar" && clusterOps.Labels[constants.KubeanClusterLabelKey] == "cluster1" &&
+					clusterOps.Spec.Image == "my-registry/spray-job:v0.1.0" && *clusterOps.Spec.ActiveDeadlineSeconds == 60 &&
+					len(clusterOps.Spec.Resources.Requests) == 0 && *clusterOps.Spec.ActionSource == configMapSource
+			},
+			want: true,
+		},
+		{
+			name: "the configured image tag",
+			args: func() bool {
+				taggedConfig := *config
+				taggedConfig.SprayJobImageTag = "v0.2.0"
+				clusterOps := &clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{Cluster: "cluster1", Image: "my-registry/spray-job"}}
+				DefaultClusterOperation(clusterOps, &taggedConfig, imageTag)
+				defaulted := &clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{Cluster: "cluster1"}}
+				DefaultClusterOperation(defaulted, &taggedConfig, imageTag)
+				tagged := &clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{Cluster: "cluster1", Image: "my-registry/spray-job:v0.3.0"}}
+				DefaultClusterOperation(tagged, &taggedConfig, imageTag)
+				return clusterOps.Spec.Image == "my-registry/spray-job:v0.2.0" && defaulted.Spec.Image == "registry:5000/kubean-io/spray-job:v0.2.0" &&
+					tagged.Spec.Image == "my-registry/spray-job:v0.3.0"
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestMutationHandlerHttp(t *testing.T) {
+	clientSet := clientsetfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.KubeanConfigMapName, Namespace: util.GetCurrentNSOrDefault()},
+		Data:       map[string]string{"SPRAY_JOB_IMAGE_REGISTRY": "registry:5000"},
+	})
+	clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(&clusterv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "released_cluster", Labels: map[string]string{constants.KeySprayRelease: "2.23"}},
+	})
+	manifestClientSet := manifestv1alpha1fake.NewSimpleClientset(
+		&manifestv1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{Name: constants.InfoManifestGlobal},
+			Spec:       manifestv1alpha1.Spec{KubeanVersion: "v0.7.0"},
+		},
+		&manifestv1alpha1.Manifest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "manifest-2.23",
+				Labels:      map[string]string{constants.KeySprayRelease: "2.23"},
+				Annotations: map[string]string{constants.KeySprayRelease: "2.23", constants.KeySprayCommit: "abcdef"},
+			},
+		},
+	)
+	handler := MutationHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanManifestSet: manifestClientSet}
+	reviewOperation := func(operation admissionv1.Operation, raw, oldRaw []byte) (*FakeResponseWriter, *admissionv1.AdmissionResponse) {
+		response := &FakeResponseWriter{}
+		admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+			Operation: operation,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+			UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+		}})
+		request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+		handler.ServeHTTP(response, request)
+		admissionReviewResponse := &admissionv1.AdmissionReview{}
+		json.Unmarshal([]byte(response.result), admissionReviewResponse)
+		return response, admissionReviewResponse.Response
+	}
+	review := func(raw []byte) (*FakeResponseWriter, *admissionv1.AdmissionResponse) {
+		return reviewOperation(admissionv1.Create, raw, nil)
+	}
+	patchedImage := func(response *admissionv1.AdmissionResponse) string {
+		patch := []jsonpatch.Operation{}
+		json.Unmarshal(response.Patch, &patch)
+		for _, operation := range patch {
+			if operation.Path == "/spec/image" {
+				image, _ := operation.Value.(string)
+				return image
+			}
+		}
+		return ""
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "bad clusterOperation json body",
+			args: func() bool {
+				response, _ := review([]byte("{abc"))
+				return response.code == http.StatusBadRequest
+			},
+			want: true,
+		},
+		{
+			name: "image of the manifest matching the sprayRelease of the cluster",
+			args: func() bool {
+				raw, _ := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "ops1"}, "spec": map[string]string{"cluster": "released_cluster"}})
+				response, admissionResponse := review(raw)
+				return response.code == http.StatusOK && admissionResponse.Allowed && *admissionResponse.PatchType == admissionv1.PatchTypeJSONPatch &&
+					patchedImage(admissionResponse) == "registry:5000/kubean-io/spray-job:2.23-abcdef"
+			},
+			want: true,
+		},
+		{
+			name: "record the creator",
+			args: func() bool {
+				raw, _ := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "ops3"}, "spec": map[string]string{"cluster": "other_cluster"}})
+				_, admissionResponse := review(raw)
+				patch := []jsonpatch.Operation{}
+				json.Unmarshal(admissionResponse.Patch, &patch)
+				for _, operation := range patch {
+					if operation.Path == "/metadata/annotations" {
+						annotations, _ := operation.Value.(map[string]interface{})
+						return annotations[clusteropscontroller.CreatedByAnnoKey] == "alice"
+					}
+				}
+				return false
+			},
+			want: true,
+		},
+		{
+			name: "image of the global manifest",
+			args: func() bool {
+				raw, _ := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "ops2"}, "spec": map[string]string{"cluster": "other_cluster"}})
+				_, admissionResponse := review(raw)
+				return admissionResponse.Allowed && patchedImage(admissionResponse) == "registry:5000/kubean-io/spray-job:v0.7.0"
+			},
+			want: true,
+		},
+		{
+			name: "record the canceller but not default the spec at update",
+			args: func() bool {
+				oldRaw, _ := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "ops4"}, "spec": map[string]interface{}{"cluster": "other_cluster"}})
+				raw, _ := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "ops4"}, "spec": map[string]interface{}{"cluster": "other_cluster", "cancel": true}})
+				_, admissionResponse := reviewOperation(admissionv1.Update, raw, oldRaw)
+				patch := []jsonpatch.Operation{}
+				json.Unmarshal(admissionResponse.Patch, &patch)
+				return admissionResponse.Allowed && len(patch) == 1 && patch[0].Path == "/metadata/annotations" &&
+					patch[0].Value.(map[string]interface{})[clusteropscontroller.CancelledByAnnoKey] == "alice"
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestRecordCanceller(t *testing.T) {
+	newOps := func(cancel bool, canceller string) *clusteroperationv1alpha1.ClusterOperation {
+		ops := &clusteroperationv1alpha1.ClusterOperation{Spec: clusteroperationv1alpha1.Spec{Cancel: cancel}}
+		if canceller != "" {
+			ops.Annotations = map[string]string{clusteropscontroller.CancelledByAnnoKey: canceller}
+		}
+		return ops
+	}
+	tests := []struct {
+		name       string
+		clusterOps *clusteroperationv1alpha1.ClusterOperation
+		oldOps     *clusteroperationv1alpha1.ClusterOperation
+		want       string
+	}{
+		{
+			name:       "not cancelled",
+			clusterOps: newOps(false, ""),
+			oldOps:     newOps(false, ""),
+			want:       "",
+		},
+		{
+			name:       "cancelled by the user",
+			clusterOps: newOps(true, ""),
+			oldOps:     newOps(false, ""),
+			want:       "alice",
+		},
+		{
+			name:       "the forged canceller is removed",
+			clusterOps: newOps(false, "bob"),
+			oldOps:     newOps(false, ""),
+			want:       "",
+		},
+		{
+			name:       "the canceller is kept at the later updates",
+			clusterOps: newOps(true, "alice"),
+			oldOps:     newOps(true, "bob"),
+			want:       "bob",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recordCanceller(test.clusterOps, test.oldOps, "alice")
+			if got := test.clusterOps.Annotations[clusteropscontroller.CancelledByAnnoKey]; got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	clusteroperationpolicyv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationpolicy/v1alpha1"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/klog/v2"
+
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/metrics"
+)
+
+// validateOperatorSubjects forbids binding the policy to kubean-operator, which creates the clusterOps of the schedules.
+func validateOperatorSubjects(policy *clusteroperationpolicyv1alpha1.ClusterOperationPolicy) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, subject := range policy.Spec.Subjects {
+		username := subject.Name
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			username = serviceaccount.MakeUsername(subject.Namespace, subject.Name)
+		}
+		if (subject.Kind == rbacv1.UserKind || subject.Kind == rbacv1.ServiceAccountKind) && isOperator(authenticationv1.UserInfo{Username: username}) {
+			errs = append(errs, field.Forbidden(field.NewPath("spec", "subjects").Index(i), "kubean-operator can not be bound to the policy"))
+		}
+	}
+	return errs
+}
+
+// PolicyReviewHandler validates the subjects and the allowed actions of the ClusterOperationPolicy.
+type PolicyReviewHandler struct{}
+
+func (handler PolicyReviewHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	decision := metrics.InvalidDecision
+	defer func() {
+		metrics.AdmissionReviewsTotal.WithLabelValues(decision).Inc()
+	}()
+	admissionReviewReq := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(request.Body).Decode(&admissionReviewReq); err != nil {
+		klog.ErrorS(err, "parse http body to AdmissionReview")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse http body to AdmissionReview")))
+		return
+	}
+	if admissionReviewReq.Request == nil || len(admissionReviewReq.Request.Object.Raw) == 0 {
+		klog.Error("parse http body to AdmissionReview but no object")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("parse http body to AdmissionReview but no object"))
+		return
+	}
+	policy := clusteroperationpolicyv1alpha1.ClusterOperationPolicy{}
+	if err := json.Unmarshal(admissionReviewReq.Request.Object.Raw, &policy); err != nil {
+		klog.ErrorS(err, "parse AdmissionReview.Object.Raw in ClusterOperationPolicy but failed")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.Object.Raw in ClusterOperationPolicy but failed")))
+		return
+	}
+	invalidErrs := clusteropscontroller.ValidatePolicy(&policy)
+	invalidErrs = append(invalidErrs, validateOperatorSubjects(&policy)...)
+	if len(invalidErrs) > 0 {
+		decision = metrics.DeniedDecision
+		writeInvalidResponse(writer, &admissionReviewReq, policy.Name, invalidErrs)
+		return
+	}
+	decision = metrics.AllowedDecision
+	httpResult, _ := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: admissionReviewReq.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{UID: admissionReviewReq.Request.UID, Allowed: true},
+	})
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(httpResult)
+}
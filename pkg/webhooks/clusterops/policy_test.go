@@ -0,0 +1,101 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	clusteroperationpolicyv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationpolicy/v1alpha1"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPolicyReviewHandlerHttp(t *testing.T) {
+	handler := PolicyReviewHandler{}
+	review := func(raw []byte) (*FakeResponseWriter, *admissionv1.AdmissionResponse) {
+		response := &FakeResponseWriter{}
+		admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{Object: runtime.RawExtension{Raw: raw}}})
+		request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+		handler.ServeHTTP(response, request)
+		admissionReviewResponse := &admissionv1.AdmissionReview{}
+		json.Unmarshal([]byte(response.result), admissionReviewResponse)
+		return response, admissionReviewResponse.Response
+	}
+	newPolicy := func(playbooks ...string) []byte {
+		raw, _ := json.Marshal(&clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy1"},
+			Spec: clusteroperationpolicyv1alpha1.Spec{
+				Subjects:  []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "operators"}},
+				Playbooks: playbooks,
+			},
+		})
+		return raw
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "bad policy json body",
+			args: func() bool {
+				response, _ := review([]byte("{abc"))
+				return response.code == http.StatusBadRequest
+			},
+			want: true,
+		},
+		{
+			name: "allow builtin playbooks",
+			args: func() bool {
+				response, admissionResponse := review(newPolicy("cluster.yml", "scale.yml"))
+				return response.code == http.StatusOK && admissionResponse.Allowed
+			},
+			want: true,
+		},
+		{
+			name: "deny unknown playbooks",
+			args: func() bool {
+				_, admissionResponse := review(newPolicy("cluster.yml", "site.yml"))
+				return !admissionResponse.Allowed && strings.Contains(admissionResponse.Result.Message, "spec.playbooks[1]: Unsupported value: \"site.yml\"")
+			},
+			want: true,
+		},
+		{
+			name: "deny binding kubean-operator",
+			args: func() bool {
+				defer func(namespace, serviceAccount string) {
+					WebhookSVCNamespace, OperatorServiceAccount = namespace, serviceAccount
+				}(WebhookSVCNamespace, OperatorServiceAccount)
+				WebhookSVCNamespace, OperatorServiceAccount = "kubean-system", "kubean"
+				raw, _ := json.Marshal(&clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "policy1"},
+					Spec: clusteroperationpolicyv1alpha1.Spec{Subjects: []rbacv1.Subject{
+						{Kind: rbacv1.ServiceAccountKind, Namespace: "team-a", Name: "kubean"},
+						{Kind: rbacv1.ServiceAccountKind, Namespace: "kubean-system", Name: "kubean"},
+						{Kind: rbacv1.UserKind, Name: "system:serviceaccount:kubean-system:kubean"},
+					}},
+				})
+				_, admissionResponse := review(raw)
+				return !admissionResponse.Allowed && !strings.Contains(admissionResponse.Result.Message, "spec.subjects[0]") &&
+					strings.Contains(admissionResponse.Result.Message, "spec.subjects[1]: Forbidden: kubean-operator can not be bound to the policy") &&
+					strings.Contains(admissionResponse.Result.Message, "spec.subjects[2]: Forbidden")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	clusteroperationschedulev1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationschedule/v1alpha1"
+	clusterClientSet "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned"
+	clusterOperationPolicyClientSet "github.com/kubean-io/kubean-api/generated/clusteroperationpolicy/clientset/versioned"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+	"github.com/kubean-io/kubean/pkg/controllers/clusteropsschedule"
+	"github.com/kubean-io/kubean/pkg/metrics"
+)
+
+// isOperator returns whether the user is the service account of kubean-operator.
+func isOperator(userInfo authenticationv1.UserInfo) bool {
+	return OperatorServiceAccount != "" && serviceaccount.MatchesUsername(WebhookSVCNamespace, OperatorServiceAccount, userInfo.Username)
+}
+
+// onBehalfOf returns the user whom the object is admitted for. The requester recorded on the object is trusted
+// only in the request of kubean-operator, e.g. to create the clusterOps of the ClusterOperationSchedule, and
+// otherwise it must be the user of the request.
+func onBehalfOf(annotations map[string]string, userInfo authenticationv1.UserInfo) (authenticationv1.UserInfo, field.ErrorList) {
+	requesterPath := field.NewPath("metadata", "annotations").Key(clusteropscontroller.RequesterAnnoKey)
+	requester, err := clusteropscontroller.Requester(annotations)
+	if err != nil {
+		return userInfo, field.ErrorList{field.Invalid(requesterPath, annotations[clusteropscontroller.RequesterAnnoKey], err.Error())}
+	}
+	if requester == nil {
+		return userInfo, nil
+	}
+	if isOperator(userInfo) {
+		return *requester, nil
+	}
+	if !equality.Semantic.DeepEqual(*requester, userInfo) {
+		return userInfo, field.ErrorList{field.Forbidden(requesterPath, fmt.Sprintf("must be the user %s", userInfo.Username))}
+	}
+	return userInfo, nil
+}
+
+// ScheduleMutationHandler records the user who creates or updates the ClusterOperationSchedule as its requester.
+type ScheduleMutationHandler struct{}
+
+func (handler ScheduleMutationHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	decision := metrics.InvalidDecision
+	defer func() {
+		metrics.AdmissionReviewsTotal.WithLabelValues(decision).Inc()
+	}()
+	admissionReviewReq := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(request.Body).Decode(&admissionReviewReq); err != nil {
+		klog.ErrorS(err, "parse http body to AdmissionReview")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse http body to AdmissionReview")))
+		return
+	}
+	if admissionReviewReq.Request == nil || len(admissionReviewReq.Request.Object.Raw) == 0 {
+		klog.Error("parse http body to AdmissionReview but no object")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("parse http body to AdmissionReview but no object"))
+		return
+	}
+	schedule := clusteroperationschedulev1alpha1.ClusterOperationSchedule{}
+	if err := json.Unmarshal(admissionReviewReq.Request.Object.Raw, &schedule); err != nil {
+		klog.ErrorS(err, "parse AdmissionReview.Object.Raw in ClusterOperationSchedule but failed")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.Object.Raw in ClusterOperationSchedule but failed")))
+		return
+	}
+	oldSchedule := clusteroperationschedulev1alpha1.ClusterOperationSchedule{}
+	if admissionReviewReq.Request.Operation == admissionv1.Update {
+		if err := json.Unmarshal(admissionReviewReq.Request.OldObject.Raw, &oldSchedule); err != nil {
+			klog.ErrorS(err, "parse AdmissionReview.OldObject.Raw in ClusterOperationSchedule but failed")
+			writer.WriteHeader(http.StatusBadRequest)
+			writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.OldObject.Raw in ClusterOperationSchedule but failed")))
+			return
+		}
+	}
+	if err := recordRequester(&schedule, &oldSchedule, admissionReviewReq.Request.UserInfo); err != nil {
+		klog.ErrorS(err, "record the requester of ClusterOperationSchedule but failed", "name", schedule.Name)
+		decision = metrics.ErrorDecision
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	httpResult, err := annotationsPatchResponse(&admissionReviewReq, schedule.Annotations)
+	if err != nil {
+		klog.ErrorS(err, "record the requester of ClusterOperationSchedule but failed", "name", schedule.Name)
+		decision = metrics.ErrorDecision
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(err.Error()))
+		return
+	}
+	decision = metrics.AllowedDecision
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(httpResult)
+}
+
+// recordRequester sets the requester annotation to the user, and kubean-operator keeps the requester of the old schedule.
+func recordRequester(schedule, oldSchedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, userInfo authenticationv1.UserInfo) error {
+	requester, ok := oldSchedule.Annotations[clusteropscontroller.RequesterAnnoKey]
+	if !isOperator(userInfo) {
+		value, err := clusteropscontroller.RequesterAnnotation(userInfo)
+		if err != nil {
+			return err
+		}
+		requester, ok = value, true
+	}
+	if !ok {
+		delete(schedule.Annotations, clusteropscontroller.RequesterAnnoKey)
+		return nil
+	}
+	if schedule.Annotations == nil {
+		schedule.Annotations = map[string]string{}
+	}
+	schedule.Annotations[clusteropscontroller.RequesterAnnoKey] = requester
+	return nil
+}
+
+// ScheduleReviewHandler checks the requester of the ClusterOperationSchedule is allowed to run the template,
+// the same as the clusterOps created by the requester.
+type ScheduleReviewHandler struct {
+	ClientSet                 kubernetes.Interface
+	KubeanClusterSet          clusterClientSet.Interface
+	KubeanClusterOpsPolicySet clusterOperationPolicyClientSet.Interface
+}
+
+// validate checks the tenant, the authorization and the ClusterOperationPolicies of the clusterOps created by the schedule.
+func (handler ScheduleReviewHandler) validate(schedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule, userInfo authenticationv1.UserInfo) (field.ErrorList, error) {
+	requester, errs := onBehalfOf(schedule.Annotations, userInfo)
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	if _, ok := schedule.Annotations[clusteropscontroller.RequesterAnnoKey]; !ok && !isOperator(userInfo) {
+		// the clusterOps without requester would be authorized as kubean-operator.
+		return field.ErrorList{field.Required(field.NewPath("metadata", "annotations").Key(clusteropscontroller.RequesterAnnoKey), "set by kubean-admission")}, nil
+	}
+	if handler.ClientSet == nil || handler.KubeanClusterSet == nil {
+		return nil, nil
+	}
+	clusterOps := clusteropsschedule.NewClusterOps(schedule, time.Now())
+	cluster, err := handler.KubeanClusterSet.KubeanV1alpha1().Clusters().Get(context.Background(), clusterOps.Spec.Cluster, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(field.NewPath("spec", "template", "spec", "cluster"), clusterOps.Spec.Cluster)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	opsHandler := AdmissionReviewHandler{ClientSet: handler.ClientSet, KubeanClusterSet: handler.KubeanClusterSet, KubeanClusterOpsPolicySet: handler.KubeanClusterOpsPolicySet}
+	if errs, err := opsHandler.authorize(cluster, clusterOps, requester); err != nil || len(errs) > 0 {
+		return templateErrors(errs), err
+	}
+	errs, err = opsHandler.validatePolicies(clusterOps, requester)
+	return templateErrors(errs), err
+}
+
+// templateErrors moves the errors of the clusterOps spec under the template of the schedule.
+func templateErrors(errs field.ErrorList) field.ErrorList {
+	for _, err := range errs {
+		if err.Field == "spec" || strings.HasPrefix(err.Field, "spec.") || strings.HasPrefix(err.Field, "spec[") {
+			err.Field = "spec.template." + err.Field
+		}
+	}
+	return errs
+}
+
+func (handler ScheduleReviewHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	decision := metrics.InvalidDecision
+	defer func() {
+		metrics.AdmissionReviewsTotal.WithLabelValues(decision).Inc()
+	}()
+	admissionReviewReq := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(request.Body).Decode(&admissionReviewReq); err != nil {
+		klog.ErrorS(err, "parse http body to AdmissionReview")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse http body to AdmissionReview")))
+		return
+	}
+	if admissionReviewReq.Request == nil || len(admissionReviewReq.Request.Object.Raw) == 0 {
+		klog.Error("parse http body to AdmissionReview but no object")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte("parse http body to AdmissionReview but no object"))
+		return
+	}
+	schedule := clusteroperationschedulev1alpha1.ClusterOperationSchedule{}
+	if err := json.Unmarshal(admissionReviewReq.Request.Object.Raw, &schedule); err != nil {
+		klog.ErrorS(err, "parse AdmissionReview.Object.Raw in ClusterOperationSchedule but failed")
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "parse AdmissionReview.Object.Raw in ClusterOperationSchedule but failed")))
+		return
+	}
+	invalidErrs, err := handler.validate(&schedule, admissionReviewReq.Request.UserInfo)
+	if err != nil {
+		klog.ErrorS(err, "validate ClusterOperationSchedule but failed")
+		decision = metrics.ErrorDecision
+		writer.WriteHeader(http.StatusBadRequest)
+		writer.Write([]byte(fmt.Sprint(err, "validate ClusterOperationSchedule but failed")))
+		return
+	}
+	if len(invalidErrs) > 0 {
+		decision = metrics.DeniedDecision
+		writeInvalidResponse(writer, &admissionReviewReq, schedule.Name, invalidErrs)
+		return
+	}
+	decision = metrics.AllowedDecision
+	httpResult, _ := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: admissionReviewReq.TypeMeta,
+		Response: &admissionv1.AdmissionResponse{UID: admissionReviewReq.Request.UID, Allowed: true},
+	})
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(httpResult)
+}
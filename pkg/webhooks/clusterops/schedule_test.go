@@ -0,0 +1,214 @@
+// Copyright 2023 Authors of kubean-io
+// SPDX-License-Identifier: Apache-2.0
+
+package clusterops
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientsetfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	clusterv1alpha1 "github.com/kubean-io/kubean-api/apis/cluster/v1alpha1"
+	clusteroperationpolicyv1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationpolicy/v1alpha1"
+	clusteroperationschedulev1alpha1 "github.com/kubean-io/kubean-api/apis/clusteroperationschedule/v1alpha1"
+	clusterv1alpha1fake "github.com/kubean-io/kubean-api/generated/cluster/clientset/versioned/fake"
+	clusteroperationpolicyv1alpha1fake "github.com/kubean-io/kubean-api/generated/clusteroperationpolicy/clientset/versioned/fake"
+	"gomodules.xyz/jsonpatch/v2"
+
+	clusteropscontroller "github.com/kubean-io/kubean/pkg/controllers/clusterops"
+)
+
+func newTestSchedule(cluster, action string, annotations map[string]string) *clusteroperationschedulev1alpha1.ClusterOperationSchedule {
+	schedule := &clusteroperationschedulev1alpha1.ClusterOperationSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "schedule1", Annotations: annotations},
+		Spec:       clusteroperationschedulev1alpha1.Spec{Schedule: "0 * * * *"},
+	}
+	schedule.Spec.Template.Spec = newValidSpec(cluster)
+	schedule.Spec.Template.Spec.Action = action
+	return schedule
+}
+
+func reviewSchedule(handler http.Handler, operation admissionv1.Operation, userInfo authenticationv1.UserInfo, schedule, oldSchedule *clusteroperationschedulev1alpha1.ClusterOperationSchedule) (*FakeResponseWriter, *admissionv1.AdmissionResponse) {
+	raw, _ := json.Marshal(schedule)
+	oldRaw, _ := json.Marshal(oldSchedule)
+	response := &FakeResponseWriter{}
+	admissionReviewBytes, _ := json.Marshal(admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{
+		Operation: operation, UserInfo: userInfo, Object: runtime.RawExtension{Raw: raw}, OldObject: runtime.RawExtension{Raw: oldRaw},
+	}})
+	request, _ := http.NewRequest("", "", bytes.NewReader(admissionReviewBytes))
+	handler.ServeHTTP(response, request)
+	admissionReviewResponse := &admissionv1.AdmissionReview{}
+	json.Unmarshal([]byte(response.result), admissionReviewResponse)
+	return response, admissionReviewResponse.Response
+}
+
+func TestScheduleMutationHandlerHttp(t *testing.T) {
+	defer func(namespace, serviceAccount string) {
+		WebhookSVCNamespace, OperatorServiceAccount = namespace, serviceAccount
+	}(WebhookSVCNamespace, OperatorServiceAccount)
+	WebhookSVCNamespace, OperatorServiceAccount = "kubean-system", "kubean"
+	alice := authenticationv1.UserInfo{Username: "alice", Groups: []string{"ops"}}
+	aliceValue, _ := clusteropscontroller.RequesterAnnotation(alice)
+	patchedRequester := func(response *admissionv1.AdmissionResponse) string {
+		patch := []jsonpatch.Operation{}
+		json.Unmarshal(response.Patch, &patch)
+		for _, operation := range patch {
+			switch value := operation.Value.(type) {
+			case map[string]interface{}:
+				requester, _ := value[clusteropscontroller.RequesterAnnoKey].(string)
+				return requester
+			case string:
+				if strings.HasSuffix(operation.Path, "kubean.io~1requester") {
+					return value
+				}
+			}
+		}
+		return ""
+	}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "bad clusterOperationSchedule json body",
+			args: func() bool {
+				response := &FakeResponseWriter{}
+				request, _ := http.NewRequest("", "", bytes.NewReader([]byte("{abc")))
+				ScheduleMutationHandler{}.ServeHTTP(response, request)
+				return response.code == http.StatusBadRequest
+			},
+			want: true,
+		},
+		{
+			name: "record the user who creates the schedule",
+			args: func() bool {
+				_, response := reviewSchedule(ScheduleMutationHandler{}, admissionv1.Create, alice, newTestSchedule("cluster1", "cluster.yml", nil), nil)
+				return response.Allowed && patchedRequester(response) == aliceValue
+			},
+			want: true,
+		},
+		{
+			name: "replace the forged requester",
+			args: func() bool {
+				schedule := newTestSchedule("cluster1", "cluster.yml", map[string]string{clusteropscontroller.RequesterAnnoKey: `{"username":"admin"}`})
+				_, response := reviewSchedule(ScheduleMutationHandler{}, admissionv1.Update, alice, schedule, schedule)
+				return response.Allowed && patchedRequester(response) == aliceValue
+			},
+			want: true,
+		},
+		{
+			name: "kubean-operator keeps the requester",
+			args: func() bool {
+				operator := authenticationv1.UserInfo{Username: "system:serviceaccount:kubean-system:kubean"}
+				oldSchedule := newTestSchedule("cluster1", "cluster.yml", map[string]string{clusteropscontroller.RequesterAnnoKey: aliceValue})
+				_, response := reviewSchedule(ScheduleMutationHandler{}, admissionv1.Update, operator, oldSchedule, oldSchedule)
+				return response.Allowed && len(response.Patch) == 0
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}
+
+func TestScheduleReviewHandlerHttp(t *testing.T) {
+	clientSet := clientsetfake.NewSimpleClientset()
+	clientSet.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		sar.Status.Allowed = sar.Spec.User == "alice" && sar.Spec.ResourceAttributes.Namespace == "team-a"
+		return true, sar, nil
+	})
+	clusterClientSet := clusterv1alpha1fake.NewSimpleClientset(
+		&clusterv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant_cluster"},
+			Spec:       clusterv1alpha1.Spec{Tenant: &clusterv1alpha1.Tenant{Namespace: "team-a", ServiceAccountName: "team-a-sa"}},
+		},
+		&clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "prod_cluster"}},
+	)
+	policyClientSet := clusteroperationpolicyv1alpha1fake.NewSimpleClientset(&clusteroperationpolicyv1alpha1.ClusterOperationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "operators"},
+		Spec: clusteroperationpolicyv1alpha1.Spec{
+			Clusters:  []string{"prod_cluster"},
+			Subjects:  []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "operators"}},
+			Playbooks: []string{"cluster.yml"},
+		},
+	})
+	handler := ScheduleReviewHandler{ClientSet: clientSet, KubeanClusterSet: clusterClientSet, KubeanClusterOpsPolicySet: policyClientSet}
+	review := func(userInfo authenticationv1.UserInfo, cluster, action string) *admissionv1.AdmissionResponse {
+		requester, _ := clusteropscontroller.RequesterAnnotation(userInfo)
+		schedule := newTestSchedule(cluster, action, map[string]string{clusteropscontroller.RequesterAnnoKey: requester})
+		_, response := reviewSchedule(handler, admissionv1.Create, userInfo, schedule, nil)
+		return response
+	}
+	alice := authenticationv1.UserInfo{Username: "alice", Groups: []string{"operators"}}
+	bob := authenticationv1.UserInfo{Username: "bob"}
+	tests := []struct {
+		name string
+		args func() bool
+		want bool
+	}{
+		{
+			name: "authorize the requester on the tenant cluster",
+			args: func() bool {
+				denied := review(bob, "tenant_cluster", "cluster.yml")
+				return review(alice, "tenant_cluster", "cluster.yml").Allowed &&
+					!denied.Allowed && strings.Contains(denied.Result.Message, "spec.template.spec.cluster: Forbidden: user bob is not allowed to operate cluster tenant_cluster")
+			},
+			want: true,
+		},
+		{
+			name: "deny the template out of the ClusterOperationPolicy",
+			args: func() bool {
+				deniedReset, deniedUser := review(alice, "prod_cluster", "reset.yml"), review(bob, "prod_cluster", "cluster.yml")
+				return review(alice, "prod_cluster", "cluster.yml").Allowed &&
+					!deniedReset.Allowed && strings.Contains(deniedReset.Result.Message, "playbook reset.yml is not allowed by ClusterOperationPolicy operators") &&
+					!deniedUser.Allowed && strings.Contains(deniedUser.Result.Message, "user bob is bound to none of the ClusterOperationPolicies [operators]")
+			},
+			want: true,
+		},
+		{
+			name: "the cluster of the template not found",
+			args: func() bool {
+				denied := review(alice, "other_cluster", "cluster.yml")
+				return !denied.Allowed && strings.Contains(denied.Result.Message, "spec.template.spec.cluster: Not found")
+			},
+			want: true,
+		},
+		{
+			name: "deny the schedule without the requester of the user",
+			args: func() bool {
+				_, missing := reviewSchedule(handler, admissionv1.Create, alice, newTestSchedule("prod_cluster", "cluster.yml", nil), nil)
+				forgedValue, _ := clusteropscontroller.RequesterAnnotation(alice)
+				_, forged := reviewSchedule(handler, admissionv1.Create, bob,
+					newTestSchedule("prod_cluster", "cluster.yml", map[string]string{clusteropscontroller.RequesterAnnoKey: forgedValue}), nil)
+				return !missing.Allowed && strings.Contains(missing.Result.Message, "Required value") &&
+					!forged.Allowed && strings.Contains(forged.Result.Message, "must be the user bob")
+			},
+			want: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.args() != test.want {
+				t.Fatal()
+			}
+		})
+	}
+}